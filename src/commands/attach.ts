@@ -0,0 +1,114 @@
+import { Command } from 'commander';
+import * as path from 'path';
+import * as fs from 'fs';
+import { loadAisanityConfig, getCurrentBranch, resolveWorkspaceOption } from '../utils/config';
+import { findWorkspaceContainer, validateContainerLabels } from '../utils/container-utils';
+import { getDevcontainerRuntimeArgs } from '../utils/container-runtime';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+import { processEnvironmentVariables, loadEnvFiles, generateDevcontainerEnvFlags } from '../utils/env-utils';
+import { resolveProfile, applyProfileToConfig, getProfileCommand, validateWorkdir, formatWorkdirCommand, ResolvedProfile } from '../utils/profile-utils';
+import { getProfileDevContainerPath } from '../utils/devcontainer-templates';
+
+/**
+ * Get the --id-label values that identify an existing container
+ * Only aisanity labels are used, so the lookup works from any shell session
+ */
+export function getAttachIdLabels(labels: Record<string, string>): string[] {
+  return Object.entries(labels)
+    .filter(([key, value]) => key.startsWith('aisanity.') && value)
+    .map(([key, value]) => `${key}=${value}`);
+}
+
+export const attachCommand = new Command('attach')
+  .description('Reconnect an interactive session to a sandbox started with "aisanity run --detach"')
+  .argument('[command...]', 'Command to run (defaults to the profile command or a shell)')
+  .option('--worktree <path>', 'Attach to the sandbox of a specific worktree')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--profile <name>', 'Sandbox profile from .aisanity config (defaults to the "default" profile)')
+  .option('--env <key=value>', 'Set environment variable (can be used multiple times). Bypasses whitelist filtering.',
+          (value, previous: string[] = []) => [...previous, value])
+  .option('-v, --verbose', 'Show detailed user information (container lookup)')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (commandArgs: string[], options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    let cwd = resolveWorkspaceOption(options);
+
+    if (options.worktree) {
+      const worktreePath = path.resolve(options.worktree);
+      if (!fs.existsSync(worktreePath)) {
+        console.error(`Worktree path does not exist: ${worktreePath}`);
+        process.exit(1);
+      }
+      cwd = worktreePath;
+    }
+
+    try {
+      const config = loadAisanityConfig(cwd);
+
+      if (!config) {
+        console.error('No .aisanity config found. Run "aisanity init" first.');
+        process.exit(1);
+      }
+
+      let profile: ResolvedProfile;
+      let workdir: string | undefined;
+      try {
+        profile = resolveProfile(config, options.profile);
+        workdir = profile.workdir !== undefined ? validateWorkdir(profile.workdir, profile.name) : undefined;
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+
+      // The container is found by the labels `aisanity run` set, so no local state is needed
+      const branch = getCurrentBranch(cwd);
+      const container = await findWorkspaceContainer(cwd, branch, profile.name, options.debug || false);
+      if (!container || !container.status.startsWith('Up') || !validateContainerLabels(container.labels)) {
+        const profileInfo = config.profiles ? `, profile: ${profile.name}` : '';
+        console.error(`No running sandbox found for this workspace (branch: ${branch}${profileInfo}).`);
+        console.error('Start one with "aisanity run --detach".');
+        process.exit(1);
+      }
+
+      logger.verbose(`Attaching to container: ${container.name} (${container.id})`);
+
+      const envCollection = processEnvironmentVariables(applyProfileToConfig(config, profile), options.env || [], {
+        fileEnv: loadEnvFiles(profile.envFile, cwd, profile.name),
+        verbose: options.verbose || false
+      });
+
+      const execArgs = ['exec', '--workspace-folder', cwd, ...getDevcontainerRuntimeArgs()];
+
+      // Reuse the devcontainer file the sandbox was started with so remoteUser and remoteEnv match
+      const defaultPath = path.join(cwd, '.devcontainer', 'devcontainer.json');
+      const profilePath = getProfileDevContainerPath(defaultPath, profile.name);
+      if (fs.existsSync(profilePath)) {
+        execArgs.push('--config', profilePath);
+      } else if (fs.existsSync(defaultPath)) {
+        execArgs.push('--config', defaultPath);
+      }
+
+      getAttachIdLabels(container.labels).forEach(label => {
+        execArgs.push('--id-label', label);
+      });
+
+      execArgs.push(...generateDevcontainerEnvFlags(envCollection.merged));
+
+      // devcontainer exec starts in the workspace folder, so a profile workdir is entered first, as run does
+      const command = commandArgs.length > 0 ? commandArgs : getProfileCommand(profile, ['bash']);
+      execArgs.push(...(workdir ? formatWorkdirCommand(command, workdir) : command));
+
+      const child = Bun.spawn(['devcontainer', ...execArgs], {
+        stdio: ['inherit', 'inherit', 'inherit'],
+        cwd
+      });
+
+      const exitCode = await child.exited;
+      process.exit(exitCode || 0);
+
+    } catch (error) {
+      console.error('Failed to attach to container:', error);
+      process.exit(1);
+    }
+  });
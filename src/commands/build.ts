@@ -0,0 +1,58 @@
+import { Command } from 'commander';
+import { loadAisanityConfig, resolveWorkspaceOption } from '../utils/config';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+import { resolveProfile, getProfileNames, validatePlatform, ResolvedProfile } from '../utils/profile-utils';
+import { buildProfileImage } from '../utils/image-build';
+
+export const buildCommand = new Command('build')
+  .description('Build the images of profiles with a build block (only when the Dockerfile or context changed)')
+  .option('--profile <name>', 'Only build the image of this profile')
+  .option('--force', 'Build even when the image is up to date')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('-v, --verbose', 'Show detailed user information')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    const cwd = resolveWorkspaceOption(options);
+
+    try {
+      const config = loadAisanityConfig(cwd);
+
+      if (!config) {
+        console.error('No .aisanity config found. Run "aisanity init" first.');
+        process.exit(1);
+      }
+
+      let profiles: ResolvedProfile[];
+      try {
+        const names = options.profile ? [options.profile] : getProfileNames(config);
+        profiles = (names.length > 0 ? names.map((name: string) => resolveProfile(config, name)) : [resolveProfile(config)])
+          .filter(profile => profile.build);
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+
+      if (profiles.length === 0) {
+        console.error(options.profile
+          ? `Profile '${options.profile}' has no build block.`
+          : 'No profile has a build block. Add build.context (and build.dockerfile) to a profile.');
+        process.exit(1);
+      }
+
+      for (const profile of profiles) {
+        logger.info(`Building image for profile '${profile.name}'...`);
+        const result = await buildProfileImage(profile.build!, profile.name, config.workspace, cwd, {
+          force: options.force || false,
+          debug: options.debug || false,
+          platform: profile.platform ? validatePlatform(profile.platform, profile.name) : undefined
+        });
+        logger.info(result.built ? `Built ${result.tag}` : `${result.tag} is up to date`);
+      }
+
+    } catch (error) {
+      console.error('Failed to build images:', error instanceof Error ? error.message : error);
+      process.exit(1);
+    }
+  });
@@ -1,83 +1,213 @@
 import { Command } from 'commander';
-import { discoverAllAisanityContainers, stopContainers, removeContainers } from '../utils/container-utils';
-import { createLoggerFromCommandOptions } from '../utils/logger';
+import * as fs from 'fs';
+import {
+  discoverAllAisanityContainers,
+  stopContainers,
+  removeContainers,
+  findCacheVolumes,
+  removeVolumes,
+  listLabeledContainers,
+  DockerContainer,
+  LABEL_WORKSPACE
+} from '../utils/container-utils';
+import { VolumeInfo } from '../utils/container-runtime';
+import { createLoggerFromCommandOptions, Logger } from '../utils/logger';
 
 export const cleanupCommand = new Command('cleanup')
   .description('Clean up orphaned containers from manually deleted worktrees')
+  .option('--volumes', 'Also remove cache volumes of deleted workspaces')
   .option('--dry-run', 'Show what would be cleaned up without actually doing it')
   .option('--force', 'Skip confirmation prompts')
   .option('-v, --verbose', 'Show detailed user information (container status, orphaned containers)')
   .option('-d, --debug', 'Show system debugging information (discovery process, timing)')
   .action(async (options) => {
     const logger = createLoggerFromCommandOptions(options);
-    
+
     try {
-      logger.info('Discovering orphaned containers...');
-
-      // Discover all containers
-      const discoveryResult = await discoverAllAisanityContainers({
-        mode: 'global',
-        includeOrphaned: true,
-        validationMode: 'permissive',
-        verbose: options.verbose || false
-      });
-
-      if (discoveryResult.errors.length > 0 && options.verbose) {
-        logger.warn('Discovery errors encountered:');
-        discoveryResult.errors.forEach(error => {
-          logger.warn(`  ${error.container}: ${error.error}`);
-        });
+      await cleanupOrphanedContainers(options, logger);
+
+      if (options.volumes) {
+        const volumes = selectOrphanedCacheVolumes(await findCacheVolumes(undefined, options.debug || false));
+        await cleanupCacheVolumes(volumes, options, logger);
       }
+    } catch (error) {
+      logger.error('Failed to cleanup orphaned containers:', error);
+      throw error;
+    }
+  });
+
+export const cleanCommand = new Command('clean')
+  .description('Remove stopped aisanity containers of every workspace')
+  .option('--volumes', 'Also remove the cache volumes of the removed containers')
+  .option('--dry-run', 'Show what would be removed without actually doing it')
+  .option('--force', 'Skip confirmation prompts')
+  .option('-v, --verbose', 'Show detailed user information (removed containers and volumes)')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (options) => {
+    const logger = createLoggerFromCommandOptions(options);
 
-      const orphanedContainers = discoveryResult.orphaned;
+    try {
+      const removed = await cleanupStoppedContainers(options, logger);
 
-      if (orphanedContainers.length === 0) {
-        logger.info('No orphaned containers found');
-        return;
+      if (options.volumes && removed.length > 0) {
+        const workspaces = removed.map(container => container.labels[LABEL_WORKSPACE]);
+        const volumes = selectCacheVolumesToClean(await findCacheVolumes(undefined, options.debug || false), workspaces);
+        await cleanupCacheVolumes(volumes, options, logger);
       }
+    } catch (error) {
+      logger.error('Failed to clean stopped containers:', error);
+      throw error;
+    }
+  });
 
-      logger.info(`Found ${orphanedContainers.length} orphaned containers:`);
-      orphanedContainers.forEach(container => {
-        logger.info(`  - ${container.name} (${container.id}) - ${container.status}`);
-      });
+async function cleanupOrphanedContainers(options: any, logger: Logger): Promise<void> {
+  logger.info('Discovering orphaned containers...');
 
-      if (options.dryRun) {
-        logger.info('\nDry run mode: No action taken');
-        return;
-      }
+  // Discover all containers
+  const discoveryResult = await discoverAllAisanityContainers({
+    mode: 'global',
+    includeOrphaned: true,
+    validationMode: 'permissive',
+    verbose: options.verbose || false
+  });
 
-      // User confirmation unless forced
-      if (!options.force) {
-        const readline = require('readline');
-        const rl = readline.createInterface({
-          input: process.stdin,
-          output: process.stdout
-        });
+  if (discoveryResult.errors.length > 0 && options.verbose) {
+    logger.warn('Discovery errors encountered:');
+    discoveryResult.errors.forEach(error => {
+      logger.warn(`  ${error.container}: ${error.error}`);
+    });
+  }
 
-        const answer = await new Promise<string>((resolve) => {
-          rl.question(`Are you sure you want to stop and remove ${orphanedContainers.length} orphaned containers? [y/N]: `, resolve);
-        });
+  const orphanedContainers = discoveryResult.orphaned;
 
-        rl.close();
+  if (orphanedContainers.length === 0) {
+    logger.info('No orphaned containers found');
+    return;
+  }
 
-        if (answer.toLowerCase() !== 'y' && answer.toLowerCase() !== 'yes') {
-          logger.info('Cleanup cancelled');
-          return;
-        }
-      }
+  logger.info(`Found ${orphanedContainers.length} orphaned containers:`);
+  orphanedContainers.forEach(container => {
+    logger.info(`  - ${container.name} (${container.id}) - ${container.status}`);
+  });
 
-      // Stop orphaned containers
-      const containerIds = orphanedContainers.map(c => c.id);
-      logger.info('Stopping orphaned containers...');
-      await stopContainers(containerIds, options.verbose);
+  if (options.dryRun) {
+    logger.info('\nDry run mode: No action taken');
+    return;
+  }
 
-      logger.info('Removing orphaned containers...');
-      await removeContainers(containerIds, options.verbose);
+  // User confirmation unless forced
+  if (!options.force && !(await confirm(`Are you sure you want to stop and remove ${orphanedContainers.length} orphaned containers? [y/N]: `))) {
+    logger.info('Cleanup cancelled');
+    return;
+  }
 
-      logger.info(`Successfully cleaned up ${orphanedContainers.length} orphaned containers`);
+  // Stop orphaned containers
+  const containerIds = orphanedContainers.map(c => c.id);
+  logger.info('Stopping orphaned containers...');
+  await stopContainers(containerIds, options.verbose);
 
-    } catch (error) {
-      logger.error('Failed to cleanup orphaned containers:', error);
-      throw error;
-    }
-  });
\ No newline at end of file
+  logger.info('Removing orphaned containers...');
+  await removeContainers(containerIds, options.verbose);
+
+  logger.info(`Successfully cleaned up ${orphanedContainers.length} orphaned containers`);
+}
+
+/**
+ * Remove the stopped containers with the aisanity.workspace label
+ * Persistent containers are created again by the next "aisanity run".
+ * @returns The containers that were removed (on a dry run, those that would be); none when cancelled
+ */
+async function cleanupStoppedContainers(options: any, logger: Logger): Promise<DockerContainer[]> {
+  logger.info('Discovering stopped containers...');
+
+  const containers = await listLabeledContainers(false, options.debug || false);
+
+  if (containers.length === 0) {
+    logger.info('No stopped containers found');
+    return [];
+  }
+
+  logger.info(`Found ${containers.length} stopped containers:`);
+  containers.forEach(container => {
+    logger.info(`  - ${container.name} (${container.id}) - ${container.labels[LABEL_WORKSPACE]}`);
+  });
+
+  if (options.dryRun) {
+    logger.info('\nDry run mode: No action taken');
+    return containers;
+  }
+
+  if (!options.force && !(await confirm(`Are you sure you want to remove ${containers.length} stopped containers? [y/N]: `))) {
+    logger.info('Container cleanup cancelled');
+    return [];
+  }
+
+  const failed = await removeContainers(containers.map(container => container.id), options.verbose);
+  const removed = containers.filter(container => !failed.includes(container.id));
+  logger.info(`Removed ${removed.length} stopped containers`);
+  return removed;
+}
+
+/**
+ * Select the cache volumes of the given workspaces
+ */
+export function selectCacheVolumesToClean(volumes: VolumeInfo[], workspaces: string[]): VolumeInfo[] {
+  return volumes.filter(volume => workspaces.includes(volume.labels[LABEL_WORKSPACE]));
+}
+
+/**
+ * Select the cache volumes whose workspace no longer exists, or that have no workspace label
+ */
+export function selectOrphanedCacheVolumes(volumes: VolumeInfo[], exists: (p: string) => boolean = fs.existsSync): VolumeInfo[] {
+  return volumes.filter(volume => {
+    const volumeWorkspace = volume.labels[LABEL_WORKSPACE];
+    return !volumeWorkspace || !exists(volumeWorkspace);
+  });
+}
+
+async function cleanupCacheVolumes(volumes: VolumeInfo[], options: any, logger: Logger): Promise<void> {
+  logger.info('\nDiscovering cache volumes...');
+
+  if (volumes.length === 0) {
+    logger.info('No cache volumes found');
+    return;
+  }
+
+  logger.info(`Found ${volumes.length} cache volumes:`);
+  volumes.forEach(volume => {
+    logger.info(`  - ${volume.name} (${volume.labels[LABEL_WORKSPACE] || 'unknown workspace'})`);
+  });
+
+  if (options.dryRun) {
+    logger.info('\nDry run mode: No action taken');
+    return;
+  }
+
+  if (!options.force && !(await confirm(`Are you sure you want to remove ${volumes.length} cache volumes? [y/N]: `))) {
+    logger.info('Volume cleanup cancelled');
+    return;
+  }
+
+  // Volumes still used by a container cannot be removed; those are reported and kept
+  const failed = await removeVolumes(volumes.map(volume => volume.name), options.verbose);
+  logger.info(`Removed ${volumes.length - failed.length} cache volumes`);
+  if (failed.length > 0) {
+    logger.warn(`${failed.length} cache volumes are still in use. Stop their containers with "aisanity stop" and try again.`);
+  }
+}
+
+async function confirm(question: string): Promise<boolean> {
+  const readline = require('readline');
+  const rl = readline.createInterface({
+    input: process.stdin,
+    output: process.stdout
+  });
+
+  const answer = await new Promise<string>((resolve) => {
+    rl.question(question, resolve);
+  });
+
+  rl.close();
+
+  return answer.toLowerCase() === 'y' || answer.toLowerCase() === 'yes';
+}
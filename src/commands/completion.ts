@@ -0,0 +1,41 @@
+import { Command } from 'commander';
+import { loadAisanityConfig, getWorkspaceRoot } from '../utils/config';
+import { getProfileNames } from '../utils/profile-utils';
+import { COMPLETION_SHELLS, CompletionShell, COMPLETE_PROFILES_COMMAND, generateCompletionScript } from '../utils/completion';
+
+export const completionCommand = new Command('completion')
+  .description(`Print a shell completion script (${COMPLETION_SHELLS.join(', ')})`)
+  .argument('<shell>', `Shell to generate the script for: ${COMPLETION_SHELLS.join(', ')}`)
+  .addHelpText('after', `
+Examples:
+  source <(aisanity completion bash)          # bash, add to ~/.bashrc
+  source <(aisanity completion zsh)           # zsh, add to ~/.zshrc
+  aisanity completion fish | source           # fish, add to ~/.config/fish/config.fish
+`)
+  .action((shell: string) => {
+    if (!(COMPLETION_SHELLS as readonly string[]).includes(shell)) {
+      console.error(`Unsupported shell "${shell}". Supported shells: ${COMPLETION_SHELLS.join(', ')}`);
+      process.exit(1);
+    }
+
+    const program = completionCommand.parent;
+    if (!program) {
+      console.error('Completion is only available through the aisanity program');
+      process.exit(1);
+    }
+    process.stdout.write(generateCompletionScript(shell as CompletionShell, program));
+  });
+
+// Called by the completion scripts; prints the profiles of the workspace the shell is in
+export const completeProfilesCommand = new Command(COMPLETE_PROFILES_COMMAND)
+  .description('List profile names for shell completion')
+  .action(() => {
+    try {
+      const config = loadAisanityConfig(getWorkspaceRoot(process.cwd()));
+      if (config) {
+        getProfileNames(config).forEach(name => console.log(name));
+      }
+    } catch (error) {
+      // Completion must stay quiet when there is no usable config
+    }
+  });
@@ -0,0 +1,87 @@
+import { Command } from 'commander';
+import * as YAML from 'yaml';
+import { loadAisanityConfig, readAisanityConfig, resolveWorkspaceOption, AisanityConfig } from '../utils/config';
+import { findHostEnvKeys, loadEnvFiles } from '../utils/env-utils';
+import { resolveProfile, applyEnvProfiles, parseProfileMount, TEMPLATE_VARIABLES } from '../utils/profile-utils';
+
+export interface EffectiveConfigOptions {
+  profile?: string;
+  envProfiles?: string[];
+  showSecrets?: boolean;
+}
+
+/**
+ * Build the configuration a run with the given profile would use: extends, the user config,
+ * env profiles, env files, interpolation and templates applied, mounts as passed to docker.
+ * Env values from the host or from env files are masked unless showSecrets is set.
+ * @param raw The same config before interpolation, used to tell which env values came from the host
+ */
+export function getEffectiveConfig(
+  config: AisanityConfig,
+  raw: AisanityConfig,
+  cwd: string,
+  options: EffectiveConfigOptions = {}
+): Record<string, unknown> {
+  const envProfiles = options.envProfiles || [];
+  const { name, env: profileEnv, mounts, ...profile } = applyEnvProfiles(config, resolveProfile(config, options.profile), envProfiles);
+  const fileEnv = loadEnvFiles(profile.envFile, cwd, name);
+  const env: Record<string, string> = { ...fileEnv, ...(config.env || {}), ...(profileEnv || {}) };
+
+  if (!options.showSecrets) {
+    const secretKeys = new Set([
+      ...Object.keys(fileEnv),
+      ...[raw.env, raw.base?.env, raw.profiles?.[name]?.env, ...envProfiles.map(envProfile => raw.envProfiles?.[envProfile])]
+        .flatMap(block => findHostEnvKeys(block, TEMPLATE_VARIABLES))
+    ]);
+    for (const key of Object.keys(env)) {
+      if (secretKeys.has(key)) {
+        env[key] = '***';
+      }
+    }
+  }
+
+  // Workspace-wide settings first, the profile blocks are replaced by the selected profile
+  const settings: Record<string, unknown> = { ...config };
+  for (const key of ['env', 'base', 'profiles', 'envProfiles', 'extends']) {
+    delete settings[key];
+  }
+
+  return {
+    ...settings,
+    profile: name,
+    ...profile,
+    ...(envProfiles.length > 0 ? { envProfiles } : {}),
+    ...(mounts ? { mounts: mounts.map(mount => parseProfileMount(mount, cwd)) } : {}),
+    ...(Object.keys(env).length > 0 ? { env } : {})
+  };
+}
+
+export const configDumpCommand = new Command('dump')
+  .description('Print the effective config of a profile as YAML, after extends, user config, env and template resolution')
+  .option('--profile <name>', 'Sandbox profile from .aisanity config (defaults to the "default" profile)')
+  .option('--env-profile <name>', 'Layer an env bundle from envProfiles on top of the profile env, as run does (can be used multiple times)',
+          (value, previous: string[] = []) => [...previous, value])
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--show-secrets', 'Print env values from the host and env files instead of masking them')
+  .action((options) => {
+    const cwd = resolveWorkspaceOption(options);
+
+    try {
+      const config = loadAisanityConfig(cwd);
+      const raw = readAisanityConfig(cwd);
+
+      if (!config || !raw) {
+        console.error('No .aisanity config found. Run "aisanity init" first.');
+        process.exit(1);
+      }
+
+      process.stdout.write(YAML.stringify(getEffectiveConfig(config, raw, cwd, {
+        profile: options.profile,
+        envProfiles: options.envProfile || [],
+        showSecrets: options.showSecrets || false
+      })));
+    } catch (error) {
+      console.error(error instanceof Error ? error.message : String(error));
+      process.exit(1);
+    }
+  });
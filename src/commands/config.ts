@@ -0,0 +1,6 @@
+import { Command } from 'commander';
+import { configDumpCommand } from './config-dump';
+
+export const configCommand = new Command('config')
+  .description('Inspect the .aisanity configuration')
+  .addCommand(configDumpCommand);
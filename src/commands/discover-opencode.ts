@@ -1,9 +1,8 @@
 import { Command } from 'commander';
 import * as YAML from 'yaml';
-import pc from 'picocolors';
 import { $ } from 'bun';
-import { loadAisanityConfig, getContainerName as getAisanityContainerName } from '../utils/config';
-import { createLoggerFromCommandOptions } from '../utils/logger';
+import { loadAisanityConfig, getContainerName as getAisanityContainerName, getWorkspaceRoot } from '../utils/config';
+import { colors } from '../utils/display';
 
 export interface OpencodeInstance {
   containerId: string;
@@ -173,7 +172,7 @@ export async function discoverOpencodeInstances(options: CommandOptions): Promis
   const instances: OpencodeInstance[] = [];
 
   try {
-    const cwd = process.cwd();
+    const cwd = getWorkspaceRoot(process.cwd());
     const config = loadAisanityConfig(cwd);
 
     if (!config) {
@@ -378,19 +377,19 @@ export async function discoverOpencodeInstances(options: CommandOptions): Promis
 // Output formatting functions
 export function formatText(result: DiscoveryResult, showAll: boolean = false): string {
   if (result.error) {
-    return pc.red(`Error: ${result.error}`);
+    return colors().red(`Error: ${result.error}`);
   }
 
   if (result.instances.length === 0) {
-      return pc.yellow('No opencode instances found');
+      return colors().yellow('No opencode instances found');
   }
 
   let output = '';
 
   if (showAll) {
-    output += pc.green(`Found ${result.instances.length} opencode instance(s):\n\n`);
+    output += colors().green(`Found ${result.instances.length} opencode instance(s):\n\n`);
     result.instances.forEach((instance, index) => {
-      output += pc.blue(`Instance ${index + 1}:\n`);
+      output += colors().blue(`Instance ${index + 1}:\n`);
       output += `  Container: ${instance.containerName}\n`;
       output += `  Port: ${instance.port}\n`;
       output += `  Age: ${instance.elapsedTime} seconds\n`;
@@ -398,10 +397,10 @@ export function formatText(result: DiscoveryResult, showAll: boolean = false): s
     });
   } else {
     if (!result.mostRecent) {
-    return pc.yellow('No opencode instances found');
+    return colors().yellow('No opencode instances found');
     }
 
-    output += pc.green('Most recent opencode instance:\n');
+    output += colors().green('Most recent opencode instance:\n');
     output += `  Container: ${result.mostRecent.containerName}\n`;
     output += `  Port: ${result.mostRecent.port}\n`;
     output += `  Age: ${result.mostRecent.elapsedTime} seconds\n`;
@@ -431,26 +430,25 @@ export const discoverOpencodeCommand = new Command('discover-opencode')
   .option('-d, --debug', 'Show system debugging information (discovery process, timing)')
    .action(async (options) => {
      try {
-       const logger = createLoggerFromCommandOptions(options);
        const result = await discoverOpencodeInstances(options);
 
        switch (options.format) {
          case 'json':
-           logger.info(JSON.stringify(result, null, 2));
+           console.log(JSON.stringify(result, null, 2));
            break;
          case 'yaml':
-           logger.info(YAML.stringify(result));
+           console.log(YAML.stringify(result));
            break;
          case 'plain':
-           logger.info(formatPlain(result));
+           console.log(formatPlain(result));
            break;
          default: // text format
-           logger.info(formatText(result, options.all));
+           console.log(formatText(result, options.all));
            break;
        }
      } catch (error) {
        const errorMessage = error instanceof Error ? error.message : String(error);
-       console.error(pc.red('Error discovering opencode instances:'), errorMessage);
+       console.error(colors().red('Error discovering opencode instances:'), errorMessage);
        process.exit(1);
      }
    });
\ No newline at end of file
@@ -0,0 +1,64 @@
+import { Command } from 'commander';
+import { resolveWorkspaceOption } from '../utils/config';
+import { getContainerRuntime, ContainerRuntime } from '../utils/container-runtime';
+import { colors, symbol } from '../utils/display';
+import { DoctorCheck, checkBinaries, checkDaemon, checkDockerGroup, checkConfig, checkImages } from '../utils/doctor';
+
+function statusSymbol(status: DoctorCheck['status']): string {
+  switch (status) {
+    case 'pass':
+      return colors().green(symbol('check'));
+    case 'warn':
+      return colors().yellow(symbol('notice'));
+    case 'fail':
+      return colors().red(symbol('cross'));
+  }
+}
+
+export function formatDoctorCheck(check: DoctorCheck): string {
+  const line = `${statusSymbol(check.status)} ${check.name}: ${check.detail}`;
+  return check.hint ? `${line}\n    ${check.hint}` : line;
+}
+
+export const doctorCommand = new Command('doctor')
+  .description('Check that the container runtime, devcontainer CLI, config and images are ready')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (options) => {
+    const cwd = resolveWorkspaceOption(options);
+
+    const debug = options.debug || false;
+    const checks: DoctorCheck[] = [];
+    const report = (check: DoctorCheck | null) => {
+      if (check) {
+        checks.push(check);
+        console.log(formatDoctorCheck(check));
+      }
+    };
+
+    let runtime: ContainerRuntime;
+    try {
+      runtime = getContainerRuntime();
+    } catch (error) {
+      report({ name: 'Container runtime', status: 'fail', detail: error instanceof Error ? error.message : String(error) });
+      process.exit(1);
+    }
+
+    checkBinaries(runtime).forEach(report);
+    const daemon = await checkDaemon(runtime, process.platform, debug);
+    report(daemon);
+    report(checkDockerGroup(runtime));
+
+    const { check: configCheck, config } = checkConfig(cwd);
+    report(configCheck);
+
+    // Images can only be looked up once the daemon answers
+    if (config && daemon.status === 'pass') {
+      (await checkImages(config, cwd, runtime, debug)).forEach(report);
+    }
+
+    const failed = checks.filter(check => check.status === 'fail').length;
+    const warnings = checks.filter(check => check.status === 'warn').length;
+    console.log(`\n${checks.length - failed - warnings} passed, ${warnings} warning(s), ${failed} failed`);
+    process.exit(failed > 0 ? 1 : 0);
+  });
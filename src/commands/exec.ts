@@ -0,0 +1,113 @@
+import { Command } from 'commander';
+import * as path from 'path';
+import * as fs from 'fs';
+import { loadAisanityConfig, getCurrentBranch, resolveWorkspaceOption } from '../utils/config';
+import { findRunningContainer, getContainerRemoteUser } from '../utils/container-utils';
+import { getContainerRuntime } from '../utils/container-runtime';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+import { processEnvironmentVariables, loadEnvFiles, formatDockerEnvArgs } from '../utils/env-utils';
+import { resolveProfile, applyProfileToConfig, applyEnvProfiles, validateWorkdir, ResolvedProfile } from '../utils/profile-utils';
+
+export const execCommand = new Command('exec')
+  .description('Run a command in the already-running container for the current workspace')
+  .argument('<command...>', 'Command to run in container (use -- to separate it from aisanity options)')
+  .option('--worktree <path>', 'Run command in specific worktree')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--profile <name>', 'Sandbox profile from .aisanity config (defaults to the "default" profile)')
+  .option('--env <key=value>', 'Set environment variable (can be used multiple times). Bypasses whitelist filtering.',
+          (value, previous: string[] = []) => [...previous, value])
+  .option('--env-profile <name>', 'Layer an env bundle from envProfiles on top of the profile env (can be used multiple times, later ones win)',
+          (value, previous: string[] = []) => [...previous, value])
+  .option('--no-tty', 'Do not allocate a TTY, even when stdout is a terminal')
+  .option('-v, --verbose', 'Show detailed user information (container lookup, user)')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (commandArgs: string[], options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    let cwd = resolveWorkspaceOption(options);
+
+    // Handle worktree option
+    if (options.worktree) {
+      const worktreePath = path.resolve(options.worktree);
+      if (!fs.existsSync(worktreePath)) {
+        console.error(`Worktree path does not exist: ${worktreePath}`);
+        process.exit(1);
+      }
+      cwd = worktreePath;
+    }
+
+    try {
+      const config = loadAisanityConfig(cwd);
+
+      if (!config) {
+        console.error('No .aisanity config found. Run "aisanity init" first.');
+        process.exit(1);
+      }
+
+      let profile: ResolvedProfile;
+      let workdir: string | undefined;
+      try {
+        profile = applyEnvProfiles(config, resolveProfile(config, options.profile), options.envProfile || []);
+        workdir = profile.workdir !== undefined ? validateWorkdir(profile.workdir, profile.name) : undefined;
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+
+      const branch = getCurrentBranch(cwd);
+
+      // Locate the existing container - never start a new one
+      const containerId = await findRunningContainer(cwd, branch, profile.name, options.debug || false);
+      if (!containerId) {
+        const profileInfo = config.profiles ? `, profile: ${profile.name}` : '';
+        console.error(`No running container found for this workspace (branch: ${branch}${profileInfo}).`);
+        console.error('Run "aisanity run" first to start the container.');
+        process.exit(1);
+      }
+
+      logger.verbose(`Found running container: ${containerId}`);
+
+      const envCollection = processEnvironmentVariables(applyProfileToConfig(config, profile), options.env || [], {
+        fileEnv: loadEnvFiles(profile.envFile, cwd, profile.name),
+        verbose: options.verbose || false
+      });
+
+      const execArgs = ['exec', '-i'];
+
+      // Allocate a TTY automatically when attached to a terminal
+      if (options.tty !== false && process.stdout.isTTY) {
+        execArgs.push('-t');
+      }
+
+      // Run as the devcontainer remote user so files match `aisanity run`
+      const remoteUser = await getContainerRemoteUser(containerId, options.debug || false);
+      if (remoteUser) {
+        execArgs.push('-u', remoteUser);
+        logger.verbose(`Running as user: ${remoteUser}`);
+      }
+
+      // Containers started by run have the workdir as their working directory, which docker exec starts in.
+      // It is passed explicitly too, for containers created before the workdir was set.
+      if (workdir) {
+        execArgs.push('-w', workdir);
+      }
+
+      execArgs.push(...formatDockerEnvArgs(envCollection.merged));
+      execArgs.push(containerId, ...commandArgs);
+
+      logger.debug(`[Docker] Executing in ${containerId}: ${commandArgs.join(' ')}`);
+
+      const child = Bun.spawn([getContainerRuntime().command, ...execArgs], {
+        stdio: ['inherit', 'inherit', 'inherit'],
+        cwd
+      });
+
+      // Forward the exit code of the command
+      const exitCode = await child.exited;
+      process.exit(exitCode || 0);
+
+    } catch (error) {
+      console.error('Failed to exec in container:', error);
+      process.exit(1);
+    }
+  });
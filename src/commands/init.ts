@@ -4,24 +4,57 @@ import * as path from 'path';
 import * as os from 'os';
 import { getWorkspaceName, createAisanityConfig, setupOpencodeConfig, detectProjectType } from '../utils/config';
 import { getDevContainerTemplate } from '../utils/devcontainer-templates';
+import { getConfigTemplate, getConfigTemplateNames, createTemplateConfig, ConfigTemplate } from '../utils/config-templates';
+import { print } from '../utils/display';
 
 export const initCommand = new Command('init')
   .description('Initialize workspace configuration and development environment')
-  .action(async () => {
+  .option('--template <lang>', 'Start from a built-in config template (see --list)')
+  .option('--list', 'List the available config templates')
+  .option('--force', 'Overwrite an existing .aisanity file')
+  .action(async (options) => {
+    if (options.list) {
+      for (const name of getConfigTemplateNames()) {
+        print(`${name.padEnd(8)} ${getConfigTemplate(name).description}`);
+      }
+      return;
+    }
+
+    let configTemplate: ConfigTemplate | undefined;
+    if (options.template) {
+      try {
+        configTemplate = getConfigTemplate(options.template);
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+    }
+
     try {
       const cwd = process.cwd();
       const workspaceName = getWorkspaceName(cwd);
 
-      console.log(`Initializing aisanity workspace: ${workspaceName}`);
+      print(`Initializing aisanity workspace: ${workspaceName}`);
 
       // Create .aisanity config file
       const configPath = path.join(cwd, '.aisanity');
-      if (fs.existsSync(configPath)) {
-        console.log('.aisanity file already exists');
+      const configExists = fs.existsSync(configPath);
+      if (configExists && fs.statSync(configPath).isDirectory() && options.force) {
+        console.error('.aisanity is a directory and cannot be overwritten. Remove it first.');
+        process.exit(1);
+      } else if (configExists && !options.force) {
+        if (configTemplate) {
+          console.error('.aisanity file already exists. Use --force to overwrite it.');
+          process.exit(1);
+        }
+        print('.aisanity file already exists');
       } else {
-        const config = createAisanityConfig(workspaceName);
+        const config = configTemplate
+          ? createTemplateConfig(workspaceName, options.template)
+          : createAisanityConfig(workspaceName);
         fs.writeFileSync(configPath, config, 'utf8');
-        console.log(`Created .aisanity config file`);
+        const templateInfo = configTemplate ? ` from the ${options.template} template` : '';
+        print(`${configExists ? 'Overwrote' : 'Created'} .aisanity config file${templateInfo}`);
       }
 
 
@@ -29,8 +62,8 @@ export const initCommand = new Command('init')
         // Setup opencode configuration
         await setupOpencodeConfig(cwd);
 
-        // Detect project type and create devcontainer
-        const projectType = detectProjectType(cwd);
+        // Detect project type (or take it from the template) and create devcontainer
+        const projectType = configTemplate ? configTemplate.projectType : detectProjectType(cwd);
         const devcontainerDir = path.join(cwd, '.devcontainer');
         if (!fs.existsSync(devcontainerDir)) {
           fs.mkdirSync(devcontainerDir, { recursive: true });
@@ -41,17 +74,17 @@ export const initCommand = new Command('init')
           const devcontainerPath = path.join(devcontainerDir, 'devcontainer.json');
           fs.writeFileSync(devcontainerPath, template.devcontainerJson, 'utf8');
           const projectTypeMessage = projectType === 'unknown' ? 'unknown project type' : `${projectType} project`;
-          console.log(`Created devcontainer for ${projectTypeMessage}`);
+          print(`Created devcontainer for ${projectTypeMessage}`);
 
           if (template.dockerfile) {
             const dockerfilePath = path.join(devcontainerDir, 'Dockerfile');
             fs.writeFileSync(dockerfilePath, template.dockerfile, 'utf8');
-            console.log(`Created Dockerfile for ${projectTypeMessage}`);
+            print(`Created Dockerfile for ${projectTypeMessage}`);
           }
         }
 
-       console.log(`Workspace ${workspaceName} initialized successfully!`);
-       console.log(`You can now use 'aisanity run' to start working in the container.`);
+       print(`Workspace ${workspaceName} initialized successfully!`);
+       print(`You can now use 'aisanity run' to start working in the container.`);
 
     } catch (error) {
       console.error('Failed to initialize workspace:', error);
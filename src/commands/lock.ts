@@ -0,0 +1,115 @@
+import { Command } from 'commander';
+import * as path from 'path';
+import * as fs from 'fs';
+import { loadAisanityConfig, readProjectConfig, resolveWorkspaceOption, AisanityConfig } from '../utils/config';
+import { pullImage, getLocalImageDigest } from '../utils/container-utils';
+import { getContainerRuntime } from '../utils/container-runtime';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+import { resolveProfile, getProfileNames, parseDuration, isSamePlatform } from '../utils/profile-utils';
+import { readDevContainerJson } from '../utils/devcontainer-templates';
+import { hashBuildContext, resolveBuildPaths } from '../utils/image-build';
+import {
+  parseImageReference,
+  readImageLock,
+  writeImageLock,
+  lockImage,
+  hashLockConfig,
+  ImageLock,
+  LockDigests,
+  LOCK_FILE_NAME
+} from '../utils/image-lock';
+import { collectProfileImages, ProfileImage } from './pull';
+
+/**
+ * Hash the config and the build context of each profile with a build block, as recorded in the lock
+ * The config is hashed as the project declares it: before env interpolation and template expansion,
+ * and without the user config, all of which differ between machines.
+ * @param profileName Only hash the build context of this profile
+ */
+export function collectLockDigests(config: AisanityConfig, cwd: string, profileName?: string): LockDigests {
+  const names = profileName ? [profileName] : getProfileNames(config);
+  const profiles = names.length > 0 ? names.map(name => resolveProfile(config, name)) : [resolveProfile(config)];
+
+  const builds: Record<string, string> = {};
+  for (const profile of profiles) {
+    if (profile.build) {
+      builds[profile.name] = 'sha256:' + hashBuildContext(resolveBuildPaths(profile.build, cwd, profile.name));
+    }
+  }
+  return { config: hashLockConfig(readProjectConfig(cwd)), builds };
+}
+
+export const lockCommand = new Command('lock')
+  .description(`Regenerate ${LOCK_FILE_NAME}: image digests, and hashes of the config and build contexts for run --frozen`)
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--pull-timeout <duration>', 'Give up pulling a missing image after this long, retries included (e.g. 5m, default 10m)')
+  .option('-v, --verbose', 'Show detailed user information (resolved digests)')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    const cwd = resolveWorkspaceOption(options);
+
+    try {
+      const config = loadAisanityConfig(cwd);
+
+      if (!config) {
+        console.error('No .aisanity config found. Run "aisanity init" first.');
+        process.exit(1);
+      }
+
+      const devcontainerPath = path.join(cwd, '.devcontainer', 'devcontainer.json');
+      const devcontainerImage = fs.existsSync(devcontainerPath) ? readDevContainerJson(devcontainerPath).image : undefined;
+
+      let images: ProfileImage[];
+      let digests: LockDigests;
+      let pullTimeout: number | undefined;
+      try {
+        images = collectProfileImages(config, devcontainerImage);
+        images.forEach(({ image }) => parseImageReference(image));
+        digests = collectLockDigests(config, cwd);
+        pullTimeout = options.pullTimeout ? parseDuration(options.pullTimeout) : undefined;
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+
+      // Images no longer in the config are dropped; digests that did not change keep their pulledAt
+      const previous = readImageLock(cwd);
+      let lock: ImageLock = { version: previous?.version ?? 1, images: {}, ...digests };
+
+      for (const { image, platform } of images) {
+        // Images written with a digest are already pinned in the config
+        if (parseImageReference(image).digest) {
+          logger.verbose(`${image} is pinned in the config`);
+          continue;
+        }
+
+        // With a platform, a local image for another platform is pulled again, as run does
+        const localPlatform = platform ? await getContainerRuntime().getImagePlatform(image, options.debug || false) : null;
+        let digest = !platform || (localPlatform !== null && isSamePlatform(localPlatform, platform))
+          ? await getLocalImageDigest(image, options.debug || false)
+          : null;
+        if (!digest) {
+          logger.info(`Pulling ${image}${platform ? ` for ${platform}` : ''}...`);
+          digest = await pullImage(image, options.debug || false, { timeout: pullTimeout, platform, onRetry: message => logger.warn(message) });
+        }
+        if (!digest) {
+          logger.warn(`No registry digest for ${image}; it is not recorded in ${LOCK_FILE_NAME}`);
+          continue;
+        }
+
+        const locked = previous?.images[image];
+        lock = locked?.digest === digest ? { ...lock, images: { ...lock.images, [image]: locked } } : lockImage(lock, image, digest);
+        logger.verbose(`Locked ${image} to ${digest}`);
+      }
+
+      writeImageLock(cwd, lock);
+      const buildCount = Object.keys(digests.builds).length;
+      logger.info(`Wrote ${LOCK_FILE_NAME}: ${Object.keys(lock.images).length} image(s), config${buildCount > 0 ? ` and ${buildCount} build context(s)` : ''}`);
+
+    } catch (error) {
+      console.error('Failed to write the lock file:', error instanceof Error ? error.message : error);
+      process.exit(1);
+    }
+  });
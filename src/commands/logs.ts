@@ -0,0 +1,104 @@
+import { Command } from 'commander';
+import { loadAisanityConfig, getCurrentBranch, resolveWorkspaceOption } from '../utils/config';
+import { findWorkspaceContainer } from '../utils/container-utils';
+import { getContainerRuntime, parseLogSince, LogOptions } from '../utils/container-runtime';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+import { resolveProfile, ResolvedProfile, parseDuration } from '../utils/profile-utils';
+import { parseOutputFormat, OutputFormat, toLogsJson, formatJson } from '../utils/output';
+
+/**
+ * Parse the quiet period of --until: a duration such as 30s, or a number of seconds
+ */
+export function parseIdleTimeout(value: string): number {
+  try {
+    return /^\d+$/.test(value.trim()) ? Number(value.trim()) * 1000 : parseDuration(value);
+  } catch (error) {
+    throw new Error(`Invalid --until value "${value}". Use a duration like 30s or a number of seconds`);
+  }
+}
+
+/**
+ * Validate the logs flags before handing them to the runtime
+ * --until follows the logs until no new output arrived for the given quiet period.
+ */
+export function parseLogOptions(options: { follow?: boolean; tail?: string; since?: string; until?: string }): LogOptions {
+  if (options.tail !== undefined && options.tail !== 'all' && !/^\d+$/.test(options.tail)) {
+    throw new Error(`Invalid --tail value "${options.tail}". Expected a number of lines or "all"`);
+  }
+
+  if (options.since !== undefined) {
+    parseLogSince(options.since);
+  }
+
+  if (options.until !== undefined) {
+    return { follow: true, tail: options.tail, since: options.since, idleTimeout: parseIdleTimeout(options.until) };
+  }
+
+  return { follow: options.follow || false, tail: options.tail, since: options.since };
+}
+
+export const logsCommand = new Command('logs')
+  .description('Show the logs of the sandbox container for the current workspace')
+  .option('-f, --follow', 'Follow log output (Ctrl-C to stop)')
+  .option('--tail <lines>', 'Number of lines to show from the end of the logs (or "all")')
+  .option('--since <duration>', 'Show logs since a timestamp (e.g. 2024-01-02T13:23:37Z) or relative duration (e.g. 42m)')
+  .option('--until <duration>', 'Follow the logs and exit 0 once no new output arrived for this long (e.g. 10s), for CI')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--profile <name>', 'Sandbox profile from .aisanity config (defaults to the "default" profile)')
+  .option('--output <format>', 'Output format: text, or json to print the container and log options instead of the logs')
+  .option('-v, --verbose', 'Show detailed user information (container lookup)')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    const cwd = resolveWorkspaceOption(options);
+
+    try {
+      const config = loadAisanityConfig(cwd);
+
+      if (!config) {
+        console.error(`No .aisanity config found in ${cwd}. Run "aisanity init" first.`);
+        process.exit(1);
+      }
+
+      let profile: ResolvedProfile;
+      let logOptions: LogOptions;
+      let outputFormat: OutputFormat;
+      try {
+        profile = resolveProfile(config, options.profile);
+        logOptions = parseLogOptions(options);
+        outputFormat = parseOutputFormat(options.output);
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+
+      const branch = getCurrentBranch(cwd);
+      const container = await findWorkspaceContainer(cwd, branch, profile.name, options.debug || false);
+      if (!container) {
+        const profileInfo = config.profiles ? `, profile: ${profile.name}` : '';
+        console.error(`No container found for workspace ${cwd} (branch: ${branch}${profileInfo}).`);
+        process.exit(1);
+      }
+
+      if (outputFormat === 'json') {
+        console.log(formatJson(toLogsJson(container, logOptions)));
+        return;
+      }
+
+      logger.verbose(`Showing logs for container: ${container.name} (${container.id})`);
+
+      // Ctrl-C stops following; that is the expected way to leave `logs -f`, so it exits 0
+      const abort = new AbortController();
+      const onInterrupt = () => abort.abort();
+      process.once('SIGINT', onInterrupt);
+
+      const exitCode = await getContainerRuntime().streamLogs(container.id, logOptions, abort.signal, options.debug || false);
+      process.removeListener('SIGINT', onInterrupt);
+      process.exit(abort.signal.aborted ? 0 : exitCode);
+
+    } catch (error) {
+      console.error('Failed to show container logs:', error instanceof Error ? error.message : error);
+      process.exit(1);
+    }
+  });
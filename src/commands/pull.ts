@@ -0,0 +1,117 @@
+import { Command } from 'commander';
+import * as path from 'path';
+import * as fs from 'fs';
+import { loadAisanityConfig, resolveWorkspaceOption, AisanityConfig } from '../utils/config';
+import { pullImage } from '../utils/container-utils';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+import { resolveProfile, getProfileNames, parseDuration, validatePlatform } from '../utils/profile-utils';
+import { readDevContainerJson } from '../utils/devcontainer-templates';
+import { parseImageReference, readImageLock, writeImageLock, lockImage, LOCK_FILE_NAME } from '../utils/image-lock';
+
+export interface ProfileImage {
+  image: string;
+  platform?: string; // The profile platform, pulled instead of the host platform
+}
+
+/**
+ * Collect the images the profiles start from, falling back to the devcontainer.json image
+ * Profiles with a build block are skipped, since their image is built with `aisanity build`.
+ * @throws Error when a profile platform is invalid
+ */
+export function collectProfileImages(config: AisanityConfig, devcontainerImage: string | undefined, profileName?: string): ProfileImage[] {
+  const names = profileName ? [profileName] : getProfileNames(config);
+  const profiles = names.length > 0 ? names.map(name => resolveProfile(config, name)) : [resolveProfile(config)];
+
+  const images = new Map<string, ProfileImage>();
+  for (const profile of profiles) {
+    if (profile.build) {
+      continue;
+    }
+    const image = profile.image || devcontainerImage;
+    if (image) {
+      const platform = profile.platform !== undefined ? validatePlatform(profile.platform, profile.name) : undefined;
+      images.set(`${image} ${platform || ''}`, platform ? { image, platform } : { image });
+    }
+  }
+  return [...images.values()];
+}
+
+export const pullCommand = new Command('pull')
+  .description(`Pull the sandbox images and record their digests in ${LOCK_FILE_NAME}`)
+  .option('--profile <name>', 'Only pull the image of this profile')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--pull-timeout <duration>', 'Give up pulling an image after this long, retries included (e.g. 5m, default 10m)')
+  .option('-v, --verbose', 'Show detailed user information (resolved digests)')
+  .option('-d, --debug', 'Show system debugging information (docker commands, timing)')
+  .action(async (options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    const cwd = resolveWorkspaceOption(options);
+
+    try {
+      const config = loadAisanityConfig(cwd);
+
+      if (!config) {
+        console.error('No .aisanity config found. Run "aisanity init" first.');
+        process.exit(1);
+      }
+
+      const devcontainerPath = path.join(cwd, '.devcontainer', 'devcontainer.json');
+      const devcontainerImage = fs.existsSync(devcontainerPath) ? readDevContainerJson(devcontainerPath).image : undefined;
+
+      let images: ProfileImage[];
+      try {
+        images = collectProfileImages(config, devcontainerImage, options.profile);
+        images.forEach(({ image }) => parseImageReference(image));
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+
+      if (images.length === 0) {
+        console.error('No image to pull: set "image" in a profile or in .devcontainer/devcontainer.json.');
+        process.exit(1);
+      }
+
+      let pullTimeout: number | undefined;
+      try {
+        pullTimeout = options.pullTimeout ? parseDuration(options.pullTimeout) : undefined;
+      } catch (error) {
+        console.error(`--pull-timeout: ${error instanceof Error ? error.message : String(error)}`);
+        process.exit(1);
+      }
+
+      let lock = readImageLock(cwd);
+      let changed = false;
+
+      for (const { image, platform } of images) {
+        logger.info(`Pulling ${image}${platform ? ` for ${platform}` : ''}...`);
+        const digest = await pullImage(image, options.debug || false, { timeout: pullTimeout, platform, onRetry: message => logger.warn(message) });
+
+        // Images written with a digest are already pinned in the config
+        if (parseImageReference(image).digest) {
+          logger.verbose(`${image} is pinned in the config`);
+          continue;
+        }
+        if (!digest) {
+          logger.warn(`No registry digest for ${image}; it is not recorded in ${LOCK_FILE_NAME}`);
+          continue;
+        }
+
+        if (lock?.images[image]?.digest !== digest) {
+          lock = lockImage(lock, image, digest);
+          changed = true;
+        }
+        logger.info(`Locked ${image} to ${digest}`);
+      }
+
+      if (changed && lock) {
+        writeImageLock(cwd, lock);
+        logger.info(`Updated ${LOCK_FILE_NAME}`);
+      }
+
+    } catch (error) {
+      console.error('Failed to pull images:', error instanceof Error ? error.message : error);
+      process.exit(1);
+    }
+  });
@@ -1,19 +1,23 @@
 import { Command } from 'commander';
 import * as path from 'path';
 import { execSync } from 'child_process';
-import { loadAisanityConfig, getContainerName } from '../utils/config';
+import { loadAisanityConfig, getContainerName, getLegacyContainerName, resolveWorkspaceOption } from '../utils/config';
+import { findContainersByName } from '../utils/container-utils';
+import { getContainerRuntime, getDevcontainerRuntimeArgs } from '../utils/container-runtime';
 import { createLoggerFromCommandOptions } from '../utils/logger';
 
 export const rebuildCommand = new Command('rebuild')
   .description('Rebuild the devcontainer')
   .option('--devcontainer-json <path>', 'Path to devcontainer.json file')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
   .option('--clean', 'Remove containers instead of just stopping them')
   .option('-v, --verbose', 'Show detailed container rebuild information')
   .option('-d, --debug', 'Show system debugging information (rebuild process, timing)')
   .action(async (options) => {
     const logger = createLoggerFromCommandOptions(options);
     try {
-      const cwd = process.cwd();
+      const cwd = resolveWorkspaceOption(options);
+
       const config = loadAisanityConfig(cwd);
 
       if (!config) {
@@ -23,26 +27,34 @@ export const rebuildCommand = new Command('rebuild')
 
       const workspaceName = config.workspace;
 
-      console.log(`Rebuilding container for workspace: ${workspaceName}`);
+      logger.info(`Rebuilding container for workspace: ${workspaceName}`);
 
       // Stop/Remove the container
       const action = options.clean ? 'Removing' : 'Stopping';
-      console.log(`${action} existing container...`);
+      logger.info(`${action} existing container...`);
 
       const containerName = getContainerName(cwd, options.verbose || false);
+      const cli = getContainerRuntime().command;
 
       try {
-        // Try to stop/remove the container using docker
-        const dockerCommand = options.clean ? `docker rm -f ${containerName}` : `docker stop ${containerName}`;
-        execSync(dockerCommand, { stdio: 'inherit' });
-        console.log(`${action.slice(0, -3)}ed container: ${containerName}`);
+        // Find the container by label, falling back to the legacy (pre-hash) name
+        const containers = await findContainersByName(containerName, getLegacyContainerName(cwd), cwd);
+        if (containers.length === 0) {
+          throw new Error(`Container ${containerName} not found`);
+        }
+
+        for (const container of containers) {
+          const dockerCommand = options.clean ? `${cli} rm -f ${container.id}` : `${cli} stop ${container.id}`;
+          execSync(dockerCommand, { stdio: 'inherit' });
+          logger.info(`${action.slice(0, -3)}ed container: ${container.name}`);
+        }
       } catch (error) {
-        console.log(`Container ${containerName} not found or already ${options.clean ? 'removed' : 'stopped'}`);
+        logger.info(`Container ${containerName} not found or already ${options.clean ? 'removed' : 'stopped'}`);
       }
 
       // Also try to stop/remove any devcontainer-related containers for this workspace
       try {
-        const output = execSync(`docker ps --filter "label=devcontainer.local_folder=${cwd}" --format "{{.Names}}"`, {
+        const output = execSync(`${cli} ps --filter "label=devcontainer.local_folder=${cwd}" --format "{{.Names}}"`, {
           encoding: 'utf8'
         });
 
@@ -50,9 +62,9 @@ export const rebuildCommand = new Command('rebuild')
 
         for (const container of containers) {
           if (container) {
-            const dockerCommand = options.clean ? `docker rm -f ${container}` : `docker stop ${container}`;
+            const dockerCommand = options.clean ? `${cli} rm -f ${container}` : `${cli} stop ${container}`;
             execSync(dockerCommand, { stdio: 'inherit' });
-            console.log(`${action.slice(0, -3)}ed devcontainer: ${container}`);
+            logger.info(`${action.slice(0, -3)}ed devcontainer: ${container}`);
           }
         }
       } catch (error) {
@@ -62,7 +74,7 @@ export const rebuildCommand = new Command('rebuild')
       // Also stop/remove any containers with the specific workspace name pattern
       // Search for both old format (aisanity-${workspaceName}) and new format (${workspaceName}-)
       try {
-        const output = execSync(`docker ps --filter "name=aisanity-${workspaceName}" --filter "name=${workspaceName}-" --format "{{.Names}}"`, {
+        const output = execSync(`${cli} ps --filter "name=aisanity-${workspaceName}" --filter "name=${workspaceName}-" --format "{{.Names}}"`, {
           encoding: 'utf8'
         });
 
@@ -70,9 +82,9 @@ export const rebuildCommand = new Command('rebuild')
 
         for (const container of containers) {
           if (container) {
-            const dockerCommand = options.clean ? `docker rm -f ${container}` : `docker stop ${container}`;
+            const dockerCommand = options.clean ? `${cli} rm -f ${container}` : `${cli} stop ${container}`;
             execSync(dockerCommand, { stdio: 'inherit' });
-            console.log(`${action.slice(0, -3)}ed aisanity container: ${container}`);
+            logger.info(`${action.slice(0, -3)}ed aisanity container: ${container}`);
           }
         }
       } catch (error) {
@@ -80,8 +92,8 @@ export const rebuildCommand = new Command('rebuild')
       }
 
       // Build the container
-      console.log('Building dev container...');
-      const buildArgs = ['build', '--workspace-folder', cwd];
+      logger.info('Building dev container...');
+      const buildArgs = ['build', '--workspace-folder', cwd, ...getDevcontainerRuntimeArgs()];
 
       if (options.devcontainerJson) {
         buildArgs.push('--config', path.resolve(options.devcontainerJson));
@@ -98,8 +110,8 @@ export const rebuildCommand = new Command('rebuild')
       }
 
       // Start the container
-      console.log('Starting dev container...');
-      const upArgs = ['up', '--workspace-folder', cwd];
+      logger.info('Starting dev container...');
+      const upArgs = ['up', '--workspace-folder', cwd, ...getDevcontainerRuntimeArgs()];
 
       if (options.devcontainerJson) {
         upArgs.push('--config', path.resolve(options.devcontainerJson));
@@ -115,7 +127,7 @@ export const rebuildCommand = new Command('rebuild')
         throw new Error(`devcontainer up failed with code ${upExitCode}`);
       }
 
-      console.log('Dev container rebuilt and started successfully');
+      logger.info('Dev container rebuilt and started successfully');
 
     } catch (error) {
       console.error('Failed to rebuild container:', error);
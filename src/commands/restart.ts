@@ -0,0 +1,108 @@
+import { Command } from 'commander';
+import { loadAisanityConfig, getCurrentBranch, resolveWorkspaceOption } from '../utils/config';
+import {
+  listContainers,
+  stopContainers,
+  matchesProfile,
+  DockerContainer,
+  DEFAULT_STOP_TIMEOUT,
+  LABEL_WORKSPACE,
+  LABEL_BRANCH,
+  LABEL_EPHEMERAL
+} from '../utils/container-utils';
+import { resolveProfile } from '../utils/profile-utils';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+import { resolveStopTimeout } from './stop';
+import { runCommand } from './run';
+
+/**
+ * Arguments for the run that starts the container again
+ * The container is started detached, and replaced when it no longer matches the config.
+ */
+export function getRestartRunArgs(cwd: string, options: { profile?: string; verbose?: boolean; debug?: boolean; quiet?: boolean }): string[] {
+  return [
+    '--detach',
+    '--auto-recreate',
+    '--workspace', cwd,
+    ...(options.profile ? ['--profile', options.profile] : []),
+    ...(options.verbose ? ['--verbose'] : []),
+    ...(options.debug ? ['--debug'] : []),
+    ...(options.quiet ? ['--quiet'] : [])
+  ];
+}
+
+/**
+ * Describe a container after the restart, one field per line
+ */
+export function formatRestartedContainer(container: DockerContainer, previousId: string): string[] {
+  return [
+    `${container.id === previousId ? 'Restarted' : 'Recreated'} container: ${container.name}`,
+    `  ID: ${container.id}`,
+    `  Image: ${container.image}`,
+    `  Status: ${container.status}`
+  ];
+}
+
+export const restartCommand = new Command('restart')
+  .description('Stop the container of the current workspace and start it again, recreating it when the config changed')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--profile <name>', 'Sandbox profile from .aisanity config (defaults to the "default" profile)')
+  .option('--timeout <seconds>', `Seconds to wait after SIGTERM before killing the container (default: stopTimeout config or ${DEFAULT_STOP_TIMEOUT})`)
+  .option('-v, --verbose', 'Show detailed user information (stop and start progress, drift details)')
+  .option('-d, --debug', 'Show system debugging information (discovery process, timing)')
+  .action(async (options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    const cwd = resolveWorkspaceOption(options);
+
+    try {
+      const config = loadAisanityConfig(cwd);
+
+      if (!config) {
+        console.error('No .aisanity config found. Run "aisanity init" first.');
+        process.exit(1);
+      }
+
+      let profileName: string;
+      let timeout: number;
+      try {
+        profileName = resolveProfile(config, options.profile).name;
+        timeout = resolveStopTimeout(options.timeout, config.stopTimeout);
+      } catch (error) {
+        console.error(error instanceof Error ? error.message : String(error));
+        process.exit(1);
+      }
+
+      // The same container run would reuse: this workspace, branch and profile, never an --rm one
+      const branch = getCurrentBranch(cwd);
+      const labels = [`${LABEL_WORKSPACE}=${cwd}`, `${LABEL_BRANCH}=${branch}`];
+      const container = (await listContainers({ all: true, labels }, options.debug || false))
+        .find(candidate => !candidate.labels[LABEL_EPHEMERAL] && matchesProfile(candidate.labels, profileName));
+
+      if (!container) {
+        console.error(`No container for profile '${profileName}' on branch ${branch}. Start one with "aisanity run".`);
+        process.exit(1);
+      }
+
+      logger.info(`Stopping container: ${container.name}`);
+      logger.verbose(`Stopping with a ${timeout}s grace period before SIGKILL`);
+      const { alreadyStopped } = await stopContainers([container.id], options.verbose || false, timeout);
+      if (alreadyStopped.length > 0) {
+        logger.verbose(`${container.name} was already stopped`);
+      }
+
+      logger.info('Starting container...');
+      await runCommand.parseAsync(getRestartRunArgs(cwd, options), { from: 'user' });
+
+      const restarted = (await listContainers({ labels }, options.debug || false))
+        .find(candidate => !candidate.labels[LABEL_EPHEMERAL] && matchesProfile(candidate.labels, profileName));
+      if (!restarted) {
+        throw new Error('Container started but could not be found by its labels');
+      }
+      formatRestartedContainer(restarted, container.id).forEach(line => console.log(line));
+
+    } catch (error) {
+      console.error('Failed to restart container:', error);
+      process.exit(1);
+    }
+  });
@@ -0,0 +1,96 @@
+import { AisanityConfig, getCurrentBranch } from '../utils/config';
+import {
+  listContainers,
+  stopContainers,
+  matchesProfile,
+  runWithConcurrency,
+  DockerContainer,
+  LABEL_WORKSPACE,
+  LABEL_BRANCH,
+  LABEL_EPHEMERAL
+} from '../utils/container-utils';
+import { resolveProfile } from '../utils/profile-utils';
+import { Logger } from '../utils/logger';
+
+// Sandboxes brought up at the same time by run with several --profile flags
+export const MAX_PARALLEL_RUNS = 4;
+
+export interface ProfileRunResult {
+  profile: string;
+  container?: DockerContainer;
+  error?: string;    // Why the profile failed to start
+  skipped?: boolean; // Not started because another profile failed first
+}
+
+/**
+ * Describe the outcome of every profile, one line each
+ * @param rolledBack Profiles whose container was stopped again because another profile failed
+ */
+export function formatProfileRunResults(results: ProfileRunResult[], rolledBack: string[] = []): string[] {
+  const width = Math.max(...results.map(result => result.profile.length));
+  return results.map(result => {
+    const name = result.profile.padEnd(width);
+    if (result.container && rolledBack.includes(result.profile)) {
+      return `${name}  ${result.container.name}  ${result.container.id}  stopped (rolled back)`;
+    }
+    if (result.container) {
+      return `${name}  ${result.container.name}  ${result.container.id}  ${result.container.status}`;
+    }
+    return `${name}  ${result.skipped ? 'not started' : 'failed'}`;
+  });
+}
+
+/**
+ * Start the containers of several profiles at once, each with its own detached run
+ * When any profile fails, the containers this call started are stopped again; ones that
+ * were already running before are left alone.
+ * @param start Starts the container of one profile and resolves to its ID
+ * @returns The outcome per profile, in the order given
+ */
+export async function runProfiles(
+  config: AisanityConfig,
+  profileNames: string[],
+  cwd: string,
+  start: (profile: string) => Promise<string | null>,
+  options: { verbose?: boolean; debug?: boolean },
+  logger: Logger
+): Promise<{ results: ProfileRunResult[]; rolledBack: string[] }> {
+  // Unknown profiles fail before anything is started
+  const profiles = [...new Set(profileNames.map(name => resolveProfile(config, name).name))];
+
+  const labels = [`${LABEL_WORKSPACE}=${cwd}`, `${LABEL_BRANCH}=${getCurrentBranch(cwd)}`];
+  const findContainer = (containers: DockerContainer[], profile: string) =>
+    containers.find(candidate => !candidate.labels[LABEL_EPHEMERAL] && matchesProfile(candidate.labels, profile));
+  const runningBefore = await listContainers({ labels }, options.debug || false);
+
+  logger.info(`Starting ${profiles.length} profiles: ${profiles.join(', ')}`);
+  const settled = await runWithConcurrency(profiles, MAX_PARALLEL_RUNS, async (profile) => {
+    logger.verbose(`Starting profile '${profile}'`);
+    return start(profile);
+  });
+
+  const running = await listContainers({ labels }, options.debug || false);
+  const results: ProfileRunResult[] = profiles.map((profile, index) => {
+    const outcome = settled[index];
+    if (!outcome) {
+      return { profile, skipped: true };
+    }
+    if (outcome.status === 'rejected') {
+      return { profile, error: outcome.reason instanceof Error ? outcome.reason.message : String(outcome.reason) };
+    }
+    const container = running.find(candidate => candidate.id === outcome.value) || findContainer(running, profile);
+    return container ? { profile, container } : { profile, error: 'container started but could not be found by its labels' };
+  });
+
+  const rolledBack: string[] = [];
+  if (results.some(result => !result.container)) {
+    const started = results.filter(result => result.container && !findContainer(runningBefore, result.profile));
+    if (started.length > 0) {
+      logger.info(`Stopping ${started.length} container(s) started by this run...`);
+      await stopContainers(started.map(result => result.container!.id), options.verbose || false, config.stopTimeout);
+      rolledBack.push(...started.map(result => result.profile));
+    }
+  }
+
+  return { results, rolledBack };
+}
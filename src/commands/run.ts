@@ -1,30 +1,165 @@
 import { Command } from 'commander';
 import * as path from 'path';
-import { $ } from 'bun';
-import { loadAisanityConfig, getContainerName, getCurrentBranch } from '../utils/config';
-import { generateContainerLabels, validateContainerLabels, ContainerLabels } from '../utils/container-utils';
+import { loadAisanityConfig, AisanityConfig, getContainerName, getCurrentBranch, resolveWorkspaceOption, getAisanityConfigPath, getUserConfigPath } from '../utils/config';
+import {
+  generateContainerLabels,
+  validateContainerLabels,
+  matchesProfile,
+  createSignalContext,
+  waitForAttachedProcess,
+  detectContainerDrift,
+  removeSandbox,
+  getPublishedPorts,
+  waitForHealthy,
+  listContainers,
+  getCacheVolumeName,
+  ensureCacheVolumes,
+  getLocalImageDigest,
+  pullImage,
+  parsePullPolicy,
+  describePullFailure,
+  PullPolicy,
+  ContainerLabels,
+  DockerContainer,
+  LABEL_WORKSPACE,
+  LABEL_BRANCH,
+  LABEL_CONTAINER,
+  LABEL_RESOURCES,
+  LABEL_EPHEMERAL,
+  LABEL_CONFIG,
+  LABEL_RESTART,
+  LABEL_STOP_TIMEOUT,
+  formatConfigHash,
+  getBuildImageTag,
+  getContainerExitState,
+  describeExit
+} from '../utils/container-utils';
 import { isWorktree, getMainGitDirPath } from '../utils/worktree-utils';
-import { createLoggerFromCommandOptions } from '../utils/logger';
-import { processEnvironmentVariables, generateDevcontainerEnvFlags } from '../utils/env-utils';
+import { createLoggerFromCommandOptions, resolveLogFormat, LogFormat, Logger } from '../utils/logger';
+import { processEnvironmentVariables, generateDevcontainerEnvFlags, maskHostEnvValues, loadEnvFiles } from '../utils/env-utils';
+import {
+  resolveProfile,
+  applyProfileToConfig,
+  resolveRunCommand,
+  applyEnvProfiles,
+  resolveProfileMounts,
+  resolveProfileSecrets,
+  formatExcludeMounts,
+  mergeCliMounts,
+  formatMountArgs,
+  parseCacheVolumes,
+  CacheVolume,
+  validatePorts,
+  formatPortArgs,
+  formatHealthcheckArgs,
+  getHealthcheckDeadline,
+  formatResourceArgs,
+  formatResourceSummary,
+  formatNetworkArgs,
+  formatDockerAccessArgs,
+  validatePlatform,
+  validateRestartPolicy,
+  getHostPlatform,
+  isSamePlatform,
+  validateWorkdir,
+  getMountTargets,
+  isPathOnMount,
+  formatWorkdirCommand,
+  resolveContainerUser,
+  parseDuration,
+  ResolvedProfile
+} from '../utils/profile-utils';
+import { getContainerRuntime, getDevcontainerRuntimeArgs, getRuntimeSocketPath } from '../utils/container-runtime';
+import {
+  createProfileDevContainer,
+  getProfileDevContainerPath,
+  hasDevContainerOverrides,
+  applyDevContainerOverrides,
+  readDevContainerJson,
+  getContainerWorkspaceFolder,
+  getDevContainerRunCommands,
+  formatShellCommands
+} from '../utils/devcontainer-templates';
+import { readImageLock, resolveLockedImage, findLockDrift, parseImageReference, LOCK_FILE_NAME } from '../utils/image-lock';
+import { collectLockDigests } from './lock';
+import { buildProfileImage, resolveBuildPaths, BuildPaths } from '../utils/image-build';
+import { parseOutputFormat, OutputFormat, toDryRunJson, toRunEventJson, RunEventType, formatJson } from '../utils/output';
+import { createEventSocket } from '../utils/events';
+import { runProfiles, formatProfileRunResults } from './run-profiles';
+import { markWarningShown } from '../utils/state';
 import * as fs from 'fs';
 
+/**
+ * An error in the options or the config of a run, printed as is
+ */
+export class RunError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = 'RunError';
+  }
+}
+
+export interface SandboxRunResult {
+  containerId: string | null; // null for a dry run, or when the container could not be found by its labels
+  exitCode: number;           // Exit code of the command; 0 when it was not run
+}
+
 export const runCommand = new Command('run')
   .description('Run interactive container work using devcontainer exec')
-  .argument('[command...]', 'Command to run in container (defaults to shell)')
+  .addHelpText('after', `
+Containers are persistent by default: run starts the existing container of the
+workspace, branch and profile (creating it when there is none) and leaves it
+running afterwards. Stop it with "aisanity stop". When the existing container no
+longer matches the config (image or config files changed), run warns; pass
+--recreate to replace it. With --rm, run uses a new container that is removed
+when the command exits.
+
+A command replaces the profile command for this run. Everything after the first
+argument (or after --) is passed to it as is, e.g. "aisanity run -- npm test --watch".`)
+  .argument('[command...]', 'Command to run in container (defaults to the profile command, or a shell)')
+  .passThroughOptions()
   .option('--devcontainer-json <path>', 'Path to devcontainer.json file')
   .option('--force-recreate', 'Force recreation of branch-specific devcontainer file')
   .option('--worktree <path>', 'Run command in specific worktree')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .option('--profile <name>', 'Sandbox profile from .aisanity config (defaults to the "default" profile; repeat with --detach to start several at once)',
+          (value, previous: string[] = []) => [...previous, value])
   .option('--env <key=value>', 'Set environment variable (can be used multiple times). Bypasses whitelist filtering.', 
           (value, previous: string[] = []) => [...previous, value])
-  .option('--dry-run', 'Show environment variables that would be passed to container without executing command')
+  .option('--env-profile <name>', 'Layer an env bundle from envProfiles on top of the profile env (can be used multiple times, later ones win)',
+          (value, previous: string[] = []) => [...previous, value])
+  .option('--mount <source:target>', 'Bind mount for this run, on top of the profile mounts (can be used multiple times, e.g. ./data:/data:ro)',
+          (value, previous: string[] = []) => [...previous, value])
+  .option('--dry-run', 'Print the container runtime command that would start the sandbox, without starting anything')
+  .option('--show-secrets', 'With --dry-run, print environment values from the host and env files instead of masking them')
+  .option('--output <format>', 'With --dry-run, print the commands as text (default) or json')
+  .option('--platform <platform>', 'Image platform for this run, e.g. linux/amd64 (overrides the profile platform)')
+  .option('--pull <policy>', 'When to pull the image: missing (default, only when not present locally), always or never')
+  .option('--frozen', `Fail instead of warning when ${LOCK_FILE_NAME} does not match the config, build context or image`)
+  .option('--pull-timeout <duration>', 'Give up pulling a missing image after this long, retries included (e.g. 5m, default 10m)')
+  .option('--wait-healthy', 'Wait for the container healthcheck to pass before running the command (or returning with --detach)')
+  .option('--rm', 'Use a new container and remove it when the command exits, instead of reusing the persistent one')
+  .option('--recreate', 'Replace the existing container with a new one (e.g. after changing the config)')
+  .option('--auto-recreate', 'Replace the existing container only when it no longer matches the config, as autoRecreate: true does')
+  .option('--detach', 'Start the container in the background, print its ID and return (reconnect with "aisanity attach")')
   .option('-v, --verbose', 'Show detailed user information (container status, orphaned containers)')
   .option('-d, --debug', 'Show system debugging information (discovery process, timing)')
   .option('--silent, --quiet', 'Suppress aisanity output, show only tool output')
+  .option('--events-socket <path>', 'Write lifecycle events (created, started, healthy, exited, stopped, failed) as JSON lines to this Unix socket')
+  .option('--log-format <format>', 'Log format: text (default) or json, with one timing record per phase on stderr (or set AISANITY_LOG_FORMAT)')
   .action(async (commandArgs: string[], options) => {
-    // Initialize logger with factory function
-    const logger = createLoggerFromCommandOptions(options);
+    let logFormat: LogFormat;
+    try {
+      logFormat = resolveLogFormat(options.logFormat);
+    } catch (error) {
+      console.error(error instanceof Error ? error.message : String(error));
+      process.exit(1);
+    }
+
+    // Initialize logger with factory function; a dry run prints nothing but the command line
+    const logger = createLoggerFromCommandOptions({ ...options, logFormat, ...(options.dryRun ? { silent: true } : {}) });
     
-    let cwd = process.cwd();
+    let cwd = resolveWorkspaceOption(options);
     
     // Handle worktree option
     if (options.worktree) {
@@ -38,6 +173,8 @@ export const runCommand = new Command('run')
     }
     
     try {
+      const configStartTime = Date.now();
+      logger.setFields({ workspace: cwd });
       const config = loadAisanityConfig(cwd);
 
       if (!config) {
@@ -45,162 +182,579 @@ export const runCommand = new Command('run')
         process.exit(1);
       }
 
-      // Process environment variables
-      const cliEnvVars = options.env || [];
-      const envCollection = processEnvironmentVariables(config, cliEnvVars, {
-        dryRun: options.dryRun || false,
-        verbose: options.verbose && !options.silent && !options.quiet
-      });
-
-      // Handle dry-run mode
-      if (options.dryRun) {
-        if (!options.silent && !options.quiet) {
-          console.log('Environment variables that would be passed to container:');
-          if (Object.keys(envCollection.merged).length === 0) {
-            console.log('  None');
-          } else {
-            Object.entries(envCollection.merged).forEach(([key, value]) => {
-              console.log(`  ${key}=${value}`);
-            });
-          }
-          
-          if (envCollection.cli && Object.keys(envCollection.cli).length > 0) {
-            console.log('\nCLI environment variables:');
-            Object.entries(envCollection.cli).forEach(([key, value]) => {
-              console.log(`  ${key}=${value}`);
-            });
-          }
-          
-          if (envCollection.host && Object.keys(envCollection.host).length > 0) {
-            console.log('\nHost environment variables (from whitelist):');
-            Object.entries(envCollection.host).forEach(([key, value]) => {
-              console.log(`  ${key}=${value}`);
-            });
-          }
-          
-          if (envCollection.config && Object.keys(envCollection.config).length > 0) {
-            console.log('\nConfig environment variables:');
-            Object.entries(envCollection.config).forEach(([key, value]) => {
-              console.log(`  ${key}=${value}`);
-            });
-          }
+      // Several profiles: each is brought up by its own detached run, at the same time
+      const profileNames: string[] = options.profile || [];
+      if (profileNames.length > 1) {
+        if (!options.detach || commandArgs.length > 0 || options.rm || options.dryRun) {
+          console.error('Several --profile flags need --detach, and cannot be combined with a command, --rm or --dry-run.');
+          process.exit(1);
         }
-        process.exit(0);
-      }
-
-      const workspaceName = config.workspace;
-      const containerName = getContainerName(cwd, options.verbose || false);
-
-      // Default to bash shell if no command provided
-      const command = commandArgs.length > 0 ? commandArgs : ['bash'];
-
-      logger.info(`Starting container for workspace: ${workspaceName}`);
-      logger.info(`Running command: ${command.join(' ')}`);
-
-       // Check for existing container first
-       const branch = getCurrentBranch(cwd);
-       let containerLabels: Record<string, string> | ContainerLabels;
-       let idLabels: string[];
-       
-       try {
-         // Try to find existing container for this workspace and branch
-         const existingResult = await $`docker ps -a --filter label=aisanity.workspace=${cwd} --filter label=aisanity.branch=${branch} --format {{.Labels}}`.text();
-         
-         if (existingResult.trim()) {
-           // Parse existing container labels to reuse them
-           const existingLabels: Record<string, string> = {};
-           existingResult.trim().split(',').forEach((label: string) => {
-             const [key, value] = label.split('=');
-             if (key && value && key.startsWith('aisanity.')) {
-               existingLabels[key] = value;
-             }
-           });
-           
-            if (existingLabels['aisanity.workspace'] && existingLabels['aisanity.branch'] && existingLabels['aisanity.container']) {
-              logger.info('Found existing container, reusing labels');
-              containerLabels = existingLabels;
-             idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
-            } else {
-              // Generate new labels if existing ones are incomplete
-              containerLabels = await generateContainerLabels(workspaceName, branch, containerName, cwd);
-              idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
-            }
-          } else {
-            // No existing container, generate new labels
-            containerLabels = await generateContainerLabels(workspaceName, branch, containerName, cwd);
-            idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
-          }
+        // The runs share the terminal, so each keeps to warnings and errors
+        const startProfile = async (name: string) => (await runSandbox(
+          config, name, cwd, [], { ...options, quiet: true }, createLoggerFromCommandOptions({ ...options, logFormat, silent: true })
+        )).containerId;
+        let outcome: Awaited<ReturnType<typeof runProfiles>>;
+        try {
+          outcome = await runProfiles(config, profileNames, cwd, startProfile, options, logger);
         } catch (error) {
-          // If Docker command fails, generate new labels
-          containerLabels = await generateContainerLabels(workspaceName, branch, containerName, cwd);
-          idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
-        }
-
-       // Validate that all required labels are present
-       if (!validateContainerLabels(containerLabels)) {
-         console.error('Failed to generate required container labels');
-         process.exit(1);
-       }
-      
-      // Determine which devcontainer.json to use
-      let devcontainerPath: string;
-      if (options.devcontainerJson) {
-        devcontainerPath = path.resolve(options.devcontainerJson);
-        logger.info(`Using specified devcontainer: ${devcontainerPath}`);
-      } else {
-        // Use default devcontainer.json
-        const defaultPath = path.join(cwd, '.devcontainer', 'devcontainer.json');
-        if (!fs.existsSync(defaultPath)) {
-          console.error('No devcontainer.json found in .devcontainer/ directory.');
+          console.error(error instanceof Error ? error.message : String(error));
           process.exit(1);
         }
-        devcontainerPath = defaultPath;
-      }
-      
-       logger.info(`Starting devcontainer for branch '${branch}' with labels: ${idLabels.join(', ')}`);
-
-         // Check if we're in a git worktree and add mount for main repo .git directory
-        const additionalMounts: string[] = [];
-        if (isWorktree(cwd)) {
-          const mainGitDir = getMainGitDirPath(cwd);
-          if (mainGitDir) {
-            const mountSpec = `type=bind,source=${mainGitDir},target=${mainGitDir}`;
-            additionalMounts.push(mountSpec);
-            logger.info(`Detected git worktree, mounting main repo .git directory: ${mainGitDir}`);
+        formatProfileRunResults(outcome.results, outcome.rolledBack).forEach(line => console.log(line));
+        if (outcome.results.some(result => !result.container)) {
+          for (const result of outcome.results.filter(result => result.error)) {
+            console.error(`Profile '${result.profile}' failed to start:`);
+            console.error(result.error);
           }
+          process.exit(1);
         }
+        return;
+      }
 
-         // First, ensure the dev container is up and running
-         logger.info('Checking/starting dev container...');
-         const upArgs = ['up', '--workspace-folder', cwd];
+      const result = await runSandbox(config, profileNames[0], cwd, commandArgs, options, logger, configStartTime);
 
-       if (devcontainerPath) {
-         upArgs.push('--config', devcontainerPath);
-       }
+      // Detached runs return rather than exit, which lets restart report on the container afterwards
+      if (options.detach && !options.dryRun) {
+        console.log(result.containerId);
+        return;
+      }
+      process.exit(result.exitCode);
+
+    } catch (error) {
+      if (error instanceof RunError) {
+        console.error(error.message);
+      } else {
+        console.error('Failed to run container:', error);
+      }
+      process.exit(1);
+    }
+  });
 
-        // Add ID labels for consistent container identification
-        idLabels.forEach(label => {
-          upArgs.push('--id-label', label);
-        });
+/**
+ * Bring up the sandbox of one profile and, unless detached, run the command in it
+ * A plain run calls this once; run with several --profile flags calls it detached for each profile.
+ * @param options The run command options
+ * @throws RunError when the options or the config are invalid
+ */
+export async function runSandbox(
+  config: AisanityConfig,
+  profileName: string | undefined,
+  cwd: string,
+  commandArgs: string[],
+  options: any,
+  logger: Logger,
+  configStartTime: number = Date.now()
+): Promise<SandboxRunResult> {
+  // Resolve the sandbox profile (falls back to the 'default' profile)
+  let profile: ResolvedProfile;
+  try {
+    profile = resolveProfile(config, profileName);
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
 
-         // Add mounts for git worktree support
-         additionalMounts.forEach(mount => {
-           upArgs.push('--mount', mount);
-         });
+  // Only mention the profile when the config actually defines profiles
+  if (config.profiles) {
+    logger.info(`Using profile: ${profile.name}`);
+  }
 
-         // Add environment variables
-         const remoteEnvFlags = generateDevcontainerEnvFlags(envCollection.merged);
-         remoteEnvFlags.forEach(flag => {
-           upArgs.push(flag);
-         });
+  // Process environment variables
+  const cliEnvVars = options.env || [];
+  let fileEnv: Record<string, string>;
+  try {
+    fileEnv = loadEnvFiles(profile.envFile, cwd, profile.name);
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+  let envProfile: ResolvedProfile;
+  try {
+    envProfile = applyEnvProfiles(config, profile, options.envProfile || []);
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+  const envCollection = processEnvironmentVariables(applyProfileToConfig(config, envProfile), cliEnvVars, {
+    fileEnv,
+    dryRun: options.dryRun || false,
+    verbose: options.verbose && !options.silent && !options.quiet
+  });
+  logger.setFields({ profile: profile.name });
 
-         if (options.verbose && !options.silent && !options.quiet && Object.keys(envCollection.merged).length > 0) {
-           logger.info(`Passing ${Object.keys(envCollection.merged).length} environment variables to container`);
+  logger.phase('config_load', configStartTime);
+
+  let outputFormat: OutputFormat;
+  try {
+    outputFormat = parseOutputFormat(options.output);
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+  if (outputFormat === 'json' && !options.dryRun) {
+    throw new RunError('--output json is only supported together with --dry-run.');
+  }
+
+  let pullTimeout: number | undefined;
+  try {
+    pullTimeout = options.pullTimeout ? parseDuration(options.pullTimeout) : undefined;
+  } catch (error) {
+    throw new RunError(`--pull-timeout: ${error instanceof Error ? error.message : String(error)}`);
+  }
+
+  let pullPolicy: PullPolicy;
+  try {
+    pullPolicy = parsePullPolicy(options.pull);
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+
+  // Images for another architecture than the host's run under emulation
+  let platform: string | undefined;
+  try {
+    const requestedPlatform = options.platform ?? profile.platform;
+    platform = requestedPlatform !== undefined ? validatePlatform(requestedPlatform, profile.name) : undefined;
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+
+  // A restart policy brings the container back after it exits or the daemon restarts
+  let restartPolicy: string | undefined;
+  try {
+    restartPolicy = profile.restart !== undefined ? validateRestartPolicy(profile.restart, profile.name) : undefined;
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+  if (restartPolicy && restartPolicy !== 'no' && options.rm) {
+    throw new RunError(`Profile '${profile.name}' sets restart: ${restartPolicy}, which conflicts with --rm removing the container. Drop --rm or use a profile without a restart policy.`);
+  }
+
+  if (options.rm && options.detach) {
+    throw new RunError('--rm removes the container when the command exits, so it cannot be combined with --detach.');
+  }
+
+  // A detached run only starts the sandbox; commands are run later via attach or exec
+  if (options.detach && commandArgs.length > 0) {
+    throw new RunError('--detach starts the container without running a command. Use "aisanity attach" or "aisanity exec" once it is up.');
+  }
+
+  const workspaceName = config.workspace;
+  const containerName = getContainerName(cwd, options.verbose || false);
+
+  // Default to the profile command (or bash shell) if no command provided
+  const command = resolveRunCommand(commandArgs, profile, ['bash']);
+
+  logger.info(`Starting container for workspace: ${workspaceName}`);
+  logger.info(`Running command: ${formatShellCommands([command])}`);
+
+   // Check for existing container first
+   const branch = getCurrentBranch(cwd);
+   let containerLabels: Record<string, string> | ContainerLabels;
+   let idLabels: string[];
+   let existingContainer: DockerContainer | undefined;
+   
+   try {
+     // Try to find existing container for this workspace and branch
+     // (--rm always starts a new container, and ephemeral ones are never reused)
+     const existingResult = options.rm
+       ? []
+       : await listContainers({ all: true, labels: [`${LABEL_WORKSPACE}=${cwd}`, `${LABEL_BRANCH}=${branch}`] }, options.debug || false);
+     existingContainer = existingResult.find(container => !container.labels[LABEL_EPHEMERAL] && matchesProfile(container.labels, profile.name));
+     
+     // Keep the container's aisanity labels
+     let existingLabels: Record<string, string> | undefined;
+     if (existingContainer) {
+       existingLabels = {};
+       for (const [key, value] of Object.entries(existingContainer.labels)) {
+         if (value && key.startsWith('aisanity.')) {
+           existingLabels[key] = value;
          }
+       }
+     }
+     
+     if (existingLabels) {
+        if (existingLabels[LABEL_WORKSPACE] && existingLabels[LABEL_BRANCH] && existingLabels[LABEL_CONTAINER]) {
+          logger.info('Found existing container, reusing labels');
+          containerLabels = existingLabels;
+         idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
+        } else {
+          // Generate new labels if existing ones are incomplete
+          existingContainer = undefined;
+          containerLabels = await generateContainerLabels(workspaceName, branch, containerName, cwd, profile.name);
+          idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
+        }
+      } else {
+        // No existing container, generate new labels
+        containerLabels = await generateContainerLabels(workspaceName, branch, containerName, cwd, profile.name);
+        idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
+      }
+    } catch (error) {
+      // If Docker command fails, generate new labels
+      containerLabels = await generateContainerLabels(workspaceName, branch, containerName, cwd, profile.name);
+      idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
+    }
+
+   // Validate that all required labels are present
+   if (!validateContainerLabels(containerLabels)) {
+     throw new RunError('Failed to generate required container labels');
+   }
+
+  // Ephemeral containers are marked so later runs never pick them up for reuse
+  if (options.rm) {
+    containerLabels = { ...containerLabels, [LABEL_EPHEMERAL]: 'true' };
+    idLabels.push(`${LABEL_EPHEMERAL}=true`);
+  }
+  
+  // Determine which devcontainer.json to use
+  let devcontainerPath: string;
+  if (options.devcontainerJson) {
+    devcontainerPath = path.resolve(options.devcontainerJson);
+    logger.info(`Using specified devcontainer: ${devcontainerPath}`);
+  } else {
+    // Use default devcontainer.json
+    const defaultPath = path.join(cwd, '.devcontainer', 'devcontainer.json');
+    if (!fs.existsSync(defaultPath)) {
+      throw new RunError('No devcontainer.json found in .devcontainer/ directory.');
+    }
+    devcontainerPath = defaultPath;
+  }
+
+  // Tag-only images start from the digest pinned in .aisanity.lock, when there is one
+  let image = profile.image;
+  let declaredImage: string | undefined;
+  let pinnedDigest: string | undefined;
+  try {
+    // Built images are local, so the lock does not apply to them
+    declaredImage = profile.build ? undefined : profile.image || readDevContainerJson(devcontainerPath).image;
+    if (declaredImage) {
+      const resolved = resolveLockedImage(declaredImage, readImageLock(cwd));
+      if (resolved.image !== declaredImage) {
+        image = resolved.image;
+        pinnedDigest = resolved.digest;
+        logger.info(`Using image pinned in ${LOCK_FILE_NAME}: ${image}`);
+      }
+    }
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+
+  if (declaredImage && pinnedDigest) {
+    try {
+      const localDigest = await getLocalImageDigest(declaredImage, options.debug || false);
+      if (localDigest && localDigest !== pinnedDigest) {
+        logger.warn(`Local image ${declaredImage} is ${localDigest}, but ${LOCK_FILE_NAME} pins ${pinnedDigest}. Using the pinned image; run "aisanity pull" to update the lock.`);
+      }
+    } catch (error) {
+      // The runtime reports problems when the container starts
+    }
+  }
+
+  // Missing images are pulled here rather than by the devcontainer CLI, so flaky registries are retried
+  // and a local image is used without contacting the registry at all
+  const startImage = image;
+  if (declaredImage && startImage && !options.dryRun) {
+    let present = false;
+    try {
+      // With a platform, a local image for another platform is pulled again
+      const localPlatform = platform ? await getContainerRuntime().getImagePlatform(startImage, options.debug || false) : null;
+      present = platform
+        ? localPlatform !== null && isSamePlatform(localPlatform, platform)
+        : await getContainerRuntime().hasImage(startImage, options.debug || false);
+    } catch (error) {
+      throw new RunError(error instanceof Error ? error.message : String(error));
+    }
+
+    if (!present && pullPolicy === 'never') {
+      throw new RunError(`Image ${startImage}${platform ? ` (${platform})` : ''} is not available locally and --pull never was given. Run "aisanity pull" while online to cache it.`);
+    }
+    if (pullPolicy === 'always' || !present) {
+      try {
+        logger.info(`Pulling ${startImage}${platform ? ` for ${platform}` : ''}...`);
+        await logger.time('image_pull', () =>
+          pullImage(startImage, options.debug || false, { timeout: pullTimeout, platform, onRetry: message => logger.warn(message) })
+        );
+      } catch (error) {
+        throw new RunError(describePullFailure(startImage, error));
+      }
+    } else {
+      logger.verbose(`Using local image ${startImage}`);
+    }
+  }
+
+  // The lock also records the config and build context it was generated against; --frozen fails on any difference
+  let lockDrift: string[];
+  try {
+    const lock = readImageLock(cwd);
+    if (!lock) {
+      lockDrift = options.frozen ? [`there is no ${LOCK_FILE_NAME}`] : [];
+    } else {
+      lockDrift = findLockDrift(lock, collectLockDigests(config, cwd, profile.name), options.frozen || false);
+      if (options.frozen && declaredImage && !lock.images[declaredImage] && !parseImageReference(declaredImage).digest) {
+        lockDrift.push(`image ${declaredImage} is not pinned`);
+      }
+    }
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+  if (lockDrift.length > 0) {
+    if (options.frozen) {
+      throw new RunError(`${LOCK_FILE_NAME} does not match the workspace (--frozen): ${lockDrift.join('; ')}. Run "aisanity lock" to regenerate it.`);
+    }
+    logger.warn(`${LOCK_FILE_NAME} is out of date: ${lockDrift.join('; ')}. Run "aisanity lock" to regenerate it.`);
+  }
+
+  // Profiles with a build block start from their image, rebuilt when the Dockerfile or context changed
+  let buildPaths: BuildPaths | undefined;
+  const build = profile.build;
+  if (build) {
+    try {
+      buildPaths = resolveBuildPaths(build, cwd, profile.name);
+      image = getBuildImageTag(workspaceName, cwd, profile.name);
+      if (!options.dryRun) {
+        const result = await logger.time('image_build', () =>
+          buildProfileImage(build, profile.name, workspaceName, cwd, { debug: options.debug || false, platform })
+        );
+        logger.info(result.built ? `Built ${image}` : `Using built image ${image}`);
+      }
+    } catch (error) {
+      throw new RunError(error instanceof Error ? error.message : String(error));
+    }
+  }
+
+  // Published ports become docker run -p flags in the profile devcontainer file
+  const portMappings = validatePorts(profile.ports || [], profile.name);
+
+  // Profile bind mounts become docker run --mount flags, since the devcontainer CLI
+  // --mount option cannot express readonly or consistency. Sources must exist before starting.
+  let profileMounts: string[];
+  let cacheVolumes: CacheVolume[];
+  let healthcheckArgs: string[];
+  let resourceArgs: string[];
+  let networkArgs: string[];
+  let dockerAccessArgs: string[] = [];
+  let containerUser: string | undefined;
+  let workspaceFolder: string;
+  let workdir: string;
+  try {
+    profileMounts = resolveProfileMounts(
+      mergeCliMounts(profile.mounts || [], options.mount || [], process.cwd()),
+      cwd,
+      profile.name,
+      options.dryRun || false
+    );
+    profileMounts.push(...resolveProfileSecrets(profile.secrets || [], cwd, profile.name));
+    // Excluded paths get anonymous volumes inside the workspace mount, which docker applies first
+    workspaceFolder = getContainerWorkspaceFolder(readDevContainerJson(devcontainerPath), cwd);
+    workdir = profile.workdir !== undefined ? validateWorkdir(profile.workdir, profile.name) : workspaceFolder;
+    profileMounts.push(...formatExcludeMounts(profile.exclude || [], workspaceFolder, profile.name));
+    cacheVolumes = parseCacheVolumes(profile.cacheVolumes, profile.name);
+    healthcheckArgs = profile.healthcheck ? formatHealthcheckArgs(profile.healthcheck, profile.name) : [];
+    resourceArgs = formatResourceArgs(profile.resources || {}, profile.name);
+    networkArgs = formatNetworkArgs(profile, profile.name);
+    if (profile.dockerAccess) {
+      dockerAccessArgs = formatDockerAccessArgs(getRuntimeSocketPath(getContainerRuntime().name), profile.name, options.dryRun || false);
+    }
+    containerUser = resolveContainerUser(profile.user, profile.name, getContainerRuntime().name);
+  } catch (error) {
+    throw new RunError(error instanceof Error ? error.message : String(error));
+  }
+
+  if (profile.dockerAccess) {
+    logger.warn(`WARNING: profile '${profile.name}' mounts the host Docker socket (dockerAccess). Anything in the sandbox can control the host's containers and, through them, the host.`);
+  }
+
+  // Cache volumes are named per workspace and created up front so they carry aisanity labels
+  const namedCacheVolumes = cacheVolumes.map(cache => ({
+    ...cache,
+    volume: getCacheVolumeName(workspaceName, cwd, cache.name)
+  }));
+  if (!options.dryRun) {
+    const createdVolumes = await ensureCacheVolumes(namedCacheVolumes, cwd, options.debug || false);
+    createdVolumes.forEach(volume => logger.info(`Created cache volume: ${volume}`));
+  }
+  profileMounts.push(...namedCacheVolumes.map(cache => `type=volume,source=${cache.volume},target=${cache.target}`));
+
+  // Check if we're in a git worktree and add mount for main repo .git directory
+  const additionalMounts: string[] = [];
+  if (isWorktree(cwd)) {
+    const mainGitDir = getMainGitDirPath(cwd);
+    if (mainGitDir) {
+      const mountSpec = `type=bind,source=${mainGitDir},target=${mainGitDir}`;
+      additionalMounts.push(mountSpec);
+      logger.info(`Detected git worktree, mounting main repo .git directory: ${mainGitDir}`);
+    }
+  }
+
+  // Files written outside of every mount are lost when the container is recreated
+  if (!isPathOnMount(workdir, [workspaceFolder, ...getMountTargets([...profileMounts, ...additionalMounts])])) {
+    logger.warn(`Profile '${profile.name}': workdir ${workdir} is not on a mount, so files written there are lost when the container is recreated.`);
+  }
+
+  // The settings a container is created with are hashed into a label, so a reused container can be checked against the config
+  const configHash = formatConfigHash({
+    devcontainer: readDevContainerJson(devcontainerPath),
+    // Env bundles reach the command through exec each run, so they are left out
+    env: { ...envCollection.file, ...(applyProfileToConfig(config, profile).env || {}) },
+    mounts: [...profileMounts, ...additionalMounts],
+    ports: portMappings,
+    resources: profile.resources,
+    // The Docker socket is access to the host, so it counts as network
+    network: [...networkArgs, ...dockerAccessArgs],
+    user: containerUser,
+    healthcheck: profile.healthcheck,
+    runArgs: profile.runArgs,
+    platform,
+    workdir: profile.workdir,
+    restart: restartPolicy
+  });
+
+  // A reused container keeps the image and settings it was created with
+  let createsContainer = !existingContainer;
+  if (existingContainer && !options.dryRun) {
+    const drift = detectContainerDrift(existingContainer, {
+      image: image || declaredImage,
+      configFiles: [getAisanityConfigPath(cwd), getUserConfigPath(), devcontainerPath].filter((file): file is string => Boolean(file)),
+      configHash
+    });
+
+    if (options.recreate || ((config.autoRecreate || options.autoRecreate) && drift.length > 0)) {
+      logger.info(`Recreating container ${existingContainer.name}${drift.length > 0 ? `: ${drift.join('; ')}` : ''}`);
+      await removeSandbox(existingContainer.id, config.stopTimeout, options.debug || false);
+      createsContainer = true;
+      containerLabels = await generateContainerLabels(workspaceName, branch, containerName, cwd, profile.name);
+      idLabels = Object.entries(containerLabels).map(([key, value]) => `${key}=${value}`);
+    } else if (drift.length > 0) {
+      logger.warn(`Container ${existingContainer.name} no longer matches the config (${drift.join('; ')}). Run with --recreate to replace it, or set autoRecreate: true in .aisanity.`);
+    } else if (options.mount) {
+      // Mounts are fixed when a container is created
+      logger.warn(`--mount only applies to new containers, and ${existingContainer.name} is reused. Add --recreate or --rm to mount ${options.mount.join(', ')}.`);
+    }
+  }
+
+  // Generate a profile-specific devcontainer file when the profile overrides it
+  // (or when the container runtime needs its run flags translated)
+  const runtime = getContainerRuntime();
+  // Resource limits are also recorded in a label so status can show them
+  const resourceSummary = formatResourceSummary(profile.resources);
+  const devcontainerOverrides = {
+    image,
+    runArgs: [
+      ...formatPortArgs(portMappings),
+      ...formatMountArgs(profileMounts),
+      ...healthcheckArgs,
+      ...resourceArgs,
+      ...networkArgs,
+      ...dockerAccessArgs,
+      ...(platform ? ['--platform', platform] : []),
+      ...(restartPolicy ? ['--restart', restartPolicy, '--label', `${LABEL_RESTART}=${restartPolicy}`] : []),
+      // The container working directory is also where aisanity exec sessions start
+      '--workdir', workdir,
+      ...(resourceSummary ? ['--label', `${LABEL_RESOURCES}=${resourceSummary}`] : []),
+      // stop --all has no workspace config to read the grace period from
+      ...(config.stopTimeout !== undefined ? ['--label', `${LABEL_STOP_TIMEOUT}=${config.stopTimeout}`] : []),
+      '--label', `${LABEL_CONFIG}=${configHash}`
+    ],
+    user: containerUser,
+    translateRunArgs: runtime.translateRunArgs?.bind(runtime),
+    extraRunArgs: profile.runArgs
+  };
+  // Dry run: print the equivalent runtime command line, with host and env file values masked unless asked for
+  if (options.dryRun) {
+    const content = applyDevContainerOverrides(readDevContainerJson(devcontainerPath), devcontainerOverrides);
+    const env = options.showSecrets ? envCollection.merged : maskHostEnvValues(envCollection.merged, { ...envCollection.file, ...envCollection.host });
+    const commands = getDevContainerRunCommands(runtime.command, content, path.dirname(devcontainerPath), {
+      workspacePath: cwd,
+      labels: idLabels,
+      env,
+      mounts: additionalMounts,
+      command
+    });
+    if (buildPaths && image) {
+      commands.unshift([runtime.command, 'build', '-f', buildPaths.dockerfile, '-t', image, buildPaths.context]);
+    }
+    if (outputFormat === 'json') {
+      console.log(formatJson(toDryRunJson({
+        workspace: cwd,
+        profile: profile.name,
+        image: image || content.image || null,
+        labels: idLabels,
+        command,
+        runArgs: profile.runArgs || [],
+        commands
+      })));
+    } else {
+      console.log(formatShellCommands(commands));
+      // The extra flags are not checked by aisanity, so they are called out separately
+      if (profile.runArgs && profile.runArgs.length > 0) {
+        console.log(`# runArgs from profile '${profile.name}', passed through unvalidated: ${formatShellCommands([profile.runArgs])}`);
+      }
+    }
+    return { containerId: null, exitCode: 0 };
+  }
+
+  if (hasDevContainerOverrides(devcontainerOverrides)) {
+    const profileDevcontainerPath = getProfileDevContainerPath(devcontainerPath, profile.name);
+    createProfileDevContainer(devcontainerPath, profileDevcontainerPath, devcontainerOverrides);
+    devcontainerPath = profileDevcontainerPath;
+    logger.info(`Using profile devcontainer: ${profileDevcontainerPath}`);
+  }
+  
+  // The container of this run is the one carrying exactly its ID labels
+  // (with --rm, the persistent container of the same profile may be running too)
+  const findSandbox = async (): Promise<string | null> =>
+    (await listContainers({ labels: idLabels }, options.debug || false))[0]?.id ?? null;
+
+  // Only recorded as shown once every option and lock check passed, so a run that fails on them still warns next time
+  if (platform && !isSamePlatform(platform, getHostPlatform())) {
+    try {
+      if (await markWarningShown(cwd, `emulation:${platform}`)) {
+        logger.warn(`Platform ${platform} differs from the host platform ${getHostPlatform()}, so the container runs under emulation and will be slower. This warning is only shown once.`);
+      }
+    } catch (error) {
+      // The warning is skipped while another command holds the state lock
+    }
+  }
 
-       // Determine if silent mode is enabled
-       const isSilent = options.silent || options.quiet || false;
+  // Editors can follow the sandbox lifecycle on a socket; a missing or slow listener never holds up the run.
+  // It is opened once the options are checked, so a run rejected up front sends nothing.
+  const events = options.eventsSocket
+    ? createEventSocket(path.resolve(options.eventsSocket), message => logger.warn(message))
+    : null;
+  let sandboxId: string | null = null;
+  const emitEvent = (event: RunEventType, container: string | null, details: { exitCode?: number; error?: string } = {}) =>
+    events?.emit(toRunEventJson(event, { workspace: cwd, profile: profile.name, container, ...details }));
 
+  try {
+     logger.info(`Starting devcontainer for branch '${branch}' with labels: ${idLabels.join(', ')}`);
+
+       // First, ensure the dev container is up and running
+       logger.info('Checking/starting dev container...');
+       const upArgs = ['up', '--workspace-folder', cwd, ...getDevcontainerRuntimeArgs(runtime)];
+
+     if (devcontainerPath) {
+       upArgs.push('--config', devcontainerPath);
+     }
+
+      // Add ID labels for consistent container identification
+      idLabels.forEach(label => {
+        upArgs.push('--id-label', label);
+      });
+
+       // Add mounts for git worktree support
+       additionalMounts.forEach(mount => {
+         upArgs.push('--mount', mount);
+       });
+
+       // Add environment variables
+       const remoteEnvFlags = generateDevcontainerEnvFlags(envCollection.merged);
+       remoteEnvFlags.forEach(flag => {
+         upArgs.push(flag);
+       });
+
+       if (options.verbose && !options.silent && !options.quiet && Object.keys(envCollection.merged).length > 0) {
+         logger.info(`Passing ${Object.keys(envCollection.merged).length} environment variables to container`);
+       }
+
+     // Determine if silent mode is enabled
+     const isSilent = options.silent || options.quiet || false;
+
+    await logger.time('container_create', async () => {
       const upResult = Bun.spawn(['devcontainer', ...upArgs], {
         stdio: isSilent ? ['inherit', 'pipe', 'pipe'] : ['inherit', 'inherit', 'inherit'],
         cwd
@@ -210,43 +764,138 @@ export const runCommand = new Command('run')
       if (upExitCode !== 0) {
         throw new Error(`devcontainer up failed with code ${upExitCode}`);
       }
+    });
 
-      logger.info('Dev container is ready');
+    logger.info('Dev container is ready');
 
-        // Now execute the command in the running container
-       const execArgs = [
-         'exec',
-         '--workspace-folder', cwd
-       ];
+    // aisanity stop clears an always/unless-stopped policy so the daemon leaves the container stopped; set it again
+    if (restartPolicy && !createsContainer && existingContainer?.labels[LABEL_RESTART] === restartPolicy) {
+      try {
+        await runtime.setRestartPolicy(existingContainer.id, restartPolicy, options.debug || false);
+      } catch (error) {
+        logger.warn(`Could not set restart policy ${restartPolicy} on ${existingContainer.name}: ${error instanceof Error ? error.message : String(error)}`);
+      }
+    }
 
-       if (devcontainerPath) {
-         execArgs.push('--config', devcontainerPath);
-       }
+    // Looked up once; the ID is reported in events and returned to the caller
+    sandboxId = await findSandbox();
+    if (createsContainer) {
+      emitEvent('created', sandboxId);
+    }
+    emitEvent('started', sandboxId);
+
+    // Report host ports docker picked for ports declared without a host port
+    const randomPorts = portMappings.filter(mapping => !mapping.hostPort);
+    if (randomPorts.length > 0 && sandboxId) {
+      for (const mapping of randomPorts) {
+        const containerPort = `${mapping.containerPort}/${mapping.protocol}`;
+        const bindings = await getPublishedPorts(sandboxId, containerPort, options.debug || false);
+        if (bindings.length > 0) {
+          logger.info(`Port ${containerPort} is published on ${bindings.join(', ')}`);
+        }
+      }
+    }
 
-        // Add ID labels for consistent container identification
-        idLabels.forEach(label => {
-          execArgs.push('--id-label', label);
-        });
+    // Hold the command (or the detached return) until the healthcheck passes
+    if (options.waitHealthy) {
+      if (!sandboxId) {
+        throw new Error('Container started but could not be found by its labels');
+      }
 
-        // Add environment variables
-        remoteEnvFlags.forEach(flag => {
-          execArgs.push(flag);
-        });
+      logger.info('Waiting for the container to become healthy...');
+      const health = await waitForHealthy(sandboxId, getHealthcheckDeadline(profile.healthcheck), options.debug || false);
+      if (!health) {
+        throw new RunError('--wait-healthy needs a healthcheck. Add a healthcheck block to the profile.');
+      }
+      if (health.status !== 'healthy') {
+        const last = health.log[health.log.length - 1];
+        throw new RunError([
+          `Container did not become healthy (status: ${health.status}).`,
+          ...(last ? [`Last health check output (exit code ${last.exitCode}):`, last.output.trimEnd()] : [])
+        ].join('\n'));
+      }
+      logger.info('Container is healthy');
+      emitEvent('healthy', sandboxId);
+    } else if (events && sandboxId && profile.healthcheck && !options.detach) {
+      // Reported in the background while the command runs, whenever the healthcheck first passes
+      waitForHealthy(sandboxId, getHealthcheckDeadline(profile.healthcheck), options.debug || false)
+        .then(health => health?.status === 'healthy' && emitEvent('healthy', sandboxId))
+        .catch(() => {});
+    }
 
-       execArgs.push(...command);
+    // Detached: the container keeps running in the background and is found again by its labels
+    if (options.detach) {
+      if (!sandboxId) {
+        throw new Error('Container started but could not be found by its labels');
+      }
+      return { containerId: sandboxId, exitCode: 0 };
+    }
 
-      // Spawn devcontainer exec process
-      const child = Bun.spawn(['devcontainer', ...execArgs], {
-        stdio: ['inherit', 'inherit', 'inherit'],
-        cwd
+      // Now execute the command in the running container
+     const execArgs = [
+       'exec',
+       '--workspace-folder', cwd,
+       ...getDevcontainerRuntimeArgs(runtime)
+     ];
+
+     if (devcontainerPath) {
+       execArgs.push('--config', devcontainerPath);
+     }
+
+      // Add ID labels for consistent container identification
+      idLabels.forEach(label => {
+        execArgs.push('--id-label', label);
       });
 
-      // Wait for process to exit
-      const exitCode = await child.exited;
-      process.exit(exitCode || 0);
+      // Add environment variables
+      remoteEnvFlags.forEach(flag => {
+        execArgs.push(flag);
+      });
 
-    } catch (error) {
-      console.error('Failed to run container:', error);
-      process.exit(1);
+     // devcontainer exec starts in the workspace folder
+     execArgs.push(...(workdir === workspaceFolder ? command : formatWorkdirCommand(command, workdir)));
+
+    // Spawn devcontainer exec process
+    const execStartTime = Date.now();
+    const child = Bun.spawn(['devcontainer', ...execArgs], {
+      stdio: ['inherit', 'inherit', 'inherit'],
+      cwd
+    });
+
+    // Ctrl-C and SIGTERM are forwarded to the command and stop the container, so an
+    // interrupted run does not leave the sandbox running behind the CLI
+    const cancellation = createSignalContext();
+    cancellation.signal.addEventListener('abort', () => logger.info(`\nReceived ${cancellation.signal.reason}, stopping the container...`));
+    // A failed command gets a reason when the container state has one, e.g. an OOM kill
+    const explainExit = async (code: number) => {
+      if (code === 0) {
+        return;
+      }
+      // The container may have stopped with the command, so stopped ones are looked up too
+      const containerId = (await listContainers({ labels: idLabels, all: true }, options.debug || false))[0]?.id;
+      const state = containerId ? await getContainerExitState(containerId, options.debug || false) : null;
+      const diagnostic = describeExit(code, state, profile.resources?.memory);
+      if (diagnostic) {
+        logger.warn(diagnostic);
+      }
+    };
+    const exitCode = await waitForAttachedProcess(
+      child,
+      findSandbox,
+      cancellation.signal,
+      { stopTimeout: config.stopTimeout, remove: options.rm || false, debug: options.debug || false, onExit: explainExit }
+    );
+    cancellation.dispose();
+    logger.phase('exec', execStartTime, { exitCode });
+    emitEvent('exited', sandboxId, { exitCode: exitCode || 0 });
+    if (options.rm || cancellation.signal.aborted) {
+      emitEvent('stopped', sandboxId);
     }
-  });
\ No newline at end of file
+    return { containerId: sandboxId, exitCode: exitCode || 0 };
+  } catch (error) {
+    emitEvent('failed', sandboxId, { error: error instanceof Error ? error.message : String(error) });
+    throw error;
+  } finally {
+    await events?.close();
+  }
+}
\ No newline at end of file
@@ -18,9 +18,9 @@ export const startAndAttachCommand = new Command("start-and-attach")
     logger.info("Starting opencode serve in background...");
 
     if (options.dryRun) {
-      console.log("Would run: aisanity run opencode serve (background)");
-      console.log(`Would wait up to ${timeout}s for discovery with ${retryInterval}s intervals`);
-      console.log("Would then run: opencode attach <discovered-host:port>");
+      logger.info("Would run: aisanity run opencode serve (background)");
+      logger.info(`Would wait up to ${timeout}s for discovery with ${retryInterval}s intervals`);
+      logger.info("Would then run: opencode attach <discovered-host:port>");
       return;
     }
 
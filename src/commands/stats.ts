@@ -2,6 +2,7 @@ import { Command } from 'commander';
 import * as fs from 'fs';
 import * as path from 'path';
 import { createLoggerFromCommandOptions } from '../utils/logger';
+import { print } from '../utils/display';
 
 interface MessageData {
   id?: string;
@@ -100,11 +101,11 @@ export async function generateStats(options: StatsOptions): Promise<void> {
     findJsonFiles(opencodeStoragePath);
 
     if (files.length === 0) {
-      console.log('No OpenCode message files found');
+      print('No OpenCode message files found');
       return;
     }
 
-    console.log(`Processing OpenCode message files...`);
+    print(`Processing OpenCode message files...`);
     
     const cutoffDate = new Date();
     
@@ -254,7 +255,7 @@ export async function generateStats(options: StatsOptions): Promise<void> {
     }
 
     if (processedFiles === 0) {
-      console.log(`No messages found in the last ${days} days`);
+      print(`No messages found in the last ${days} days`);
       return;
     }
 
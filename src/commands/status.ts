@@ -1,12 +1,31 @@
 import { Command } from 'commander';
-import { execSync } from 'child_process';
 import * as path from 'path';
 import * as fs from 'fs';
-import { loadAisanityConfig, getContainerName, getCurrentBranch } from '../utils/config';
+import { loadAisanityConfig, getContainerName, getCurrentBranch, resolveWorkspaceOption, getAisanityConfigPath } from '../utils/config';
 import { getAllWorktrees, getWorktreeName, WorktreeInfo, WorktreeList } from '../utils/worktree-utils';
-import { executeDockerCommand, discoverWorkspaceContainers, Container, discoverAllAisanityContainers } from '../utils/container-utils';
+import {
+  executeDockerCommand,
+  discoverWorkspaceContainers,
+  Container,
+  discoverAllAisanityContainers,
+  discoverByLabels,
+  listContainers,
+  DockerContainer,
+  LABEL_WORKSPACE,
+  LABEL_BRANCH,
+  LABEL_PROFILE,
+  LABEL_VERSION,
+  LABEL_RESOURCES,
+  LABEL_RESTART,
+  parseContainerState
+} from '../utils/container-utils';
+import { print, symbol } from '../utils/display';
 import { createLoggerFromCommandOptions } from '../utils/logger';
 import { formatOrphanedContainerInfo } from '../utils/logger-helpers';
+import { parseOutputFormat, OutputFormat, toStatusJson, formatJson } from '../utils/output';
+
+// Kept here for callers that import it from the status command
+export { parseContainerState };
 
 // Internal interfaces for status display
 
@@ -15,7 +34,7 @@ export interface WorkspaceStatusRow {
   workspace: string;      // Workspace name (from config.workspace)
   branch: string;         // Branch name (from aisanity.branch label or detected)
   container: string;      // Container name
-  worktreeStatus: string; // "✅ worktree-name" | "❌ none"
+  worktreeStatus: string; // "✅ worktree-name" | "❌ none", or their --no-emoji fallbacks
   status: string;         // Container status (Running/Stopped/Not created)
   ports: string;          // Port information
   isCurrentWorktree: boolean;  // Whether branch matches current active worktree
@@ -23,6 +42,17 @@ export interface WorkspaceStatusRow {
   hasWarning: boolean;    // Indicates if container has label issues
 }
 
+// Host-wide sandbox row (status --all)
+export interface SandboxStatusRow {
+  workspace: string;      // Absolute workspace path (from aisanity.workspace label)
+  profile: string;        // Profile name (from aisanity.profile label)
+  state: string;          // Container state (running/exited/created/...)
+  uptime: string;         // Time since start for running containers, otherwise "-"
+  ports: string;          // Published ports
+  resources: string;      // Resource limits (from aisanity.resources label), or "-"
+  restart: string;        // Restart policy (from aisanity.restart label), or "-"
+}
+
 // Container label validation interface
 export interface ContainerLabelValidation {
   isValid: boolean;
@@ -72,18 +102,18 @@ export function validateAndExtractLabels(
   let detectionMethod: ContainerLabelValidation['detectionMethod'] = 'unknown';
   
   // Check workspace label
-  const hasWorkspaceLabel = !!container.labels['aisanity.workspace'];
+  const hasWorkspaceLabel = !!container.labels[LABEL_WORKSPACE];
   if (!hasWorkspaceLabel) {
-    warnings.push(`Container ${container.name} missing aisanity.workspace label`);
+    warnings.push(`Container ${container.name} missing ${LABEL_WORKSPACE} label`);
   }
   
   // Check branch label (preferred method)
-  const hasBranchLabel = !!container.labels['aisanity.branch'];
+  const hasBranchLabel = !!container.labels[LABEL_BRANCH];
   if (hasBranchLabel) {
-    detectedBranch = container.labels['aisanity.branch'];
+    detectedBranch = container.labels[LABEL_BRANCH];
     detectionMethod = 'label';
   } else {
-    warnings.push(`Container ${container.name} missing aisanity.branch label`);
+    warnings.push(`Container ${container.name} missing ${LABEL_BRANCH} label`);
     
     // Fallback 1: Parse from container name pattern
     const nameMatch = container.name.match(/^aisanity-(.+)$/);
@@ -150,7 +180,7 @@ export function shouldIncludeContainer(
   };
   
   const normalizedExpected = normalizePath(expectedWorkspace);
-  const containerWorkspace = container.labels['aisanity.workspace'];
+  const containerWorkspace = container.labels[LABEL_WORKSPACE];
   
   // Include if workspace label matches (after normalization)
   if (containerWorkspace && normalizePath(containerWorkspace) === normalizedExpected) {
@@ -296,10 +326,10 @@ export async function groupContainersByWorkspace(
         let isCurrentWorktree: boolean;
         try {
           const status = resolveWorktreeStatus(branch, worktreeMap);
-          worktreeStatus = status.exists ? `✅ ${status.name}` : '❌ none';
+          worktreeStatus = status.exists ? `${symbol('success')} ${status.name}` : `${symbol('failure')} none`;
           isCurrentWorktree = status.isActive;
         } catch (error) {
-          worktreeStatus = '❓ error';
+          worktreeStatus = `${symbol('unknown')} error`;
           isCurrentWorktree = false;
           warnings.push({
             type: 'worktree_resolution',
@@ -353,15 +383,150 @@ export async function groupContainersByWorkspace(
   }
 }
 
+/**
+ * Build sandbox rows for every labeled container, sorted by workspace path
+ */
+export function buildSandboxStatusRows(containers: DockerContainer[]): SandboxStatusRow[] {
+  const rows = containers
+    .filter(container => container.labels[LABEL_WORKSPACE])
+    .map(container => {
+      const { state, uptime } = parseContainerState(container.status || '');
+      return {
+        workspace: container.labels[LABEL_WORKSPACE],
+        profile: container.labels[LABEL_PROFILE] || 'default',
+        state,
+        uptime,
+        ports: container.ports && container.ports.trim() ? container.ports.trim() : '-',
+        resources: container.labels[LABEL_RESOURCES] || '-',
+        restart: container.labels[LABEL_RESTART] || '-'
+      };
+    });
+
+  rows.sort((a, b) => {
+    if (a.workspace !== b.workspace) return a.workspace.localeCompare(b.workspace);
+    return a.profile.localeCompare(b.profile);
+  });
+
+  return rows;
+}
+
+/**
+ * Format sandbox rows into a table
+ * Columns are sized to their longest value so long workspace paths never break alignment
+ */
+export function formatSandboxTable(rows: SandboxStatusRow[]): string {
+  const columns: { key: keyof SandboxStatusRow; title: string }[] = [
+    { key: 'workspace', title: 'Workspace' },
+    { key: 'profile', title: 'Profile' },
+    { key: 'state', title: 'State' },
+    { key: 'uptime', title: 'Uptime' },
+    { key: 'ports', title: 'Ports' },
+    { key: 'resources', title: 'Resources' },
+    { key: 'restart', title: 'Restart' }
+  ];
+
+  const widths = columns.map(column =>
+    Math.max(getDisplayWidth(column.title), ...rows.map(row => getDisplayWidth(row[column.key])))
+  );
+
+  const border = (left: string, middle: string, right: string): string =>
+    left + widths.map(width => '─'.repeat(width + 2)).join(middle) + right;
+  const line = (values: string[]): string =>
+    '│ ' + values.map((value, i) => padToDisplayWidth(value, widths[i])).join(' │ ') + ' │';
+
+  let table = border('┌', '┬', '┐') + '\n';
+  table += line(columns.map(column => column.title)) + '\n';
+  table += border('├', '┼', '┤') + '\n';
+
+  for (const row of rows) {
+    table += line(columns.map(column => row[column.key])) + '\n';
+  }
+
+  return table + border('└', '┴', '┘');
+}
+
+/**
+ * Display every aisanity sandbox on the host, regardless of the current directory
+ */
+async function displayAllSandboxesStatus(verbose: boolean, debug: boolean): Promise<void> {
+  const containers = await discoverByLabels(debug);
+  const rows = buildSandboxStatusRows(containers);
+
+  if (rows.length === 0) {
+    console.log('No aisanity sandboxes found');
+    return;
+  }
+
+  console.log(formatSandboxTable(rows));
+
+  const running = rows.filter(row => row.state === 'running').length;
+  const workspaces = new Set(rows.map(row => row.workspace)).size;
+  console.log(`\nTotal: ${rows.length} containers across ${workspaces} workspace(s) (${running} running, ${rows.length - running} not running)`);
+
+  if (verbose) {
+    for (const container of containers) {
+      console.log(`  ${container.name} (${container.id}): ${container.labels[LABEL_WORKSPACE]}`);
+    }
+  }
+}
+
+/**
+ * Print the containers of a workspace (and its worktrees), or of the whole host, as JSON
+ */
+async function displayStatusJson(workspacePath: string | null, includeWorktrees: boolean, debug: boolean): Promise<void> {
+  if (!workspacePath) {
+    console.log(formatJson(toStatusJson(null, await discoverByLabels(debug))));
+    return;
+  }
+
+  let paths = [workspacePath];
+  if (includeWorktrees) {
+    try {
+      const worktrees = getAllWorktrees(workspacePath);
+      paths = [worktrees.main.path, ...worktrees.worktrees.map(worktree => worktree.path)];
+    } catch (error) {
+      // Not a git repository: only the workspace itself has containers
+    }
+  }
+
+  const containers: DockerContainer[] = [];
+  for (const containerPath of paths) {
+    containers.push(...await listContainers({ all: true, labels: [`${LABEL_WORKSPACE}=${containerPath}`] }, debug));
+  }
+  console.log(formatJson(toStatusJson(workspacePath, containers)));
+}
+
 export const statusCommand = new Command('status')
   .description('Display the status of all containers used for the current workspace')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
   .option('--worktree <path>', 'Show status for specific worktree')
+  .option('--all', 'Show every aisanity sandbox on this host (works outside a workspace)')
+  .option('--output <format>', 'Output format: text or json')
   .option('-v, --verbose', 'Show detailed user information (container status, orphaned containers)')
   .option('-d, --debug', 'Show system debugging information (discovery process, timing)')
   .action(async (options) => {
     const logger = createLoggerFromCommandOptions(options);
-    let cwd = process.cwd();
     let worktrees: WorktreeList | null = null;
+
+    let outputFormat: OutputFormat;
+    try {
+      outputFormat = parseOutputFormat(options.output);
+    } catch (error) {
+      console.error(error instanceof Error ? error.message : String(error));
+      process.exit(1);
+    }
+    
+    // Host-wide listing does not need a workspace config
+    if (options.all) {
+      if (outputFormat === 'json') {
+        await displayStatusJson(null, false, options.debug || false);
+        return;
+      }
+      await displayAllSandboxesStatus(options.verbose || false, options.debug || false);
+      return;
+    }
+
+    let cwd = resolveWorkspaceOption(options);
     
     // Handle worktree option - maintain existing behavior
     if (options.worktree) {
@@ -369,12 +534,21 @@ export const statusCommand = new Command('status')
       if (!fs.existsSync(worktreePath)) {
         throw new Error(`Worktree path does not exist: ${worktreePath}`);
       }
-      logger.info(`Showing status for worktree: ${worktreePath}`);
       cwd = worktreePath;
+      if (outputFormat === 'json') {
+        await displayStatusJson(cwd, false, options.debug || false);
+        return;
+      }
+      logger.info(`Showing status for worktree: ${worktreePath}`);
       await displaySingleWorktreeStatus(cwd, options.verbose || false, options.debug || false);
       return;
     }
     
+    if (outputFormat === 'json') {
+      await displayStatusJson(cwd, true, options.debug || false);
+      return;
+    }
+
     try {
       // Get all worktrees to determine display format (cache result to avoid duplicate calls)
       worktrees = getAllWorktrees(cwd);
@@ -408,7 +582,7 @@ export const statusCommand = new Command('status')
               workspace: workspaceName,
               branch: worktrees.main.branch || 'unknown',
               container: worktrees.main.containerName || 'unknown',
-              worktreeStatus: '✅ main',
+              worktreeStatus: `${symbol('success')} main`,
               status: 'Unknown',
               ports: '-',
               isCurrentWorktree: worktrees.main.isActive,
@@ -421,7 +595,7 @@ export const statusCommand = new Command('status')
                 workspace: workspaceName,
                 branch: worktree.branch || 'unknown',
                 container: worktree.containerName || 'unknown',
-                worktreeStatus: `✅ ${worktreeName}`,
+                worktreeStatus: `${symbol('success')} ${worktreeName}`,
                 status: 'Unknown',
                 ports: '-',
                 isCurrentWorktree: worktree.isActive,
@@ -468,6 +642,7 @@ async function displayUnifiedWorktreeStatus(worktrees: WorktreeList, verbose: bo
   // Generate and display workspace summary
   const summary = generateWorkspaceSummary(workspaceName, rows);
   console.log(`\nWorkspace: ${summary.workspaceName}`);
+  console.log(`Config: ${getAisanityConfigPath(workspacePath) || 'not found'}`);
   console.log(`Current: ${summary.currentWorktree}`);
   console.log(`Total: ${summary.totalContainers} containers (${summary.runningContainers} running, ${summary.stoppedContainers} stopped)`);
   console.log(`Worktrees: ${summary.containersWithWorktrees} with worktree, ${summary.containersWithoutWorktrees} without worktree`);
@@ -484,9 +659,9 @@ async function displayUnifiedWorktreeStatus(worktrees: WorktreeList, verbose: bo
     });
     
     if (discoveryResult.orphaned.length > 0) {
-      console.log(`\n⚠️  Warning: ${discoveryResult.orphaned.length} orphaned containers detected`);
-      console.log('These containers may be from manually deleted worktrees.');
-      console.log('Consider running "aisanity stop --all-worktrees" to clean them up.');
+      print(`\n${symbol('warning')}  Warning: ${discoveryResult.orphaned.length} orphaned containers detected`);
+      print('These containers may be from manually deleted worktrees.');
+      print('Consider running "aisanity stop --all-worktrees" to clean them up.');
       
       if (verbose) {
         const orphanedInfo = formatOrphanedContainerInfo(
@@ -519,53 +694,48 @@ export async function displaySingleWorktreeStatus(cwd: string, verbose: boolean,
   const branch = getCurrentBranch(cwd);
 
   console.log(`Workspace: ${workspaceName}`);
+  console.log(`Config: ${getAisanityConfigPath(cwd) || 'not found'}`);
   console.log(`Branch: ${branch}`);
   console.log(`Container: ${containerName}`);
   console.log('─'.repeat(50));
 
-  // Check main container status using full workspace path
+  // Check container status using full workspace path
+  let containers: DockerContainer[] | null = null;
   try {
-    const output = execSync(`docker ps -a --filter "label=aisanity.workspace=${cwd}" --format "table {{.Names}}\t{{.Status}}\t{{.Ports}}"`, {
-      encoding: 'utf8'
-    });
-
-    if (output.trim()) {
-      console.log('Main Container:');
-      console.log(output);
-    } else {
-      console.log('Main Container: Not found');
-    }
+    containers = await listContainers({ all: true, labels: [`${LABEL_WORKSPACE}=${cwd}`] }, debug);
   } catch (error) {
+    // Reported below
+  }
+
+  if (!containers) {
     console.log('Main Container: Error checking status');
+  } else if (containers.length > 0) {
+    console.log('Main Container:');
+    console.log(formatColumns([['NAMES', 'STATUS', 'PORTS'], ...containers.map(c => [c.name, c.status, c.ports])]));
+    console.log('');
+  } else {
+    console.log('Main Container: Not found');
   }
 
   // Check for devcontainer related to current workspace
-  try {
-    const output = execSync(`docker ps -a --filter "label=aisanity.workspace=${cwd}" --format "table {{.Names}}\t{{.Status}}\t{{.Image}}"`, {
-      encoding: 'utf8'
-    });
-
-    const lines = output.trim().split('\n');
-    if (lines.length > 1) {
-      console.log('\nDevcontainer:');
-      // Show all containers for this workspace
-      for (let i = 1; i < lines.length; i++) {
-        const containerLine = lines[i];
-        if (containerLine && containerLine.trim()) {
-          const parts = containerLine.split('\t');
-          if (parts.length >= 3) {
-            console.log(`  Name: ${parts[0]}`);
-            console.log(`  Status: ${parts[1]}`);
-            console.log(`  Image: ${parts[2]}`);
-            console.log(''); // Add spacing between containers
-          }
-        }
-      }
-    } else {
-      console.log('\nDevcontainer: Not running');
-    }
-  } catch (error) {
+  if (!containers) {
     console.log('\nDevcontainer: Error checking status');
+  } else if (containers.length > 0) {
+    console.log('\nDevcontainer:');
+    // Show all containers for this workspace
+    // Profile, version and resource limits are read back from the labels set when the container was created
+    for (const container of containers) {
+      console.log(`  Name: ${container.name}`);
+      console.log(`  Status: ${container.status}`);
+      console.log(`  Image: ${container.image}`);
+      console.log(`  Profile: ${container.labels[LABEL_PROFILE] || 'default'}`);
+      console.log(`  Resources: ${container.labels[LABEL_RESOURCES] || 'unlimited'}`);
+      console.log(`  Restart: ${container.labels[LABEL_RESTART] || 'no'}`);
+      console.log(`  Version: ${container.labels[LABEL_VERSION] || 'unknown'}`);
+      console.log(''); // Add spacing between containers
+    }
+  } else {
+    console.log('\nDevcontainer: Not running');
   }
 
   // Check workspace configuration
@@ -582,6 +752,16 @@ export async function displaySingleWorktreeStatus(cwd: string, verbose: boolean,
   }
 }
 
+/**
+ * Format rows as left-aligned columns, like docker's table output
+ */
+function formatColumns(rows: string[][]): string {
+  const widths = rows[0].map((_, column) => Math.max(...rows.map(row => row[column].length)));
+  return rows
+    .map(row => row.map((cell, column) => (column === row.length - 1 ? cell : cell.padEnd(widths[column] + 3))).join(''))
+    .join('\n');
+}
+
 /**
  * Get display width of text, accounting for Unicode characters and emojis
  * Emojis and wide Unicode characters take 2 display columns
@@ -677,7 +857,7 @@ function calculateWorkspaceColumnWidths(rows: WorkspaceStatusRow[]): {
   const contentWidths = { ...minWidths };
   
   for (const row of rows) {
-    const indicator = row.isCurrentWorktree ? '→ ' : '  ';
+    const indicator = row.isCurrentWorktree ? `${symbol('current')} ` : '  ';
     contentWidths.workspace = Math.max(contentWidths.workspace, Math.min(getDisplayWidth(indicator + row.workspace), maxWidths.workspace));
     contentWidths.branch = Math.max(contentWidths.branch, Math.min(getDisplayWidth(row.branch), maxWidths.branch));
     contentWidths.container = Math.max(contentWidths.container, Math.min(getDisplayWidth(row.container), maxWidths.container));
@@ -705,7 +885,7 @@ export function formatWorkspaceTable(rows: WorkspaceStatusRow[]): string {
   
   // Build table rows
   for (const row of rows) {
-    const indicator = row.isCurrentWorktree ? '→' : ' ';
+    const indicator = row.isCurrentWorktree ? symbol('current') : ' ';
     const workspaceName = indicator + ' ' + truncateText(row.workspace, colWidths.workspace - 2);
     
     const rowText = '│ ' + padToDisplayWidth(workspaceName, colWidths.workspace) + ' │ ' + 
@@ -745,7 +925,7 @@ export function generateWorkspaceSummary(
       stoppedContainers++;
     }
     
-    if (row.worktreeStatus.startsWith('✅')) {
+    if (row.worktreeStatus.startsWith(symbol('success'))) {
       containersWithWorktrees++;
     } else {
       containersWithoutWorktrees++;
@@ -777,7 +957,7 @@ function displayErrorsAndWarnings(
 ): void {
   // Display critical errors
   if (errors.length > 0) {
-    console.log('\n❌ Errors encountered:');
+    console.log(`\n${symbol('failure')} Errors encountered:`);
     for (const error of errors) {
       console.log(`   ${error.message}`);
       if (verbose) {
@@ -790,16 +970,16 @@ function displayErrorsAndWarnings(
   // Display warnings
   if (warnings.length > 0) {
     if (verbose) {
-      console.log('\n⚠️  Warnings:');
+      print(`\n${symbol('warning')}  Warnings:`);
       for (const warning of warnings) {
-        console.log(`   ${warning.container}: ${warning.type}`);
+        print(`   ${warning.container}: ${warning.type}`);
         for (const detail of warning.details) {
-          console.log(`     - ${detail}`);
+          print(`     - ${detail}`);
         }
-        console.log(`     Suggestion: ${warning.suggestion}`);
+        print(`     Suggestion: ${warning.suggestion}`);
       }
     } else {
-      console.log(`\n⚠️  ${warnings.length} warning(s) detected. Run with --verbose for details.`);
+      print(`\n${symbol('warning')}  ${warnings.length} warning(s) detected. Run with --verbose for details.`);
     }
   }
 }
\ No newline at end of file
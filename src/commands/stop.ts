@@ -2,28 +2,66 @@ import { Command } from 'commander';
 import { execSync } from 'child_process';
 import * as path from 'path';
 import * as fs from 'fs';
-import { loadAisanityConfig, getCurrentBranch } from '../utils/config';
+import { loadAisanityConfig, getCurrentBranch, resolveWorkspaceOption } from '../utils/config';
 import { getAllWorktrees } from '../utils/worktree-utils';
-import { stopContainers, discoverAllAisanityContainers } from '../utils/container-utils';
-import { createLoggerFromCommandOptions } from '../utils/logger';
+import { stopContainers, discoverAllAisanityContainers, listLabeledContainers, DEFAULT_STOP_TIMEOUT, LABEL_WORKSPACE, LABEL_STOP_TIMEOUT } from '../utils/container-utils';
+import { createLoggerFromCommandOptions, Logger } from '../utils/logger';
+
+// Exit code used when every targeted container was already stopped, so scripts can ignore it
+export const ALREADY_STOPPED_EXIT_CODE = 2;
+
+/**
+ * Resolve the stop grace period: --timeout flag, then stopTimeout config, then the default
+ */
+export function resolveStopTimeout(optionValue: string | undefined, configValue: number | undefined): number {
+  if (optionValue === undefined) {
+    return configValue ?? DEFAULT_STOP_TIMEOUT;
+  }
+
+  const timeout = Number(optionValue);
+  if (!Number.isInteger(timeout) || timeout < 0) {
+    throw new Error(`Invalid --timeout value "${optionValue}". Expected a non-negative number of seconds`);
+  }
+  return timeout;
+}
+
+/**
+ * Get the stop grace period a container was created with, or the default for containers without one
+ */
+export function getContainerStopTimeout(labels: Record<string, string>): number {
+  const value = labels[LABEL_STOP_TIMEOUT];
+  const timeout = Number(value);
+  return value && Number.isInteger(timeout) && timeout >= 0 ? timeout : DEFAULT_STOP_TIMEOUT;
+}
 
 export const stopCommand = new Command('stop')
   .description('Stop all containers used for the current workspace')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
   .option('--worktree <path>', 'Stop containers for specific worktree')
   .option('--all-worktrees', 'Stop containers for all worktrees')
+  .option('--all', 'Stop every running aisanity container, in all workspaces')
+  .option('--dry-run', 'Show which containers would be stopped without stopping them')
+  .option('--timeout <seconds>', `Seconds to wait after SIGTERM before killing the container (default: stopTimeout config or ${DEFAULT_STOP_TIMEOUT}; with --all, the stopTimeout each container was created with)`)
   .option('-v, --verbose', 'Show detailed user information (container status, orphaned containers)')
   .option('-d, --debug', 'Show system debugging information (discovery process, timing)')
   .action(async (options) => {
     const logger = createLoggerFromCommandOptions(options);
     
     try {
-      let cwd = process.cwd();
+      let cwd = resolveWorkspaceOption(options);
       let config;
       
+       if (options.all) {
+         // Without --timeout, each container keeps the grace period of the workspace it was created for
+         await stopAllAisanityContainers(logger, options.timeout !== undefined ? resolveStopTimeout(options.timeout, undefined) : undefined, options);
+         return;
+       }
+
        // Handle worktree options
        if (options.allWorktrees) {
-         // Stop all worktree containers with confirmation
-         await stopAllWorktreeContainers(logger, options.verbose, options.debug);
+         // Stop all worktree containers with confirmation; the worktrees share the stopTimeout of the workspace config
+         const timeout = resolveStopTimeout(options.timeout, loadAisanityConfig(cwd)?.stopTimeout);
+         await stopAllWorktreeContainers(logger, timeout, options.verbose, options.debug);
          logger.info('All worktree containers stopped successfully');
          return;
        }
@@ -67,11 +105,24 @@ config = loadAisanityConfig(cwd);
          return;
        }
 
-       // Extract container IDs and stop them
+       if (options.dryRun) {
+         logger.info(`Would stop ${branchContainers.length} containers:`);
+         branchContainers.forEach(container => logger.info(`  - ${container.name} (${container.id})`));
+         return;
+       }
+
+       // Extract container IDs and stop them gracefully
        const containerIds = branchContainers.map(container => container.id);
-       await stopContainers(containerIds, options.verbose || false);
+       const timeout = resolveStopTimeout(options.timeout, config.stopTimeout);
+       logger.verbose(`Stopping with a ${timeout}s grace period before SIGKILL`);
+       const { stopped, alreadyStopped } = await stopContainers(containerIds, options.verbose || false, timeout);
 
-       logger.info(`Stopped ${containerIds.length} containers for branch: ${currentBranch}`);
+       if (stopped.length === 0 && alreadyStopped.length === containerIds.length) {
+         logger.info(`Containers for branch ${currentBranch} are already stopped`);
+         process.exit(ALREADY_STOPPED_EXIT_CODE);
+       }
+
+       logger.info(`Stopped ${stopped.length} containers for branch: ${currentBranch}`);
 
     } catch (error) {
        console.error('Failed to stop containers:', error);
@@ -79,10 +130,45 @@ config = loadAisanityConfig(cwd);
      }
   });
 
+/**
+ * Stop every running container with the aisanity.workspace label; unlabeled containers are never touched
+ */
+async function stopAllAisanityContainers(logger: Logger, timeout: number | undefined, options: any): Promise<void> {
+  const containers = await listLabeledContainers(true, options.debug || false);
+
+  if (containers.length === 0) {
+    logger.info('No running aisanity containers found');
+    return;
+  }
+
+  logger.info(`${options.dryRun ? 'Would stop' : 'Stopping'} ${containers.length} containers:`);
+  containers.forEach(container => {
+    logger.info(`  - ${container.name} (${container.id}) - ${container.labels[LABEL_WORKSPACE]}`);
+  });
+
+  if (options.dryRun) {
+    logger.info('\nDry run mode: No action taken');
+    return;
+  }
+
+  const byTimeout = new Map<number, string[]>();
+  for (const container of containers) {
+    const containerTimeout = timeout ?? getContainerStopTimeout(container.labels);
+    byTimeout.set(containerTimeout, [...(byTimeout.get(containerTimeout) || []), container.id]);
+  }
+
+  let stoppedCount = 0;
+  for (const [containerTimeout, ids] of byTimeout) {
+    const { stopped } = await stopContainers(ids, options.verbose || false, containerTimeout);
+    stoppedCount += stopped.length;
+  }
+  logger.info(`Stopped ${stoppedCount} containers`);
+}
+
 /**
  * UPDATED: Stop containers for all worktrees using unified discovery
  */
-async function stopAllWorktreeContainers(logger: any, verbose: boolean = false, debug: boolean = false): Promise<void> {
+async function stopAllWorktreeContainers(logger: any, timeout: number, verbose: boolean = false, debug: boolean = false): Promise<void> {
   try {
     logger.info('Discovering all aisanity-related containers...');
 
@@ -149,9 +235,9 @@ async function stopAllWorktreeContainers(logger: any, verbose: boolean = false,
 
     // Stop all discovered containers
     const containerIds = allContainers.map(c => c.id);
-    await stopContainers(containerIds, verbose);
+    const { stopped } = await stopContainers(containerIds, verbose, timeout);
 
-    logger.info(`Successfully stopped ${containerIds.length} containers`);
+    logger.info(`Successfully stopped ${stopped.length} containers`);
 
   } catch (error) {
     logger.error('Failed to stop all worktree containers:', error);
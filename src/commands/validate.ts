@@ -0,0 +1,39 @@
+import { Command } from 'commander';
+import { resolveWorkspaceOption } from '../utils/config';
+import { ConfigValidationError } from '../utils/config-validation';
+import { checkConfigFile } from '../utils/config-check';
+import { createLoggerFromCommandOptions } from '../utils/logger';
+
+export function formatConfigProblem(error: ConfigValidationError): string {
+  return error.line !== undefined ? `line ${error.line}: ${error.detail}` : error.detail;
+}
+
+export const validateCommand = new Command('validate')
+  .description('Check the .aisanity config and report every problem at once (exits non-zero when there are any)')
+  .option('--profile <name>', 'Also check that this profile exists, as run --profile would')
+  .option('--check-mounts', 'Also check that mount, secret and build sources exist on the host')
+  .option('--workspace <path>', 'Workspace root to use instead of the nearest .aisanity above the current directory')
+  .action((options) => {
+    const logger = createLoggerFromCommandOptions(options);
+
+    const cwd = resolveWorkspaceOption(options);
+
+    const { configPath, errors, warnings } = checkConfigFile(cwd, {
+      profile: options.profile,
+      checkMounts: options.checkMounts || false
+    });
+
+    warnings.forEach(warning => logger.warn(`Warning: ${warning}`));
+
+    if (errors.length > 0) {
+      console.error(`${configPath}: ${errors.length} problem(s)`);
+      for (const error of errors) {
+        // Problems found in a parent config or the user config name their own file
+        const location = error.configPath !== configPath ? `${error.configPath}: ` : '';
+        console.error(`  ${location}${formatConfigProblem(error)}`);
+      }
+      process.exit(1);
+    }
+
+    logger.info(`${configPath} is valid`);
+  });
@@ -3,6 +3,7 @@ import * as path from 'path';
 import { getMainWorkspacePath, getWorktreeByName, getAllWorktrees, isWorktree } from '../utils/worktree-utils';
 import { checkWorktreeEnabled } from '../utils/config';
 import { createLoggerFromCommandOptions } from '../utils/logger';
+import { symbol } from '../utils/display';
 
 export const worktreeCheckCommand = new Command('check')
   .description('Check worktree status and display information')
@@ -70,27 +71,27 @@ export const worktreeCheckCommand = new Command('check')
         `worktree '${path.basename(cwd)}'` : 
         'main workspace';
       
-      console.log(`Checking worktree '${worktreeName}' from ${currentLocation}`);
+      logger.info(`Checking worktree '${worktreeName}' from ${currentLocation}`);
        
       if (options.verbose) {
-        console.log(`Current path: ${cwd}`);
-        console.log(`Target path: ${worktreePath}`);
-        console.log(`Target branch: ${worktree.branch}`);
-        console.log(`Target container: ${worktree.containerName}`);
+        logger.info(`Current path: ${cwd}`);
+        logger.info(`Target path: ${worktreePath}`);
+        logger.info(`Target branch: ${worktree.branch}`);
+        logger.info(`Target container: ${worktree.containerName}`);
       }
        
       // Preserve existing functionality: change directory in Node.js process
       // Note: This only affects the Node.js process, not the user's shell
       process.chdir(worktreePath);
        
-      console.log(`✓ Worktree exists: ${worktreeName}`);
-      console.log(`  Path: ${worktreePath}`);
-      console.log(`  Branch: ${worktree.branch}`);
-      console.log(`  Container: ${worktree.containerName}`);
+      logger.info(`${symbol('check')} Worktree exists: ${worktreeName}`);
+      logger.info(`  Path: ${worktreePath}`);
+      logger.info(`  Branch: ${worktree.branch}`);
+      logger.info(`  Container: ${worktree.containerName}`);
 
       // Note about container provisioning
-      console.log('');
-      console.log(`Note: If this is your first time in this worktree, run 'aisanity run' to provision the container`);
+      logger.info('');
+      logger.info(`Note: If this is your first time in this worktree, run 'aisanity run' to provision the container`);
       
     } catch (error) {
       console.error('Failed to check worktree:', error);
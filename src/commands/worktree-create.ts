@@ -14,6 +14,7 @@ import {
 } from '../utils/worktree-utils';
 import { loadAisanityConfig, checkWorktreeEnabled } from '../utils/config';
 import { createLoggerFromCommandOptions } from '../utils/logger';
+import { symbol } from '../utils/display';
 
 
 export const worktreeCreateCommand = new Command('create')
@@ -62,10 +63,10 @@ export const worktreeCreateCommand = new Command('create')
        }
       
       if (options.verbose) {
-        console.log(`Creating worktree for branch: ${branch}`);
-        console.log(`Top-level path: ${topLevelPath}`);
-        console.log(`Git root: ${gitRoot}`);
-        console.log(`Config path: ${mainConfigPath}`);
+        logger.info(`Creating worktree for branch: ${branch}`);
+        logger.info(`Top-level path: ${topLevelPath}`);
+        logger.info(`Git root: ${gitRoot}`);
+        logger.info(`Config path: ${mainConfigPath}`);
       }
       
       // Create worktrees directory if it doesn't exist
@@ -73,7 +74,7 @@ export const worktreeCreateCommand = new Command('create')
       if (!fs.existsSync(worktreesDir)) {
         fs.mkdirSync(worktreesDir, { recursive: true });
         if (options.verbose) {
-          console.log(`Created worktrees directory: ${worktreesDir}`);
+          logger.info(`Created worktrees directory: ${worktreesDir}`);
         }
       }
       
@@ -115,7 +116,7 @@ export const worktreeCreateCommand = new Command('create')
         }
         
         if (options.verbose) {
-          console.log(`Created git worktree: ${worktreePath} ${branchExists ? '(existing branch)' : '(new branch)'}`);
+          logger.info(`Created git worktree: ${worktreePath} ${branchExists ? '(existing branch)' : '(new branch)'}`);
         }
        } catch (error) {
          console.error(`Failed to create git worktree: ${error}`);
@@ -125,7 +126,7 @@ export const worktreeCreateCommand = new Command('create')
        // Copy .aisanity config to worktree
        copyConfigToWorktree(mainConfigPath, worktreePath);
        if (options.verbose) {
-         console.log(`Copied .aisanity config to worktree`);
+         logger.info(`Copied .aisanity config to worktree`);
        }
 
        // Copy .devcontainer if it exists locally but is not tracked in git
@@ -133,7 +134,7 @@ export const worktreeCreateCommand = new Command('create')
          try {
            copyDevContainerToWorktree(gitRoot, worktreePath);
            if (options.verbose) {
-             console.log(`Copied .devcontainer directory to worktree (not tracked in git)`);
+             logger.info(`Copied .devcontainer directory to worktree (not tracked in git)`);
            }
          } catch (error) {
            console.warn(`Warning: Failed to copy .devcontainer to worktree: ${error}`);
@@ -143,7 +144,7 @@ export const worktreeCreateCommand = new Command('create')
        // Create aisanity directory structure
        createAisanityDirectory(worktreePath);
        if (options.verbose) {
-         console.log(`Created aisanity directory structure`);
+         logger.info(`Created aisanity directory structure`);
        }
       
 
@@ -153,22 +154,22 @@ export const worktreeCreateCommand = new Command('create')
       const newWorktree = worktrees.worktrees.find(wt => wt.path === worktreePath);
       
       if (newWorktree) {
-        console.log(`✓ Created worktree '${branch}'`);
-        console.log(`  Path: ${newWorktree.path}`);
-        console.log(`  Branch: ${newWorktree.branch}`);
-        console.log(`  Container name: ${newWorktree.containerName}`);
-        console.log(`  Config: ${newWorktree.configPath}`);
-        console.log(`  Container: Not provisioned (run 'aisanity run' when ready)`);
+        logger.info(`${symbol('check')} Created worktree '${branch}'`);
+        logger.info(`  Path: ${newWorktree.path}`);
+        logger.info(`  Branch: ${newWorktree.branch}`);
+        logger.info(`  Container name: ${newWorktree.containerName}`);
+        logger.info(`  Config: ${newWorktree.configPath}`);
+        logger.info(`  Container: Not provisioned (run 'aisanity run' when ready)`);
       }
       
       // Show path to switch to worktree if requested (default behavior)
       if (options.switch !== false) {
-        console.log(`\nTo switch to this worktree, run:`);
-        console.log(`  cd ${worktreePath}`);
-        console.log(`\nThen run 'aisanity run' to start the development container`);
+        logger.info(`\nTo switch to this worktree, run:`);
+        logger.info(`  cd ${worktreePath}`);
+        logger.info(`\nThen run 'aisanity run' to start the development container`);
       } else {
-        console.log(`\nWorktree created at: ${worktreePath}`);
-        console.log(`To switch to it, run: cd ${worktreePath}`);
+        logger.info(`\nWorktree created at: ${worktreePath}`);
+        logger.info(`To switch to it, run: cd ${worktreePath}`);
       }
       
      } catch (error) {
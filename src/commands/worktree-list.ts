@@ -1,9 +1,10 @@
 import { Command } from 'commander';
 import * as path from 'path';
-import { $ } from 'bun';
-import { getAllWorktrees, isWorktree } from '../utils/worktree-utils';
-import { checkWorktreeEnabled } from '../utils/config';
+import { getAllWorktrees, isWorktree, WorktreeInfo } from '../utils/worktree-utils';
+import { checkWorktreeEnabled, getLegacyContainerName } from '../utils/config';
+import { findContainersByName } from '../utils/container-utils';
 import { createLoggerFromCommandOptions } from '../utils/logger';
+import { symbol } from '../utils/display';
 
 export const worktreeListCommand = new Command('list')
   .description('List all worktrees and their container status')
@@ -24,8 +25,8 @@ export const worktreeListCommand = new Command('list')
       
       // Display main workspace
       const main = worktrees.main;
-      const mainStatus = await getContainerStatus(main.containerName, options.verbose);
-      const mainIndicator = main.isActive ? '→' : ' ';
+      const mainStatus = await getContainerStatus(main, options.verbose);
+      const mainIndicator = main.isActive ? symbol('current') : ' ';
       const mainActive = main.isActive ? '(active)' : '';
       
       console.log(`${mainIndicator} Main Workspace ${mainActive}`);
@@ -45,8 +46,8 @@ export const worktreeListCommand = new Command('list')
         console.log('');
         
         for (const worktree of worktrees.worktrees) {
-          const worktreeStatus = await getContainerStatus(worktree.containerName, options.verbose);
-          const worktreeIndicator = worktree.isActive ? '→' : ' ';
+          const worktreeStatus = await getContainerStatus(worktree, options.verbose);
+          const worktreeIndicator = worktree.isActive ? symbol('current') : ' ';
           const worktreeActive = worktree.isActive ? '(active)' : '';
           const worktreeName = worktree.path.split(path.sep).pop() || 'unknown';
           
@@ -75,34 +76,29 @@ export const worktreeListCommand = new Command('list')
   });
 
 /**
- * Get container status for a given container name
+ * Get container status for a worktree
+ * Containers created before path-hashed names are found through their legacy name
  */
-async function getContainerStatus(containerName: string, verbose: boolean = false): Promise<string> {
+async function getContainerStatus(worktree: WorktreeInfo, verbose: boolean = false): Promise<string> {
   try {
-    // Single call to get all containers and their status
-    const result = await $`docker ps -a --filter label=aisanity.container=${containerName} --format {{.Names}}\t{{.Status}}`.text();
+    const containers = await findContainersByName(
+      worktree.containerName,
+      getLegacyContainerName(worktree.path),
+      worktree.path
+    );
     
-    const lines = result.trim().split('\n').filter((line: string) => line.trim() !== '');
-    
-    if (lines.length === 0) {
+    if (containers.length === 0) {
       return 'Not created';
     }
     
-    // Look for the container that matches the expected name
-    for (const line of lines) {
-      const [name, status] = line.split('\t');
-      if (name === containerName) {
-        // Check if container is running by looking for "Up" in status
-        if (status.includes('Up')) {
-          return `Running (${status})`;
-        } else {
-          return `Stopped (${status})`;
-        }
-      }
+    // Check if container is running by looking for "Up" in status
+    const status = containers[0].status;
+    if (status.includes('Up')) {
+      return `Running (${status})`;
+    } else {
+      return `Stopped (${status})`;
     }
-    
-    return 'Not created';
   } catch (error) {
     return 'Unknown (Docker error)';
   }
-}
\ No newline at end of file
+}
@@ -6,6 +6,7 @@ import { getMainWorkspacePath, getWorktreeByName, getAllWorktrees } from '../uti
 import { checkWorktreeEnabled } from '../utils/config';
 import { discoverByLabels, stopContainers, removeContainers } from '../utils/container-utils';
 import { createLoggerFromCommandOptions } from '../utils/logger';
+import { symbol } from '../utils/display';
 
 export const worktreeRemoveCommand = new Command('remove')
   .description('Remove a worktree and clean up associated containers')
@@ -60,12 +61,12 @@ export const worktreeRemoveCommand = new Command('remove')
        }
       
       // Show worktree details
-      console.log(`Worktree to remove:`);
-      console.log(`  Name: ${worktreeName}`);
-      console.log(`  Path: ${worktreePath}`);
-      console.log(`  Branch: ${worktree.branch}`);
-      console.log(`  Container: ${worktree.containerName}`);
-      console.log('');
+      logger.info(`Worktree to remove:`);
+      logger.info(`  Name: ${worktreeName}`);
+      logger.info(`  Path: ${worktreePath}`);
+      logger.info(`  Branch: ${worktree.branch}`);
+      logger.info(`  Container: ${worktree.containerName}`);
+      logger.info('');
       
       // Confirmation check
       if (!options.force) {
@@ -82,13 +83,13 @@ export const worktreeRemoveCommand = new Command('remove')
         rl.close();
         
         if (answer.toLowerCase() !== 'y' && answer.toLowerCase() !== 'yes') {
-           console.log('Worktree removal cancelled');
+           logger.info('Worktree removal cancelled');
            throw new Error('Worktree removal cancelled by user');
          }
       }
       
       if (options.verbose) {
-        console.log(`Removing worktree: ${worktreeName}`);
+        logger.info(`Removing worktree: ${worktreeName}`);
       }
       
       // Stop and remove containers using label-based discovery
@@ -105,9 +106,9 @@ export const worktreeRemoveCommand = new Command('remove')
 
         if (matchingContainers.length > 0) {
           if (options.verbose) {
-            console.log(`Found ${matchingContainers.length} container(s) for workspace:`);
+            logger.info(`Found ${matchingContainers.length} container(s) for workspace:`);
             matchingContainers.forEach(container => {
-              console.log(`  - '${container.name}' (ID: ${container.id})`);
+              logger.info(`  - '${container.name}' (ID: ${container.id})`);
             });
           }
 
@@ -121,19 +122,19 @@ export const worktreeRemoveCommand = new Command('remove')
           if (options.verbose) {
             matchingContainers.forEach(container => {
               const expectedName = container.labels['aisanity.container'] || 'unknown';
-              console.log(`Cleaned up container: ${container.name} (${expectedName})`);
+              logger.info(`Cleaned up container: ${container.name} (${expectedName})`);
             });
           }
         } else {
           if (options.verbose) {
-            console.log(`No containers found for workspace: ${worktree.path}`);
+            logger.info(`No containers found for workspace: ${worktree.path}`);
           }
         }
       } catch (error) {
         // Container discovery or cleanup failed, but continue with worktree removal
         if (options.verbose) {
           console.warn(`Container cleanup encountered issues: ${error instanceof Error ? error.message : 'Unknown error'}`);
-          console.log('Continuing with worktree directory cleanup...');
+          logger.info('Continuing with worktree directory cleanup...');
         }
       }
       
@@ -150,7 +151,7 @@ export const worktreeRemoveCommand = new Command('remove')
         }
         
         if (options.verbose) {
-          console.log(`Removed git worktree: ${worktreePath}`);
+          logger.info(`Removed git worktree: ${worktreePath}`);
         }
       } catch (error) {
         console.error(`Failed to remove git worktree: ${error}`);
@@ -161,19 +162,19 @@ export const worktreeRemoveCommand = new Command('remove')
       if (fs.existsSync(worktreePath)) {
         fs.rmSync(worktreePath, { recursive: true, force: true });
         if (options.verbose) {
-          console.log(`Removed worktree directory: ${worktreePath}`);
+          logger.info(`Removed worktree directory: ${worktreePath}`);
         }
       }
       
-      console.log(`✓ Successfully removed worktree '${worktreeName}'`);
+      logger.info(`${symbol('check')} Successfully removed worktree '${worktreeName}'`);
       
       // Show remaining worktrees
       const remainingWorktrees = getAllWorktrees(cwd);
-      console.log('');
-      console.log(`Remaining worktrees: ${remainingWorktrees.worktrees.length}`);
+      logger.info('');
+      logger.info(`Remaining worktrees: ${remainingWorktrees.worktrees.length}`);
       
       if (remainingWorktrees.worktrees.length > 0) {
-        console.log('Use "aisanity worktree list" to see all worktrees');
+        logger.info('Use "aisanity worktree list" to see all worktrees');
       }
       
     } catch (error) {
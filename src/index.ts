@@ -10,34 +10,67 @@ if (typeof Bun === 'undefined') {
 import { Command } from 'commander';
 import { initCommand } from './commands/init';
 import { runCommand } from './commands/run';
+import { execCommand } from './commands/exec';
+import { logsCommand } from './commands/logs';
+import { attachCommand } from './commands/attach';
 import { stopCommand } from './commands/stop';
+import { restartCommand } from './commands/restart';
 import { statusCommand } from './commands/status';
 import { rebuildCommand } from './commands/rebuild';
+import { pullCommand } from './commands/pull';
+import { lockCommand } from './commands/lock';
+import { buildCommand } from './commands/build';
 import { discoverOpencodeCommand } from './commands/discover-opencode';
 import { statsCommand } from './commands/stats';
 import { worktreeCommand } from './commands/worktree';
-import { cleanupCommand } from './commands/cleanup';
+import { cleanupCommand, cleanCommand } from './commands/cleanup';
 import { startAndAttachCommand } from './commands/start-and-attach';
+import { completionCommand, completeProfilesCommand } from './commands/completion';
+import { doctorCommand } from './commands/doctor';
+import { validateCommand } from './commands/validate';
+import { configCommand } from './commands/config';
 import { getVersion } from './utils/version';
+import { addDisplayOptions } from './utils/display';
+import { addConfigOptions } from './utils/config';
 
 const program = new Command();
 
 program
   .name('aisanity')
   .description('Devcontainer wrapper for sandboxed development environments')
-  .version(getVersion()); // Dynamic version detection
+  .version(getVersion()) // Dynamic version detection
+  .enablePositionalOptions(); // Lets run pass the options after its command through to it
 
 // Register commands
 program.addCommand(initCommand);
 program.addCommand(runCommand);
+program.addCommand(execCommand);
+program.addCommand(logsCommand);
+program.addCommand(attachCommand);
 program.addCommand(stopCommand);
+program.addCommand(restartCommand);
 program.addCommand(statusCommand);
 program.addCommand(rebuildCommand);
+program.addCommand(pullCommand);
+program.addCommand(lockCommand);
+program.addCommand(buildCommand);
 program.addCommand(discoverOpencodeCommand);
 program.addCommand(statsCommand);
 program.addCommand(worktreeCommand);
 program.addCommand(cleanupCommand);
+program.addCommand(cleanCommand);
 program.addCommand(startAndAttachCommand);
+program.addCommand(doctorCommand);
+program.addCommand(validateCommand);
+program.addCommand(configCommand);
+program.addCommand(completionCommand);
+program.addCommand(completeProfilesCommand, { hidden: true });
+
+// --quiet and --no-emoji apply the same way to every command
+addDisplayOptions(program);
+
+// --config replaces the .aisanity lookup of every command
+addConfigOptions(program);
 
 // Parse command line arguments
 program.parse();
\ No newline at end of file
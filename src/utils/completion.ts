@@ -0,0 +1,270 @@
+import { Command } from 'commander';
+
+export const COMPLETION_SHELLS = ['bash', 'zsh', 'fish'] as const;
+export type CompletionShell = typeof COMPLETION_SHELLS[number];
+
+// Hidden command the generated scripts call to list the profiles of the current workspace
+export const COMPLETE_PROFILES_COMMAND = '__complete-profiles';
+
+// How the value of an option is completed
+type ValueCompletion = 'profiles' | 'directories' | 'files' | 'none';
+
+const VALUE_COMPLETIONS: Record<string, ValueCompletion> = {
+  '--profile': 'profiles',
+  '--workspace': 'directories',
+  '--worktree': 'directories',
+  '--devcontainer-json': 'files',
+  '--config': 'files',
+  '--events-socket': 'files'
+};
+
+export interface CompletionOption {
+  names: string[];         // e.g. ['-v', '--verbose']
+  description: string;
+  value?: ValueCompletion; // Set for options that take a value
+}
+
+export interface CompletionCommand {
+  path: string[];          // Command names from the top level, e.g. ['worktree', 'create']
+  aliases: string[];
+  description: string;
+  options: CompletionOption[];
+  subcommands: string[];
+}
+
+/**
+ * Describe the commands and options of the CLI for completion scripts
+ * Commands whose name starts with "__" are internal and left out.
+ */
+export function collectCompletionCommands(program: Command): CompletionCommand[] {
+  const collected: CompletionCommand[] = [];
+
+  const visit = (command: Command, commandPath: string[]) => {
+    const subcommands = command.commands.filter(sub => !sub.name().startsWith('__'));
+    collected.push({
+      path: commandPath,
+      aliases: commandPath.length > 0 ? command.aliases() : [],
+      description: command.description() || '',
+      options: command.options.map(option => {
+        const names = option.flags.split(/[ ,|]+/).filter(part => part.startsWith('-'));
+        const takesValue = /[<[]/.test(option.flags);
+        return {
+          names,
+          description: option.description || '',
+          ...(takesValue ? { value: VALUE_COMPLETIONS[names[names.length - 1]] || 'none' } : {})
+        };
+      }),
+      subcommands: subcommands.map(sub => sub.name())
+    });
+    subcommands.forEach(sub => visit(sub, [...commandPath, sub.name()]));
+  };
+  visit(program, []);
+
+  return collected;
+}
+
+/**
+ * Generate the completion script for a shell
+ */
+export function generateCompletionScript(shell: CompletionShell, program: Command): string {
+  const commands = collectCompletionCommands(program);
+  const name = program.name();
+  switch (shell) {
+    case 'bash':
+      return generateBashCompletion(name, commands);
+    case 'zsh':
+      return generateZshCompletion(name, commands);
+    case 'fish':
+      return generateFishCompletion(name, commands);
+  }
+}
+
+function optionsWithValues(commands: CompletionCommand[], value?: ValueCompletion): string[] {
+  const names = new Set<string>();
+  for (const command of commands) {
+    for (const option of command.options) {
+      if (option.value && (!value || option.value === value)) {
+        option.names.forEach(optionName => names.add(optionName));
+      }
+    }
+  }
+  return [...names].sort();
+}
+
+function commandGroups(commands: CompletionCommand[]): string[] {
+  return commands.filter(command => command.path.length === 1 && command.subcommands.length > 0).map(command => command.path[0]);
+}
+
+// Patterns matching a command path, including the aliases of its last name
+function casePatterns(command: CompletionCommand): string[] {
+  const parent = command.path.slice(0, -1).join(' ');
+  return [command.path[command.path.length - 1], ...command.aliases].map(last => `"${parent ? `${parent} ${last}` : last}"`);
+}
+
+// Case branches completing option values, one per kind of value that some option takes
+function valueCases(commands: CompletionCommand[], actions: Record<'profiles' | 'directories' | 'files', string>): string {
+  return (Object.keys(actions) as ('profiles' | 'directories' | 'files')[])
+    .map(kind => ({ names: optionsWithValues(commands, kind), action: actions[kind] }))
+    .filter(entry => entry.names.length > 0)
+    .map(entry => `    ${entry.names.join('|')}) ${entry.action}; return ;;`)
+    .join('\n');
+}
+
+function wordsOf(command: CompletionCommand): string {
+  return [...command.subcommands, ...command.options.flatMap(option => option.names)].join(' ');
+}
+
+function generateBashCompletion(name: string, commands: CompletionCommand[]): string {
+  const fn = `_${name.replace(/[^a-zA-Z0-9_]/g, '_')}`;
+  const cases = commands.map(command => `    ${command.path.length === 0 ? '""' : casePatterns(command).join('|')}) words="${wordsOf(command)}" ;;`);
+
+  return `# bash completion for ${name}
+# Load with: source <(${name} completion bash)
+${fn}() {
+  local cur="\${COMP_WORDS[COMP_CWORD]}"
+  local prev="\${COMP_WORDS[COMP_CWORD-1]}"
+  local value_opts=" ${optionsWithValues(commands).join(' ')} "
+  local groups=" ${commandGroups(commands).join(' ')} "
+  local cmd="" words="" skip=0 i word
+
+  # The command path is made of the words that are neither options nor option values
+  for ((i = 1; i < COMP_CWORD; i++)); do
+    word="\${COMP_WORDS[i]}"
+    if ((skip)); then skip=0; continue; fi
+    if [[ "$value_opts" == *" $word "* ]]; then skip=1; continue; fi
+    [[ "$word" == -* ]] && continue
+    if [[ -z "$cmd" ]]; then
+      cmd="$word"
+    elif [[ "$groups" == *" $cmd "* ]]; then
+      cmd="$cmd $word"
+    fi
+  done
+
+  case "$prev" in
+${valueCases(commands, {
+    profiles: `COMPREPLY=($(compgen -W "$(${name} ${COMPLETE_PROFILES_COMMAND} 2>/dev/null)" -- "$cur"))`,
+    directories: 'COMPREPLY=($(compgen -d -- "$cur"))',
+    files: 'COMPREPLY=($(compgen -f -- "$cur"))'
+  })}
+  esac
+  [[ "$value_opts" == *" $prev "* ]] && return
+
+  case "$cmd" in
+${cases.join('\n')}
+  esac
+  COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -o default -F ${fn} ${name}
+`;
+}
+
+function zshQuote(text: string): string {
+  return `'${text.replace(/'/g, `'\\''`)}'`;
+}
+
+function generateZshCompletion(name: string, commands: CompletionCommand[]): string {
+  const fn = `_${name.replace(/[^a-zA-Z0-9_]/g, '_')}`;
+  const describe = (command: CompletionCommand): string => {
+    const entries = [
+      ...command.subcommands.map(sub => {
+        const child = commands.find(other => other.path.join(' ') === [...command.path, sub].join(' '));
+        return `${sub}:${child?.description || ''}`;
+      }),
+      ...command.options.flatMap(option => option.names.map(optionName => `${optionName}:${option.description}`))
+    ];
+    return entries.map(entry => `      ${zshQuote(entry.replace(/:/, '\0').replace(/:/g, '\\:').replace('\0', ':'))}`).join('\n');
+  };
+  const cases = commands.map(command =>
+    `    ${command.path.length === 0 ? '""' : casePatterns(command).join('|')})\n      entries=(\n${describe(command)}\n      ) ;;`
+  );
+
+  return `#compdef ${name}
+# zsh completion for ${name}
+# Load with: source <(${name} completion zsh)
+${fn}() {
+  local -a entries
+  local value_opts=" ${optionsWithValues(commands).join(' ')} "
+  local groups=" ${commandGroups(commands).join(' ')} "
+  local cmd="" word skip=0 i
+
+  # The command path is made of the words that are neither options nor option values
+  for ((i = 2; i < CURRENT; i++)); do
+    word="\${words[i]}"
+    if ((skip)); then skip=0; continue; fi
+    if [[ "$value_opts" == *" $word "* ]]; then skip=1; continue; fi
+    [[ "$word" == -* ]] && continue
+    if [[ -z "$cmd" ]]; then
+      cmd="$word"
+    elif [[ "$groups" == *" $cmd "* ]]; then
+      cmd="$cmd $word"
+    fi
+  done
+
+  case "\${words[CURRENT-1]}" in
+${valueCases(commands, {
+    profiles: `compadd -- \${(f)"$(${name} ${COMPLETE_PROFILES_COMMAND} 2>/dev/null)"}`,
+    directories: '_directories',
+    files: '_files'
+  })}
+  esac
+  [[ "$value_opts" == *" \${words[CURRENT-1]} "* ]] && return
+
+  case "$cmd" in
+${cases.join('\n')}
+  esac
+  _describe 'command or option' entries
+}
+compdef ${fn} ${name}
+`;
+}
+
+function fishQuote(text: string): string {
+  return `'${text.replace(/\\/g, '\\\\').replace(/'/g, "\\'")}'`;
+}
+
+function generateFishCompletion(name: string, commands: CompletionCommand[]): string {
+  const lines = [
+    `# fish completion for ${name}`,
+    `# Load with: ${name} completion fish | source`,
+    `complete -c ${name} -f`
+  ];
+  const groups = commandGroups(commands);
+
+  for (const command of commands) {
+    // Conditions selecting the command: no subcommand yet at the top, otherwise its path has been typed
+    let condition: string;
+    if (command.path.length === 0) {
+      condition = '__fish_use_subcommand';
+    } else if (command.path.length === 1) {
+      const names = [command.path[0], ...command.aliases].join(' ');
+      const group = groups.includes(command.path[0]) ? `; and not __fish_seen_subcommand_from ${command.subcommands.join(' ')}` : '';
+      condition = `__fish_seen_subcommand_from ${names}${group}`;
+    } else {
+      condition = command.path.map((part, index) =>
+        `__fish_seen_subcommand_from ${index === command.path.length - 1 ? [part, ...command.aliases].join(' ') : part}`
+      ).join('; and ');
+    }
+
+    for (const sub of command.subcommands) {
+      const child = commands.find(other => other.path.join(' ') === [...command.path, sub].join(' '));
+      lines.push(`complete -c ${name} -n ${fishQuote(condition)} -a ${sub} -d ${fishQuote(child?.description || '')}`);
+    }
+
+    for (const option of command.options) {
+      const flags = option.names.map(optionName => (optionName.startsWith('--') ? `-l ${optionName.substring(2)}` : `-s ${optionName.substring(1)}`));
+      let value = '';
+      if (option.value === 'profiles') {
+        value = ` -r -a ${fishQuote(`(${name} ${COMPLETE_PROFILES_COMMAND} 2>/dev/null)`)}`;
+      } else if (option.value === 'directories') {
+        value = ` -r -a ${fishQuote('(__fish_complete_directories)')}`;
+      } else if (option.value === 'files') {
+        value = ' -r -F';
+      } else if (option.value) {
+        value = ' -r';
+      }
+      lines.push(`complete -c ${name} -n ${fishQuote(condition)} ${flags.join(' ')}${value} -d ${fishQuote(option.description)}`);
+    }
+  }
+
+  return lines.join('\n') + '\n';
+}
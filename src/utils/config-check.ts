@@ -0,0 +1,183 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+import { AisanityConfig, resolveConfigExtends, mergeUserConfig, loadUserConfig, getConfigOverride } from './config';
+import { ConfigValidationError, collectAisanityYamlErrors, collectAisanityConfigErrors } from './config-validation';
+import { resolveDeclaredEnv } from './env-utils';
+import { resolveBuildPaths } from './image-build';
+import {
+  DEFAULT_PROFILE,
+  getProfileNames,
+  resolveProfile,
+  validatePorts,
+  formatResourceArgs,
+  formatNetworkArgs,
+  resolveContainerUser,
+  validatePlatform,
+  validateWorkdir,
+  validateRestartPolicy,
+  formatHealthcheckArgs,
+  parseCacheVolumes,
+  formatExcludeMounts,
+  parseProfileMount,
+  resolveProfileMounts,
+  resolveProfileSecrets,
+  getProfileWarnings,
+  resolveProfileTemplates,
+  PROFILE_TEMPLATE_PLACEHOLDER
+} from './profile-utils';
+
+export interface ConfigCheckOptions {
+  profile?: string;      // Profile that must exist, as for run --profile
+  checkMounts?: boolean; // Also require mount, secret and build sources to exist on the host
+  hostEnv?: Record<string, string | undefined>;
+}
+
+export interface ConfigCheckResult {
+  configPath: string;
+  errors: ConfigValidationError[];
+  warnings: string[];
+}
+
+/**
+ * Validate the .aisanity config of a workspace the way run would, without stopping at the first problem
+ * Structural problems (YAML syntax, unknown fields, types) are all reported first; the profile
+ * checks only run on a config that passed them.
+ */
+export function checkConfigFile(cwd: string, options: ConfigCheckOptions = {}): ConfigCheckResult {
+  const hostEnv = options.hostEnv || process.env;
+  const override = getConfigOverride();
+  let configPath = override ? override.path : path.join(cwd, '.aisanity');
+  const errors: ConfigValidationError[] = [];
+  const warnings: string[] = [];
+  const result = { configPath, errors, warnings };
+
+  if (!override && !fs.existsSync(configPath)) {
+    errors.push(new ConfigValidationError(configPath, undefined, 'file does not exist. Run "aisanity init" first'));
+    return result;
+  }
+
+  let parsed: unknown;
+  if (override) {
+    // --config: a file or stdin, always YAML (which JSON also is)
+    const decoded = collectAisanityYamlErrors(override.content ?? fs.readFileSync(configPath, 'utf8'), configPath);
+    parsed = decoded.value;
+    errors.push(...decoded.errors);
+  } else if (fs.statSync(configPath).isDirectory()) {
+    configPath = path.join(configPath, 'config.json');
+    result.configPath = configPath;
+    try {
+      parsed = JSON.parse(fs.readFileSync(configPath, 'utf8'));
+    } catch (error) {
+      errors.push(new ConfigValidationError(configPath, undefined, error instanceof Error ? error.message : String(error)));
+      return result;
+    }
+    errors.push(...collectAisanityConfigErrors(parsed, configPath));
+  } else {
+    const decoded = collectAisanityYamlErrors(fs.readFileSync(configPath, 'utf8'), configPath);
+    parsed = decoded.value;
+    errors.push(...decoded.errors);
+  }
+
+  if (errors.length > 0 || !parsed) {
+    return result;
+  }
+
+  const attempt = (check: () => void) => {
+    try {
+      check();
+    } catch (error) {
+      errors.push(error instanceof ConfigValidationError
+        ? error
+        : new ConfigValidationError(configPath, undefined, error instanceof Error ? error.message : String(error)));
+    }
+  };
+
+  let config = parsed as AisanityConfig;
+  attempt(() => {
+    config = mergeUserConfig(loadUserConfig(hostEnv), resolveConfigExtends(config, configPath));
+  });
+  if (errors.length > 0) {
+    return result;
+  }
+
+  // Template variables in mounts; the config is kept unexpanded if they are broken
+  const variables = { workspace: cwd, home: hostEnv.HOME || os.homedir() };
+  attempt(() => {
+    config = resolveProfileTemplates(config, variables);
+  });
+
+  // Env interpolation, one block at a time so every broken block is named
+  const envBlocks: [string, unknown, string][] = [
+    ['base', config.base?.env, PROFILE_TEMPLATE_PLACEHOLDER],
+    ...Object.entries(config.profiles || {}).map(([name, profile]): [string, unknown, string] => [`Profile '${name}'`, profile?.env, name]),
+    ...Object.entries(config.envProfiles || {}).map(([name, env]): [string, unknown, string] => [`Env profile '${name}'`, env, PROFILE_TEMPLATE_PLACEHOLDER])
+  ];
+  for (const [label, env, profile] of envBlocks) {
+    if (env !== undefined && env !== null) {
+      attempt(() => {
+        try {
+          resolveDeclaredEnv(env, hostEnv, { ...variables, profile });
+        } catch (error) {
+          throw new Error(`${label} env ${error instanceof Error ? error.message : String(error)}`);
+        }
+      });
+    }
+  }
+
+  const names = getProfileNames(config);
+  for (const profileName of names.length > 0 ? names : [undefined]) {
+    const profile = resolveProfile(config, profileName);
+    const name = profile.name;
+
+    attempt(() => validatePorts(profile.ports || [], name));
+    attempt(() => formatResourceArgs(profile.resources || {}, name));
+    attempt(() => formatNetworkArgs(profile, name));
+    attempt(() => resolveContainerUser(profile.user, name, 'cli'));
+    if (profile.platform !== undefined) {
+      attempt(() => validatePlatform(profile.platform!, name));
+    }
+    if (profile.workdir !== undefined) {
+      attempt(() => validateWorkdir(profile.workdir!, name));
+    }
+    if (profile.restart !== undefined) {
+      attempt(() => validateRestartPolicy(profile.restart!, name));
+    }
+    attempt(() => parseCacheVolumes(profile.cacheVolumes, name));
+    attempt(() => formatExcludeMounts(profile.exclude || [], '/workspace', name));
+    if (profile.healthcheck) {
+      attempt(() => formatHealthcheckArgs(profile.healthcheck!, name));
+    }
+
+    for (const mount of profile.mounts || []) {
+      attempt(() => {
+        if (options.checkMounts) {
+          resolveProfileMounts([mount], cwd, name, true);
+          return;
+        }
+        try {
+          parseProfileMount(mount, cwd);
+        } catch (error) {
+          throw new Error(`Profile '${name}': ${error instanceof Error ? error.message : String(error)}`);
+        }
+      });
+    }
+
+    if (options.checkMounts) {
+      attempt(() => resolveProfileSecrets(profile.secrets || [], cwd, name));
+      if (profile.build) {
+        attempt(() => resolveBuildPaths(profile.build!, cwd, name));
+      }
+    }
+  }
+
+  // Profile references
+  if (options.profile) {
+    attempt(() => resolveProfile(config, options.profile));
+  } else if (names.length > 0 && !names.includes(DEFAULT_PROFILE)) {
+    warnings.push(`No '${DEFAULT_PROFILE}' profile: aisanity run needs --profile (one of ${names.join(', ')})`);
+  }
+
+  warnings.push(...getProfileWarnings(config));
+  return result;
+}
@@ -0,0 +1,93 @@
+import * as YAML from 'yaml';
+import { AisanityConfig, ProfileConfig, ProjectType } from './config';
+
+export interface ConfigTemplate {
+  description: string;
+  projectType: ProjectType;  // Devcontainer template written next to the config
+  profile: ProfileConfig;    // Default profile of the generated config
+}
+
+// Images match the devcontainer templates so the profile image and devcontainer.json agree
+const CONFIG_TEMPLATES: Record<string, ConfigTemplate> = {
+  go: {
+    description: 'Go toolchain with module and build caches',
+    projectType: 'go',
+    profile: {
+      image: 'mcr.microsoft.com/devcontainers/go:1.25',
+      cacheVolumes: {
+        gomod: '/go/pkg/mod',
+        gobuild: '/home/vscode/.cache/go-build'
+      }
+    }
+  },
+  node: {
+    description: 'Node.js 22 with an npm cache',
+    projectType: 'nodejs',
+    profile: {
+      image: 'mcr.microsoft.com/devcontainers/javascript-node:22',
+      env: { NODE_ENV: 'development' },
+      cacheVolumes: {
+        npm: '/home/node/.npm'
+      }
+    }
+  },
+  python: {
+    description: 'Python 3.13 with pip and uv caches',
+    projectType: 'python',
+    profile: {
+      image: 'mcr.microsoft.com/devcontainers/python:3.13',
+      env: { PYTHONDONTWRITEBYTECODE: '1' },
+      cacheVolumes: {
+        pip: '/home/vscode/.cache/pip',
+        uv: '/home/vscode/.cache/uv'
+      }
+    }
+  },
+  rust: {
+    description: 'Rust toolchain with cargo registry and git caches',
+    projectType: 'rust',
+    profile: {
+      image: 'mcr.microsoft.com/devcontainers/rust:1',
+      cacheVolumes: {
+        'cargo-registry': '/usr/local/cargo/registry',
+        'cargo-git': '/usr/local/cargo/git'
+      }
+    }
+  }
+};
+
+/**
+ * Get the names of the built-in config templates
+ */
+export function getConfigTemplateNames(): string[] {
+  return Object.keys(CONFIG_TEMPLATES).sort();
+}
+
+/**
+ * Get a built-in config template by name
+ * @throws Error listing the available templates when the name is unknown
+ */
+export function getConfigTemplate(name: string): ConfigTemplate {
+  const template = CONFIG_TEMPLATES[name];
+  if (!template) {
+    throw new Error(`Unknown template '${name}'. Available templates: ${getConfigTemplateNames().join(', ')}`);
+  }
+  return template;
+}
+
+/**
+ * Render the .aisanity config for a template
+ */
+export function createTemplateConfig(workspaceName: string, templateName: string): string {
+  const template = getConfigTemplate(templateName);
+  const config: AisanityConfig = {
+    workspace: workspaceName,
+    env: {},
+    worktree: false,
+    profiles: {
+      default: template.profile
+    }
+  };
+
+  return YAML.stringify(config);
+}
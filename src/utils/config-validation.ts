@@ -0,0 +1,356 @@
+import * as YAML from 'yaml';
+
+/**
+ * Error for invalid .aisanity config files
+ * The message always names the config file and, when known, the line of the offending field
+ */
+export class ConfigValidationError extends Error {
+  constructor(public configPath: string, public line: number | undefined, public detail: string) {
+    super(`Invalid .aisanity config ${line !== undefined ? `${configPath}:${line}` : configPath}: ${detail}`);
+    this.name = 'ConfigValidationError';
+  }
+}
+
+type FieldType = 'string' | 'boolean' | 'number' | 'createMissing' | 'list' | 'map' | 'env' | 'command' | 'mounts' | 'healthcheck' | 'resources' | 'build' | 'secrets' | 'profile' | 'profiles' | 'envProfiles';
+
+// Fields accepted in a profile (and in the base block)
+const PROFILE_FIELDS: Record<string, FieldType> = {
+  image: 'string',
+  build: 'build',
+  mounts: 'mounts',
+  exclude: 'list',
+  env: 'env',
+  envFile: 'command',
+  secrets: 'secrets',
+  ports: 'list',
+  command: 'command',
+  cacheVolumes: 'map',
+  healthcheck: 'healthcheck',
+  resources: 'resources',
+  network: 'string',
+  user: 'string',
+  runArgs: 'list',
+  dockerAccess: 'boolean',
+  platform: 'string',
+  workdir: 'string',
+  restart: 'string',
+  clear: 'boolean'
+};
+
+// Fields accepted in a mount entry given as a map
+const MOUNT_FIELDS: Record<string, FieldType> = {
+  source: 'string',
+  target: 'string',
+  readonly: 'boolean',
+  consistency: 'string',
+  createMissing: 'createMissing'
+};
+
+// Fields accepted in a profile secret
+const SECRET_FIELDS: Record<string, FieldType> = {
+  source: 'string',
+  target: 'string'
+};
+
+// Fields accepted in a profile healthcheck
+const HEALTHCHECK_FIELDS: Record<string, FieldType> = {
+  command: 'command',
+  interval: 'string',
+  timeout: 'string',
+  retries: 'number',
+  startPeriod: 'string'
+};
+
+// Fields accepted in a profile build block
+const BUILD_FIELDS: Record<string, FieldType> = {
+  context: 'string',
+  dockerfile: 'string'
+};
+
+// Fields accepted in profile resource limits
+const RESOURCES_FIELDS: Record<string, FieldType> = {
+  cpus: 'number',
+  memory: 'string',
+  pidsLimit: 'number'
+};
+
+// Top-level fields accepted in .aisanity
+const CONFIG_FIELDS: Record<string, FieldType> = {
+  extends: 'string',
+  workspace: 'string',
+  containerName: 'string',
+  env: 'map',
+  envWhitelist: 'list',
+  envProfiles: 'envProfiles',
+  worktree: 'boolean',
+  stopTimeout: 'number',
+  autoRecreate: 'boolean',
+  base: 'profile',
+  profiles: 'profiles',
+  clear: 'boolean'
+};
+
+// Looks up the line of a field from its path, e.g. ['profiles', 'test', 'mounts']
+type LineLookup = (fieldPath: string[]) => number | undefined;
+
+// Receives every problem found; throwing from it stops at the first one
+type ReportError = (error: ConfigValidationError) => void;
+
+const throwError: ReportError = (error) => {
+  throw error;
+};
+
+/**
+ * Parse and validate a YAML .aisanity file
+ * Unknown fields and type mismatches are reported with their line number
+ */
+export function parseAisanityYaml(content: string, configPath: string): unknown {
+  return decodeAisanityYaml(content, configPath, throwError);
+}
+
+/**
+ * Parse and validate a YAML .aisanity file like parseAisanityYaml, collecting every problem instead of stopping at the first
+ * @returns The parsed value (undefined when the YAML itself is broken) and the problems found
+ */
+export function collectAisanityYamlErrors(content: string, configPath: string): { value: unknown; errors: ConfigValidationError[] } {
+  const errors: ConfigValidationError[] = [];
+  const value = decodeAisanityYaml(content, configPath, (error) => errors.push(error));
+  return { value, errors };
+}
+
+function decodeAisanityYaml(content: string, configPath: string, report: ReportError): unknown {
+  const lineCounter = new YAML.LineCounter();
+  const doc = YAML.parseDocument(content, { lineCounter, uniqueKeys: true });
+
+  if (doc.errors.length > 0) {
+    doc.errors.forEach((error) => report(new ConfigValidationError(configPath, error.linePos?.[0]?.line, error.message.split('\n')[0])));
+    return undefined;
+  }
+
+  const keyLines = new Map<string, number>();
+  collectKeyLines(doc.contents, [], lineCounter, keyLines);
+
+  const value = doc.toJS();
+  checkAisanityConfig(value, configPath, (fieldPath) => keyLines.get(fieldPath.join('.')), report);
+  return value;
+}
+
+/**
+ * Validate a parsed .aisanity config object against the known fields
+ */
+export function validateAisanityConfig(value: unknown, configPath: string, lineOf: LineLookup = () => undefined): void {
+  checkAisanityConfig(value, configPath, lineOf, throwError);
+}
+
+/**
+ * Validate a parsed .aisanity config object, collecting every problem instead of stopping at the first
+ */
+export function collectAisanityConfigErrors(value: unknown, configPath: string, lineOf: LineLookup = () => undefined): ConfigValidationError[] {
+  const errors: ConfigValidationError[] = [];
+  checkAisanityConfig(value, configPath, lineOf, (error) => errors.push(error));
+  return errors;
+}
+
+function checkAisanityConfig(value: unknown, configPath: string, lineOf: LineLookup, report: ReportError): void {
+  if (value === null || value === undefined) {
+    return;
+  }
+
+  if (!isPlainObject(value)) {
+    report(new ConfigValidationError(configPath, undefined, 'expected a map of settings at the top level'));
+    return;
+  }
+
+  validateFields(value, CONFIG_FIELDS, [], configPath, lineOf, report);
+}
+
+function validateFields(
+  value: Record<string, unknown>,
+  fields: Record<string, FieldType>,
+  fieldPath: string[],
+  configPath: string,
+  lineOf: LineLookup,
+  report: ReportError
+): void {
+  for (const [key, fieldValue] of Object.entries(value)) {
+    const currentPath = [...fieldPath, key];
+    const type = fields[key];
+
+    if (!type) {
+      const suggestion = suggestField(key, Object.keys(fields));
+      const location = fieldPath.length > 0 ? ` in ${describePath(fieldPath)}` : '';
+      report(new ConfigValidationError(
+        configPath,
+        lineOf(currentPath),
+        `unknown field "${key}"${location}${suggestion ? ` (did you mean "${suggestion}"?)` : ''}`
+      ));
+      continue;
+    }
+
+    validateFieldType(fieldValue, type, currentPath, configPath, lineOf, report);
+  }
+}
+
+function validateFieldType(
+  value: unknown,
+  type: FieldType,
+  fieldPath: string[],
+  configPath: string,
+  lineOf: LineLookup,
+  report: ReportError
+): void {
+  // Empty values (e.g. "env:" with nothing after it) are treated as unset
+  if (value === null || value === undefined) {
+    return;
+  }
+
+  const fail = (expected: string): void => {
+    report(new ConfigValidationError(configPath, lineOf(fieldPath), `field "${fieldPath.join('.')}" must be ${expected}`));
+  };
+
+  switch (type) {
+    case 'string':
+      if (typeof value !== 'string') fail('a string');
+      break;
+    case 'boolean':
+      if (typeof value !== 'boolean') fail('true or false');
+      break;
+    case 'number':
+      if (typeof value !== 'number') fail('a number');
+      break;
+    case 'createMissing':
+      if (typeof value !== 'boolean' && value !== 'directory' && value !== 'file') fail('true, false, directory or file');
+      break;
+    case 'list':
+      if (!Array.isArray(value)) fail('a list');
+      break;
+    case 'map':
+      if (!isPlainObject(value)) fail('a map');
+      break;
+    case 'env':
+      if (!Array.isArray(value) && !isPlainObject(value)) fail('a map of KEY: value or a list of KEY entries');
+      break;
+    case 'command':
+      if (typeof value !== 'string' && !Array.isArray(value)) fail('a string or a list');
+      break;
+    case 'mounts':
+      if (!Array.isArray(value)) return fail('a list');
+      value.forEach((mount, index) => {
+        const mountPath = [...fieldPath, String(index)];
+        if (isPlainObject(mount)) {
+          validateFields(mount, MOUNT_FIELDS, mountPath, configPath, lineOf, report);
+        } else if (typeof mount !== 'string') {
+          report(new ConfigValidationError(configPath, lineOf(fieldPath), `field "${mountPath.join('.')}" must be a string or a map`));
+        }
+      });
+      break;
+    case 'secrets':
+      if (!Array.isArray(value)) return fail('a list');
+      value.forEach((secret, index) => {
+        const secretPath = [...fieldPath, String(index)];
+        if (!isPlainObject(secret)) {
+          report(new ConfigValidationError(configPath, lineOf(fieldPath), `field "${secretPath.join('.')}" must be a map with a source`));
+          return;
+        }
+        validateFields(secret, SECRET_FIELDS, secretPath, configPath, lineOf, report);
+      });
+      break;
+    case 'healthcheck':
+      if (!isPlainObject(value)) return fail('a map');
+      validateFields(value, HEALTHCHECK_FIELDS, fieldPath, configPath, lineOf, report);
+      break;
+    case 'build':
+      if (!isPlainObject(value)) return fail('a map');
+      validateFields(value, BUILD_FIELDS, fieldPath, configPath, lineOf, report);
+      break;
+    case 'resources':
+      if (!isPlainObject(value)) return fail('a map');
+      validateFields(value, RESOURCES_FIELDS, fieldPath, configPath, lineOf, report);
+      break;
+    case 'profile':
+      if (!isPlainObject(value)) return fail('a map');
+      validateFields(value, PROFILE_FIELDS, fieldPath, configPath, lineOf, report);
+      break;
+    case 'envProfiles':
+      if (!isPlainObject(value)) return fail('a map of env profile names to env blocks');
+      for (const [name, env] of Object.entries(value)) {
+        validateFieldType(env, 'env', [...fieldPath, name], configPath, lineOf, report);
+      }
+      break;
+    case 'profiles':
+      if (!isPlainObject(value)) return fail('a map of profile names to profiles');
+      for (const [name, profile] of Object.entries(value)) {
+        validateFieldType(profile, 'profile', [...fieldPath, name], configPath, lineOf, report);
+      }
+      break;
+  }
+}
+
+/**
+ * Record the line of every mapping key, keyed by its dotted path
+ */
+function collectKeyLines(node: unknown, fieldPath: string[], lineCounter: YAML.LineCounter, keyLines: Map<string, number>): void {
+  if (YAML.isSeq(node)) {
+    node.items.forEach((item, index) => collectKeyLines(item, [...fieldPath, String(index)], lineCounter, keyLines));
+    return;
+  }
+
+  if (!YAML.isMap(node)) {
+    return;
+  }
+
+  for (const pair of node.items) {
+    if (!YAML.isScalar(pair.key)) {
+      continue;
+    }
+
+    const currentPath = [...fieldPath, String(pair.key.value)];
+    if (pair.key.range) {
+      keyLines.set(currentPath.join('.'), lineCounter.linePos(pair.key.range[0]).line);
+    }
+    collectKeyLines(pair.value, currentPath, lineCounter, keyLines);
+  }
+}
+
+function describePath(fieldPath: string[]): string {
+  if (fieldPath[0] === 'profiles' && fieldPath.length === 2) {
+    return `profile '${fieldPath[1]}'`;
+  }
+  return `"${fieldPath.join('.')}"`;
+}
+
+/**
+ * Suggest a known field for a likely typo (at most two edits away)
+ */
+function suggestField(key: string, knownFields: string[]): string | undefined {
+  let best: { field: string; distance: number } | undefined;
+
+  for (const field of knownFields) {
+    const distance = editDistance(key.toLowerCase(), field.toLowerCase());
+    if (distance <= 2 && (!best || distance < best.distance)) {
+      best = { field, distance };
+    }
+  }
+
+  return best?.field;
+}
+
+function editDistance(a: string, b: string): number {
+  const previous = Array.from({ length: b.length + 1 }, (_, i) => i);
+
+  for (let i = 1; i <= a.length; i++) {
+    let diagonal = previous[0];
+    previous[0] = i;
+    for (let j = 1; j <= b.length; j++) {
+      const above = previous[j];
+      previous[j] = Math.min(previous[j] + 1, previous[j - 1] + 1, diagonal + (a[i - 1] === b[j - 1] ? 0 : 1));
+      diagonal = above;
+    }
+  }
+
+  return previous[b.length];
+}
+
+export function isPlainObject(value: unknown): value is Record<string, unknown> {
+  return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
@@ -1,15 +1,83 @@
 import * as fs from 'fs';
 import * as path from 'path';
+import * as os from 'os';
 import { execSync } from 'child_process';
 import * as YAML from 'yaml';
+import { Command } from 'commander';
 import { isWorktree as isWorktreeUtil, getWorktreeName as getWorktreeNameUtil } from './worktree-utils';
+import { generateContainerName } from './container-utils';
+import { validateProfilePorts, validateProfileResources, validateProfileNetworks, resolveProfileEnvironments, resolveProfileTemplates, getProfileWarnings } from './profile-utils';
+import { ConfigValidationError, parseAisanityYaml, validateAisanityConfig, isPlainObject } from './config-validation';
+import { print } from './display';
+
+export interface MountConfig {
+  source: string;                            // Host path, relative paths resolve against the workspace root
+  target: string;                            // Absolute path inside the container
+  readonly?: boolean;
+  consistency?: 'cached' | 'delegated' | 'consistent'; // macOS bind mount consistency
+  createMissing?: boolean | 'directory' | 'file'; // Create a missing source (true means a directory) instead of failing
+}
+
+export interface HealthcheckConfig {
+  command: string | string[];  // Run in the container; exit code 0 means healthy
+  interval?: string;           // Durations such as "5s" or "1m30s"
+  timeout?: string;
+  retries?: number;            // Consecutive failures before the container is unhealthy
+  startPeriod?: string;        // Failures during this period do not count
+}
+
+export interface BuildConfig {
+  context?: string;            // Build context, relative to the workspace root (default ".")
+  dockerfile?: string;         // Dockerfile, relative to the context (default "Dockerfile")
+}
+
+export interface ResourcesConfig {
+  cpus?: number;               // CPU cores, fractions allowed (docker run --cpus)
+  memory?: string;             // Memory limit such as "512m" or "2g" (docker run --memory)
+  pidsLimit?: number;          // Maximum number of processes, -1 for unlimited
+}
+
+export interface SecretConfig {
+  source: string;              // Host file, relative paths resolve against the workspace root
+  target?: string;             // Path inside the container (default /run/secrets/<file name>)
+}
+
+export interface ProfileConfig {
+  image?: string;              // Overrides the image from devcontainer.json
+  build?: BuildConfig;         // Build a local image with `aisanity build` (takes precedence over image)
+  mounts?: (string | MountConfig)[]; // Bind mounts: "source:target[:ro]" or a MountConfig map
+  exclude?: string[];          // Workspace subpaths hidden behind anonymous volumes, e.g. node_modules
+  env?: Record<string, string>; // KEY: value, KEY: ${HOST_VAR[:-default]}, or a list of KEY passthroughs
+  envFile?: string | string[]; // dotenv files relative to the workspace root, loaded under env
+  secrets?: SecretConfig[];    // Files mounted read-only into the container
+  ports?: string[];            // Published ports: "8080:8080", "127.0.0.1:5432:5432" or "8080" (random host port)
+  command?: string | string[]; // Default command for `aisanity run`
+  cacheVolumes?: Record<string, string>; // Named cache volume -> container path, kept across runs
+  healthcheck?: HealthcheckConfig;
+  resources?: ResourcesConfig;
+  network?: string;            // none, bridge, host or a named docker network
+  user?: string;               // "auto" (host uid:gid, the Linux default), "image" (the image's user) or e.g. "1000:1000"
+  runArgs?: string[];          // Extra docker run arguments, one per entry, appended verbatim and not validated
+  dockerAccess?: boolean;      // Mount the host Docker socket into the sandbox (gives it control of the host)
+  platform?: string;           // Image platform such as linux/amd64, emulated when it differs from the host
+  workdir?: string;            // Directory run and exec start commands in (default: the workspace folder)
+  restart?: string;            // Restart policy: no, on-failure[:max], unless-stopped or always
+  clear?: boolean;             // Ignore the user config for this block
+}
 
 export interface AisanityConfig {
+  extends?: string;                          // Parent config merged under this one, relative to this file
   workspace: string;
   containerName?: string;
   env?: Record<string, string>;
   envWhitelist?: string[];
+  envProfiles?: Record<string, Record<string, string>>; // Named env bundles layered on the run profile with --env-profile
   worktree?: boolean;
+  stopTimeout?: number;                      // Seconds to wait after SIGTERM before SIGKILL on stop
+  autoRecreate?: boolean;                    // Replace a reused container whose config changed instead of warning
+  base?: ProfileConfig;                      // Shared settings inherited by every profile
+  profiles?: Record<string, ProfileConfig>;  // Named sandbox profiles selected with --profile
+  clear?: boolean;                           // Ignore the user config entirely
 }
 
 export function getWorkspaceName(cwd: string): string {
@@ -73,8 +141,9 @@ export function validateContainerNameLength(containerName: string): string {
 /**
  * Get dynamic container name based on workspace and current branch
  * If containerName is explicitly set in config, use that
- * Otherwise, generate as {workspace}-{currentBranch} for main workspace
- * or {workspace}-{worktree-name} for worktrees
+ * Otherwise, generate as {workspace}-{currentBranch}-{pathHash} for main workspace
+ * or {workspace}-{worktree-name}-{pathHash} for worktrees.
+ * The path hash keeps names unique for workspaces that share a folder name.
  */
 export function getContainerName(cwd: string, verbose: boolean = false): string {
   const config = loadAisanityConfig(cwd);
@@ -87,37 +156,64 @@ export function getContainerName(cwd: string, verbose: boolean = false): string
     return config.containerName;
   }
 
+  const dynamicContainerName = generateContainerName(getContainerNamePrefix(cwd, verbose), cwd);
+
+  if (verbose) {
+    console.error(`Auto-generated container name: ${dynamicContainerName}`);
+  }
+
+  return dynamicContainerName;
+}
+
+/**
+ * Get the container name used before workspace path hashes were introduced
+ * Used as a fallback so containers created by older versions can still be found
+ */
+export function getLegacyContainerName(cwd: string): string {
+  const config = loadAisanityConfig(cwd);
+  if (!config) {
+    throw new Error('No .aisanity config found');
+  }
+
+  if (config.containerName) {
+    return config.containerName;
+  }
+
+  return getContainerNamePrefix(cwd);
+}
+
+/**
+ * Human-readable part of the container name: {workspace}-{branch} or {workspace}-{worktree-name}
+ */
+function getContainerNamePrefix(cwd: string, verbose: boolean = false): string {
   // Check if we're in a worktree
   const isWorktreeDir = isWorktree(cwd);
   
-  // Otherwise, generate dynamic container name
   const workspaceName = getWorkspaceName(cwd);
-  let dynamicContainerName: string;
+  let prefix: string;
   
   if (isWorktreeDir) {
     // For worktrees, use worktree name instead of branch
     const worktreeName = getWorktreeName(cwd);
     const sanitizedWorktreeName = sanitizeBranchName(worktreeName);
-    dynamicContainerName = `${workspaceName}-${sanitizedWorktreeName}`;
+    prefix = `${workspaceName}-${sanitizedWorktreeName}`;
     
     if (verbose) {
-      console.error(`Auto-generated container name: ${dynamicContainerName} (workspace: ${workspaceName}, worktree: ${worktreeName})`);
+      console.error(`Container name prefix: ${prefix} (workspace: ${workspaceName}, worktree: ${worktreeName})`);
     }
   } else {
     // For main workspace, use branch name
     const currentBranch = getCurrentBranch(cwd);
     const sanitizedBranch = sanitizeBranchName(currentBranch);
-    dynamicContainerName = `${workspaceName}-${sanitizedBranch}`;
+    prefix = `${workspaceName}-${sanitizedBranch}`;
     
     if (verbose) {
-      console.error(`Auto-generated container name: ${dynamicContainerName} (workspace: ${workspaceName}, branch: ${currentBranch})`);
+      console.error(`Container name prefix: ${prefix} (workspace: ${workspaceName}, branch: ${currentBranch})`);
     }
   }
 
   // Validate container name length
-  const validatedContainerName = validateContainerNameLength(dynamicContainerName);
-
-  return validatedContainerName;
+  return validateContainerNameLength(prefix);
 }
 
 export function createAisanityConfig(workspaceName: string): string {
@@ -133,34 +229,360 @@ export function createAisanityConfig(workspaceName: string): string {
 
 
 
-export function loadAisanityConfig(cwd: string): AisanityConfig | null {
+/**
+ * A config given with --config, used instead of the .aisanity of the workspace
+ * content is set when the config was read from stdin (path is then a placeholder for messages).
+ */
+export interface ConfigOverride {
+  path: string;
+  content?: string;
+}
+
+// Config given with --config, used by every config lookup of this invocation
+let configOverride: ConfigOverride | null = null;
+
+export function setConfigOverride(override: ConfigOverride | null): void {
+  configOverride = override;
+}
+
+export function getConfigOverride(): ConfigOverride | null {
+  return configOverride;
+}
+
+/**
+ * Resolve a --config value: "-" reads the config from stdin, anything else is a file path
+ * Config from stdin has no directory to find the workspace from, so --workspace is required with it.
+ */
+export function resolveConfigOption(
+  value: string,
+  workspace: string | undefined,
+  readStdin: () => string = () => fs.readFileSync(0, 'utf8')
+): ConfigOverride {
+  if (value === '-') {
+    if (!workspace) {
+      throw new Error('--config - reads the config from stdin, so the workspace root must be given with --workspace <path>');
+    }
+    return { path: path.join(path.resolve(workspace), '<stdin>'), content: readStdin() };
+  }
+
+  const configPath = path.resolve(value);
+  if (!fs.existsSync(configPath) || fs.statSync(configPath).isDirectory()) {
+    throw new Error(`Config file does not exist: ${configPath}`);
+  }
+  return { path: configPath };
+}
+
+/**
+ * Add --config to every command of the CLI and apply it before the action runs
+ */
+export function addConfigOptions(program: Command): void {
+  const visit = (command: Command) => {
+    for (const sub of command.commands) {
+      visit(sub);
+    }
+    if (command !== program && command.commands.length === 0) {
+      command.option('--config <path>', 'Config file to use instead of the nearest .aisanity ("-" reads it from stdin and needs --workspace)');
+    }
+  };
+  visit(program);
+
+  program.hook('preAction', (_program, actionCommand) => {
+    const options = actionCommand.opts();
+    if (options.config === undefined) {
+      return;
+    }
+    try {
+      setConfigOverride(resolveConfigOption(options.config, options.workspace));
+    } catch (error) {
+      console.error(error instanceof Error ? error.message : String(error));
+      process.exit(1);
+    }
+  });
+}
+
+/**
+ * Find the nearest directory with a .aisanity config, starting at startDir and walking up
+ * The search stops at a git repository root (a directory containing .git) or the filesystem root
+ */
+export function findWorkspaceRoot(startDir: string): string | null {
+  let dir = path.resolve(startDir);
+
+  while (true) {
+    if (fs.existsSync(path.join(dir, '.aisanity'))) {
+      return dir;
+    }
+
+    const parent = path.dirname(dir);
+    if (parent === dir || fs.existsSync(path.join(dir, '.git'))) {
+      return null;
+    }
+    dir = parent;
+  }
+}
+
+/**
+ * Get the workspace root for a command run from cwd
+ * Falls back to cwd when no config is found, so callers report the missing config as before
+ */
+export function getWorkspaceRoot(cwd: string): string {
+  // With --config there is nothing to search for; the workspace is cwd unless --workspace says otherwise
+  if (configOverride) {
+    return path.resolve(cwd);
+  }
+  return findWorkspaceRoot(cwd) || path.resolve(cwd);
+}
+
+/**
+ * Get the workspace a command works on: the --workspace option when given, otherwise the workspace root above the current directory
+ * A --workspace path that does not exist is reported and exits.
+ */
+export function resolveWorkspaceOption(options: { workspace?: string }): string {
+  if (!options.workspace) {
+    return getWorkspaceRoot(process.cwd());
+  }
+
+  const workspacePath = path.resolve(options.workspace);
+  if (!fs.existsSync(workspacePath)) {
+    console.error(`Workspace path does not exist: ${workspacePath}`);
+    process.exit(1);
+  }
+  return workspacePath;
+}
+
+/**
+ * Get the path of the config file loadAisanityConfig reads for a workspace, or null if there is none
+ */
+export function getAisanityConfigPath(cwd: string): string | null {
+  if (configOverride) {
+    return configOverride.content === undefined ? configOverride.path : null;
+  }
+
   const configPath = path.join(cwd, '.aisanity');
 
   if (!fs.existsSync(configPath)) {
     return null;
   }
 
-  try {
-    let configContent: string;
-    
-    // Check if .aisanity is a directory (new format) or file (old format)
-    if (fs.statSync(configPath).isDirectory()) {
-      const configFile = path.join(configPath, 'config.json');
-      if (!fs.existsSync(configFile)) {
-        return null;
+  if (fs.statSync(configPath).isDirectory()) {
+    const configFile = path.join(configPath, 'config.json');
+    return fs.existsSync(configFile) ? configFile : null;
+  }
+
+  return configPath;
+}
+
+/**
+ * Get the path of the machine-wide user config ($XDG_CONFIG_HOME/aisanity/config.yaml)
+ */
+export function getUserConfigPath(env: Record<string, string | undefined> = process.env): string {
+  const configHome = env.XDG_CONFIG_HOME || path.join(env.HOME || os.homedir(), '.config');
+  return path.join(configHome, 'aisanity', 'config.yaml');
+}
+
+/**
+ * Load the user config, or null when there is none
+ */
+export function loadUserConfig(env: Record<string, string | undefined> = process.env): Partial<AisanityConfig> | null {
+  const configPath = getUserConfigPath(env);
+  if (!fs.existsSync(configPath)) {
+    return null;
+  }
+  return parseAisanityYaml(fs.readFileSync(configPath, 'utf8'), configPath) as Partial<AisanityConfig> | null;
+}
+
+/**
+ * Merge the user config under a project config
+ * Maps merge key by key with the project winning, lists are appended after the user entries,
+ * and a project map with `clear: true` is taken as-is without anything from the user config.
+ */
+export function mergeUserConfig(user: Partial<AisanityConfig> | null, project: AisanityConfig): AisanityConfig {
+  if (!user) {
+    return project;
+  }
+  return mergeConfigValue(user, project, '') as AisanityConfig;
+}
+
+function mergeConfigValue(user: unknown, project: unknown, key: string): unknown {
+  if (project === undefined || project === null) {
+    return user ?? project;
+  }
+  if (user === undefined || user === null) {
+    return project;
+  }
+
+  // env accepts a map or a list of KEY / KEY=value entries; merge both forms as maps so keys override
+  if (key === 'env' && (Array.isArray(user) || Array.isArray(project))) {
+    return mergeConfigValue(envToMap(user), envToMap(project), '');
+  }
+
+  // A command is a single value even when written as a list
+  if (key === 'command') {
+    return project;
+  }
+
+  // runArgs are argv tokens whose flags repeat (--cap-add A --cap-add B), so nothing is deduplicated
+  if (key === 'runArgs' && Array.isArray(user) && Array.isArray(project)) {
+    return [...user, ...project];
+  }
+
+  if (Array.isArray(user) && Array.isArray(project)) {
+    const merged = [...user];
+    for (const entry of project) {
+      if (typeof entry === 'object' || !merged.includes(entry)) {
+        merged.push(entry);
       }
-      configContent = fs.readFileSync(configFile, 'utf8');
-      // Parse as JSON for new format
-      return JSON.parse(configContent) as AisanityConfig;
-    } else {
-      // Old format - .aisanity is a file
-      configContent = fs.readFileSync(configPath, 'utf8');
-      return YAML.parse(configContent) as AisanityConfig;
     }
-  } catch (error) {
-    console.error('Failed to parse .aisanity config:', error);
+    return merged;
+  }
+
+  if (isPlainObject(user) && isPlainObject(project)) {
+    if (project.clear === true) {
+      return project;
+    }
+    const merged: Record<string, unknown> = { ...user };
+    for (const [childKey, value] of Object.entries(project)) {
+      // Env bundles take the same forms as env blocks
+      merged[childKey] = mergeConfigValue(user[childKey], value, key === 'envProfiles' ? 'env' : childKey);
+    }
+    return merged;
+  }
+
+  return project;
+}
+
+/**
+ * Merge the chain of configs named by `extends` under a config, parent first
+ * Paths are relative to the file that names them. Parents merge like the user config does.
+ * @throws ConfigValidationError when a parent is missing or the chain loops back on itself
+ */
+export function resolveConfigExtends<T extends Partial<AisanityConfig>>(config: T, configPath: string, visited: string[] = []): T {
+  const chain = [...visited, path.resolve(configPath)];
+  if (!config || !config.extends) {
+    return config;
+  }
+
+  const { extends: parentRef, ...own } = config;
+  const parentPath = path.resolve(path.dirname(configPath), parentRef);
+  if (chain.includes(parentPath)) {
+    throw new ConfigValidationError(configPath, undefined, `circular extends: ${[...chain, parentPath].join(' -> ')}`);
+  }
+  if (!fs.existsSync(parentPath)) {
+    throw new ConfigValidationError(configPath, undefined, `extends ${parentRef}, but ${parentPath} does not exist`);
+  }
+
+  const parsed = parseAisanityYaml(fs.readFileSync(parentPath, 'utf8'), parentPath) as Partial<AisanityConfig> | null;
+  const parent = resolveConfigExtends(parsed || {}, parentPath, chain);
+  return mergeConfigValue(parent, own, '') as T;
+}
+
+function envToMap(env: unknown): Record<string, unknown> {
+  if (!Array.isArray(env)) {
+    return env as Record<string, unknown>;
+  }
+
+  const map: Record<string, unknown> = {};
+  for (const entry of env) {
+    const text = String(entry);
+    const equalIndex = text.indexOf('=');
+    // Bare keys stay null so they are still forwarded from the host
+    map[equalIndex === -1 ? text : text.substring(0, equalIndex)] = equalIndex === -1 ? null : text.substring(equalIndex + 1);
+  }
+  return map;
+}
+
+// Commands may load the config several times, but each warning is printed once
+const reportedWarnings = new Set<string>();
+
+function reportProfileWarnings(warnings: string[]): void {
+  for (const warning of warnings) {
+    if (!reportedWarnings.has(warning)) {
+      reportedWarnings.add(warning);
+      console.error(`Warning: ${warning}`);
+    }
+  }
+}
+
+/**
+ * Read the .aisanity config of a workspace with its extends parents and the user config merged in,
+ * before env interpolation and template expansion. Use loadAisanityConfig for the config commands run with.
+ */
+export function readAisanityConfig(cwd: string): AisanityConfig | null {
+  const config = readProjectConfig(cwd);
+
+  // Machine-wide defaults sit under the project config
+  return config ? mergeUserConfig(loadUserConfig(), config) : config;
+}
+
+/**
+ * Read the .aisanity config of a workspace with its extends parents, but without the user config,
+ * so the result is the same on every machine that checks out the project (the lock hashes it)
+ */
+export function readProjectConfig(cwd: string): AisanityConfig | null {
+  if (configOverride) {
+    const content = configOverride.content ?? fs.readFileSync(configOverride.path, 'utf8');
+    const config = parseAisanityYaml(content, configOverride.path) as AisanityConfig;
+    return config ? resolveConfigExtends(config, configOverride.path) : config;
+  }
+
+  const configPath = path.join(cwd, '.aisanity');
+
+  if (!fs.existsSync(configPath)) {
     return null;
   }
+
+  let config: AisanityConfig;
+
+  // Check if .aisanity is a directory (new format) or file (old format)
+  if (fs.statSync(configPath).isDirectory()) {
+    const configFile = path.join(configPath, 'config.json');
+    if (!fs.existsSync(configFile)) {
+      return null;
+    }
+    const configContent = fs.readFileSync(configFile, 'utf8');
+
+    // Parse as JSON for new format
+    let parsed: unknown;
+    try {
+      parsed = JSON.parse(configContent);
+    } catch (error) {
+      throw new ConfigValidationError(configFile, undefined, error instanceof Error ? error.message : String(error));
+    }
+    validateAisanityConfig(parsed, configFile);
+    config = parsed as AisanityConfig;
+  } else {
+    // Old format - .aisanity is a file, decoded strictly so typos are reported with their line
+    const configContent = fs.readFileSync(configPath, 'utf8');
+    config = parseAisanityYaml(configContent, configPath) as AisanityConfig;
+  }
+
+  // Parents named by extends sit under the file that extends them
+  if (config) {
+    config = resolveConfigExtends(config, fs.statSync(configPath).isDirectory() ? path.join(configPath, 'config.json') : configPath);
+  }
+
+  return config;
+}
+
+export function loadAisanityConfig(cwd: string): AisanityConfig | null {
+  const configPath = configOverride ? configOverride.path : path.join(cwd, '.aisanity');
+  let config = readAisanityConfig(cwd);
+
+  // Resolve and validate profile declarations instead of failing later at container creation
+  if (config) {
+    try {
+      const variables = { workspace: cwd, home: process.env.HOME || os.homedir() };
+      config = resolveProfileTemplates(config, variables);
+      config = resolveProfileEnvironments(config, process.env, variables);
+      validateProfilePorts(config);
+      validateProfileResources(config);
+      validateProfileNetworks(config);
+      reportProfileWarnings(getProfileWarnings(config));
+    } catch (error) {
+      throw new ConfigValidationError(configPath, undefined, error instanceof Error ? error.message : String(error));
+    }
+  }
+
+  return config;
 }
 
 
@@ -175,14 +597,14 @@ export async function setupOpencodeConfig(cwd: string): Promise<void> {
     fs.renameSync(oldConfigPath, newConfigPath);
     // Remove .opencode directory
     fs.rmSync(path.join(cwd, '.opencode'), { recursive: true, force: true });
-    console.log(`Migrated opencode config to ${newConfigPath}`);
+    print(`Migrated opencode config to ${newConfigPath}`);
   } else if (!fs.existsSync(newConfigPath)) {
     // Create a basic config if it doesn't exist
     const config = {};
     fs.writeFileSync(newConfigPath, JSON.stringify(config, null, 2), 'utf8');
-    console.log(`Created opencode config at ${newConfigPath}`);
+    print(`Created opencode config at ${newConfigPath}`);
   } else {
-    console.log(`Opencode config already exists at ${newConfigPath}`);
+    print(`Opencode config already exists at ${newConfigPath}`);
   }
 }
 
@@ -0,0 +1,1077 @@
+import * as fs from "fs";
+import * as path from "path";
+import { DEFAULT_DOCKER_TIMEOUT, DockerContainer, executeDockerCommand, parseDockerOutput } from "./container-utils";
+
+// Container runtime backends selectable with AISANITY_RUNTIME
+export type ContainerRuntimeName = "cli" | "sdk" | "podman";
+
+const RUNTIME_ENV_VAR = "AISANITY_RUNTIME";
+const DEFAULT_DOCKER_SOCKET = "/var/run/docker.sock";
+
+// Pulling large images can take a while on slow connections
+const PULL_TIMEOUT = 30 * 60 * 1000;
+
+export interface ListContainersOptions {
+  all?: boolean; // Include stopped containers (docker ps -a)
+  labels?: string[]; // Label filters: "key" or "key=value", all must match
+  ids?: string[]; // Container ID filters
+}
+
+export interface VolumeInfo {
+  name: string;
+  labels: Record<string, string>;
+}
+
+export interface ListVolumesOptions {
+  labels?: string[]; // Label filters: "key" or "key=value", all must match
+  names?: string[]; // Exact volume names
+}
+
+export interface LogOptions {
+  follow?: boolean;
+  tail?: string; // Number of lines from the end, or "all"
+  since?: string; // Timestamp or relative duration such as "42m"
+  idleTimeout?: number; // Stop following after this many milliseconds without new output
+}
+
+export interface BuildImageOptions {
+  context: string; // Absolute build context directory
+  dockerfile: string; // Absolute Dockerfile path
+  tag: string;
+  labels?: Record<string, string>;
+  platform?: string; // e.g. linux/amd64, built under emulation when it is not the host platform
+}
+
+export interface ContainerExitState {
+  running: boolean;
+  exitCode: number; // Exit code of the container's main process, 0 while it runs
+  oomKilled: boolean; // The kernel killed it for going over its memory limit
+  error: string; // Error reported by the runtime, empty when none
+}
+
+export interface ContainerHealth {
+  status: string; // "starting", "healthy" or "unhealthy"
+  log: { exitCode: number; output: string }[]; // Most recent checks, oldest first
+}
+
+/**
+ * Operations aisanity performs on containers directly
+ * The devcontainer CLI still creates and execs into containers; everything else goes through here.
+ * Methods throw when the daemon reports an error.
+ */
+export interface ContainerRuntime {
+  readonly name: ContainerRuntimeName;
+  readonly command: string; // docker-compatible CLI binary, also passed to the devcontainer CLI
+  // Version of the daemon (or podman service); throws when it cannot be reached
+  getServerVersion(debug?: boolean): Promise<string>;
+  listContainers(options: ListContainersOptions, debug?: boolean): Promise<DockerContainer[]>;
+  isContainerRunning(containerId: string, debug?: boolean): Promise<boolean>;
+  stopContainer(containerId: string, timeout: number, debug?: boolean): Promise<void>;
+  // Change the restart policy of an existing container, e.g. "no" or "on-failure:3"
+  setRestartPolicy(containerId: string, policy: string, debug?: boolean): Promise<void>;
+  removeContainer(containerId: string, debug?: boolean): Promise<void>;
+  // Host bindings of a container port such as "8080/tcp", e.g. "0.0.0.0:49153"
+  getPortBindings(containerId: string, containerPort: string, debug?: boolean): Promise<string[]>;
+  getContainerLabels(containerId: string, debug?: boolean): Promise<Record<string, string>>;
+  // Health of a container with a healthcheck, null when it has none
+  getContainerHealth(containerId: string, debug?: boolean): Promise<ContainerHealth | null>;
+  getContainerState(containerId: string, debug?: boolean): Promise<ContainerExitState>;
+  listVolumes(options: ListVolumesOptions, debug?: boolean): Promise<VolumeInfo[]>;
+  createVolume(name: string, labels: Record<string, string>, debug?: boolean): Promise<void>;
+  removeVolume(name: string, debug?: boolean): Promise<void>;
+  // Aborting the signal stops the pull; platform selects the image variant, e.g. linux/amd64
+  pullImage(image: string, debug?: boolean, signal?: AbortSignal, platform?: string): Promise<void>;
+  // Registry digests of a local image ("name@sha256:..."), empty when the image is not present
+  getImageDigests(image: string, debug?: boolean): Promise<string[]>;
+  hasImage(image: string, debug?: boolean): Promise<boolean>;
+  // Platform of a local image such as "linux/arm64/v8", null when the image is not present
+  getImagePlatform(image: string, debug?: boolean): Promise<string | null>;
+  // Build an image, writing the build output to stdout
+  buildImage(options: BuildImageOptions, debug?: boolean): Promise<void>;
+  // Write container logs to stdout/stderr until they end or the signal aborts; resolves to the exit code
+  streamLogs(containerId: string, options: LogOptions, signal?: AbortSignal, debug?: boolean): Promise<number>;
+  // Rewrite docker run flags for runtimes that need different ones
+  translateRunArgs?(runArgs: string[]): string[];
+}
+
+/**
+ * Runtime that shells out to the docker CLI
+ */
+export class DockerCliRuntime implements ContainerRuntime {
+  readonly name: ContainerRuntimeName = "cli";
+  readonly command: string = "docker";
+
+  // Go template for the labels column of ps output
+  protected labelsFormat = "{{.Labels}}";
+
+  protected parseListOutput(output: string): DockerContainer[] {
+    return parseDockerOutput(output);
+  }
+
+  // Go template printing the server version
+  protected versionCommand = "version --format \"{{.Server.Version}}\"";
+
+  async getServerVersion(debug: boolean = false): Promise<string> {
+    const result = await executeDockerCommand(`${this.command} ${this.versionCommand}`, { silent: true, debug });
+    if (!result.success) {
+      throw new Error(result.stderr.trim() || `${this.command} version failed`);
+    }
+    return result.stdout.trim();
+  }
+
+  async listContainers(options: ListContainersOptions, debug: boolean = false): Promise<DockerContainer[]> {
+    const filters = [
+      ...(options.labels || []).map((label) => `--filter "label=${label}"`),
+      ...(options.ids || []).map((id) => `--filter id=${id}`),
+    ];
+    const command = [
+      `${this.command} ps`,
+      options.all ? "-a" : "",
+      ...filters,
+      `--format "{{.ID}}\t{{.Names}}\t{{.Image}}\t{{.Status}}\t{{.Ports}}\t${this.labelsFormat}"`,
+    ]
+      .filter(Boolean)
+      .join(" ");
+
+    const result = await executeDockerCommand(command, { silent: true, debug });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+    return this.parseListOutput(result.stdout);
+  }
+
+  async isContainerRunning(containerId: string, debug: boolean = false): Promise<boolean> {
+    const result = await executeDockerCommand(`${this.command} inspect --format "{{.State.Running}}" ${containerId}`, {
+      silent: true,
+      debug,
+    });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+    return result.stdout.trim() === "true";
+  }
+
+  async stopContainer(containerId: string, timeout: number, debug: boolean = false): Promise<void> {
+    // Allow the docker CLI to outlive the grace period
+    const result = await executeDockerCommand(`${this.command} stop --time ${timeout} ${containerId}`, {
+      silent: true,
+      debug,
+      timeout: timeout * 1000 + DEFAULT_DOCKER_TIMEOUT,
+    });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+  }
+
+  async setRestartPolicy(containerId: string, policy: string, debug: boolean = false): Promise<void> {
+    const result = await executeDockerCommand(`${this.command} update --restart ${policy} ${containerId}`, { silent: true, debug });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+  }
+
+  async removeContainer(containerId: string, debug: boolean = false): Promise<void> {
+    // -v also removes anonymous volumes (profile excludes); named volumes are kept
+    const result = await executeDockerCommand(`${this.command} rm -v ${containerId}`, { silent: true, debug });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+  }
+
+  async getPortBindings(containerId: string, containerPort: string, debug: boolean = false): Promise<string[]> {
+    const result = await executeDockerCommand(`${this.command} port ${containerId} ${containerPort}`, { silent: true, debug });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+    return result.stdout
+      .trim()
+      .split("\n")
+      .map((line) => line.trim())
+      .filter((line) => line !== "");
+  }
+
+  async getContainerLabels(containerId: string, debug: boolean = false): Promise<Record<string, string>> {
+    const result = await executeDockerCommand(`${this.command} inspect --format "{{json .Config.Labels}}" ${containerId}`, {
+      silent: true,
+      debug,
+    });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+    return JSON.parse(result.stdout.trim()) || {};
+  }
+
+  async getContainerHealth(containerId: string, debug: boolean = false): Promise<ContainerHealth | null> {
+    const result = await executeDockerCommand(`${this.command} inspect --format "{{json .State.Health}}" ${containerId}`, {
+      silent: true,
+      debug,
+    });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+    return fromApiHealth(JSON.parse(result.stdout.trim()));
+  }
+
+  async getContainerState(containerId: string, debug: boolean = false): Promise<ContainerExitState> {
+    const result = await executeDockerCommand(`${this.command} inspect --format "{{json .State}}" ${containerId}`, {
+      silent: true,
+      debug,
+    });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+    return fromApiState(JSON.parse(result.stdout.trim()));
+  }
+
+  async listVolumes(options: ListVolumesOptions, debug: boolean = false): Promise<VolumeInfo[]> {
+    const filters = [
+      ...(options.labels || []).map((label) => `--filter "label=${label}"`),
+      ...(options.names || []).map((name) => `--filter name=${name}`),
+    ];
+    const result = await executeDockerCommand([`${this.command} volume ls`, ...filters, '--format "{{.Name}}"'].join(" "), {
+      silent: true,
+      debug,
+    });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+
+    // The name filter matches substrings, so keep exact matches only
+    const names = result.stdout
+      .split("\n")
+      .map((line) => line.trim())
+      .filter((name) => name !== "" && (!options.names || options.names.includes(name)));
+    if (names.length === 0) {
+      return [];
+    }
+
+    const inspect = await executeDockerCommand(`${this.command} volume inspect --format "{{json .Labels}}" ${names.join(" ")}`, {
+      silent: true,
+      debug,
+    });
+    if (!inspect.success) {
+      throw new Error(inspect.stderr);
+    }
+
+    const labels = inspect.stdout.split("\n").filter((line) => line.trim() !== "");
+    return names.map((name, index) => ({ name, labels: JSON.parse(labels[index] || "null") || {} }));
+  }
+
+  async createVolume(name: string, labels: Record<string, string>, debug: boolean = false): Promise<void> {
+    const labelArgs = Object.entries(labels).map(([key, value]) => `--label "${key}=${value}"`);
+    const result = await executeDockerCommand([`${this.command} volume create`, ...labelArgs, name].join(" "), {
+      silent: true,
+      debug,
+    });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+  }
+
+  async removeVolume(name: string, debug: boolean = false): Promise<void> {
+    const result = await executeDockerCommand(`${this.command} volume rm ${name}`, { silent: true, debug });
+    if (!result.success) {
+      throw new Error(result.stderr);
+    }
+  }
+
+  async pullImage(image: string, debug: boolean = false, signal?: AbortSignal, platform?: string): Promise<void> {
+    const args = ["pull", ...(platform ? ["--platform", platform] : []), image];
+    if (debug) {
+      console.log(`[Docker] Executing: ${this.command} ${args.join(" ")}`);
+    }
+    signal?.throwIfAborted();
+
+    // Progress goes straight to the terminal
+    const child = Bun.spawn([this.command, ...args], { stdio: ["ignore", "inherit", "inherit"] });
+    const abort = () => child.kill();
+    signal?.addEventListener("abort", abort, { once: true });
+    const exitCode = await child.exited;
+    signal?.removeEventListener("abort", abort);
+    signal?.throwIfAborted();
+    if (exitCode !== 0) {
+      throw new Error(`${this.command} pull ${image} failed with code ${exitCode}`);
+    }
+  }
+
+  async getImageDigests(image: string, debug: boolean = false): Promise<string[]> {
+    const result = await executeDockerCommand(`${this.command} image inspect --format "{{json .RepoDigests}}" ${image}`, {
+      silent: true,
+      debug,
+    });
+    if (!result.success) {
+      if (/no such image|image not known/i.test(result.stderr)) {
+        return [];
+      }
+      throw new Error(result.stderr);
+    }
+    return JSON.parse(result.stdout.trim()) || [];
+  }
+
+  async hasImage(image: string, debug: boolean = false): Promise<boolean> {
+    const result = await executeDockerCommand(`${this.command} image inspect --format "{{.Id}}" ${image}`, {
+      silent: true,
+      debug,
+    });
+    if (!result.success && !/no such image|image not known/i.test(result.stderr)) {
+      throw new Error(result.stderr);
+    }
+    return result.success;
+  }
+
+  async getImagePlatform(image: string, debug: boolean = false): Promise<string | null> {
+    const result = await executeDockerCommand(
+      `${this.command} image inspect --format "{{.Os}}/{{.Architecture}}{{if .Variant}}/{{.Variant}}{{end}}" ${image}`,
+      { silent: true, debug },
+    );
+    if (!result.success) {
+      if (/no such image|image not known/i.test(result.stderr)) {
+        return null;
+      }
+      throw new Error(result.stderr);
+    }
+    return result.stdout.trim();
+  }
+
+  async buildImage(options: BuildImageOptions, debug: boolean = false): Promise<void> {
+    const args = ["build", "-f", options.dockerfile, "-t", options.tag];
+    if (options.platform) {
+      args.push("--platform", options.platform);
+    }
+    for (const [key, value] of Object.entries(options.labels || {})) {
+      args.push("--label", `${key}=${value}`);
+    }
+    args.push(options.context);
+
+    if (debug) {
+      console.log(`[Docker] Executing: ${this.command} ${args.join(" ")}`);
+    }
+
+    // Build output goes straight to the terminal
+    const child = Bun.spawn([this.command, ...args], { stdio: ["ignore", "inherit", "inherit"] });
+    const exitCode = await child.exited;
+    if (exitCode !== 0) {
+      throw new Error(`${this.command} build failed with code ${exitCode}`);
+    }
+  }
+
+  async streamLogs(containerId: string, options: LogOptions, signal?: AbortSignal, debug: boolean = false): Promise<number> {
+    const args = ["logs"];
+    if (options.follow) args.push("--follow");
+    if (options.tail !== undefined) args.push("--tail", options.tail);
+    if (options.since !== undefined) args.push("--since", options.since);
+    args.push(containerId);
+
+    if (debug) {
+      console.log(`[Docker] Executing: ${this.command} ${args.join(" ")}`);
+    }
+
+    // With an idle timeout the output passes through aisanity, so each chunk can reset the timer
+    const idle = createIdleSignal(options.idleTimeout, signal);
+    const output = options.idleTimeout !== undefined ? "pipe" : "inherit";
+    const child = Bun.spawn([this.command, ...args], { stdio: ["ignore", output, output] });
+    const onAbort = () => child.kill();
+    idle.signal.addEventListener("abort", onAbort, { once: true });
+
+    const forward = async (stream: ReadableStream<Uint8Array>, target: NodeJS.WriteStream) => {
+      for await (const chunk of stream as unknown as AsyncIterable<Uint8Array>) {
+        idle.reset();
+        target.write(chunk);
+      }
+    };
+
+    try {
+      const [exitCode] = await Promise.all([
+        child.exited,
+        ...(output === "pipe"
+          ? [forward(child.stdout as ReadableStream<Uint8Array>, process.stdout), forward(child.stderr as ReadableStream<Uint8Array>, process.stderr)]
+          : []),
+      ]);
+      // Ctrl-C reaches the CLI as well; an interrupted follow is a normal way to stop, as is going idle
+      if (idle.signal.aborted || child.signalCode === "SIGINT" || exitCode === 130) {
+        return 0;
+      }
+      return exitCode;
+    } finally {
+      idle.signal.removeEventListener("abort", onAbort);
+      idle.dispose();
+    }
+  }
+}
+
+/**
+ * Runtime for rootless Podman, using its docker-compatible CLI
+ * Podman prints labels as a Go map in ps output, so they are requested as JSON instead.
+ */
+export class PodmanCliRuntime extends DockerCliRuntime {
+  readonly name: ContainerRuntimeName = "podman";
+  readonly command: string = "podman";
+
+  protected labelsFormat = "{{json .Labels}}";
+
+  // podman version only reports a server for remote connections; info always needs the service
+  protected versionCommand = "info --format \"{{.Version.Version}}\"";
+
+  protected parseListOutput(output: string): DockerContainer[] {
+    return parsePodmanPsOutput(output);
+  }
+
+  translateRunArgs(runArgs: string[]): string[] {
+    return translatePodmanRunArgs(runArgs);
+  }
+}
+
+/**
+ * Parse podman ps output where the labels column is a JSON object
+ */
+export function parsePodmanPsOutput(output: string): DockerContainer[] {
+  const containers: DockerContainer[] = [];
+
+  for (const line of output.split("\n")) {
+    if (line.trim() === "") {
+      continue;
+    }
+
+    const parts = line.split("\t");
+    if (parts.length < 6) {
+      continue;
+    }
+
+    const [id, name, image, status, ports] = parts;
+    let labels: Record<string, string> = {};
+    try {
+      labels = JSON.parse(parts.slice(5).join("\t")) || {};
+    } catch (error) {
+      // Containers without labels print an empty column
+    }
+
+    containers.push({ id, name, image, status, labels, ports: ports || "" });
+  }
+
+  return containers;
+}
+
+/**
+ * Translate docker run flags into their podman equivalents
+ * Rootless podman maps the host user to root by default; keep-id keeps workspace files owned by the host user.
+ */
+export function translatePodmanRunArgs(runArgs: string[]): string[] {
+  const translated: string[] = [];
+
+  for (let i = 0; i < runArgs.length; i++) {
+    const arg = runArgs[i];
+    if (arg === "--gpus" && i + 1 < runArgs.length) {
+      translated.push("--device", `nvidia.com/gpu=${runArgs[++i]}`);
+    } else if (arg.startsWith("--gpus=")) {
+      translated.push("--device", `nvidia.com/gpu=${arg.substring("--gpus=".length)}`);
+    } else {
+      translated.push(arg);
+    }
+  }
+
+  if (!translated.some((arg) => arg === "--userns" || arg.startsWith("--userns="))) {
+    translated.push("--userns=keep-id");
+  }
+
+  return translated;
+}
+
+// Subset of the Docker Engine API container list entry used by aisanity
+export interface DockerApiContainer {
+  Id: string;
+  Names?: string[];
+  Image?: string;
+  Status?: string;
+  Ports?: { IP?: string; PrivatePort: number; PublicPort?: number; Type: string }[];
+  Labels?: Record<string, string> | null;
+}
+
+/**
+ * Runtime that talks to the Docker Engine API directly, for hosts where only the socket is available
+ * Output is normalized to what the docker CLI prints so callers behave identically with both runtimes.
+ */
+export class DockerApiRuntime implements ContainerRuntime {
+  readonly name: ContainerRuntimeName = "sdk";
+  readonly command: string = "docker";
+
+  constructor(private readonly dockerHost: string = getDockerHost()) {}
+
+  async getServerVersion(debug: boolean = false): Promise<string> {
+    const version = await this.request<{ Version: string }>("GET", "/version", debug);
+    return version.Version;
+  }
+
+  async listContainers(options: ListContainersOptions, debug: boolean = false): Promise<DockerContainer[]> {
+    const filters: Record<string, string[]> = {};
+    if (options.labels && options.labels.length > 0) {
+      filters.label = options.labels;
+    }
+    if (options.ids && options.ids.length > 0) {
+      filters.id = options.ids;
+    }
+
+    const query = new URLSearchParams({ all: options.all ? "1" : "0", filters: JSON.stringify(filters) });
+    const containers = await this.request<DockerApiContainer[]>("GET", `/containers/json?${query}`, debug);
+    return containers.map(fromApiContainer);
+  }
+
+  async isContainerRunning(containerId: string, debug: boolean = false): Promise<boolean> {
+    const info = await this.inspect(containerId, debug);
+    return Boolean(info.State?.Running);
+  }
+
+  async stopContainer(containerId: string, timeout: number, debug: boolean = false): Promise<void> {
+    await this.request("POST", `/containers/${encodeURIComponent(containerId)}/stop?t=${timeout}`, debug, {
+      timeout: timeout * 1000 + DEFAULT_DOCKER_TIMEOUT,
+    });
+  }
+
+  async setRestartPolicy(containerId: string, policy: string, debug: boolean = false): Promise<void> {
+    const [name, maxRetries] = policy.split(":");
+    await this.request("POST", `/containers/${encodeURIComponent(containerId)}/update`, debug, {
+      body: { RestartPolicy: { Name: name, MaximumRetryCount: Number(maxRetries || 0) } },
+    });
+  }
+
+  async removeContainer(containerId: string, debug: boolean = false): Promise<void> {
+    await this.request("DELETE", `/containers/${encodeURIComponent(containerId)}?v=1`, debug);
+  }
+
+  async getPortBindings(containerId: string, containerPort: string, debug: boolean = false): Promise<string[]> {
+    const info = await this.inspect(containerId, debug);
+    const bindings: { HostIp?: string; HostPort?: string }[] = info.NetworkSettings?.Ports?.[containerPort] || [];
+    return bindings.map((binding) => formatHostAddress(binding.HostIp || "0.0.0.0", binding.HostPort || ""));
+  }
+
+  async getContainerLabels(containerId: string, debug: boolean = false): Promise<Record<string, string>> {
+    const info = await this.inspect(containerId, debug);
+    return info.Config?.Labels || {};
+  }
+
+  async getContainerHealth(containerId: string, debug: boolean = false): Promise<ContainerHealth | null> {
+    const info = await this.inspect(containerId, debug);
+    return fromApiHealth(info.State?.Health);
+  }
+
+  async getContainerState(containerId: string, debug: boolean = false): Promise<ContainerExitState> {
+    const info = await this.inspect(containerId, debug);
+    return fromApiState(info.State);
+  }
+
+  async listVolumes(options: ListVolumesOptions, debug: boolean = false): Promise<VolumeInfo[]> {
+    const filters: Record<string, string[]> = {};
+    if (options.labels && options.labels.length > 0) {
+      filters.label = options.labels;
+    }
+    if (options.names && options.names.length > 0) {
+      filters.name = options.names;
+    }
+
+    const query = new URLSearchParams({ filters: JSON.stringify(filters) });
+    const result = await this.request<{ Volumes: { Name: string; Labels?: Record<string, string> | null }[] | null }>(
+      "GET",
+      `/volumes?${query}`,
+      debug,
+    );
+
+    return (result.Volumes || [])
+      .filter((volume) => !options.names || options.names.includes(volume.Name))
+      .map((volume) => ({ name: volume.Name, labels: volume.Labels || {} }));
+  }
+
+  async createVolume(name: string, labels: Record<string, string>, debug: boolean = false): Promise<void> {
+    await this.request("POST", "/volumes/create", debug, { body: { Name: name, Labels: labels } });
+  }
+
+  async removeVolume(name: string, debug: boolean = false): Promise<void> {
+    await this.request("DELETE", `/volumes/${encodeURIComponent(name)}`, debug);
+  }
+
+  async pullImage(image: string, debug: boolean = false, signal?: AbortSignal, platform?: string): Promise<void> {
+    const query = new URLSearchParams({ fromImage: image, ...(platform ? { platform } : {}) });
+    const progress = await this.request<string>("POST", `/images/create?${query}`, debug, { timeout: PULL_TIMEOUT, raw: true, signal });
+
+    // The daemon answers 200 and reports pull failures in the progress stream
+    for (const line of progress.split("\n")) {
+      if (line.includes('"error"')) {
+        throw new Error(`Error response from daemon: ${JSON.parse(line).error}`);
+      }
+    }
+  }
+
+  async getImageDigests(image: string, debug: boolean = false): Promise<string[]> {
+    try {
+      const info = await this.request<{ RepoDigests?: string[] | null }>("GET", `/images/${encodeURIComponent(image)}/json`, debug);
+      return info.RepoDigests || [];
+    } catch (error: unknown) {
+      if (error instanceof Error && /no such image/i.test(error.message)) {
+        return [];
+      }
+      throw error;
+    }
+  }
+
+  async hasImage(image: string, debug: boolean = false): Promise<boolean> {
+    try {
+      await this.request("GET", `/images/${encodeURIComponent(image)}/json`, debug);
+      return true;
+    } catch (error: unknown) {
+      if (error instanceof Error && /no such image/i.test(error.message)) {
+        return false;
+      }
+      throw error;
+    }
+  }
+
+  async getImagePlatform(image: string, debug: boolean = false): Promise<string | null> {
+    try {
+      const info = await this.request<{ Os: string; Architecture: string; Variant?: string }>("GET", `/images/${encodeURIComponent(image)}/json`, debug);
+      return [info.Os, info.Architecture, info.Variant].filter(Boolean).join("/");
+    } catch (error: unknown) {
+      if (error instanceof Error && /no such image/i.test(error.message)) {
+        return null;
+      }
+      throw error;
+    }
+  }
+
+  async buildImage(options: BuildImageOptions, debug: boolean = false): Promise<void> {
+    // The daemon reads the Dockerfile from the context archive
+    const dockerfile = path.relative(options.context, options.dockerfile);
+    if (dockerfile.startsWith("..") || path.isAbsolute(dockerfile)) {
+      throw new Error(`The Dockerfile must be inside the build context with the sdk runtime: ${options.dockerfile}`);
+    }
+
+    const tar = Bun.spawn(["tar", "-c", "-C", options.context, "."], { stdout: "pipe", stderr: "pipe" });
+    const archive = await new Response(tar.stdout).arrayBuffer();
+    if ((await tar.exited) !== 0) {
+      throw new Error(`Failed to archive the build context ${options.context}: ${(await new Response(tar.stderr).text()).trim()}`);
+    }
+
+    const query = new URLSearchParams({
+      t: options.tag,
+      dockerfile,
+      labels: JSON.stringify(options.labels || {}),
+      ...(options.platform ? { platform: options.platform } : {}),
+    });
+    const { url, socketPath } = resolveApiUrl(this.dockerHost, `/build?${query}`);
+    if (debug) {
+      console.log(`[Docker API] POST /build?${query}`);
+    }
+
+    let response: Response;
+    try {
+      response = await fetch(url, {
+        method: "POST",
+        body: archive,
+        headers: { "Content-Type": "application/x-tar" },
+        signal: AbortSignal.timeout(PULL_TIMEOUT),
+        ...(socketPath ? { unix: socketPath } : {}),
+      } as RequestInit);
+    } catch (error: unknown) {
+      const message = error instanceof Error ? error.message : "Unknown error";
+      throw new Error(`Cannot connect to the Docker daemon at ${this.dockerHost}: ${message}\n\nSuggestion: Is Docker running?`);
+    }
+
+    if (!response.ok || !response.body) {
+      const body = await response.text();
+      let message = body.trim();
+      try {
+        message = JSON.parse(body).message || message;
+      } catch (error) {
+        // Keep the raw body
+      }
+      throw new Error(`Error response from daemon: ${message}`);
+    }
+
+    // The build output is a stream of JSON lines; failures are reported in it with a 200 status
+    const decoder = new TextDecoder();
+    let pending = "";
+    const handleLine = (line: string) => {
+      if (line.trim() === "") {
+        return;
+      }
+      const message = JSON.parse(line);
+      if (message.error) {
+        throw new Error(`Error response from daemon: ${message.error}`);
+      }
+      if (message.stream) {
+        process.stdout.write(message.stream);
+      }
+    };
+
+    for await (const chunk of response.body as unknown as AsyncIterable<Uint8Array>) {
+      pending += decoder.decode(chunk, { stream: true });
+      const lines = pending.split("\n");
+      pending = lines.pop() || "";
+      lines.forEach(handleLine);
+    }
+    handleLine(pending);
+  }
+
+  async streamLogs(containerId: string, options: LogOptions, signal?: AbortSignal, debug: boolean = false): Promise<number> {
+    const info = await this.inspect(containerId, debug);
+
+    const query = new URLSearchParams({ stdout: "1", stderr: "1", follow: options.follow ? "1" : "0" });
+    if (options.tail !== undefined) query.set("tail", options.tail);
+    if (options.since !== undefined) query.set("since", String(parseLogSince(options.since)));
+
+    const { url, socketPath } = resolveApiUrl(this.dockerHost, `/containers/${encodeURIComponent(containerId)}/logs?${query}`);
+    if (debug) {
+      console.log(`[Docker API] GET /containers/${containerId}/logs?${query}`);
+    }
+
+    const idle = createIdleSignal(options.idleTimeout, signal);
+    let response: Response;
+    try {
+      response = await fetch(url, { signal: idle.signal, ...(socketPath ? { unix: socketPath } : {}) } as RequestInit);
+    } catch (error: unknown) {
+      idle.dispose();
+      if (idle.signal.aborted) {
+        return 0;
+      }
+      const message = error instanceof Error ? error.message : "Unknown error";
+      throw new Error(`Cannot connect to the Docker daemon at ${this.dockerHost}: ${message}\n\nSuggestion: Is Docker running?`);
+    }
+
+    if (!response.ok || !response.body) {
+      idle.dispose();
+      throw new Error(`Error response from daemon: ${(await response.text()).trim()}`);
+    }
+
+    // Without a TTY the daemon multiplexes stdout and stderr into one framed stream
+    const write = info.Config?.Tty
+      ? (chunk: Uint8Array) => process.stdout.write(chunk)
+      : createLogDemuxer((stream, chunk) => (stream === 2 ? process.stderr : process.stdout).write(chunk));
+
+    try {
+      for await (const chunk of response.body as unknown as AsyncIterable<Uint8Array>) {
+        idle.reset();
+        write(chunk);
+      }
+    } catch (error: unknown) {
+      if (!idle.signal.aborted) {
+        throw error;
+      }
+    } finally {
+      idle.dispose();
+    }
+    return 0;
+  }
+
+  private inspect(containerId: string, debug: boolean): Promise<any> {
+    return this.request("GET", `/containers/${encodeURIComponent(containerId)}/json`, debug);
+  }
+
+  private async request<T = unknown>(
+    method: string,
+    apiPath: string,
+    debug: boolean,
+    options?: { timeout?: number; body?: unknown; raw?: boolean; signal?: AbortSignal },
+  ): Promise<T> {
+    const startTime = Date.now();
+    const { url, socketPath } = resolveApiUrl(this.dockerHost, apiPath);
+
+    if (debug) {
+      console.log(`[Docker API] ${method} ${apiPath}`);
+    }
+
+    let response: Response;
+    try {
+      response = await fetch(url, {
+        method,
+        signal: options?.signal
+          ? AbortSignal.any([options.signal, AbortSignal.timeout(options.timeout || DEFAULT_DOCKER_TIMEOUT)])
+          : AbortSignal.timeout(options?.timeout || DEFAULT_DOCKER_TIMEOUT),
+        ...(options?.body !== undefined
+          ? { body: JSON.stringify(options.body), headers: { "Content-Type": "application/json" } }
+          : {}),
+        ...(socketPath ? { unix: socketPath } : {}),
+      } as RequestInit);
+    } catch (error: unknown) {
+      if (options?.signal?.aborted) {
+        throw options.signal.reason;
+      }
+      const message = error instanceof Error ? error.message : "Unknown error";
+      throw new Error(`Cannot connect to the Docker daemon at ${this.dockerHost}: ${message}\n\nSuggestion: Is Docker running?`);
+    }
+
+    const body = await response.text();
+
+    if (debug) {
+      console.log(`[Docker API] ${response.status} (${Date.now() - startTime}ms)`);
+    }
+
+    // 304 is returned when stopping a container that is already stopped
+    if (!response.ok && response.status !== 304) {
+      let message = body.trim();
+      try {
+        message = JSON.parse(body).message || message;
+      } catch (error) {
+        // Keep the raw body
+      }
+      throw new Error(`Error response from daemon: ${message}`);
+    }
+
+    if (options?.raw) {
+      return body as T;
+    }
+    return (body ? JSON.parse(body) : undefined) as T;
+  }
+}
+
+/**
+ * Split the Docker API's multiplexed log stream into stdout (1) and stderr (2) frames
+ * Each frame has an 8 byte header: stream type, three padding bytes and a big-endian payload size.
+ * Frames can span chunks, so incomplete data is buffered until the rest arrives.
+ */
+export function createLogDemuxer(write: (stream: number, payload: Uint8Array) => void): (chunk: Uint8Array) => void {
+  let buffer = new Uint8Array(0);
+
+  return (chunk: Uint8Array) => {
+    const combined = new Uint8Array(buffer.length + chunk.length);
+    combined.set(buffer);
+    combined.set(chunk, buffer.length);
+
+    let offset = 0;
+    while (combined.length - offset >= 8) {
+      const size = new DataView(combined.buffer, combined.byteOffset + offset + 4, 4).getUint32(0);
+      if (combined.length - offset - 8 < size) {
+        break;
+      }
+      write(combined[offset], combined.subarray(offset + 8, offset + 8 + size));
+      offset += 8 + size;
+    }
+
+    buffer = combined.slice(offset);
+  };
+}
+
+/**
+ * An AbortSignal that aborts with the given signal, or once no reset came for timeoutMs
+ * Without a timeout it only follows the given signal. Call dispose to stop the timer.
+ */
+export function createIdleSignal(
+  timeoutMs: number | undefined,
+  signal?: AbortSignal,
+): { signal: AbortSignal; reset: () => void; dispose: () => void } {
+  const controller = new AbortController();
+  const onAbort = () => controller.abort(signal?.reason);
+  if (signal?.aborted) {
+    controller.abort(signal.reason);
+  }
+  signal?.addEventListener("abort", onAbort, { once: true });
+
+  let timer: ReturnType<typeof setTimeout> | undefined;
+  const reset = () => {
+    if (timeoutMs === undefined || controller.signal.aborted) {
+      return;
+    }
+    clearTimeout(timer);
+    timer = setTimeout(() => controller.abort("idle"), timeoutMs);
+  };
+  reset();
+
+  return {
+    signal: controller.signal,
+    reset,
+    dispose: () => {
+      clearTimeout(timer);
+      signal?.removeEventListener("abort", onAbort);
+    },
+  };
+}
+
+/**
+ * Convert a docker logs --since value into a unix timestamp (seconds)
+ * Accepts relative durations ("90s", "42m", "1h30m"), unix timestamps and RFC 3339 dates.
+ */
+export function parseLogSince(value: string, now: number = Date.now()): number {
+  const trimmed = value.trim();
+
+  if (/^\d+(\.\d+)?$/.test(trimmed)) {
+    return Math.floor(Number(trimmed));
+  }
+
+  if (/^(\d+(ms|s|m|h))+$/.test(trimmed)) {
+    const units: Record<string, number> = { ms: 1, s: 1000, m: 60000, h: 3600000 };
+    let millis = 0;
+    for (const [, amount, unit] of trimmed.matchAll(/(\d+)(ms|s|m|h)/g)) {
+      millis += Number(amount) * units[unit];
+    }
+    return Math.floor((now - millis) / 1000);
+  }
+
+  const date = Date.parse(trimmed);
+  if (/^\d{4}-\d{2}-\d{2}/.test(trimmed) && !Number.isNaN(date)) {
+    return Math.floor(date / 1000);
+  }
+
+  throw new Error(`Invalid --since value "${value}". Use a duration like 42m or a timestamp like 2024-01-02T13:23:37Z`);
+}
+
+/**
+ * Convert the State.Health object of docker inspect (same shape in the API) into a ContainerHealth
+ */
+export function fromApiHealth(
+  health: { Status?: string; Log?: { ExitCode?: number; Output?: string }[] | null } | null | undefined,
+): ContainerHealth | null {
+  if (!health || !health.Status || health.Status === "none") {
+    return null;
+  }
+  return {
+    status: health.Status,
+    log: (health.Log || []).map((entry) => ({ exitCode: entry.ExitCode ?? -1, output: entry.Output || "" })),
+  };
+}
+
+/**
+ * Convert the State object of docker inspect (same shape in the API) into a ContainerExitState
+ */
+export function fromApiState(
+  state: { Running?: boolean; ExitCode?: number; OOMKilled?: boolean; Error?: string } | null | undefined,
+): ContainerExitState {
+  return {
+    running: Boolean(state?.Running),
+    exitCode: state?.ExitCode ?? 0,
+    oomKilled: Boolean(state?.OOMKilled),
+    error: state?.Error || "",
+  };
+}
+
+/**
+ * Convert a Docker Engine API container into the docker ps representation
+ */
+export function fromApiContainer(container: DockerApiContainer): DockerContainer {
+  return {
+    id: container.Id.substring(0, 12),
+    name: (container.Names?.[0] || "").replace(/^\//, ""),
+    image: container.Image || "",
+    status: container.Status || "",
+    labels: container.Labels || {},
+    ports: formatApiPorts(container.Ports || []),
+  };
+}
+
+/**
+ * Format API port entries like the docker ps Ports column, e.g. "0.0.0.0:8080->8080/tcp, 9000/tcp"
+ */
+export function formatApiPorts(ports: NonNullable<DockerApiContainer["Ports"]>): string {
+  return ports
+    .map((port) =>
+      port.PublicPort
+        ? `${formatHostAddress(port.IP || "0.0.0.0", String(port.PublicPort))}->${port.PrivatePort}/${port.Type}`
+        : `${port.PrivatePort}/${port.Type}`,
+    )
+    .join(", ");
+}
+
+function formatHostAddress(hostIp: string, hostPort: string): string {
+  return hostIp.includes(":") ? `[${hostIp}]:${hostPort}` : `${hostIp}:${hostPort}`;
+}
+
+/**
+ * Get the Docker daemon address from DOCKER_HOST, defaulting to the local socket
+ */
+export function getDockerHost(env: Record<string, string | undefined> = process.env): string {
+  return env.DOCKER_HOST || `unix://${DEFAULT_DOCKER_SOCKET}`;
+}
+
+/**
+ * Host path of the socket the daemon of a runtime listens on, for sharing it with a sandbox
+ * @throws Error when docker is reached over TCP
+ */
+export function getRuntimeSocketPath(
+  runtimeName: ContainerRuntimeName,
+  env: Record<string, string | undefined> = process.env,
+  uid: number | undefined = process.getuid?.(),
+): string {
+  if (runtimeName === "podman") {
+    return uid === 0 || !env.XDG_RUNTIME_DIR ? "/run/podman/podman.sock" : path.join(env.XDG_RUNTIME_DIR, "podman", "podman.sock");
+  }
+  const dockerHost = getDockerHost(env);
+  if (!dockerHost.startsWith("unix://")) {
+    throw new Error(`dockerAccess needs a unix socket, but DOCKER_HOST is ${dockerHost}`);
+  }
+  return dockerHost.substring("unix://".length);
+}
+
+function resolveApiUrl(dockerHost: string, apiPath: string): { url: string; socketPath?: string } {
+  if (dockerHost.startsWith("unix://")) {
+    return { url: `http://localhost${apiPath}`, socketPath: dockerHost.substring("unix://".length) };
+  }
+  if (dockerHost.startsWith("tcp://")) {
+    return { url: `http://${dockerHost.substring("tcp://".length)}${apiPath}` };
+  }
+  throw new Error(`Unsupported DOCKER_HOST ${dockerHost}. Expected unix:// or tcp://`);
+}
+
+/**
+ * Decide which runtime to use
+ * AISANITY_RUNTIME=cli|sdk|podman forces a backend. Otherwise the docker CLI is preferred when it is
+ * on PATH, then podman, and the API is used when only the daemon socket is reachable.
+ */
+export function selectContainerRuntime(env: Record<string, string | undefined> = process.env): ContainerRuntimeName {
+  const requested = env[RUNTIME_ENV_VAR];
+  if (requested) {
+    if (requested !== "cli" && requested !== "sdk" && requested !== "podman") {
+      throw new Error(`Invalid ${RUNTIME_ENV_VAR} "${requested}". Expected cli, sdk or podman`);
+    }
+    return requested;
+  }
+
+  if (isOnPath("docker", env.PATH || "")) {
+    return "cli";
+  }
+
+  if (isOnPath("podman", env.PATH || "")) {
+    return "podman";
+  }
+
+  const dockerHost = getDockerHost(env);
+  if (dockerHost.startsWith("tcp://") || fs.existsSync(dockerHost.substring("unix://".length))) {
+    return "sdk";
+  }
+
+  // Neither is available; the CLI runtime reports the most familiar errors
+  return "cli";
+}
+
+/**
+ * Check whether an executable is found in a PATH-style list of directories
+ */
+export function isOnPath(binary: string, searchPath: string): boolean {
+  const executable = process.platform === "win32" ? `${binary}.exe` : binary;
+  return searchPath
+    .split(path.delimiter)
+    .filter(Boolean)
+    .some((dir) => fs.existsSync(path.join(dir, executable)));
+}
+
+let activeRuntime: ContainerRuntime | null = null;
+
+/**
+ * Get the container runtime for this process (selected once)
+ */
+export function getContainerRuntime(): ContainerRuntime {
+  if (!activeRuntime) {
+    const name = selectContainerRuntime();
+    activeRuntime =
+      name === "sdk" ? new DockerApiRuntime() : name === "podman" ? new PodmanCliRuntime() : new DockerCliRuntime();
+  }
+  return activeRuntime;
+}
+
+/**
+ * Get the devcontainer CLI flags that point it at the runtime's binary
+ */
+export function getDevcontainerRuntimeArgs(runtime: ContainerRuntime = getContainerRuntime()): string[] {
+  return runtime.command === "docker" ? [] : ["--docker-path", runtime.command];
+}
+
+/**
+ * Override the container runtime (used by tests)
+ */
+export function setContainerRuntime(runtime: ContainerRuntime | null): void {
+  activeRuntime = runtime;
+}
@@ -1,12 +1,41 @@
 import { $ } from "bun";
 import { execSync } from "child_process";
+import { createHash } from "crypto";
 import * as fs from "fs";
+import * as os from "os";
 import * as path from "path";
 import { getAllWorktrees, WorktreeList } from "./worktree-utils";
 import { getVersionAsync } from "./version";
+import { DEFAULT_PROFILE } from "./profile-utils";
+import { getContainerRuntime, ContainerExitState, ContainerHealth, ListContainersOptions, VolumeInfo } from "./container-runtime";
+import { selectRepoDigest } from "./image-lock";
 
 // Constants for Docker command execution
-const DEFAULT_DOCKER_TIMEOUT = 10000; // 10 seconds
+export const DEFAULT_DOCKER_TIMEOUT = 10000; // 10 seconds
+
+// Docker label keys set on every container aisanity creates
+export const LABEL_WORKSPACE = "aisanity.workspace"; // Absolute workspace path
+export const LABEL_BRANCH = "aisanity.branch";
+export const LABEL_CONTAINER = "aisanity.container"; // Generated container name
+export const LABEL_CREATED = "aisanity.created";
+export const LABEL_VERSION = "aisanity.version"; // CLI version that created the container
+export const LABEL_PROFILE = "aisanity.profile"; // Sandbox profile name
+export const LABEL_CACHE = "aisanity.cache"; // Cache name on cache volumes
+export const LABEL_RESOURCES = "aisanity.resources"; // Resource limits the container was started with
+export const LABEL_EPHEMERAL = "aisanity.ephemeral"; // Set on containers started with run --rm
+export const LABEL_CONFIG = "aisanity.config"; // Hashes of the run config sections the container was created with
+export const LABEL_RESTART = "aisanity.restart"; // Restart policy the container was created with
+export const LABEL_STOP_TIMEOUT = "aisanity.stop-timeout"; // stopTimeout config the container was created with
+
+// Policies under which the daemon may start a stopped container again (Podman treats unless-stopped as always)
+const RESURRECTING_RESTART_POLICIES = ["always", "unless-stopped"];
+
+// Seconds docker waits after SIGTERM before sending SIGKILL on stop
+export const DEFAULT_STOP_TIMEOUT = 10;
+
+// Constants for container naming
+const MAX_CONTAINER_NAME_LENGTH = 128; // Docker container name limit
+const WORKSPACE_HASH_LENGTH = 8; // Hex characters of the workspace path hash
 
 // Simple cache for container status queries
 interface CacheEntry<T> {
@@ -43,6 +72,7 @@ export interface ContainerLabels {
   "aisanity.container": string;
   "aisanity.created": string;
   "aisanity.version": string;
+  "aisanity.profile"?: string;
 }
 
 export interface DockerContainer {
@@ -195,6 +225,14 @@ export async function executeDockerCommand(
   }
 }
 
+/**
+ * List containers through the active container runtime (docker CLI or Docker API)
+ * @throws Error when the runtime cannot query the daemon
+ */
+export async function listContainers(options: ListContainersOptions, debug: boolean = false): Promise<DockerContainer[]> {
+  return getContainerRuntime().listContainers(options, debug);
+}
+
 /**
  * Multi-tier container discovery for current workspace
  */
@@ -229,15 +267,8 @@ export async function discoverWorkspaceContainers(
 
   // Strategy 1: Discover by workspace label (primary)
   try {
-    const result = await executeDockerCommand(
-      `docker ps -a --filter label=aisanity.workspace="${workspaceId}" --format "{{.ID}}\t{{.Names}}\t{{.Status}}\t{{.Ports}}\t{{.Labels}}"`,
-      { silent: true, debug },
-    );
-
-    if (result.success) {
-      const parsedContainers = parseDockerOutputToContainers(result.stdout, workspaceId);
-      containers.push(...parsedContainers);
-    }
+    const result = await listContainers({ all: true, labels: [`${LABEL_WORKSPACE}=${workspaceId}`] }, debug);
+    containers.push(...result.map((container) => toContainer(container, workspaceId)));
   } catch (error: unknown) {
     if (debug) {
       console.log(`Strategy 1 (workspace label) failed: ${error instanceof Error ? error.message : "Unknown error"}`);
@@ -320,29 +351,11 @@ export function parseDockerOutputToContainers(output: string, workspaceId?: stri
       });
     }
 
-    // Determine container status
-    let containerStatus: "Running" | "Stopped" | "Not created";
-    if (status.includes("Up")) {
-      containerStatus = "Running";
-    } else if (status.includes("Exited") || status.includes("Created")) {
-      containerStatus = "Stopped";
-    } else {
-      containerStatus = "Not created";
-    }
-
-    const portsArray =
-      ports && ports.trim()
-        ? ports
-            .trim()
-            .split(",")
-            .map((p) => p.trim())
-        : [];
-
     containers.push({
       id,
       name,
-      status: containerStatus,
-      ports: portsArray,
+      status: toContainerStatus(status),
+      ports: splitPorts(ports),
       labels,
       workspaceId: labels["aisanity.workspace"] || workspaceId,
       branchName: labels["aisanity.branch"],
@@ -352,6 +365,39 @@ export function parseDockerOutputToContainers(output: string, workspaceId?: stri
   return containers;
 }
 
+/**
+ * Convert a docker ps entry into a Container
+ */
+function toContainer(container: DockerContainer, workspaceId?: string): Container {
+  return {
+    id: container.id,
+    name: container.name,
+    status: toContainerStatus(container.status),
+    ports: splitPorts(container.ports),
+    labels: container.labels,
+    workspaceId: container.labels["aisanity.workspace"] || workspaceId,
+    branchName: container.labels["aisanity.branch"],
+  };
+}
+
+function toContainerStatus(status: string): "Running" | "Stopped" | "Not created" {
+  if (status.includes("Up")) {
+    return "Running";
+  } else if (status.includes("Exited") || status.includes("Created")) {
+    return "Stopped";
+  }
+  return "Not created";
+}
+
+function splitPorts(ports: string | undefined): string[] {
+  return ports && ports.trim()
+    ? ports
+        .trim()
+        .split(",")
+        .map((p) => p.trim())
+    : [];
+}
+
 /**
  * Reliable container status querying
  */
@@ -371,54 +417,17 @@ export async function getContainerStatus(containerId: string): Promise<{
   }
 
   try {
-    const dockerResult = await executeDockerCommand(
-      `docker ps -a --filter id=${containerId} --format "{{.Status}}\t{{.Ports}}"`,
-      { silent: true },
-    );
-
-    if (!dockerResult.success) {
-      const errorResult = {
-        status: "Not created" as const,
-        ports: [] as string[],
-        error: dockerResult.stderr,
-      };
-      setCache(cacheKey, errorResult);
-      return errorResult;
-    }
-
-    const lines = dockerResult.stdout
-      .trim()
-      .split("\n")
-      .filter((line: string) => line.trim() !== "");
+    const containers = await listContainers({ all: true, ids: [containerId] });
 
-    if (lines.length === 0) {
+    if (containers.length === 0) {
       const emptyResult = { status: "Not created" as const, ports: [] as string[] };
       setCache(cacheKey, emptyResult);
       return emptyResult;
     }
 
-    const [status, ports] = lines[0].split("\t");
-
-    let containerStatus: "Running" | "Stopped" | "Not created";
-    if (status.includes("Up")) {
-      containerStatus = "Running";
-    } else if (status.includes("Exited") || status.includes("Created")) {
-      containerStatus = "Stopped";
-    } else {
-      containerStatus = "Not created";
-    }
-
-    const portsArray =
-      ports && ports.trim()
-        ? ports
-            .trim()
-            .split(",")
-            .map((p: string) => p.trim())
-        : [];
-
     const statusResult = {
-      status: containerStatus,
-      ports: portsArray,
+      status: toContainerStatus(containers[0].status),
+      ports: splitPorts(containers[0].ports),
     };
 
     // Cache the result
@@ -459,51 +468,24 @@ export async function getContainerInfo(containerId: string): Promise<{
   branchName?: string;
 }> {
   try {
-    const result = await executeDockerCommand(
-      `docker ps -a --filter id=${containerId} --format "{{.ID}}\t{{.Names}}\t{{.Status}}\t{{.Ports}}\t{{.Labels}}"`,
-      { silent: true },
-    );
-
-    if (!result.success) {
-      throw new Error(`Failed to get container info: ${result.stderr}`);
+    let containers: DockerContainer[];
+    try {
+      containers = await listContainers({ all: true, ids: [containerId] });
+    } catch (error: unknown) {
+      throw new Error(`Failed to get container info: ${error instanceof Error ? error.message : "Unknown error"}`);
     }
 
-    const lines = result.stdout
-      .trim()
-      .split("\n")
-      .filter((line) => line.trim() !== "");
-
-    if (lines.length === 0) {
+    if (containers.length === 0) {
       throw new Error(`Container ${containerId} not found`);
     }
 
-    const [id, name, status, ports, labelsStr] = lines[0].split("\t");
-    const labels: Record<string, string> = {};
-
-    if (labelsStr && labelsStr.trim() !== "") {
-      labelsStr.split(",").forEach((label) => {
-        const parts = label.split("=");
-        const key = parts[0];
-        const value = parts.slice(1).join("=");
-        if (key) {
-          labels[key] = value || "";
-        }
-      });
-    }
-
-    const portsArray =
-      ports && ports.trim()
-        ? ports
-            .trim()
-            .split(",")
-            .map((p) => p.trim())
-        : [];
+    const { id, name, status, ports, labels } = containers[0];
 
     return {
       id,
       name,
       status,
-      ports: portsArray,
+      ports: splitPorts(ports),
       labels,
       workspaceId: labels["aisanity.workspace"],
       branchName: labels["aisanity.branch"],
@@ -858,15 +840,7 @@ export async function discoverByLabels(debug: boolean = false): Promise<DockerCo
   }
 
   try {
-    const result = await executeDockerCommand(
-      'docker ps -a --filter label=aisanity.workspace --format "{{.ID}}\t{{.Names}}\t{{.Image}}\t{{.Status}}\t{{.Ports}}\t{{.Labels}}"',
-      { silent: true, debug },
-    );
-
-    if (result.success) {
-      return parseDockerOutput(result.stdout);
-    }
-    return [];
+    return await listContainers({ all: true, labels: [LABEL_WORKSPACE] }, debug);
   } catch (error: unknown) {
     if (debug) {
       console.warn("Label-based discovery failed:", error instanceof Error ? error.message : "Unknown error");
@@ -902,15 +876,7 @@ export async function discoverByDevcontainerMetadata(debug: boolean = false): Pr
   }
 
   try {
-    const result = await executeDockerCommand(
-      'docker ps -a --filter label=devcontainer.local_folder --format "{{.ID}}\t{{.Names}}\t{{.Image}}\t{{.Status}}\t{{.Ports}}\t{{.Labels}}"',
-      { silent: true, debug },
-    );
-
-    if (result.success) {
-      return parseDockerOutput(result.stdout);
-    }
-    return [];
+    return await listContainers({ all: true, labels: ["devcontainer.local_folder"] }, debug);
   } catch (error: unknown) {
     if (debug) {
       console.warn("Devcontainer metadata discovery failed:", error instanceof Error ? error.message : "Unknown error");
@@ -960,44 +926,146 @@ export function parseDockerOutput(output: string): DockerContainer[] {
   return containers;
 }
 
+/**
+ * Parse the docker ps status column into a state and uptime
+ * e.g. "Up 2 hours (healthy)" -> running, "2 hours"; "Exited (0) 3 minutes ago" -> exited, "-"
+ */
+export function parseContainerState(status: string): { state: string; uptime: string } {
+  const trimmed = status.trim();
+
+  if (trimmed.startsWith("Up ")) {
+    const paused = /\(Paused\)/i.test(trimmed);
+    const uptime = trimmed.substring(3).replace(/\s*\((healthy|unhealthy|health: starting|Paused)\)\s*$/i, "").trim();
+    return { state: paused ? "paused" : "running", uptime: uptime || "-" };
+  }
+
+  const stateWord = trimmed.split(/[\s(]/)[0];
+  return { state: stateWord ? stateWord.toLowerCase() : "unknown", uptime: "-" };
+}
+
+/**
+ * Error raised when stopping a container that is not running
+ * Callers (and scripts via the stop exit code) can treat this as a no-op
+ */
+export class ContainerAlreadyStoppedError extends Error {
+  constructor(public containerId: string) {
+    super(`Container ${containerId} is already stopped`);
+    this.name = "ContainerAlreadyStoppedError";
+  }
+}
+
+/**
+ * Stop a single container gracefully
+ * Docker sends SIGTERM, waits up to `timeout` seconds, then sends SIGKILL if it is still running
+ * @throws ContainerAlreadyStoppedError if the container is not running
+ */
+export async function stopContainer(
+  containerId: string,
+  timeout: number = DEFAULT_STOP_TIMEOUT,
+  debug: boolean = false,
+): Promise<void> {
+  const runtime = getContainerRuntime();
+
+  let running: boolean;
+  try {
+    running = await runtime.isContainerRunning(containerId, debug);
+  } catch (error: unknown) {
+    throw new Error(`Failed to inspect container ${containerId}: ${error instanceof Error ? error.message : "Unknown error"}`);
+  }
+
+  if (!running) {
+    throw new ContainerAlreadyStoppedError(containerId);
+  }
+
+  // A stopped container should stay stopped; run sets the policy again when it reuses the container
+  try {
+    const labels = await runtime.getContainerLabels(containerId, debug);
+    if (RESURRECTING_RESTART_POLICIES.includes(labels[LABEL_RESTART])) {
+      await runtime.setRestartPolicy(containerId, "no", debug);
+    }
+  } catch (error: unknown) {
+    if (debug) {
+      console.log(`Could not clear the restart policy of ${containerId}: ${error instanceof Error ? error.message : "Unknown error"}`);
+    }
+  }
+
+  try {
+    await runtime.stopContainer(containerId, timeout, debug);
+  } catch (error: unknown) {
+    throw new Error(`Failed to stop container ${containerId}: ${error instanceof Error ? error.message : "Unknown error"}`);
+  }
+}
+
 /**
  * Stop containers by IDs
+ * @returns IDs that were stopped and IDs that were already stopped
  */
-export async function stopContainers(containerIds: string[], verbose: boolean = false): Promise<void> {
+export async function stopContainers(
+  containerIds: string[],
+  verbose: boolean = false,
+  timeout: number = DEFAULT_STOP_TIMEOUT,
+): Promise<{ stopped: string[]; alreadyStopped: string[] }> {
+  const stopped: string[] = [];
+  const alreadyStopped: string[] = [];
+
   for (const id of containerIds) {
     try {
-      const result = await executeDockerCommand(`docker stop ${id}`, { silent: true });
-      if (result.success) {
+      await stopContainer(id, timeout);
+      stopped.push(id);
+      if (verbose) {
+        console.log(`Stopped container: ${id}`);
+      }
+    } catch (error: unknown) {
+      if (error instanceof ContainerAlreadyStoppedError) {
+        alreadyStopped.push(id);
         if (verbose) {
-          console.log(`Stopped container: ${id}`);
+          console.log(`Container already stopped: ${id}`);
         }
       } else {
-        console.warn(`Failed to stop container ${id}:`, result.stderr);
+        console.warn(`Failed to stop container ${id}:`, error instanceof Error ? error.message : "Unknown error");
       }
-    } catch (error: unknown) {
-      console.warn(`Failed to stop container ${id}:`, error instanceof Error ? error.message : "Unknown error");
     }
   }
+
+  return { stopped, alreadyStopped };
+}
+
+/**
+ * Pick the running (including paused and restarting) or the stopped containers that carry the aisanity.workspace label
+ * Containers without the label are never selected, whatever the runtime filter returned.
+ */
+export function selectLabeledContainers(containers: DockerContainer[], running: boolean): DockerContainer[] {
+  return containers.filter((container) => {
+    const state = parseContainerState(container.status || "").state;
+    return Boolean(container.labels[LABEL_WORKSPACE]) && ["running", "paused", "restarting"].includes(state) === running;
+  });
+}
+
+/**
+ * List the running or the stopped aisanity containers of every workspace (stop --all and clean)
+ */
+export async function listLabeledContainers(running: boolean, debug: boolean = false): Promise<DockerContainer[]> {
+  return selectLabeledContainers(await listContainers({ all: !running, labels: [LABEL_WORKSPACE] }, debug), running);
 }
 
 /**
  * Remove containers by IDs
+ * @returns IDs of the containers that could not be removed
  */
-export async function removeContainers(containerIds: string[], verbose: boolean = false): Promise<void> {
+export async function removeContainers(containerIds: string[], verbose: boolean = false): Promise<string[]> {
+  const failed: string[] = [];
   for (const id of containerIds) {
     try {
-      const result = await executeDockerCommand(`docker rm ${id}`, { silent: true });
-      if (result.success) {
-        if (verbose) {
-          console.log(`Removed container: ${id}`);
-        }
-      } else {
-        console.warn(`Failed to remove container ${id}:`, result.stderr);
+      await getContainerRuntime().removeContainer(id);
+      if (verbose) {
+        console.log(`Removed container: ${id}`);
       }
     } catch (error: unknown) {
+      failed.push(id);
       console.warn(`Failed to remove container ${id}:`, error instanceof Error ? error.message : "Unknown error");
     }
   }
+  return failed;
 }
 
 /**
@@ -1006,7 +1074,7 @@ export async function removeContainers(containerIds: string[], verbose: boolean
 export function validateContainerLabels(labels: ContainerLabels | Record<string, string> | undefined): boolean {
   if (!labels) return false;
 
-  const requiredLabels: (keyof ContainerLabels)[] = ["aisanity.workspace", "aisanity.branch", "aisanity.container"];
+  const requiredLabels: (keyof ContainerLabels)[] = [LABEL_WORKSPACE, LABEL_BRANCH, LABEL_CONTAINER];
 
   return requiredLabels.every((label) => labels[label] !== undefined);
 }
@@ -1019,17 +1087,29 @@ export async function generateContainerLabels(
   branch: string,
   containerName: string,
   workspacePath: string,
+  profile: string = DEFAULT_PROFILE,
 ): Promise<ContainerLabels> {
   // Get version from version utility
   const version = await getVersionAsync();
 
-  return {
-    "aisanity.workspace": workspacePath,
-    "aisanity.branch": branch,
-    "aisanity.container": containerName,
-    "aisanity.created": new Date().toISOString(),
-    "aisanity.version": version,
+  const labels: ContainerLabels = {
+    [LABEL_WORKSPACE]: workspacePath,
+    [LABEL_BRANCH]: branch,
+    [LABEL_CONTAINER]: containerName,
+    [LABEL_CREATED]: new Date().toISOString(),
+    [LABEL_VERSION]: version,
+    [LABEL_PROFILE]: profile,
   };
+
+  return labels;
+}
+
+/**
+ * Check if container labels belong to the given profile
+ * Containers created before profile labels existed are treated as the default profile
+ */
+export function matchesProfile(labels: Record<string, string>, profile: string): boolean {
+  return (labels[LABEL_PROFILE] || DEFAULT_PROFILE) === profile;
 }
 
 /**
@@ -1042,3 +1122,601 @@ export function isContainerOrphaned(container: DockerContainer, existingWorktree
   }
   return !existingWorktrees.includes(workspacePath);
 }
+
+/**
+ * Get a short, stable hash of the absolute workspace path
+ */
+export function hashWorkspacePath(workspacePath: string): string {
+  return createHash("sha256").update(path.resolve(workspacePath)).digest("hex").substring(0, WORKSPACE_HASH_LENGTH);
+}
+
+/**
+ * Generate a container name that is unique per workspace path
+ * Format: {prefix}-{hash}, where prefix is the human-readable name (e.g. {workspace}-{branch})
+ * The prefix is truncated when needed so the hash always survives the Docker length limit
+ */
+export function generateContainerName(prefix: string, workspacePath: string): string {
+  const hash = hashWorkspacePath(workspacePath);
+  const maxPrefixLength = MAX_CONTAINER_NAME_LENGTH - hash.length - 1;
+  const trimmedPrefix = prefix.substring(0, maxPrefixLength).replace(/-+$/, "");
+  return `${trimmedPrefix}-${hash}`;
+}
+
+/**
+ * Get the docker volume name of a cache volume
+ * Format: aisanity-{workspace}-{pathHash}-{cacheName}, so caches never leak between projects
+ */
+export function getCacheVolumeName(workspaceName: string, workspacePath: string, cacheName: string): string {
+  const workspace = workspaceName.replace(/[^a-zA-Z0-9_.-]/g, "_");
+  return `aisanity-${workspace}-${hashWorkspacePath(workspacePath)}-${cacheName}`;
+}
+
+/**
+ * Get the image tag of a profile built with `aisanity build`
+ * Format: aisanity-{workspace}-{pathHash}:{profile}, so builds never collide between projects
+ */
+export function getBuildImageTag(workspaceName: string, workspacePath: string, profileName: string): string {
+  // Repository names are lowercase; tags allow word characters, dots and dashes
+  const workspace = workspaceName.toLowerCase().replace(/[^a-z0-9]+/g, "-").replace(/^-+|-+$/g, "");
+  const tag = profileName.replace(/[^a-zA-Z0-9_.-]/g, "_").replace(/^[.-]/, "_").substring(0, 128);
+  return `aisanity-${workspace ? `${workspace}-` : ""}${hashWorkspacePath(workspacePath)}:${tag}`;
+}
+
+/**
+ * Create any cache volumes that do not exist yet
+ * Volumes are labelled with their workspace so `aisanity clean --volumes` can find them later
+ * @returns Names of the volumes that were created
+ */
+export async function ensureCacheVolumes(
+  volumes: { name: string; volume: string }[],
+  workspacePath: string,
+  debug: boolean = false,
+): Promise<string[]> {
+  if (volumes.length === 0) {
+    return [];
+  }
+
+  const runtime = getContainerRuntime();
+  const existing = new Set(
+    (await runtime.listVolumes({ names: volumes.map((v) => v.volume) }, debug)).map((volume) => volume.name),
+  );
+
+  const created: string[] = [];
+  for (const { name, volume } of volumes) {
+    if (!existing.has(volume)) {
+      await runtime.createVolume(volume, { [LABEL_WORKSPACE]: workspacePath, [LABEL_CACHE]: name }, debug);
+      created.push(volume);
+    }
+  }
+  return created;
+}
+
+/**
+ * Find cache volumes created by aisanity, optionally only those of one workspace
+ */
+export async function findCacheVolumes(workspacePath?: string, debug: boolean = false): Promise<VolumeInfo[]> {
+  const labels = [LABEL_CACHE];
+  if (workspacePath) {
+    labels.push(`${LABEL_WORKSPACE}=${workspacePath}`);
+  }
+  return getContainerRuntime().listVolumes({ labels }, debug);
+}
+
+/**
+ * Remove volumes by name
+ * @returns Names of the volumes that could not be removed (e.g. still in use)
+ */
+export async function removeVolumes(names: string[], verbose: boolean = false): Promise<string[]> {
+  const failed: string[] = [];
+  for (const name of names) {
+    try {
+      await getContainerRuntime().removeVolume(name);
+      if (verbose) {
+        console.log(`Removed volume: ${name}`);
+      }
+    } catch (error: unknown) {
+      failed.push(name);
+      console.warn(`Failed to remove volume ${name}:`, error instanceof Error ? error.message : "Unknown error");
+    }
+  }
+  return failed;
+}
+
+/**
+ * Poll the health of a container until it is healthy or unhealthy, or the timeout passes
+ * @returns The last health reported (still "starting" on timeout), or null when the container has no healthcheck
+ */
+export async function waitForHealthy(
+  containerId: string,
+  timeoutMs: number,
+  debug: boolean = false,
+  pollInterval: number = 1000,
+): Promise<ContainerHealth | null> {
+  const deadline = Date.now() + timeoutMs;
+
+  while (true) {
+    const health = await getContainerRuntime().getContainerHealth(containerId, debug);
+    if (!health || health.status !== "starting" || Date.now() >= deadline) {
+      return health;
+    }
+    await new Promise((resolve) => setTimeout(resolve, pollInterval));
+  }
+}
+
+/**
+ * Read the state of a container to explain why a command run in it failed
+ * @returns null when the container is gone or cannot be inspected
+ */
+export async function getContainerExitState(containerId: string, debug: boolean = false): Promise<ContainerExitState | null> {
+  try {
+    return await getContainerRuntime().getContainerState(containerId, debug);
+  } catch (error) {
+    return null;
+  }
+}
+
+/**
+ * Explain a non-zero exit of a command run in a sandbox
+ * OOM kills come from the container state; other exit codes above 128 are named after their signal.
+ * @returns A diagnostic to print, or null when the exit code says it all
+ */
+export function describeExit(exitCode: number, state: ContainerExitState | null, memory?: string): string | null {
+  const raiseMemory = memory ? `consider raising resources.memory (currently ${memory})` : "consider setting resources.memory";
+
+  if (state?.oomKilled) {
+    return `Container was OOM-killed; ${raiseMemory}`;
+  }
+
+  if (exitCode > 128) {
+    const signal = Object.entries(os.constants.signals).find(([, number]) => number === exitCode - 128)?.[0];
+    // The OOM killer also picks single processes, which leaves the container running and unflagged
+    if (signal === "SIGKILL") {
+      return `Command was killed by SIGKILL (exit code ${exitCode}); if it ran out of memory, ${raiseMemory}`;
+    }
+    if (signal) {
+      return `Command was terminated by ${signal} (exit code ${exitCode})`;
+    }
+  }
+
+  if (state && !state.running) {
+    return `Container stopped with exit code ${state.exitCode}${state.error ? `: ${state.error}` : ""}`;
+  }
+
+  return null;
+}
+
+export interface AttachedProcess {
+  exited: Promise<number>;
+  kill(signal?: NodeJS.Signals): void;
+}
+
+/**
+ * Create an AbortSignal that aborts when the CLI receives one of the given signals
+ * The abort reason is the signal name. A second signal exits immediately, so a stuck
+ * cleanup can still be interrupted. Call dispose once the guarded work is done.
+ */
+export function createSignalContext(signals: NodeJS.Signals[] = ["SIGINT", "SIGTERM"]): {
+  signal: AbortSignal;
+  dispose: () => void;
+} {
+  const controller = new AbortController();
+  const handler = (received: NodeJS.Signals) => {
+    if (controller.signal.aborted) {
+      process.exit(128 + (os.constants.signals[received] || 0));
+    }
+    controller.abort(received);
+  };
+
+  signals.forEach((name) => process.on(name, handler));
+  return {
+    signal: controller.signal,
+    dispose: () => signals.forEach((name) => process.off(name, handler)),
+  };
+}
+
+/**
+ * Run a task for each item, at most limit at a time, like an errgroup
+ * Once a task fails no new tasks are started; the ones already running finish.
+ * @returns One result per item, undefined for items that were never started
+ */
+export async function runWithConcurrency<T, R>(
+  items: T[],
+  limit: number,
+  task: (item: T) => Promise<R>,
+): Promise<(PromiseSettledResult<R> | undefined)[]> {
+  const results: (PromiseSettledResult<R> | undefined)[] = items.map(() => undefined);
+  let next = 0;
+  let failed = false;
+
+  const worker = async () => {
+    while (!failed && next < items.length) {
+      const index = next++;
+      try {
+        results[index] = { status: "fulfilled", value: await task(items[index]) };
+      } catch (reason) {
+        results[index] = { status: "rejected", reason };
+        failed = true;
+      }
+    }
+  };
+
+  await Promise.all(Array.from({ length: Math.max(1, Math.min(limit, items.length)) }, worker));
+  return results;
+}
+
+/**
+ * Stop a container if it is running, then remove it
+ */
+export async function removeSandbox(containerId: string, stopTimeout: number = DEFAULT_STOP_TIMEOUT, debug: boolean = false): Promise<void> {
+  try {
+    await stopContainer(containerId, stopTimeout, debug);
+  } catch (error) {
+    if (!(error instanceof ContainerAlreadyStoppedError)) {
+      throw error;
+    }
+  }
+  await getContainerRuntime().removeContainer(containerId, debug);
+}
+
+// Parts of the run config that are fixed when a container is created
+export type RunConfigSection = "devcontainer" | "env" | "mounts" | "ports" | "resources" | "network" | "user" | "healthcheck" | "runArgs" | "platform" | "workdir" | "restart";
+
+// Sections added after the aisanity.config label are only hashed when set, so containers created before them do not drift
+const OPTIONAL_CONFIG_SECTIONS: RunConfigSection[] = ["platform", "workdir", "restart"];
+
+/**
+ * Hash each section of the run config into the value of the aisanity.config label, e.g. "env=1a2b3c4d5e6f,mounts=..."
+ * Sections are hashed separately so that a later run can name the ones that changed.
+ */
+export function formatConfigHash(sections: Record<RunConfigSection, unknown>): string {
+  // Map keys are sorted so that reordering the config does not change the hash
+  const canonical = (_key: string, value: unknown) =>
+    value && typeof value === "object" && !Array.isArray(value)
+      ? Object.fromEntries(Object.entries(value as Record<string, unknown>).sort(([a], [b]) => a.localeCompare(b)))
+      : value;
+
+  return (Object.keys(sections) as RunConfigSection[])
+    .filter((name) => sections[name] !== undefined || !OPTIONAL_CONFIG_SECTIONS.includes(name))
+    .sort()
+    .map((name) => `${name}=${createHash("sha256").update(JSON.stringify(sections[name] ?? null, canonical)).digest("hex").substring(0, 12)}`)
+    .join(",");
+}
+
+/**
+ * Name the sections whose hash differs between two aisanity.config label values
+ */
+export function diffConfigHash(previous: string, current: string): string[] {
+  const parse = (value: string) => new Map(value.split(",").filter(Boolean).map((entry) => entry.split("=") as [string, string]));
+  const before = parse(previous);
+  const after = parse(current);
+  return [...new Set([...before.keys(), ...after.keys()])].filter((name) => before.get(name) !== after.get(name)).sort();
+}
+
+/**
+ * Describe how a reused container differs from what the current config would create
+ * Compares the image and the config hash label. Containers created before the label existed
+ * are flagged when a config file changed after they were created.
+ * @returns One entry per difference, empty when the container is up to date
+ */
+export function detectContainerDrift(
+  container: DockerContainer,
+  expected: { image?: string; configFiles: string[]; configHash?: string },
+  mtimeOf: (file: string) => number | undefined = (file) => (fs.existsSync(file) ? fs.statSync(file).mtimeMs : undefined),
+): string[] {
+  const drift: string[] = [];
+
+  if (expected.image && container.image && container.image !== expected.image) {
+    drift.push(`image is ${container.image}, config wants ${expected.image}`);
+  }
+
+  const createdHash = container.labels[LABEL_CONFIG];
+  if (expected.configHash && createdHash) {
+    const changed = diffConfigHash(createdHash, expected.configHash);
+    if (changed.length > 0) {
+      drift.push(`${changed.join(", ")} changed since the container was created`);
+    }
+    return drift;
+  }
+
+  const created = Date.parse(container.labels[LABEL_CREATED] || "");
+  if (!Number.isNaN(created)) {
+    for (const file of expected.configFiles) {
+      const modified = mtimeOf(file);
+      if (modified !== undefined && modified > created) {
+        drift.push(`${path.basename(file)} changed after the container was created`);
+      }
+    }
+  }
+
+  return drift;
+}
+
+/**
+ * Wait for a process attached to a sandbox, such as devcontainer exec
+ * When the signal aborts first, its reason is forwarded to the process and the container is
+ * stopped before returning, so no sandbox is left running. With remove, the container is
+ * stopped and removed however the process ends. onExit runs when the process exits on its own,
+ * before the container is removed, so it can still be inspected.
+ * @returns The process exit code, or 128 + the signal number when cancelled
+ */
+export async function waitForAttachedProcess(
+  child: AttachedProcess,
+  findContainer: () => Promise<string | null>,
+  signal: AbortSignal,
+  options: { stopTimeout?: number; remove?: boolean; debug?: boolean; onExit?: (exitCode: number) => Promise<void> } = {},
+): Promise<number> {
+  const cancelled = new Promise<NodeJS.Signals>((resolve) => {
+    const resolveReason = () => resolve(typeof signal.reason === "string" ? (signal.reason as NodeJS.Signals) : "SIGTERM");
+    if (signal.aborted) {
+      resolveReason();
+    } else {
+      signal.addEventListener("abort", resolveReason, { once: true });
+    }
+  });
+
+  const outcome = await Promise.race([
+    child.exited.then((exitCode) => ({ exitCode })),
+    cancelled.then((received) => ({ received })),
+  ]);
+  const release = async () => {
+    const containerId = await findContainer();
+    if (!containerId) {
+      return;
+    }
+    const stopTimeout = options.stopTimeout ?? DEFAULT_STOP_TIMEOUT;
+    try {
+      if (options.remove) {
+        await removeSandbox(containerId, stopTimeout, options.debug || false);
+      } else {
+        await stopContainer(containerId, stopTimeout, options.debug || false);
+      }
+    } catch (error) {
+      if (!(error instanceof ContainerAlreadyStoppedError)) {
+        console.error(error instanceof Error ? error.message : String(error));
+      }
+    }
+  };
+
+  if ("exitCode" in outcome) {
+    if (options.onExit) {
+      await options.onExit(outcome.exitCode);
+    }
+    if (options.remove) {
+      await release();
+    }
+    return outcome.exitCode;
+  }
+
+  child.kill(outcome.received);
+  await release();
+  return 128 + (os.constants.signals[outcome.received] || 0);
+}
+
+export interface PullOptions {
+  attempts?: number; // Tries before giving up, including the first
+  backoff?: number;  // Milliseconds before the first retry, doubled after each failure
+  timeout?: number;  // Milliseconds for all attempts together, backoff included
+  onRetry?: (message: string) => void;
+  platform?: string; // Image platform to pull, e.g. linux/amd64
+}
+
+// Registries and proxies fail every now and then, so pulls are retried a couple of times
+export const PULL_DEFAULTS: Required<Omit<PullOptions, "onRetry" | "platform">> = {
+  attempts: 3,
+  backoff: 2000,
+  timeout: 10 * 60 * 1000
+};
+
+function waitForRetry(millis: number, signal: AbortSignal): Promise<void> {
+  return new Promise((resolve, reject) => {
+    const onAbort = () => {
+      clearTimeout(timer);
+      reject(signal.reason);
+    };
+    const timer = setTimeout(() => {
+      signal.removeEventListener("abort", onAbort);
+      resolve();
+    }, millis);
+    signal.addEventListener("abort", onAbort, { once: true });
+  });
+}
+
+/**
+ * Pull an image, retrying failed attempts with exponential backoff, and return the registry digest it resolved to
+ * @returns null for images without a registry digest (e.g. built locally)
+ * @throws Error with the last failure once all attempts failed or the timeout expired
+ */
+export async function pullImage(image: string, debug: boolean = false, options: PullOptions = {}): Promise<string | null> {
+  const attempts = options.attempts ?? PULL_DEFAULTS.attempts;
+  const backoff = options.backoff ?? PULL_DEFAULTS.backoff;
+  const timeout = options.timeout ?? PULL_DEFAULTS.timeout;
+  const runtime = getContainerRuntime();
+  const signal = AbortSignal.timeout(timeout);
+
+  let lastError: unknown;
+  for (let attempt = 1; attempt <= attempts; attempt++) {
+    try {
+      await runtime.pullImage(image, debug, signal, options.platform);
+      return selectRepoDigest(image, await runtime.getImageDigests(image, debug));
+    } catch (error) {
+      if (signal.aborted) {
+        break;
+      }
+      lastError = error;
+      if (attempt === attempts) {
+        break;
+      }
+
+      const delay = backoff * 2 ** (attempt - 1);
+      options.onRetry?.(`Pulling ${image} failed (attempt ${attempt}/${attempts}), retrying in ${delay / 1000}s: ${error instanceof Error ? error.message : error}`);
+      try {
+        await waitForRetry(delay, signal);
+      } catch (abortError) {
+        break;
+      }
+    }
+  }
+
+  const reason = lastError instanceof Error ? lastError.message : String(lastError);
+  if (signal.aborted) {
+    throw new Error(`Pulling ${image} timed out after ${timeout / 1000}s${lastError ? ` (last error: ${reason})` : ""}`);
+  }
+  throw new Error(`Pulling ${image} failed after ${attempts} attempts: ${reason}`);
+}
+
+// When run pulls the image, as with docker compose --pull
+export type PullPolicy = "never" | "missing" | "always";
+export const PULL_POLICIES: PullPolicy[] = ["never", "missing", "always"];
+
+/**
+ * Parse the --pull option; missing (pull only images that are not present locally) is the default
+ */
+export function parsePullPolicy(value: string | undefined): PullPolicy {
+  if (value === undefined) {
+    return "missing";
+  }
+  if (!(PULL_POLICIES as string[]).includes(value)) {
+    throw new Error(`Invalid --pull value "${value}". Expected ${PULL_POLICIES.join(", ")}`);
+  }
+  return value as PullPolicy;
+}
+
+// Messages of docker, podman and the Go resolver when the registry cannot be reached
+const CONNECTIVITY_ERROR_PATTERN =
+  /dial tcp|no such host|network is unreachable|connection refused|i\/o timeout|TLS handshake timeout|temporary failure in name resolution|server misbehaving|timed out after/i;
+
+/**
+ * Explain a failed pull, pointing offline users at aisanity pull instead of the registry error alone
+ */
+export function describePullFailure(image: string, error: unknown): string {
+  const message = error instanceof Error ? error.message : String(error);
+  if (!CONNECTIVITY_ERROR_PATTERN.test(message)) {
+    return message;
+  }
+  return `Cannot pull ${image}: the registry could not be reached. Run "aisanity pull" while online to cache the image, then use --pull never to start from it offline.\n(${message})`;
+}
+
+/**
+ * Get the registry digest of a local image, or null when the image is not present
+ */
+export async function getLocalImageDigest(image: string, debug: boolean = false): Promise<string | null> {
+  return selectRepoDigest(image, await getContainerRuntime().getImageDigests(image, debug));
+}
+
+/**
+ * Find containers by their aisanity.container label
+ * Falls back to the legacy (pre-hash) container name so containers created by older
+ * versions are still found. Legacy matches are restricted to the given workspace path,
+ * since legacy names can collide between workspaces with the same folder name.
+ */
+export async function findContainersByName(
+  containerName: string,
+  legacyContainerName?: string,
+  workspacePath?: string,
+  debug: boolean = false,
+): Promise<DockerContainer[]> {
+  const lookup = async (name: string): Promise<DockerContainer[]> => {
+    try {
+      return await listContainers({ all: true, labels: [`${LABEL_CONTAINER}=${name}`] }, debug);
+    } catch (error: unknown) {
+      return [];
+    }
+  };
+
+  const containers = await lookup(containerName);
+  if (containers.length > 0 || !legacyContainerName || legacyContainerName === containerName) {
+    return containers;
+  }
+
+  const legacyContainers = await lookup(legacyContainerName);
+  if (debug && legacyContainers.length > 0) {
+    console.log(`[Discovery] Found ${legacyContainers.length} container(s) under legacy name ${legacyContainerName}`);
+  }
+
+  if (!workspacePath) {
+    return legacyContainers;
+  }
+
+  const normalizedWorkspace = path.resolve(workspacePath);
+  return legacyContainers.filter((container) => {
+    const containerWorkspace = container.labels[LABEL_WORKSPACE];
+    return !containerWorkspace || path.resolve(containerWorkspace) === normalizedWorkspace;
+  });
+}
+
+/**
+ * Find the running container for a workspace branch
+ * @param workspacePath - Absolute workspace path (aisanity.workspace label)
+ * @param branch - Branch name (aisanity.branch label)
+ * @param profile - Profile name (aisanity.profile label)
+ * @returns Container ID or null if no matching container is running
+ */
+export async function findRunningContainer(
+  workspacePath: string,
+  branch: string,
+  profile: string = DEFAULT_PROFILE,
+  debug: boolean = false,
+): Promise<string | null> {
+  let containers: DockerContainer[];
+  try {
+    containers = await listContainers({ labels: [`${LABEL_WORKSPACE}=${workspacePath}`, `${LABEL_BRANCH}=${branch}`] }, debug);
+  } catch (error: unknown) {
+    throw new Error(`Failed to query running containers: ${error instanceof Error ? error.message : "Unknown error"}`);
+  }
+
+  const container = containers.find((c) => matchesProfile(c.labels, profile));
+  return container ? container.id : null;
+}
+
+/**
+ * Find the container for a workspace branch and profile, whether or not it is running
+ * A running container is preferred when several match (e.g. a stale stopped one is left behind)
+ */
+export async function findWorkspaceContainer(
+  workspacePath: string,
+  branch: string,
+  profile: string = DEFAULT_PROFILE,
+  debug: boolean = false,
+): Promise<DockerContainer | null> {
+  const containers = (
+    await listContainers({ all: true, labels: [`${LABEL_WORKSPACE}=${workspacePath}`, `${LABEL_BRANCH}=${branch}`] }, debug)
+  ).filter((c) => matchesProfile(c.labels, profile));
+
+  return containers.find((c) => c.status.startsWith("Up")) || containers[0] || null;
+}
+
+/**
+ * Get the host addresses a container port is published on
+ * @param containerPort - Container port with protocol, e.g. "8080/tcp"
+ * @returns Host bindings such as "0.0.0.0:49153"
+ */
+export async function getPublishedPorts(containerId: string, containerPort: string, debug: boolean = false): Promise<string[]> {
+  try {
+    return await getContainerRuntime().getPortBindings(containerId, containerPort, debug);
+  } catch (error: unknown) {
+    return [];
+  }
+}
+
+/**
+ * Get the remote user recorded by the devcontainer CLI in the devcontainer.metadata label
+ */
+export async function getContainerRemoteUser(containerId: string, debug: boolean = false): Promise<string | undefined> {
+  try {
+    const labels = await getContainerRuntime().getContainerLabels(containerId, debug);
+    const metadata = JSON.parse(labels["devcontainer.metadata"]);
+    const entries = Array.isArray(metadata) ? metadata : [metadata];
+    let user: string | undefined;
+
+    // Later entries take precedence, mirroring devcontainer CLI merge order
+    for (const entry of entries) {
+      if (entry && typeof entry === "object") {
+        user = entry.remoteUser || entry.containerUser || user;
+      }
+    }
+
+    return user;
+  } catch (error) {
+    return undefined;
+  }
+}
@@ -423,3 +423,185 @@ export function getBranchSpecificDevContainerPath(cwd: string, branch: string):
 export function getBaseDevContainerPath(cwd: string): string {
   return path.join(cwd, ".devcontainer", "devcontainer.json");
 }
+
+export interface DevContainerOverrides {
+  image?: string;
+  runArgs?: string[]; // Appended to the base runArgs (docker run flags)
+  user?: string; // Container and exec user, e.g. "1000:1000"
+  translateRunArgs?: (runArgs: string[]) => string[]; // Container runtime flag translation, applied to the final runArgs
+  extraRunArgs?: string[]; // Profile runArgs, appended last and left untranslated
+}
+
+/**
+ * Creates a profile-specific devcontainer.json file by copying from the base file and applying
+ * the profile overrides. When the profile overrides the image, any build configuration from the
+ * base file is dropped so the devcontainer CLI uses the image directly.
+ */
+export function createProfileDevContainer(basePath: string, profilePath: string, overrides: DevContainerOverrides): void {
+  const modifiedContent = applyDevContainerOverrides(readDevContainerJson(basePath), overrides);
+
+  const jsonString = JSON.stringify(modifiedContent, null, 2);
+
+  try {
+    fs.writeFileSync(profilePath, jsonString, "utf8");
+  } catch (error: any) {
+    if (error.code === "EACCES" || error.code === "EPERM") {
+      throw new PermissionError(profilePath, "writing");
+    } else if (error.code === "ENOSPC") {
+      throw new DiskSpaceError(profilePath);
+    }
+    throw error;
+  }
+}
+
+/**
+ * Applies profile overrides to parsed devcontainer.json content without writing anything.
+ */
+export function applyDevContainerOverrides(content: any, overrides: DevContainerOverrides): any {
+  const modifiedContent = { ...content };
+
+  if (overrides.image) {
+    delete modifiedContent.build;
+    delete modifiedContent.dockerFile;
+    modifiedContent.image = overrides.image;
+  }
+
+  if (overrides.runArgs && overrides.runArgs.length > 0) {
+    modifiedContent.runArgs = [...(modifiedContent.runArgs || []), ...overrides.runArgs];
+  }
+
+  // containerUser becomes docker run --user; remoteUser makes exec sessions use the same user.
+  // The uid already matches, so the devcontainer CLI has nothing to remap.
+  if (overrides.user) {
+    modifiedContent.containerUser = overrides.user;
+    modifiedContent.remoteUser = overrides.user;
+    modifiedContent.updateRemoteUserUID = false;
+  }
+
+  if (overrides.translateRunArgs) {
+    modifiedContent.runArgs = overrides.translateRunArgs(modifiedContent.runArgs || []);
+  }
+
+  // Passed through as written, so they come after (and can override) the flags aisanity generates
+  if (overrides.extraRunArgs && overrides.extraRunArgs.length > 0) {
+    modifiedContent.runArgs = [...(modifiedContent.runArgs || []), ...overrides.extraRunArgs];
+  }
+
+  return modifiedContent;
+}
+
+export interface DevContainerRunOptions {
+  workspacePath: string;
+  labels: string[]; // key=value labels the container is identified by
+  env: Record<string, string>;
+  mounts?: string[]; // Extra --mount specs, e.g. the main .git directory of a worktree
+  command: string[];
+}
+
+/**
+ * Get the path the workspace is mounted at inside the container: the devcontainer.json
+ * workspaceFolder, or /workspaces/<folder name> like the devcontainer CLI
+ */
+export function getContainerWorkspaceFolder(content: any, workspacePath: string): string {
+  const folderName = path.basename(workspacePath);
+  if (!content.workspaceFolder) {
+    return `/workspaces/${folderName}`;
+  }
+  return String(content.workspaceFolder)
+    .replace(/\$\{localWorkspaceFolder\}/g, workspacePath)
+    .replace(/\$\{localWorkspaceFolderBasename\}/g, folderName);
+}
+
+/**
+ * Builds the container runtime commands equivalent to starting a devcontainer config, for
+ * `aisanity run --dry-run`. Configs that build from a Dockerfile get a build command first.
+ * Features and lifecycle commands are applied by the devcontainer CLI and are not included.
+ */
+export function getDevContainerRunCommands(
+  runtimeCommand: string,
+  content: any,
+  configDir: string,
+  options: DevContainerRunOptions,
+): string[][] {
+  const folderName = path.basename(options.workspacePath);
+  const substitute = (value: string): string =>
+    value
+      .replace(/\$\{localWorkspaceFolder\}/g, options.workspacePath)
+      .replace(/\$\{localWorkspaceFolderBasename\}/g, folderName);
+
+  const commands: string[][] = [];
+  let image: string = content.image;
+  const dockerfile = content.build?.dockerfile || content.dockerFile;
+  if (!image && dockerfile) {
+    image = `aisanity-${folderName.toLowerCase().replace(/[^a-z0-9_.-]/g, "_")}`;
+    const context = path.resolve(configDir, content.build?.context || ".");
+    const buildArgs = Object.entries(content.build?.args || {}).flatMap(([key, value]) => ["--build-arg", `${key}=${value}`]);
+    commands.push([runtimeCommand, "build", "-t", image, "-f", path.resolve(configDir, dockerfile), ...buildArgs, context]);
+  }
+  if (!image) {
+    throw new Error("devcontainer.json has neither an image nor a Dockerfile build");
+  }
+
+  const workspaceFolder = getContainerWorkspaceFolder(content, options.workspacePath);
+  const workspaceMount = content.workspaceMount
+    ? substitute(content.workspaceMount)
+    : `type=bind,source=${options.workspacePath},target=${workspaceFolder}`;
+
+  const run = [runtimeCommand, "run", "-it"];
+  options.labels.forEach((label) => run.push("--label", label));
+  run.push("--mount", workspaceMount, "-w", workspaceFolder);
+  (options.mounts || []).forEach((mount) => run.push("--mount", mount));
+
+  for (const mount of content.mounts || []) {
+    if (typeof mount === "string") {
+      run.push("--mount", substitute(mount));
+    } else if (mount && mount.target) {
+      const spec = [`type=${mount.type || "bind"}`, mount.source && `source=${substitute(mount.source)}`, `target=${mount.target}`];
+      run.push("--mount", spec.filter(Boolean).join(","));
+    }
+  }
+
+  if (content.containerUser) {
+    run.push("-u", content.containerUser);
+  }
+  for (const [key, value] of Object.entries(content.containerEnv || {})) {
+    run.push("-e", `${key}=${substitute(String(value))}`);
+  }
+  for (const [key, value] of Object.entries(options.env)) {
+    run.push("-e", `${key}=${value}`);
+  }
+
+  run.push(...(content.runArgs || []), image, ...options.command);
+  commands.push(run);
+  return commands;
+}
+
+/**
+ * Formats commands as a single copy-pasteable shell line, joined with &&
+ */
+export function formatShellCommands(commands: string[][]): string {
+  const quote = (arg: string): string => (/^[a-zA-Z0-9_\/.,:=@%+-]+$/.test(arg) ? arg : `'${arg.replace(/'/g, "'\\''")}'`);
+  return commands.map((command) => command.map(quote).join(" ")).join(" && ");
+}
+
+/**
+ * Gets the path to the profile-specific devcontainer file.
+ * The file lives next to the base file so relative paths (Dockerfile, context) keep working.
+ */
+export function getProfileDevContainerPath(basePath: string, profileName: string): string {
+  const sanitizedProfile = profileName.replace(/[^a-zA-Z0-9]/g, "_");
+  return path.join(path.dirname(basePath), `.aisanity-profile-${sanitizedProfile}.json`);
+}
+
+/**
+ * Check whether a profile needs a generated devcontainer file
+ */
+export function hasDevContainerOverrides(overrides: DevContainerOverrides): boolean {
+  return (
+    Boolean(overrides.image) ||
+    Boolean(overrides.runArgs && overrides.runArgs.length > 0) ||
+    Boolean(overrides.user) ||
+    Boolean(overrides.translateRunArgs) ||
+    Boolean(overrides.extraRunArgs && overrides.extraRunArgs.length > 0)
+  );
+}
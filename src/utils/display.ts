@@ -0,0 +1,101 @@
+import { Command } from 'commander';
+import pc from 'picocolors';
+
+/**
+ * Terminal presentation shared by all commands: quiet mode, colors and emoji
+ * Set once per invocation from the --quiet and --no-emoji flags and the NO_COLOR convention.
+ */
+export interface DisplaySettings {
+  quiet: boolean; // Only errors and the output of the container are printed
+  color: boolean;
+  emoji: boolean;
+}
+
+export type SymbolName = 'success' | 'failure' | 'unknown' | 'warning' | 'check' | 'cross' | 'notice' | 'current';
+
+// Fallbacks for --no-emoji stay ASCII so they also render in terminals without Unicode fonts
+const SYMBOLS: Record<SymbolName, { emoji: string; plain: string }> = {
+  success: { emoji: '✅', plain: '[ok]' },
+  failure: { emoji: '❌', plain: '[x]' },
+  unknown: { emoji: '❓', plain: '[?]' },
+  warning: { emoji: '⚠️', plain: '[!]' },
+  check: { emoji: '✓', plain: '[ok]' },
+  cross: { emoji: '✗', plain: '[x]' },
+  notice: { emoji: '!', plain: '[!]' },
+  current: { emoji: '→', plain: '>' }
+};
+
+/**
+ * Resolve the display settings from command options and the environment
+ * Colors follow picocolors (which honors NO_COLOR, FORCE_COLOR and non-TTY output); a non-empty NO_COLOR always wins.
+ */
+export function resolveDisplaySettings(
+  options: { quiet?: boolean; silent?: boolean; emoji?: boolean } = {},
+  env: Record<string, string | undefined> = process.env
+): DisplaySettings {
+  return {
+    quiet: options.quiet || options.silent || false,
+    color: env.NO_COLOR ? false : pc.isColorSupported,
+    emoji: options.emoji !== false
+  };
+}
+
+let settings: DisplaySettings = resolveDisplaySettings();
+let palette = pc.createColors(settings.color);
+
+export function configureDisplay(next: DisplaySettings): void {
+  settings = next;
+  palette = pc.createColors(next.color);
+}
+
+export function getDisplaySettings(): DisplaySettings {
+  return settings;
+}
+
+/**
+ * Symbol for a status, or its ASCII fallback with --no-emoji
+ */
+export function symbol(name: SymbolName): string {
+  return settings.emoji ? SYMBOLS[name].emoji : SYMBOLS[name].plain;
+}
+
+/**
+ * Color functions, turned into no-ops when colors are off
+ */
+export function colors(): ReturnType<typeof pc.createColors> {
+  return palette;
+}
+
+/**
+ * Print an informational message - suppressed with --quiet
+ * For commands without a logger; the Logger applies the same rule to info, verbose and debug.
+ */
+export function print(message: string = ''): void {
+  if (!settings.quiet) {
+    console.log(message);
+  }
+}
+
+/**
+ * Add --quiet and --no-emoji to every command of the CLI and apply them before the action runs
+ * Commands that already define a quiet option (run has --silent, --quiet) keep their own.
+ */
+export function addDisplayOptions(program: Command): void {
+  const visit = (command: Command) => {
+    for (const sub of command.commands) {
+      visit(sub);
+    }
+    if (command === program || command.commands.length > 0) {
+      return;
+    }
+    if (!command.options.some(option => option.flags.includes('--quiet'))) {
+      command.option('-q, --quiet', 'Only print errors and the output of the container');
+    }
+    command.option('--no-emoji', 'Print ASCII symbols instead of emoji');
+  };
+  visit(program);
+
+  program.hook('preAction', (_program, actionCommand) => {
+    configureDisplay(resolveDisplaySettings(actionCommand.opts()));
+  });
+}
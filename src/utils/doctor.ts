@@ -0,0 +1,208 @@
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { AisanityConfig, loadAisanityConfig, getAisanityConfigPath } from './config';
+import { ContainerRuntime, getDockerHost, isOnPath } from './container-runtime';
+import { getBuildImageTag } from './container-utils';
+import { getProfileNames, resolveProfile } from './profile-utils';
+import { readDevContainerJson } from './devcontainer-templates';
+
+export type CheckStatus = 'pass' | 'warn' | 'fail';
+
+export interface DoctorCheck {
+  name: string;
+  status: CheckStatus; // fail marks a missing hard prerequisite
+  detail: string;
+  hint?: string;       // What to do about a warning or failure
+}
+
+/**
+ * Check that the binaries aisanity shells out to are installed
+ */
+export function checkBinaries(runtime: ContainerRuntime, env: Record<string, string | undefined> = process.env): DoctorCheck[] {
+  const searchPath = env.PATH || '';
+  const checks: DoctorCheck[] = [];
+
+  if (runtime.name === 'sdk') {
+    checks.push({ name: 'Container runtime', status: 'pass', detail: `Docker Engine API at ${getDockerHost(env)}` });
+  } else if (isOnPath(runtime.command, searchPath)) {
+    checks.push({ name: 'Container runtime', status: 'pass', detail: `${runtime.command} (${runtime.name})` });
+  } else {
+    checks.push({
+      name: 'Container runtime',
+      status: 'fail',
+      detail: `${runtime.command} is not on PATH`,
+      hint: 'Install Docker (https://docs.docker.com/get-docker/) or Podman, or select a runtime with AISANITY_RUNTIME'
+    });
+  }
+
+  checks.push(isOnPath('devcontainer', searchPath)
+    ? { name: 'Devcontainer CLI', status: 'pass', detail: 'devcontainer' }
+    : {
+        name: 'Devcontainer CLI',
+        status: 'fail',
+        detail: 'devcontainer is not on PATH',
+        hint: 'Install it with: npm install -g @devcontainers/cli'
+      });
+
+  return checks;
+}
+
+/**
+ * Check that the daemon answers
+ */
+export async function checkDaemon(
+  runtime: ContainerRuntime,
+  platform: NodeJS.Platform = process.platform,
+  debug: boolean = false
+): Promise<DoctorCheck> {
+  try {
+    const version = await runtime.getServerVersion(debug);
+    return { name: 'Daemon', status: 'pass', detail: `${runtime.command} ${version}` };
+  } catch (error) {
+    const message = (error instanceof Error ? error.message : String(error)).split('\n')[0];
+    let hint: string;
+    if (/permission denied/i.test(message)) {
+      hint = 'Your user cannot open the Docker socket; see the docker group check below';
+    } else if (runtime.name === 'podman') {
+      hint = platform === 'linux' ? 'Start the service with: systemctl --user start podman.socket' : 'Start the VM with: podman machine start';
+    } else {
+      hint = platform === 'linux' ? 'Start Docker with: sudo systemctl start docker' : 'Start Docker Desktop (or another Docker VM such as colima)';
+    }
+    return { name: 'Daemon', status: 'fail', detail: `cannot reach the ${runtime.command} daemon: ${message}`, hint };
+  }
+}
+
+export interface UserGroups {
+  user: string;
+  uid: number;
+  gids: number[];   // Groups of the current process
+  groupFile: string; // Contents of /etc/group
+}
+
+function readUserGroups(): UserGroups {
+  let groupFile = '';
+  try {
+    groupFile = fs.readFileSync('/etc/group', 'utf8');
+  } catch (error) {
+    // Treated as a system without a docker group
+  }
+  return {
+    user: os.userInfo().username,
+    uid: process.getuid ? process.getuid() : -1,
+    gids: process.getgroups ? process.getgroups() : [],
+    groupFile
+  };
+}
+
+/**
+ * Check that the user may use the Docker socket without sudo (Linux only)
+ * Returns null where the check does not apply: other platforms and podman, which runs rootless.
+ */
+export function checkDockerGroup(
+  runtime: ContainerRuntime,
+  platform: NodeJS.Platform = process.platform,
+  groups: UserGroups = platform === 'linux' ? readUserGroups() : { user: '', uid: -1, gids: [], groupFile: '' }
+): DoctorCheck | null {
+  if (platform !== 'linux' || runtime.name === 'podman') {
+    return null;
+  }
+
+  const name = 'Docker group';
+  if (groups.uid === 0) {
+    return { name, status: 'pass', detail: 'running as root' };
+  }
+
+  const entry = groups.groupFile.split('\n').map(line => line.split(':')).find(fields => fields[0] === 'docker');
+  if (!entry) {
+    return {
+      name,
+      status: 'warn',
+      detail: 'there is no docker group on this system',
+      hint: 'Rootless Docker does not need one; otherwise follow the Docker post-install steps for Linux'
+    };
+  }
+
+  const gid = Number(entry[2]);
+  const members = (entry[3] || '').split(',').map(member => member.trim());
+  if (groups.gids.includes(gid)) {
+    return { name, status: 'pass', detail: `${groups.user} is in the docker group` };
+  }
+  if (members.includes(groups.user)) {
+    return {
+      name,
+      status: 'warn',
+      detail: `${groups.user} was added to the docker group after this session started`,
+      hint: 'Log out and back in, or run: newgrp docker'
+    };
+  }
+  return {
+    name,
+    status: 'warn',
+    detail: `${groups.user} is not in the docker group`,
+    hint: `Add it with: sudo usermod -aG docker ${groups.user} (then log in again)`
+  };
+}
+
+/**
+ * Check that the workspace config loads
+ */
+export function checkConfig(cwd: string): { check: DoctorCheck; config: AisanityConfig | null } {
+  try {
+    const config = loadAisanityConfig(cwd);
+    if (!config) {
+      return {
+        check: { name: 'Config', status: 'warn', detail: `no .aisanity config in ${cwd}`, hint: 'Run "aisanity init" to create one' },
+        config: null
+      };
+    }
+    const profiles = getProfileNames(config);
+    const profileInfo = profiles.length > 0 ? ` (profiles: ${profiles.join(', ')})` : '';
+    return { check: { name: 'Config', status: 'pass', detail: `${getAisanityConfigPath(cwd)}${profileInfo}` }, config };
+  } catch (error) {
+    return { check: { name: 'Config', status: 'fail', detail: error instanceof Error ? error.message : String(error) }, config: null };
+  }
+}
+
+/**
+ * Check that the image of every profile is present locally
+ * Missing images are warnings: run pulls them, and built images are built on first use.
+ */
+export async function checkImages(config: AisanityConfig, cwd: string, runtime: ContainerRuntime, debug: boolean = false): Promise<DoctorCheck[]> {
+  const devcontainerPath = path.join(cwd, '.devcontainer', 'devcontainer.json');
+  let devcontainerImage: string | undefined;
+  try {
+    devcontainerImage = fs.existsSync(devcontainerPath) ? readDevContainerJson(devcontainerPath).image : undefined;
+  } catch (error) {
+    // Reported by the profiles that need the image
+  }
+
+  const names = getProfileNames(config);
+  const checks: DoctorCheck[] = [];
+  for (const profileName of names.length > 0 ? names : [undefined]) {
+    const profile = resolveProfile(config, profileName);
+    const name = `Image (${profile.name})`;
+    const image = profile.build ? getBuildImageTag(config.workspace, cwd, profile.name) : profile.image || devcontainerImage;
+
+    if (!image) {
+      checks.push({ name, status: 'warn', detail: 'no image configured', hint: 'Set "image" in the profile or in .devcontainer/devcontainer.json' });
+      continue;
+    }
+
+    try {
+      if (await runtime.hasImage(image, debug)) {
+        checks.push({ name, status: 'pass', detail: image });
+      } else {
+        checks.push({
+          name,
+          status: 'warn',
+          detail: `${image} is not present locally`,
+          hint: profile.build ? 'Build it with: aisanity build' : 'aisanity run pulls it on first use, or run: aisanity pull'
+        });
+      }
+    } catch (error) {
+      checks.push({ name, status: 'warn', detail: `could not inspect ${image}: ${error instanceof Error ? error.message : String(error)}` });
+    }
+  }
+  return checks;
+}
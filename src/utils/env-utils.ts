@@ -1,3 +1,5 @@
+import * as fs from 'fs';
+import * as path from 'path';
 import { AisanityConfig } from './config';
 
 // Pattern cache for performance optimization
@@ -12,6 +14,7 @@ const DEFAULT_BLOCKED_VARS = [
 export interface EnvCollection {
   cli: Record<string, string>;
   host: Record<string, string>;
+  file: Record<string, string>;   // Loaded from the profile envFile entries
   config: Record<string, string>;
   merged: Record<string, string>;
 }
@@ -23,6 +26,7 @@ export interface PatternValidationResult {
 }
 
 export interface EnvProcessingOptions {
+  fileEnv?: Record<string, string>; // Values from env files, see loadEnvFiles
   dryRun?: boolean;
   verbose?: boolean;
   securityLevel?: 'strict' | 'moderate' | 'permissive';
@@ -176,21 +180,22 @@ export function parseCliEnvVars(cliEnvArgs: string[]): Record<string, string> {
 }
 
 /**
- * Merge environment variables with precedence: CLI > config > host
+ * Merge environment variables with precedence: CLI > config > env files > host
  */
 export function mergeEnvVariables(
   cliEnv: Record<string, string>,
   hostEnv: Record<string, string>,
   configEnv: Record<string, string>,
-  _whitelist: string[]
+  _whitelist: string[],
+  fileEnv: Record<string, string> = {}
 ): Record<string, string> {
   const merged: Record<string, string> = {};
   
-  // Start with config environment variables (lowest precedence)
-  Object.assign(merged, configEnv);
+  // Start with env file and config environment variables (lowest precedence)
+  Object.assign(merged, fileEnv, configEnv);
   
   // Add host environment variables (middle precedence)
-  // Only add if not already present from config
+  // Only add if not already present from config or an env file
   for (const [key, value] of Object.entries(hostEnv)) {
     if (!(key in merged)) {
       merged[key] = value;
@@ -204,6 +209,211 @@ export function mergeEnvVariables(
   return merged;
 }
 
+/**
+ * Interpolate ${VAR} and ${VAR:-default} references from the host environment
+ * Config template variables such as ${workspace} are taken from templates before the host is consulted.
+ * Throws when a referenced variable is unset and no default is given
+ */
+export function interpolateEnvValue(
+  value: string,
+  hostEnv: Record<string, string | undefined> = process.env,
+  templates: Record<string, string> = {}
+): string {
+  return value.replace(/\$\{([^}:]*)(:-([^}]*))?\}/g, (_match, name: string, hasDefault: string | undefined, fallback: string | undefined) => {
+    if (Object.prototype.hasOwnProperty.call(templates, name)) {
+      return templates[name];
+    }
+    if (!isValidEnvVarName(name)) {
+      throw new Error(`Invalid host variable reference "\${${name}}"`);
+    }
+
+    // As in the shell, only ${VAR:-default} treats an empty value like an unset one
+    const hostValue = hostEnv[name];
+    if (hasDefault !== undefined) {
+      return hostValue ? hostValue : fallback || '';
+    }
+    if (hostValue !== undefined) {
+      return hostValue;
+    }
+
+    throw new Error(`Host variable ${name} is not set (use \${${name}:-default} to provide a fallback)`);
+  });
+}
+
+/**
+ * Resolve a declared env block into concrete values
+ * Accepts a map (KEY: value, KEY: ${HOST_VAR}) or a list (- KEY passthrough, - KEY=value).
+ * Passthrough variables that are unset on the host are skipped.
+ */
+export function resolveDeclaredEnv(
+  env: unknown,
+  hostEnv: Record<string, string | undefined> = process.env,
+  templates: Record<string, string> = {}
+): Record<string, string> {
+  const resolved: Record<string, string> = {};
+
+  const setValue = (key: string, value: unknown): void => {
+    if (!isValidEnvVarName(key)) {
+      throw new Error(`Invalid environment variable name: "${key}". Must follow POSIX naming rules`);
+    }
+
+    if (value === null || value === undefined) {
+      // Bare key: forward the host value
+      const hostValue = hostEnv[key];
+      if (hostValue !== undefined) {
+        resolved[key] = hostValue;
+      }
+      return;
+    }
+
+    try {
+      resolved[key] = interpolateEnvValue(String(value), hostEnv, templates);
+    } catch (error) {
+      throw new Error(`${key}: ${error instanceof Error ? error.message : String(error)}`);
+    }
+  };
+
+  if (env === null || env === undefined) {
+    return resolved;
+  }
+
+  if (Array.isArray(env)) {
+    for (const entry of env) {
+      const text = String(entry);
+      const equalIndex = text.indexOf('=');
+      if (equalIndex === -1) {
+        setValue(text, null);
+      } else {
+        setValue(text.substring(0, equalIndex), text.substring(equalIndex + 1));
+      }
+    }
+    return resolved;
+  }
+
+  if (typeof env === 'object') {
+    for (const [key, value] of Object.entries(env as Record<string, unknown>)) {
+      setValue(key, value);
+    }
+    return resolved;
+  }
+
+  throw new Error('env must be a map of KEY: value or a list of KEY entries');
+}
+
+/**
+ * Name the keys of a declared env block whose value comes from the host: bare keys forwarded
+ * from the host and values that reference a host variable. Template variables do not count.
+ */
+export function findHostEnvKeys(env: unknown, templateNames: readonly string[] = []): string[] {
+  const referencesHost = (value: string) =>
+    [...value.matchAll(/\$\{([^}:]*)(:-[^}]*)?\}/g)].some(match => !templateNames.includes(match[1]));
+
+  const keys: string[] = [];
+  if (Array.isArray(env)) {
+    for (const entry of env) {
+      const text = String(entry);
+      const equalIndex = text.indexOf('=');
+      if (equalIndex === -1 || referencesHost(text.substring(equalIndex + 1))) {
+        keys.push(equalIndex === -1 ? text : text.substring(0, equalIndex));
+      }
+    }
+  } else if (env && typeof env === 'object') {
+    for (const [key, value] of Object.entries(env as Record<string, unknown>)) {
+      if (value === null || (typeof value === 'string' && referencesHost(value))) {
+        keys.push(key);
+      }
+    }
+  }
+  return keys;
+}
+
+/**
+ * Parse a dotenv file: KEY=value lines, with optional "export " prefixes and # comments
+ * Single-quoted values are literal; double-quoted values may span lines and support \n, \t, \" and \\.
+ * @throws Error naming the file and line of a malformed entry
+ */
+export function parseEnvFile(content: string, filePath: string): Record<string, string> {
+  const env: Record<string, string> = {};
+  const lines = content.replace(/\r\n/g, '\n').split('\n');
+
+  for (let index = 0; index < lines.length; index++) {
+    const lineNumber = index + 1;
+    const line = lines[index].trim();
+    if (line === '' || line.startsWith('#')) {
+      continue;
+    }
+
+    const match = line.match(/^(?:export\s+)?([^=\s]+)\s*=\s*(.*)$/);
+    if (!match) {
+      throw new Error(`${filePath}:${lineNumber}: expected KEY=value`);
+    }
+    const [, key, rest] = match;
+    if (!isValidEnvVarName(key)) {
+      throw new Error(`${filePath}:${lineNumber}: invalid variable name "${key}"`);
+    }
+
+    const quote = rest[0];
+    if (quote !== '"' && quote !== "'") {
+      // Unquoted values end at a comment preceded by whitespace
+      env[key] = rest.replace(/\s+#.*$/, '').trim();
+      continue;
+    }
+
+    // Quoted values may continue on the following lines until the closing quote
+    let text = rest.substring(1);
+    let end = findClosingQuote(text, quote);
+    while (end === -1 && index + 1 < lines.length) {
+      index++;
+      text += '\n' + lines[index];
+      end = findClosingQuote(text, quote);
+    }
+    if (end === -1) {
+      throw new Error(`${filePath}:${lineNumber}: unterminated ${quote === '"' ? 'double' : 'single'} quote`);
+    }
+
+    const trailing = text.substring(end + 1).trim();
+    if (trailing !== '' && !trailing.startsWith('#')) {
+      throw new Error(`${filePath}:${lineNumber}: unexpected text after the closing quote`);
+    }
+
+    const value = text.substring(0, end);
+    env[key] = quote === "'"
+      ? value
+      : value.replace(/\\([nrt"\\$])/g, (_match, escaped: string) => ({ n: '\n', r: '\r', t: '\t' } as Record<string, string>)[escaped] ?? escaped);
+  }
+
+  return env;
+}
+
+function findClosingQuote(text: string, quote: string): number {
+  for (let i = 0; i < text.length; i++) {
+    if (quote === '"' && text[i] === '\\') {
+      i++;
+    } else if (text[i] === quote) {
+      return i;
+    }
+  }
+  return -1;
+}
+
+/**
+ * Load the env files of a profile, relative to the workspace root; later files win
+ */
+export function loadEnvFiles(envFile: string | string[] | undefined, workspacePath: string, profileName: string): Record<string, string> {
+  const files = envFile === undefined ? [] : Array.isArray(envFile) ? envFile : [envFile];
+  const env: Record<string, string> = {};
+
+  for (const file of files) {
+    const filePath = path.resolve(workspacePath, file);
+    if (!fs.existsSync(filePath)) {
+      throw new Error(`Profile '${profileName}': env file does not exist: ${filePath}`);
+    }
+    Object.assign(env, parseEnvFile(fs.readFileSync(filePath, 'utf8'), filePath));
+  }
+
+  return env;
+}
+
 /**
  * Format environment variables for devcontainer --remote-env flags
  */
@@ -211,6 +421,31 @@ export function formatRemoteEnvArgs(env: Record<string, string>): string[] {
   return Object.entries(env).map(([key, value]) => `--remote-env=${key}=${value}`);
 }
 
+/**
+ * Mask the values of variables passed through from the host, for printing commands
+ * A value counts as passed through when it came from the whitelist or equals the host value
+ * of the same variable (bare keys and ${VAR} references in profile env blocks)
+ */
+export function maskHostEnvValues(
+  env: Record<string, string>,
+  passthrough: Record<string, string>,
+  hostEnv: Record<string, string | undefined> = process.env
+): Record<string, string> {
+  const masked: Record<string, string> = {};
+  for (const [key, value] of Object.entries(env)) {
+    const fromHost = key in passthrough || (value !== '' && hostEnv[key] === value);
+    masked[key] = fromHost ? '***' : value;
+  }
+  return masked;
+}
+
+/**
+ * Format environment variables for docker exec/run -e flags
+ */
+export function formatDockerEnvArgs(env: Record<string, string>): string[] {
+  return Object.entries(env).flatMap(([key, value]) => ['-e', `${key}=${value}`]);
+}
+
 /**
  * Validate whitelist patterns
  */
@@ -252,7 +487,7 @@ export function validateWhitelistPatterns(patterns: string[]): PatternValidation
 export function processEnvironmentVariables(
   config: AisanityConfig,
   cliEnvVars: string[],
-  _options: EnvProcessingOptions = {}
+  options: EnvProcessingOptions = {}
 ): EnvCollection {
   // Parse CLI environment variables
   const cliEnv = parseCliEnvVars(cliEnvVars);
@@ -273,11 +508,13 @@ export function processEnvironmentVariables(
   const configEnv = config.env || {};
   
   // Merge with precedence
-  const merged = mergeEnvVariables(cliEnv, hostEnv, configEnv, whitelist);
+  const fileEnv = options.fileEnv || {};
+  const merged = mergeEnvVariables(cliEnv, hostEnv, configEnv, whitelist, fileEnv);
   
   return {
     cli: cliEnv,
     host: hostEnv,
+    file: fileEnv,
     config: configEnv,
     merged
   };
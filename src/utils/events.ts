@@ -0,0 +1,98 @@
+import * as net from 'net';
+import { RunEventJson } from './output';
+
+// Events kept while the socket connects or the consumer lags behind; later ones are dropped
+const MAX_PENDING_EVENTS = 100;
+// How long close waits for the last events to be written
+const CLOSE_TIMEOUT = 500;
+
+export interface EventSink {
+  emit(event: RunEventJson): void;
+  close(): Promise<void>;
+}
+
+/**
+ * Write events as newline-delimited JSON to a Unix socket another program listens on
+ * Writing never blocks the caller: events wait in a bounded queue while the socket connects or
+ * the consumer is slow, and the sink turns itself off (after one onError call) when the socket fails.
+ */
+export function createEventSocket(socketPath: string, onError: (message: string) => void): EventSink {
+  const pending: string[] = [];
+  let connected = false;
+  let blocked = false; // The socket buffer is full until the next drain
+  let failed = false;
+  let dropped = 0;
+
+  const socket = net.createConnection(socketPath);
+  socket.unref();
+
+  const flush = () => {
+    while (connected && !blocked && pending.length > 0) {
+      blocked = !socket.write(pending.shift()!);
+    }
+  };
+
+  socket.on('connect', () => {
+    connected = true;
+    flush();
+  });
+  socket.on('drain', () => {
+    blocked = false;
+    flush();
+  });
+  socket.on('error', (error) => {
+    if (!failed) {
+      failed = true;
+      onError(`Events socket ${socketPath}: ${error.message}; no more events are sent`);
+    }
+    pending.length = 0;
+    socket.destroy();
+  });
+
+  return {
+    emit(event: RunEventJson): void {
+      if (failed) {
+        return;
+      }
+      if (pending.length >= MAX_PENDING_EVENTS) {
+        dropped++;
+        return;
+      }
+      pending.push(JSON.stringify(dropped > 0 ? { ...event, dropped } : event) + '\n');
+      dropped = 0;
+      flush();
+    },
+
+    close(): Promise<void> {
+      return new Promise(resolve => {
+        if (failed) {
+          resolve();
+          return;
+        }
+        const timer = setTimeout(() => {
+          socket.destroy();
+          resolve();
+        }, CLOSE_TIMEOUT);
+        // Called again on connect and drain until the queue is written
+        const end = () => {
+          if (!connected || pending.length > 0) {
+            return;
+          }
+          socket.off('connect', end);
+          socket.off('drain', end);
+          socket.end(() => {
+            clearTimeout(timer);
+            resolve();
+          });
+        };
+        socket.on('connect', end);
+        socket.on('drain', end);
+        socket.once('close', () => {
+          clearTimeout(timer);
+          resolve();
+        });
+        end();
+      });
+    }
+  };
+}
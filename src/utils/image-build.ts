@@ -0,0 +1,152 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { createHash } from 'crypto';
+import { BuildConfig } from './config';
+import { getContainerRuntime } from './container-runtime';
+import { getBuildImageTag, LABEL_WORKSPACE, LABEL_PROFILE } from './container-utils';
+import { readWorkspaceState, updateWorkspaceState } from './state';
+
+export interface BuildPaths {
+  context: string;    // Absolute build context directory
+  dockerfile: string; // Absolute Dockerfile path
+}
+
+export interface BuildResult {
+  tag: string;
+  built: boolean; // False when the image was already up to date
+}
+
+/**
+ * Resolve a profile build block: the context is relative to the workspace root and
+ * the Dockerfile is relative to the context (defaulting to <context>/Dockerfile)
+ * @throws Error when the context or Dockerfile does not exist
+ */
+export function resolveBuildPaths(build: BuildConfig, workspacePath: string, profileName: string): BuildPaths {
+  const context = path.resolve(workspacePath, build.context || '.');
+  const dockerfile = path.resolve(context, build.dockerfile || 'Dockerfile');
+
+  if (!fs.existsSync(context) || !fs.statSync(context).isDirectory()) {
+    throw new Error(`Profile '${profileName}': build context ${context} is not a directory`);
+  }
+  if (!fs.existsSync(dockerfile)) {
+    throw new Error(`Profile '${profileName}': Dockerfile ${dockerfile} does not exist`);
+  }
+  return { context, dockerfile };
+}
+
+/**
+ * Convert a .dockerignore pattern into a regular expression over slash-separated relative paths
+ */
+function dockerignorePatternToRegExp(pattern: string): RegExp {
+  const source = pattern
+    .replace(/^\/+|\/+$/g, '')
+    .split(/(\*\*\/?|\*|\?)/)
+    .map(part => {
+      if (part === '**/') return '(.*/)?';
+      if (part === '**') return '.*';
+      if (part === '*') return '[^/]*';
+      if (part === '?') return '[^/]';
+      return part.replace(/[.+^${}()|[\]\\]/g, '\\$&');
+    })
+    .join('');
+  // A matching directory excludes everything below it
+  return new RegExp(`^${source}(/.*)?$`);
+}
+
+/**
+ * Read the .dockerignore rules of a build context
+ * Later rules win, and rules starting with "!" re-include matching paths.
+ */
+export function readDockerignore(context: string): { exclude: boolean; pattern: RegExp }[] {
+  const ignorePath = path.join(context, '.dockerignore');
+  if (!fs.existsSync(ignorePath)) {
+    return [];
+  }
+
+  return fs.readFileSync(ignorePath, 'utf8')
+    .split('\n')
+    .map(line => line.trim())
+    .filter(line => line !== '' && !line.startsWith('#'))
+    .map(line => ({
+      exclude: !line.startsWith('!'),
+      pattern: dockerignorePatternToRegExp(path.posix.normalize(line.replace(/^!/, '')))
+    }));
+}
+
+function isIgnored(relativePath: string, rules: { exclude: boolean; pattern: RegExp }[]): boolean {
+  let ignored = false;
+  for (const rule of rules) {
+    if (rule.pattern.test(relativePath)) {
+      ignored = rule.exclude;
+    }
+  }
+  return ignored;
+}
+
+/**
+ * Hash the Dockerfile and every file of the build context that docker would send
+ * Paths and contents both count, so renames and edits change the hash. .git is skipped.
+ */
+export function hashBuildContext(paths: BuildPaths): string {
+  const hash = createHash('sha256');
+  const rules = readDockerignore(paths.context);
+
+  hash.update('Dockerfile\0').update(fs.readFileSync(paths.dockerfile)).update('\0');
+
+  const walk = (dir: string) => {
+    for (const entry of fs.readdirSync(dir, { withFileTypes: true }).sort((a, b) => a.name.localeCompare(b.name))) {
+      const fullPath = path.join(dir, entry.name);
+      const relativePath = path.relative(paths.context, fullPath).split(path.sep).join('/');
+      if (entry.name === '.git' || isIgnored(relativePath, rules)) {
+        continue;
+      }
+
+      if (entry.isDirectory()) {
+        walk(fullPath);
+      } else if (entry.isSymbolicLink()) {
+        hash.update(`${relativePath}\0->${fs.readlinkSync(fullPath)}\0`);
+      } else if (entry.isFile()) {
+        hash.update(`${relativePath}\0`).update(fs.readFileSync(fullPath)).update('\0');
+      }
+    }
+  };
+  walk(paths.context);
+
+  return hash.digest('hex');
+}
+
+/**
+ * Build the image of a profile with a build block, unless the image for the current
+ * Dockerfile and context already exists. The hash of the last build is kept in the workspace state.
+ */
+export async function buildProfileImage(
+  build: BuildConfig,
+  profileName: string,
+  workspaceName: string,
+  workspacePath: string,
+  options: { force?: boolean; debug?: boolean; platform?: string } = {}
+): Promise<BuildResult> {
+  const paths = resolveBuildPaths(build, workspacePath, profileName);
+  const tag = getBuildImageTag(workspaceName, workspacePath, profileName);
+  // Switching the platform needs a new build from the same context
+  const hash = options.platform ? `${hashBuildContext(paths)}@${options.platform}` : hashBuildContext(paths);
+  const runtime = getContainerRuntime();
+
+  const state = readWorkspaceState(workspacePath);
+  if (!options.force && state.builds?.[tag]?.hash === hash && (await runtime.hasImage(tag, options.debug || false))) {
+    return { tag, built: false };
+  }
+
+  await runtime.buildImage(
+    { ...paths, tag, labels: { [LABEL_WORKSPACE]: workspacePath, [LABEL_PROFILE]: profileName }, platform: options.platform },
+    options.debug || false
+  );
+
+  // Builds of other profiles may finish at the same time, so the state is changed under the lock
+  await updateWorkspaceState(workspacePath, latest => ({
+    ...latest,
+    builds: { ...(latest.builds || {}), [tag]: { hash, builtAt: new Date().toISOString() } }
+  }));
+
+  return { tag, built: true };
+}
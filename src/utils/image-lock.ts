@@ -0,0 +1,183 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { createHash } from 'crypto';
+
+export const LOCK_FILE_NAME = '.aisanity.lock';
+const LOCK_VERSION = 1;
+
+export interface ImageReference {
+  name: string;     // Repository, including any registry host and port
+  tag?: string;
+  digest?: string;  // sha256:<64 hex characters>
+}
+
+export interface LockedImage {
+  digest: string;
+  pulledAt: string; // ISO timestamp of the pull that resolved the digest
+}
+
+export interface ImageLock {
+  version: number;
+  images: Record<string, LockedImage>; // Keyed by the image reference as written in the config
+  config?: string;                     // Hash of the config the lock was generated against (aisanity lock)
+  builds?: Record<string, string>;     // Build context hash per profile with a build block
+}
+
+// What the lock records besides image digests, computed from the current workspace
+export interface LockDigests {
+  config: string;
+  builds: Record<string, string>;
+}
+
+/**
+ * Split an image reference such as "ghcr.io:443/org/app:1.2@sha256:..." into its parts
+ * @throws Error when the digest is malformed
+ */
+export function parseImageReference(image: string): ImageReference {
+  const [nameAndTag, digest, ...extra] = image.split('@');
+  if (extra.length > 0 || (digest !== undefined && !/^sha256:[a-f0-9]{64}$/.test(digest))) {
+    throw new Error(`Invalid image "${image}". Digests must look like @sha256:<64 hex characters>`);
+  }
+
+  // A colon after the last slash separates the tag; earlier colons belong to a registry port
+  const tagIndex = nameAndTag.lastIndexOf(':');
+  const hasTag = tagIndex > nameAndTag.lastIndexOf('/');
+
+  return {
+    name: hasTag ? nameAndTag.substring(0, tagIndex) : nameAndTag,
+    ...(hasTag ? { tag: nameAndTag.substring(tagIndex + 1) } : {}),
+    ...(digest ? { digest } : {})
+  };
+}
+
+/**
+ * Pin an image to a digest, keeping the tag for readability (the runtime ignores it)
+ */
+export function formatPinnedImage(image: string, digest: string): string {
+  const reference = parseImageReference(image);
+  return `${reference.name}${reference.tag ? `:${reference.tag}` : ''}@${digest}`;
+}
+
+/**
+ * Pick the digest of an image from its RepoDigests, preferring the entry of the same repository
+ */
+export function selectRepoDigest(image: string, repoDigests: string[]): string | null {
+  const { name } = parseImageReference(image);
+  const entry = repoDigests.find(repoDigest => {
+    const repository = repoDigest.split('@')[0];
+    return repository === name || repository.endsWith(`/${name}`);
+  }) || repoDigests[0];
+
+  return entry && entry.includes('@') ? entry.split('@')[1] : null;
+}
+
+export function getImageLockPath(cwd: string): string {
+  return path.join(cwd, LOCK_FILE_NAME);
+}
+
+/**
+ * Read the workspace image lock, or null when there is none
+ */
+export function readImageLock(cwd: string): ImageLock | null {
+  const lockPath = getImageLockPath(cwd);
+  if (!fs.existsSync(lockPath)) {
+    return null;
+  }
+
+  let lock: ImageLock;
+  try {
+    lock = JSON.parse(fs.readFileSync(lockPath, 'utf8'));
+  } catch (error) {
+    throw new Error(`Invalid lock file ${lockPath}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+
+  if (!lock || typeof lock.images !== 'object' || lock.images === null) {
+    throw new Error(`Invalid lock file ${lockPath}: expected an "images" map`);
+  }
+  return lock;
+}
+
+export function writeImageLock(cwd: string, lock: ImageLock): void {
+  // Sorted keys keep diffs of the lock file small
+  const sorted = <T>(map: Record<string, T>): Record<string, T> =>
+    Object.fromEntries(Object.keys(map).sort().map(key => [key, map[key]]));
+  const content = {
+    version: LOCK_VERSION,
+    images: sorted(lock.images),
+    ...(lock.config !== undefined ? { config: lock.config } : {}),
+    ...(lock.builds !== undefined ? { builds: sorted(lock.builds) } : {})
+  };
+  fs.writeFileSync(getImageLockPath(cwd), JSON.stringify(content, null, 2) + '\n', 'utf8');
+}
+
+/**
+ * Hash a config for the lock
+ * Map keys are sorted, so reordering the config does not change the hash. Pass the config
+ * before env interpolation and template expansion, since those differ from machine to machine.
+ */
+export function hashLockConfig(config: unknown): string {
+  const canonical = (_key: string, value: unknown) =>
+    value && typeof value === 'object' && !Array.isArray(value)
+      ? Object.fromEntries(Object.entries(value as Record<string, unknown>).sort(([a], [b]) => a.localeCompare(b)))
+      : value;
+  return 'sha256:' + createHash('sha256').update(JSON.stringify(config ?? null, canonical)).digest('hex');
+}
+
+/**
+ * Compare the config and build contexts with what the lock was generated against
+ * Without frozen, only what the lock records is compared; with it, anything the lock does not cover is drift too.
+ * @param current Digests of the workspace; builds may cover only some profiles
+ * @returns One message per difference, empty when the lock matches
+ */
+export function findLockDrift(lock: ImageLock, current: LockDigests, frozen: boolean = false): string[] {
+  const drift: string[] = [];
+
+  if (lock.config === undefined) {
+    if (frozen) {
+      drift.push(`${LOCK_FILE_NAME} does not record the config`);
+    }
+  } else if (lock.config !== current.config) {
+    drift.push(`the config changed since ${LOCK_FILE_NAME} was generated`);
+  }
+
+  for (const [profile, hash] of Object.entries(current.builds)) {
+    const locked = lock.builds?.[profile];
+    if (locked === undefined) {
+      if (frozen || lock.builds !== undefined) {
+        drift.push(`the build context of profile '${profile}' is not in ${LOCK_FILE_NAME}`);
+      }
+    } else if (locked !== hash) {
+      drift.push(`the build context of profile '${profile}' changed since ${LOCK_FILE_NAME} was generated`);
+    }
+  }
+
+  return drift;
+}
+
+/**
+ * Record the digest an image resolved to
+ */
+export function lockImage(lock: ImageLock | null, image: string, digest: string, now: Date = new Date()): ImageLock {
+  return {
+    ...(lock || {}),
+    version: LOCK_VERSION,
+    images: { ...(lock?.images || {}), [image]: { digest, pulledAt: now.toISOString() } }
+  };
+}
+
+/**
+ * Get the reference to start an image with: images with a digest are used as written,
+ * tag-only images are pinned when the lock has an entry for them
+ */
+export function resolveLockedImage(image: string, lock: ImageLock | null): { image: string; digest?: string } {
+  const reference = parseImageReference(image);
+  if (reference.digest) {
+    return { image, digest: reference.digest };
+  }
+
+  const locked = lock?.images[image];
+  if (!locked) {
+    return { image };
+  }
+  return { image: formatPinnedImage(image, locked.digest), digest: locked.digest };
+}
@@ -3,13 +3,84 @@
  * Supports four-tier verbosity: Silent → Normal → Verbose → Debug
  */
 
+export const LOG_FORMATS = ['text', 'json'] as const;
+export type LogFormat = typeof LOG_FORMATS[number];
+
+const LOG_FORMAT_ENV_VAR = 'AISANITY_LOG_FORMAT';
+
+/**
+ * Pick the log format from the --log-format flag, falling back to AISANITY_LOG_FORMAT
+ * @throws Error for formats other than text and json
+ */
+export function resolveLogFormat(value: string | undefined, env: Record<string, string | undefined> = process.env): LogFormat {
+  const format = value ?? env[LOG_FORMAT_ENV_VAR] ?? 'text';
+  if (!(LOG_FORMATS as readonly string[]).includes(format)) {
+    throw new Error(`Invalid log format "${format}". Expected one of: ${LOG_FORMATS.join(', ')}`);
+  }
+  return format as LogFormat;
+}
+
+// One line of --log-format json output
+export interface LogRecord {
+  time: string;          // ISO timestamp
+  level: 'info' | 'warn' | 'error';
+  msg: string;
+  phase?: string;        // Set on timing records, e.g. "image_pull"
+  durationMs?: number;
+  status?: 'ok' | 'error';
+  [field: string]: unknown; // Context fields such as workspace and profile
+}
+
 export class Logger {
+  private fields: Record<string, unknown> = {};
+
   constructor(
     private silent: boolean = false,
     private verboseMode: boolean = false,
-    private debugMode: boolean = false
+    private debugMode: boolean = false,
+    private format: LogFormat = 'text'
   ) {}
 
+  /**
+   * Add context fields (e.g. workspace, profile) to every JSON record
+   */
+  setFields(fields: Record<string, unknown>): void {
+    this.fields = { ...this.fields, ...fields };
+  }
+
+  /**
+   * Record how long a phase of the command took, measured from startTime (Date.now())
+   * JSON logs get one record per phase on stderr; text logs only show it with --debug.
+   */
+  phase(name: string, startTime: number, extra: Record<string, unknown> = {}): void {
+    const durationMs = Date.now() - startTime;
+    if (this.format === 'json') {
+      this.write({ level: 'info', msg: `${name} finished`, phase: name, durationMs, status: 'ok', ...extra });
+    } else {
+      this.debug(`[Timing] ${name}: ${durationMs}ms`);
+    }
+  }
+
+  /**
+   * Run a phase and record its duration, including when it fails
+   */
+  async time<T>(name: string, fn: () => Promise<T> | T): Promise<T> {
+    const startTime = Date.now();
+    try {
+      const result = await fn();
+      this.phase(name, startTime);
+      return result;
+    } catch (error) {
+      this.phase(name, startTime, { status: 'error', error: error instanceof Error ? error.message : String(error) });
+      throw error;
+    }
+  }
+
+  // JSON records go to stderr so they never mix with the output of the command
+  private write(record: Omit<LogRecord, 'time'>): void {
+    console.error(JSON.stringify({ time: new Date().toISOString(), ...record, ...this.fields }));
+  }
+
   /**
    * Log informational message - suppressed in silent mode
    * Use for: standard command output, success messages
@@ -25,6 +96,10 @@ export class Logger {
    * Use for: failures, critical issues
    */
   error(message: string): void {
+    if (this.format === 'json') {
+      this.write({ level: 'error', msg: message });
+      return;
+    }
     console.error(message);
   }
 
@@ -53,6 +128,10 @@ export class Logger {
    * Use for: non-fatal issues, deprecation notices
    */
   warn(message: string): void {
+    if (this.format === 'json') {
+      this.write({ level: 'warn', msg: message });
+      return;
+    }
     console.error(message);
   }
 }
@@ -65,11 +144,13 @@ export function createLogger(options: {
   silent?: boolean;
   verbose?: boolean;
   debug?: boolean;
+  logFormat?: LogFormat;
 }): Logger {
   return new Logger(
     options.silent || false,
     options.verbose || false,
-    options.debug || false
+    options.debug || false,
+    options.logFormat || 'text'
   );
 }
 
@@ -79,8 +160,9 @@ export function createLogger(options: {
  */
 export function createLoggerFromCommandOptions(commandOptions: any): Logger {
   return createLogger({
-    silent: commandOptions.silent || false,
+    silent: commandOptions.silent || commandOptions.quiet || false,
     verbose: commandOptions.verbose || false,
-    debug: commandOptions.debug || false
+    debug: commandOptions.debug || false,
+    logFormat: commandOptions.logFormat === 'json' ? 'json' : 'text'
   });
 }
\ No newline at end of file
@@ -0,0 +1,134 @@
+import { DockerContainer, parseContainerState, LABEL_WORKSPACE, LABEL_BRANCH, LABEL_PROFILE } from './container-utils';
+import { LogOptions } from './container-runtime';
+
+/**
+ * Machine-readable output of the commands that support --output json
+ * Fields are only ever added to these structs; bump JSON_SCHEMA_VERSION when one changes meaning or is removed.
+ */
+export const JSON_SCHEMA_VERSION = 1;
+
+export const OUTPUT_FORMATS = ['text', 'json'] as const;
+export type OutputFormat = typeof OUTPUT_FORMATS[number];
+
+export interface ContainerJson {
+  id: string;
+  name: string;
+  state: string;                  // running, exited, created, paused, ...
+  status: string;                 // Status as reported by the runtime, e.g. "Up 2 hours"
+  image: string;
+  workspace: string | null;       // aisanity.workspace label
+  branch: string | null;          // aisanity.branch label
+  profile: string;                // aisanity.profile label, "default" when unset
+  ports: string[];                // Published ports, e.g. "0.0.0.0:3000->3000/tcp"
+  labels: Record<string, string>;
+}
+
+// aisanity status --output json
+export interface StatusJson {
+  schemaVersion: number;
+  workspace: string | null;       // Workspace path, null for status --all
+  containers: ContainerJson[];
+}
+
+// aisanity run --dry-run --output json
+export interface DryRunJson {
+  schemaVersion: number;
+  workspace: string;
+  profile: string;
+  image: string | null;           // Image the container starts from, when known before building
+  labels: Record<string, string>; // Labels the container is identified by
+  command: string[];              // Command run in the container
+  runArgs: string[];              // Unvalidated profile runArgs, included verbatim in the run command
+  commands: string[][];           // Runtime commands equivalent to the run, in order
+}
+
+// aisanity logs --output json
+export interface LogsJson {
+  schemaVersion: number;
+  container: ContainerJson;
+  follow: boolean;
+  tail: string | null;
+  since: string | null;
+  idleTimeoutMs: number | null;   // logs --until: stop following after this long without output
+}
+
+// aisanity run --events-socket, one event per line as the sandbox goes through its lifecycle
+export const RUN_EVENT_TYPES = ['created', 'started', 'healthy', 'exited', 'stopped', 'failed'] as const;
+export type RunEventType = typeof RUN_EVENT_TYPES[number];
+
+export interface RunEventJson {
+  schemaVersion: number;
+  event: RunEventType;
+  time: string;                   // ISO timestamp
+  workspace: string;
+  profile: string;
+  container: string | null;       // Container ID, null when it could not be found
+  exitCode?: number;              // Exit code of the command, for exited
+  error?: string;                 // Why the sandbox could not be started, for failed
+  dropped?: number;               // Events left out right before this one because the consumer fell behind
+}
+
+/**
+ * Validate the --output flag
+ * @throws Error for formats other than text and json
+ */
+export function parseOutputFormat(value: string | undefined): OutputFormat {
+  if (value === undefined) {
+    return 'text';
+  }
+  if (!(OUTPUT_FORMATS as readonly string[]).includes(value)) {
+    throw new Error(`Invalid --output value "${value}". Expected one of: ${OUTPUT_FORMATS.join(', ')}`);
+  }
+  return value as OutputFormat;
+}
+
+export function toContainerJson(container: DockerContainer): ContainerJson {
+  return {
+    id: container.id,
+    name: container.name,
+    state: parseContainerState(container.status || '').state,
+    status: container.status || '',
+    image: container.image,
+    workspace: container.labels[LABEL_WORKSPACE] || null,
+    branch: container.labels[LABEL_BRANCH] || null,
+    profile: container.labels[LABEL_PROFILE] || 'default',
+    ports: (container.ports || '').split(',').map(port => port.trim()).filter(Boolean),
+    labels: { ...container.labels }
+  };
+}
+
+export function toStatusJson(workspace: string | null, containers: DockerContainer[]): StatusJson {
+  return { schemaVersion: JSON_SCHEMA_VERSION, workspace, containers: containers.map(toContainerJson) };
+}
+
+export function toDryRunJson(run: Omit<DryRunJson, 'schemaVersion' | 'labels'> & { labels: string[] }): DryRunJson {
+  const labels: Record<string, string> = {};
+  for (const label of run.labels) {
+    const separator = label.indexOf('=');
+    labels[label.substring(0, separator)] = label.substring(separator + 1);
+  }
+  return { schemaVersion: JSON_SCHEMA_VERSION, ...run, labels };
+}
+
+export function toLogsJson(container: DockerContainer, options: LogOptions): LogsJson {
+  return {
+    schemaVersion: JSON_SCHEMA_VERSION,
+    container: toContainerJson(container),
+    follow: options.follow || false,
+    tail: options.tail ?? null,
+    since: options.since ?? null,
+    idleTimeoutMs: options.idleTimeout ?? null
+  };
+}
+
+export function toRunEventJson(
+  event: RunEventType,
+  run: { workspace: string; profile: string; container: string | null; exitCode?: number; error?: string },
+  time: Date = new Date()
+): RunEventJson {
+  return { schemaVersion: JSON_SCHEMA_VERSION, event, time: time.toISOString(), ...run };
+}
+
+export function formatJson(value: StatusJson | DryRunJson | LogsJson): string {
+  return JSON.stringify(value, null, 2);
+}
@@ -0,0 +1,947 @@
+import * as fs from 'fs';
+import * as path from 'path';
+import { AisanityConfig, MountConfig, ProfileConfig, HealthcheckConfig, ResourcesConfig, SecretConfig } from './config';
+import { resolveDeclaredEnv } from './env-utils';
+import { formatShellCommands } from './devcontainer-templates';
+
+export const DEFAULT_PROFILE = 'default';
+
+// Where dockerAccess mounts the host socket, the default of docker clients
+export const CONTAINER_DOCKER_SOCKET = '/var/run/docker.sock';
+
+export interface ResolvedProfile extends ProfileConfig {
+  name: string;
+}
+
+export interface CacheVolume {
+  name: string;   // Cache name from the config, namespaced per workspace when the volume is created
+  target: string; // Absolute path inside the container
+}
+
+export interface PortMapping {
+  hostIp?: string;
+  hostPort?: string;      // Undefined when docker should pick a random host port
+  containerPort: string;
+  protocol: string;
+}
+
+/**
+ * Get the names of all profiles defined in the config
+ */
+export function getProfileNames(config: AisanityConfig): string[] {
+  return Object.keys(config.profiles || {}).sort();
+}
+
+function toList(value: string | string[] | undefined): string[] {
+  if (value === undefined) {
+    return [];
+  }
+  return Array.isArray(value) ? value : [value];
+}
+
+/**
+ * Merge a profile on top of a base block
+ * Scalars are overridden, env, cache volume and resource maps are merged and mounts are concatenated
+ */
+export function mergeProfiles(base: ProfileConfig, override: ProfileConfig): ProfileConfig {
+  const merged: ProfileConfig = { ...base, ...override };
+
+  // A profile that picks its own image (or build) replaces the inherited one
+  if (override.image && !override.build) {
+    delete merged.build;
+  } else if (override.build && !override.image) {
+    delete merged.image;
+  }
+
+  if (base.env || override.env) {
+    merged.env = { ...(base.env || {}), ...(override.env || {}) };
+  }
+
+  if (base.mounts || override.mounts) {
+    merged.mounts = [...(base.mounts || []), ...(override.mounts || [])];
+  }
+
+  if (base.exclude || override.exclude) {
+    merged.exclude = [...new Set([...(base.exclude || []), ...(override.exclude || [])])];
+  }
+
+  if (base.envFile || override.envFile) {
+    merged.envFile = [...toList(base.envFile), ...toList(override.envFile)];
+  }
+
+  if (base.secrets || override.secrets) {
+    merged.secrets = [...(base.secrets || []), ...(override.secrets || [])];
+  }
+
+  if (base.cacheVolumes || override.cacheVolumes) {
+    merged.cacheVolumes = { ...(base.cacheVolumes || {}), ...(override.cacheVolumes || {}) };
+  }
+
+  if (base.resources || override.resources) {
+    merged.resources = { ...(base.resources || {}), ...(override.resources || {}) };
+  }
+
+  if (base.ports || override.ports) {
+    merged.ports = [...(base.ports || []), ...(override.ports || [])];
+  }
+
+  if (base.runArgs || override.runArgs) {
+    merged.runArgs = [...(base.runArgs || []), ...(override.runArgs || [])];
+  }
+
+  return merged;
+}
+
+/**
+ * Resolve the profile to use for a command
+ * Falls back to the 'default' profile when no name is given.
+ * Configs without a profiles map resolve to the base block (or an empty profile).
+ */
+export function resolveProfile(config: AisanityConfig, profileName?: string): ResolvedProfile {
+  const base = config.base || {};
+  const profiles = config.profiles;
+
+  if (!profiles || Object.keys(profiles).length === 0) {
+    if (profileName && profileName !== DEFAULT_PROFILE) {
+      throw new Error(`Profile '${profileName}' not found: no profiles are defined in .aisanity config`);
+    }
+    return { ...fillProfileVariable(base, DEFAULT_PROFILE), name: DEFAULT_PROFILE };
+  }
+
+  const name = profileName || DEFAULT_PROFILE;
+  const profile = profiles[name];
+
+  if (!profile) {
+    const available = getProfileNames(config).join(', ');
+    if (!profileName) {
+      throw new Error(`No --profile given and no '${DEFAULT_PROFILE}' profile defined. Available profiles: ${available}`);
+    }
+    throw new Error(`Profile '${name}' not found in .aisanity config. Available profiles: ${available}`);
+  }
+
+  return { ...fillProfileVariable(mergeProfiles(base, profile), name), name };
+}
+
+const MOUNT_CONSISTENCY_VALUES = ['cached', 'delegated', 'consistent'];
+
+/**
+ * Convert a profile mount into a docker --mount value
+ * Accepts "source:target[:ro|rw][,cached|delegated]" or a { source, target, readonly, consistency } map.
+ * Relative sources are resolved against the workspace path
+ */
+export function parseProfileMount(mount: string | MountConfig, workspacePath: string): string {
+  return formatMountSpec(parseMountEntry(mount, workspacePath));
+}
+
+/**
+ * Validate a profile mount and resolve its source to an absolute host path
+ */
+function parseMountEntry(mount: string | MountConfig, workspacePath: string): MountConfig {
+  const entry = typeof mount === 'string' ? parseMountString(mount) : mount;
+  const label = typeof mount === 'string' ? `"${mount}"` : JSON.stringify(mount);
+
+  if (!entry || typeof entry.source !== 'string' || !entry.source || typeof entry.target !== 'string' || !entry.target) {
+    throw new Error(`Invalid mount ${label}. Expected source:target or a map with source and target`);
+  }
+
+  if (!entry.target.startsWith('/')) {
+    throw new Error(`Invalid mount ${label}. Target must be an absolute container path`);
+  }
+
+  if (entry.readonly !== undefined && typeof entry.readonly !== 'boolean') {
+    throw new Error(`Invalid mount ${label}. readonly must be true or false`);
+  }
+
+  if (entry.consistency !== undefined && !MOUNT_CONSISTENCY_VALUES.includes(entry.consistency)) {
+    throw new Error(`Invalid mount ${label}. consistency must be one of: ${MOUNT_CONSISTENCY_VALUES.join(', ')}`);
+  }
+
+  if (entry.createMissing !== undefined && typeof entry.createMissing !== 'boolean' && entry.createMissing !== 'directory' && entry.createMissing !== 'file') {
+    throw new Error(`Invalid mount ${label}. createMissing must be true, false, directory or file`);
+  }
+
+  return { ...entry, source: path.resolve(workspacePath, entry.source) };
+}
+
+function formatMountSpec(entry: MountConfig): string {
+  let spec = `type=bind,source=${entry.source},target=${entry.target}`;
+  if (entry.readonly) {
+    spec += ',readonly';
+  }
+  if (entry.consistency) {
+    spec += `,consistency=${entry.consistency}`;
+  }
+  return spec;
+}
+
+/**
+ * Split "source:target[:options]" where options is a comma separated list of ro, rw and consistency values
+ */
+function parseMountString(mount: string): MountConfig | undefined {
+  const [source, target, options, ...extra] = mount.split(':');
+  if (!source || !target || extra.length > 0) {
+    return undefined;
+  }
+
+  const entry: MountConfig = { source, target };
+  for (const option of options !== undefined ? options.split(',') : []) {
+    if (option === 'ro' || option === 'rw') {
+      entry.readonly = option === 'ro';
+    } else if (MOUNT_CONSISTENCY_VALUES.includes(option)) {
+      entry.consistency = option as MountConfig['consistency'];
+    } else {
+      return undefined;
+    }
+  }
+  return entry;
+}
+
+/**
+ * Put the --mount flags of a run on top of the profile mounts
+ * Relative flag sources resolve against the current directory, and a flag replaces
+ * the profile mount with the same target.
+ * @throws Error for flags that are not source:target[:options]
+ */
+export function mergeCliMounts(mounts: (string | MountConfig)[], cliMounts: string[], cwd: string): (string | MountConfig)[] {
+  const overrides = cliMounts.map(mount => {
+    const entry = parseMountString(mount);
+    if (!entry) {
+      throw new Error(`Invalid --mount "${mount}". Expected source:target[:ro]`);
+    }
+    return { ...entry, source: path.resolve(cwd, entry.source) };
+  });
+
+  const targets = new Set(overrides.map(entry => entry.target));
+  const kept = mounts.filter(mount => {
+    const target = typeof mount === 'string' ? parseMountString(mount)?.target : mount?.target;
+    return !target || !targets.has(target);
+  });
+  return [...kept, ...overrides];
+}
+
+/**
+ * Resolve the mounts of a profile and check that every source exists on the host
+ * Docker would otherwise fail (or silently create a root-owned directory) when the container starts.
+ * Mounts with createMissing get their source created by the current user instead, except in a dry run.
+ */
+export function resolveProfileMounts(
+  mounts: (string | MountConfig)[],
+  workspacePath: string,
+  profileName: string,
+  dryRun: boolean = false
+): string[] {
+  return mounts.map(mount => {
+    let entry: MountConfig;
+    try {
+      entry = parseMountEntry(mount, workspacePath);
+    } catch (error) {
+      throw new Error(`Profile '${profileName}': ${error instanceof Error ? error.message : String(error)}`);
+    }
+
+    const kind = entry.createMissing === 'file' ? 'file' : 'directory';
+    if (!fs.existsSync(entry.source)) {
+      if (!entry.createMissing) {
+        throw new Error(`Profile '${profileName}': mount source does not exist: ${entry.source} (set createMissing on the mount to create it)`);
+      }
+      if (!dryRun) {
+        fs.mkdirSync(kind === 'file' ? path.dirname(entry.source) : entry.source, { recursive: true });
+        if (kind === 'file') {
+          fs.writeFileSync(entry.source, '', { flag: 'wx' });
+        }
+      }
+    } else if (entry.createMissing && fs.statSync(entry.source).isDirectory() !== (kind === 'directory')) {
+      throw new Error(`Profile '${profileName}': mount source ${entry.source} is not a ${kind}, but createMissing is ${entry.createMissing}`);
+    }
+
+    return formatMountSpec(entry);
+  });
+}
+
+/**
+ * Resolve the secrets of a profile into read-only bind mounts
+ * Sources must be existing files; targets default to /run/secrets/<file name>.
+ */
+export function resolveProfileSecrets(secrets: SecretConfig[], workspacePath: string, profileName: string): string[] {
+  const targets = new Set<string>();
+  return secrets.map(secret => {
+    if (!secret || typeof secret.source !== 'string' || secret.source === '') {
+      throw new Error(`Profile '${profileName}': secrets need a source file`);
+    }
+
+    const source = path.resolve(workspacePath, secret.source);
+    const target = secret.target || `/run/secrets/${path.basename(source)}`;
+    if (!target.startsWith('/')) {
+      throw new Error(`Profile '${profileName}': secret target ${target} must be an absolute container path`);
+    }
+    if (targets.has(target)) {
+      throw new Error(`Profile '${profileName}': two secrets are mounted at ${target}`);
+    }
+    targets.add(target);
+
+    if (!fs.existsSync(source) || !fs.statSync(source).isFile()) {
+      throw new Error(`Profile '${profileName}': secret file does not exist: ${source}`);
+    }
+    return formatMountSpec({ source, target, readonly: true });
+  });
+}
+
+/**
+ * Turn the exclude list of a profile into anonymous volume mounts over the workspace mount
+ * The volumes hide the host copies of the excluded paths (e.g. node_modules), so they are
+ * not synced from the host and keep their own contents for the life of the container.
+ */
+export function formatExcludeMounts(exclude: string[], workspaceFolder: string, profileName: string): string[] {
+  const targets = new Set<string>();
+  for (const entry of exclude) {
+    const relative = path.posix.normalize(String(entry).replace(/\/+$/, ''));
+    if (path.posix.isAbsolute(relative) || relative === '.' || relative === '..' || relative.startsWith('../')) {
+      throw new Error(`Profile '${profileName}': exclude entry "${entry}" must be a path inside the workspace`);
+    }
+    targets.add(path.posix.join(workspaceFolder, relative));
+  }
+  return [...targets].map(target => `type=volume,target=${target}`);
+}
+
+/**
+ * Convert docker --mount values into docker run arguments
+ */
+export function formatMountArgs(specs: string[]): string[] {
+  const args: string[] = [];
+  for (const spec of specs) {
+    args.push('--mount', spec);
+  }
+  return args;
+}
+
+/**
+ * Parse the cache volumes of a profile
+ * Names become part of a docker volume name, so they are restricted to docker's volume name characters
+ */
+export function parseCacheVolumes(cacheVolumes: Record<string, string> | undefined, profileName: string): CacheVolume[] {
+  return Object.entries(cacheVolumes || {}).map(([name, target]) => {
+    if (!/^[a-zA-Z0-9][a-zA-Z0-9_.-]*$/.test(name)) {
+      throw new Error(`Profile '${profileName}': invalid cache volume name "${name}". Use letters, digits, '.', '_' and '-'`);
+    }
+    if (typeof target !== 'string' || !target.startsWith('/')) {
+      throw new Error(`Profile '${profileName}': cache volume "${name}" target must be an absolute container path`);
+    }
+    return { name, target };
+  });
+}
+
+/**
+ * Parse a port declaration: "container", "host:container" or "ip:host:container", with optional /tcp or /udp
+ */
+export function parsePortMapping(spec: string): PortMapping {
+  const value = String(spec).trim();
+  const [address, protocol = 'tcp', ...extra] = value.split('/');
+
+  if (extra.length > 0 || (protocol !== 'tcp' && protocol !== 'udp')) {
+    throw new Error(`Invalid port "${spec}". Protocol must be tcp or udp`);
+  }
+
+  const parts = address.split(':');
+  if (parts.length > 3) {
+    throw new Error(`Invalid port "${spec}". Expected host:container, ip:host:container or container`);
+  }
+
+  const containerPort = parts[parts.length - 1];
+  const hostPort = parts.length >= 2 ? parts[parts.length - 2] : undefined;
+  const hostIp = parts.length === 3 ? parts[0] : undefined;
+
+  const isPort = (port: string): boolean => /^\d+$/.test(port) && Number(port) >= 1 && Number(port) <= 65535;
+
+  if (!isPort(containerPort) || (hostPort !== undefined && !isPort(hostPort))) {
+    throw new Error(`Invalid port "${spec}". Ports must be numbers between 1 and 65535`);
+  }
+
+  if (hostIp !== undefined && !/^\d{1,3}(\.\d{1,3}){3}$/.test(hostIp)) {
+    throw new Error(`Invalid port "${spec}". Host IP must be an IPv4 address`);
+  }
+
+  return { hostIp, hostPort, containerPort, protocol };
+}
+
+/**
+ * Validate the port declarations of a profile
+ * Rejects malformed entries and host ports that are published more than once
+ */
+export function validatePorts(ports: string[], profileName: string): PortMapping[] {
+  if (!Array.isArray(ports)) {
+    throw new Error(`Profile '${profileName}': ports must be a list`);
+  }
+
+  const mappings = ports.map(port => {
+    try {
+      return parsePortMapping(port);
+    } catch (error) {
+      throw new Error(`Profile '${profileName}': ${error instanceof Error ? error.message : String(error)}`);
+    }
+  });
+
+  // A mapping without a host IP binds every address, so it conflicts with the same port on any IP
+  const isWildcard = (hostIp?: string) => !hostIp || hostIp === '0.0.0.0';
+  mappings.forEach((mapping, index) => {
+    if (!mapping.hostPort) {
+      return;
+    }
+    const previous = mappings.findIndex((other, otherIndex) =>
+      otherIndex < index &&
+      other.hostPort === mapping.hostPort &&
+      other.protocol === mapping.protocol &&
+      (isWildcard(other.hostIp) || isWildcard(mapping.hostIp) || other.hostIp === mapping.hostIp)
+    );
+    if (previous !== -1) {
+      throw new Error(
+        `Profile '${profileName}': host port ${mapping.hostPort}/${mapping.protocol} is published twice ("${ports[previous]}" and "${ports[index]}")`
+      );
+    }
+  });
+
+  return mappings;
+}
+
+/**
+ * Get every profile merged with the base block, keyed by name
+ * Configs without profiles yield the base block as the default profile.
+ */
+function getMergedProfiles(config: AisanityConfig): [string, ProfileConfig][] {
+  const base = config.base || {};
+  const profiles = config.profiles;
+
+  if (!profiles || Object.keys(profiles).length === 0) {
+    return [[DEFAULT_PROFILE, base]];
+  }
+  return getProfileNames(config).map(name => [name, mergeProfiles(base, profiles[name] || {})]);
+}
+
+/**
+ * Validate the ports of the base block and every profile (after inheritance)
+ */
+export function validateProfilePorts(config: AisanityConfig): void {
+  for (const [name, profile] of getMergedProfiles(config)) {
+    validatePorts(profile.ports || [], name);
+  }
+}
+
+// Docker refuses memory limits below 6MB
+const MIN_MEMORY_BYTES = 6 * 1024 * 1024;
+
+/**
+ * Parse a docker memory size such as "512m" or "2g" into bytes
+ */
+export function parseMemorySize(value: string): number {
+  const match = /^(\d+(\.\d+)?)([bkmg])?$/i.exec(String(value).trim());
+  if (!match) {
+    throw new Error(`Invalid memory "${value}". Use a number with a unit, e.g. 512m or 2g`);
+  }
+
+  const units: Record<string, number> = { b: 1, k: 1024, m: 1024 ** 2, g: 1024 ** 3 };
+  return Math.floor(Number(match[1]) * units[(match[3] || 'b').toLowerCase()]);
+}
+
+/**
+ * Convert profile resource limits into docker run --cpus, --memory and --pids-limit arguments
+ * Rejects values docker would refuse (or that cannot be meant), naming the profile
+ */
+export function formatResourceArgs(resources: ResourcesConfig, profileName: string): string[] {
+  const args: string[] = [];
+
+  if (resources.cpus !== undefined) {
+    if (typeof resources.cpus !== 'number' || !(resources.cpus > 0)) {
+      throw new Error(`Profile '${profileName}': resources cpus must be a number above 0`);
+    }
+    args.push('--cpus', String(resources.cpus));
+  }
+
+  if (resources.memory !== undefined) {
+    let bytes: number;
+    try {
+      bytes = parseMemorySize(resources.memory);
+    } catch (error) {
+      throw new Error(`Profile '${profileName}': resources ${error instanceof Error ? error.message : String(error)}`);
+    }
+    if (bytes < MIN_MEMORY_BYTES) {
+      throw new Error(`Profile '${profileName}': resources memory "${resources.memory}" is below the 6m minimum`);
+    }
+    args.push('--memory', resources.memory);
+  }
+
+  if (resources.pidsLimit !== undefined) {
+    if (!Number.isInteger(resources.pidsLimit) || (resources.pidsLimit < 1 && resources.pidsLimit !== -1)) {
+      throw new Error(`Profile '${profileName}': resources pidsLimit must be a positive whole number (or -1 for unlimited)`);
+    }
+    args.push('--pids-limit', String(resources.pidsLimit));
+  }
+
+  return args;
+}
+
+/**
+ * Summarize resource limits for the resources label, e.g. "cpus=2 memory=2g pidsLimit=256"
+ */
+export function formatResourceSummary(resources: ResourcesConfig | undefined): string {
+  return (['cpus', 'memory', 'pidsLimit'] as const)
+    .filter(key => resources?.[key] !== undefined)
+    .map(key => `${key}=${resources![key]}`)
+    .join(' ');
+}
+
+/**
+ * Validate the resource limits of the base block and every profile (after inheritance)
+ */
+export function validateProfileResources(config: AisanityConfig): void {
+  for (const [name, profile] of getMergedProfiles(config)) {
+    formatResourceArgs(profile.resources || {}, name);
+  }
+}
+
+/**
+ * Convert the profile network into docker run --network arguments
+ * Accepts none, bridge, host or the name of a docker network. A profile without
+ * network access cannot publish ports, so that combination is rejected.
+ */
+export function formatNetworkArgs(profile: ProfileConfig, profileName: string): string[] {
+  if (profile.network === undefined) {
+    return [];
+  }
+
+  if (typeof profile.network !== 'string' || !/^[a-zA-Z0-9][a-zA-Z0-9_.-]*$/.test(profile.network)) {
+    throw new Error(`Profile '${profileName}': network must be none, bridge, host or the name of a docker network (got "${profile.network}")`);
+  }
+  if (profile.network === 'none' && profile.ports && profile.ports.length > 0) {
+    throw new Error(`Profile '${profileName}': ports cannot be published with network "none"`);
+  }
+  return ['--network', profile.network];
+}
+
+/**
+ * Convert dockerAccess into docker run flags sharing the host Docker socket with the sandbox
+ * The socket is mounted where docker clients look for it, and its group is added so a
+ * non-root container user can open it. Nothing is installed in the image.
+ * @throws Error when the socket does not exist (except in dry runs)
+ */
+export function formatDockerAccessArgs(socketPath: string, profileName: string, dryRun: boolean = false): string[] {
+  const mount = ['--mount', `type=bind,source=${socketPath},target=${CONTAINER_DOCKER_SOCKET}`];
+  if (!fs.existsSync(socketPath)) {
+    if (dryRun) {
+      return mount;
+    }
+    throw new Error(`Profile '${profileName}': dockerAccess needs the Docker socket, but ${socketPath} does not exist`);
+  }
+  return [...mount, '--group-add', String(fs.statSync(socketPath).gid)];
+}
+
+// Node.js architecture names as used in image platforms
+const PLATFORM_ARCHITECTURES: Record<string, string> = {
+  x64: 'amd64',
+  arm64: 'arm64',
+  arm: 'arm',
+  ia32: '386',
+  ppc64: 'ppc64le',
+  s390x: 's390x',
+  riscv64: 'riscv64'
+};
+
+/**
+ * Check an image platform such as linux/amd64 or linux/arm64/v8
+ * @returns The platform in lower case
+ */
+export function validatePlatform(platform: string, profileName: string): string {
+  if (typeof platform !== 'string' || !/^[a-z0-9]+\/[a-z0-9_]+(\/[a-z0-9]+)?$/i.test(platform)) {
+    throw new Error(`Profile '${profileName}': platform must be os/arch[/variant], e.g. linux/amd64 (got "${platform}")`);
+  }
+  return platform.toLowerCase();
+}
+
+/**
+ * The platform containers run natively on: always linux, since Docker Desktop runs them in a Linux VM
+ */
+export function getHostPlatform(arch: string = process.arch): string {
+  return `linux/${PLATFORM_ARCHITECTURES[arch] || arch}`;
+}
+
+/**
+ * Compare two platforms by os and architecture, and by variant when both name one
+ */
+export function isSamePlatform(a: string, b: string): boolean {
+  const [osA, archA, variantA] = a.toLowerCase().split('/');
+  const [osB, archB, variantB] = b.toLowerCase().split('/');
+  return osA === osB && archA === archB && (!variantA || !variantB || variantA === variantB);
+}
+
+/**
+ * Check that a profile workdir is an absolute path inside the container
+ */
+export function validateWorkdir(workdir: string, profileName: string): string {
+  if (typeof workdir !== 'string' || !path.posix.isAbsolute(workdir)) {
+    throw new Error(`Profile '${profileName}': workdir must be an absolute path in the container, e.g. /workspace (got "${workdir}")`);
+  }
+  return path.posix.normalize(workdir);
+}
+
+// Docker restart policies; on-failure takes an optional retry limit, e.g. on-failure:5
+export const RESTART_POLICIES = ['no', 'on-failure', 'unless-stopped', 'always'] as const;
+
+/**
+ * Check a restart policy the way docker run --restart takes it
+ */
+export function validateRestartPolicy(policy: string, profileName: string): string {
+  const [name, maxRetries, ...rest] = String(policy).split(':');
+  const valid = (RESTART_POLICIES as readonly string[]).includes(name) && rest.length === 0 &&
+    (maxRetries === undefined || (name === 'on-failure' && /^\d+$/.test(maxRetries)));
+  if (typeof policy !== 'string' || !valid) {
+    throw new Error(`Profile '${profileName}': restart must be one of no, on-failure[:max], unless-stopped, always (got "${policy}")`);
+  }
+  return policy;
+}
+
+/**
+ * Get the container paths of --mount specs such as "type=bind,source=/src,target=/data"
+ */
+export function getMountTargets(mounts: string[]): string[] {
+  return mounts
+    .map(mount => mount.split(',').find(part => part.startsWith('target=') || part.startsWith('dst=') || part.startsWith('destination=')))
+    .filter((part): part is string => part !== undefined)
+    .map(part => part.substring(part.indexOf('=') + 1));
+}
+
+/**
+ * Check whether a container path is one of the targets or lies below one
+ */
+export function isPathOnMount(dir: string, targets: string[]): boolean {
+  return targets.some(target => {
+    const normalized = path.posix.normalize(target).replace(/\/+$/, '') || '/';
+    return dir === normalized || dir.startsWith(normalized === '/' ? '/' : `${normalized}/`);
+  });
+}
+
+/**
+ * Start a command in another directory than the workspace folder the devcontainer CLI uses
+ */
+export function formatWorkdirCommand(command: string[], workdir: string): string[] {
+  return ['sh', '-c', 'cd "$0" && exec "$@"', workdir, ...command];
+}
+
+/**
+ * Resolve the user a profile runs as
+ * "auto" maps to the host uid:gid so files created on bind mounts are owned by the host user. It is
+ * the default on Linux; elsewhere (and for "image") the image's own user is kept. Rootless podman
+ * already runs as the host user through --userns=keep-id, so auto needs no user there.
+ * @returns The user for docker run --user, or undefined to keep the image user
+ */
+export function resolveContainerUser(
+  user: string | undefined,
+  profileName: string,
+  runtimeName: string,
+  platform: string = process.platform,
+  ids: { uid?: number; gid?: number } = { uid: process.getuid?.(), gid: process.getgid?.() }
+): string | undefined {
+  const requested = user ?? (platform === 'linux' ? 'auto' : 'image');
+
+  if (requested === 'image') {
+    return undefined;
+  }
+  if (requested === 'auto') {
+    if (runtimeName === 'podman' || ids.uid === undefined || ids.gid === undefined) {
+      return undefined;
+    }
+    return `${ids.uid}:${ids.gid}`;
+  }
+
+  const name = '([a-z_][a-z0-9_.-]*|\\d+)';
+  if (!new RegExp(`^${name}(:${name})?$`).test(requested)) {
+    throw new Error(`Profile '${profileName}': user must be auto, image, or a user such as "1000:1000" or "node" (got "${requested}")`);
+  }
+  return requested;
+}
+
+/**
+ * Validate the network of the base block and every profile (after inheritance)
+ */
+export function validateProfileNetworks(config: AisanityConfig): void {
+  for (const [name, profile] of getMergedProfiles(config)) {
+    formatNetworkArgs(profile, name);
+  }
+}
+
+/**
+ * Find profile settings that are valid but probably not what was meant (after inheritance)
+ */
+export function getProfileWarnings(config: AisanityConfig): string[] {
+  const warnings: string[] = [];
+  for (const [name, profile] of getMergedProfiles(config)) {
+    if (profile.dockerAccess && profile.network === 'none') {
+      warnings.push(`Profile '${name}': dockerAccess with network "none" still gives the sandbox control of the host through the Docker socket`);
+    }
+  }
+  return warnings;
+}
+
+// Variables config strings may reference, e.g. "${workspace}/data:/data"
+export const TEMPLATE_VARIABLES = ['workspace', 'home', 'profile'] as const;
+export type TemplateVariables = Record<typeof TEMPLATE_VARIABLES[number], string>;
+
+// Blocks shared by several profiles keep ${profile} until the profile is resolved
+export const PROFILE_TEMPLATE_PLACEHOLDER = '${profile}';
+
+/**
+ * Expand ${workspace}, ${home} and ${profile} in a config string
+ * @throws Error for any other ${...} token, so typos are not passed on literally
+ */
+export function expandTemplateVariables(value: string, variables: TemplateVariables): string {
+  return value.replace(/\$\{([^}]*)\}/g, (token, name: string) => {
+    if (!(TEMPLATE_VARIABLES as readonly string[]).includes(name)) {
+      throw new Error(`unknown template variable ${token}. Use ${TEMPLATE_VARIABLES.map(variable => `\${${variable}}`).join(', ')}`);
+    }
+    return variables[name as keyof TemplateVariables];
+  });
+}
+
+/**
+ * Call resolve for the base block and every profile with the template variables of that block
+ * ${profile} is left in place for the base block, which every profile inherits.
+ */
+function mapProfileBlocks(
+  config: AisanityConfig,
+  variables: Omit<TemplateVariables, 'profile'>,
+  resolve: (block: ProfileConfig, label: string, templates: TemplateVariables) => ProfileConfig
+): AisanityConfig {
+  const resolved: AisanityConfig = { ...config };
+  if (config.base) {
+    resolved.base = resolve(config.base, 'base', { ...variables, profile: PROFILE_TEMPLATE_PLACEHOLDER });
+  }
+
+  if (config.profiles) {
+    resolved.profiles = {};
+    for (const [name, profile] of Object.entries(config.profiles)) {
+      resolved.profiles[name] = resolve(profile || {}, `Profile '${name}'`, { ...variables, profile: name });
+    }
+  }
+
+  return resolved;
+}
+
+/**
+ * Expand the template variables in the mount sources and targets of the base block and every profile
+ */
+export function resolveProfileTemplates(config: AisanityConfig, variables: Omit<TemplateVariables, 'profile'>): AisanityConfig {
+  return mapProfileBlocks(config, variables, (block, label, templates) => {
+    if (!block.mounts) {
+      return block;
+    }
+    const expand = (value: string) => {
+      try {
+        return expandTemplateVariables(value, templates);
+      } catch (error) {
+        throw new Error(`${label} mounts: ${error instanceof Error ? error.message : String(error)}`);
+      }
+    };
+    return {
+      ...block,
+      mounts: block.mounts.map(mount => {
+        if (typeof mount === 'string') {
+          return expand(mount);
+        }
+        if (!mount || typeof mount !== 'object') {
+          return mount;
+        }
+        return {
+          ...mount,
+          ...(typeof mount.source === 'string' ? { source: expand(mount.source) } : {}),
+          ...(typeof mount.target === 'string' ? { target: expand(mount.target) } : {})
+        };
+      })
+    };
+  });
+}
+
+/**
+ * Resolve the env blocks of the base block and every profile against the host environment
+ * Interpolation happens once at load time so later merging only deals with plain values.
+ * With variables, ${workspace}, ${home} and ${profile} are expanded too.
+ */
+export function resolveProfileEnvironments(
+  config: AisanityConfig,
+  hostEnv: Record<string, string | undefined> = process.env,
+  variables?: Omit<TemplateVariables, 'profile'>
+): AisanityConfig {
+  const resolveEnv = (env: unknown, label: string, templates: Record<string, string>): Record<string, string> => {
+    try {
+      return resolveDeclaredEnv(env, hostEnv, templates);
+    } catch (error) {
+      throw new Error(`${label} env ${error instanceof Error ? error.message : String(error)}`);
+    }
+  };
+
+  const resolved = mapProfileBlocks(config, variables || { workspace: '', home: '' }, (block, label, templates) =>
+    block.env === undefined ? block : { ...block, env: resolveEnv(block.env, label, variables ? templates : {}) }
+  );
+
+  if (config.envProfiles) {
+    resolved.envProfiles = {};
+    for (const [name, env] of Object.entries(config.envProfiles)) {
+      const templates = variables ? { ...variables, profile: PROFILE_TEMPLATE_PLACEHOLDER } : {};
+      resolved.envProfiles[name] = resolveEnv(env || {}, `Env profile '${name}'`, templates);
+    }
+  }
+
+  return resolved;
+}
+
+/**
+ * Fill in the ${profile} left in inherited mounts and env values
+ */
+function fillProfileVariable(profile: ProfileConfig, name: string): ProfileConfig {
+  const fill = (value: string) => value.split(PROFILE_TEMPLATE_PLACEHOLDER).join(name);
+  const filled: ProfileConfig = { ...profile };
+  if (profile.mounts) {
+    filled.mounts = profile.mounts.map(mount => {
+      if (typeof mount === 'string') {
+        return fill(mount);
+      }
+      return mount && typeof mount === 'object'
+        ? { ...mount, ...(typeof mount.source === 'string' ? { source: fill(mount.source) } : {}), ...(typeof mount.target === 'string' ? { target: fill(mount.target) } : {}) }
+        : mount;
+    });
+  }
+  if (profile.env && !Array.isArray(profile.env)) {
+    filled.env = Object.fromEntries(Object.entries(profile.env).map(([key, value]) => [key, typeof value === 'string' ? fill(value) : value]));
+  }
+  return filled;
+}
+
+/**
+ * Layer the env bundles named with --env-profile on top of the run profile env, in the order given
+ * @throws Error when a bundle is not defined in envProfiles
+ */
+export function applyEnvProfiles(config: AisanityConfig, profile: ResolvedProfile, names: string[]): ResolvedProfile {
+  if (names.length === 0) {
+    return profile;
+  }
+
+  const envProfiles = config.envProfiles || {};
+  let env = { ...(profile.env || {}) };
+  for (const name of names) {
+    if (!Object.prototype.hasOwnProperty.call(envProfiles, name)) {
+      const available = Object.keys(envProfiles).sort();
+      if (available.length === 0) {
+        throw new Error(`Env profile '${name}' not found: no envProfiles are defined in .aisanity config`);
+      }
+      throw new Error(`Env profile '${name}' not found in .aisanity config. Available env profiles: ${available.join(', ')}`);
+    }
+    env = { ...env, ...envProfiles[name] };
+  }
+  return { ...profile, env: fillProfileVariable({ env }, profile.name).env };
+}
+
+// Docker's defaults for healthcheck settings that are not given
+const HEALTHCHECK_DEFAULTS = { interval: '30s', timeout: '30s', retries: 3, startPeriod: '0s' };
+
+/**
+ * Parse a docker duration such as "500ms", "5s" or "1m30s" into milliseconds
+ */
+export function parseDuration(value: string): number {
+  const trimmed = String(value).trim();
+  if (!/^(\d+(\.\d+)?(ms|s|m|h))+$/.test(trimmed)) {
+    throw new Error(`Invalid duration "${value}". Use a number with a unit, e.g. 500ms, 5s or 1m30s`);
+  }
+
+  const units: Record<string, number> = { ms: 1, s: 1000, m: 60000, h: 3600000 };
+  let millis = 0;
+  for (const [, amount, , unit] of trimmed.matchAll(/(\d+(\.\d+)?)(ms|s|m|h)/g)) {
+    millis += Number(amount) * units[unit];
+  }
+  return millis;
+}
+
+/**
+ * Convert a profile healthcheck into docker run --health-* arguments
+ */
+export function formatHealthcheckArgs(healthcheck: HealthcheckConfig, profileName: string): string[] {
+  const command = Array.isArray(healthcheck.command) ? formatShellCommands([healthcheck.command]) : healthcheck.command;
+  if (!command) {
+    throw new Error(`Profile '${profileName}': healthcheck needs a command`);
+  }
+
+  const args = ['--health-cmd', command];
+  for (const [key, flag] of [['interval', '--health-interval'], ['timeout', '--health-timeout'], ['startPeriod', '--health-start-period']] as const) {
+    const value = healthcheck[key];
+    if (value !== undefined) {
+      try {
+        parseDuration(value);
+      } catch (error) {
+        throw new Error(`Profile '${profileName}': healthcheck ${key}: ${error instanceof Error ? error.message : String(error)}`);
+      }
+      args.push(flag, value);
+    }
+  }
+
+  if (healthcheck.retries !== undefined) {
+    if (!Number.isInteger(healthcheck.retries) || healthcheck.retries < 1) {
+      throw new Error(`Profile '${profileName}': healthcheck retries must be a positive whole number`);
+    }
+    args.push('--health-retries', String(healthcheck.retries));
+  }
+  return args;
+}
+
+/**
+ * Time (ms) after which a container that is still not healthy has used up its retries
+ * The start period plus one interval and timeout per attempt, and one extra interval of slack.
+ */
+export function getHealthcheckDeadline(healthcheck: Partial<HealthcheckConfig> = {}): number {
+  const interval = parseDuration(healthcheck.interval || HEALTHCHECK_DEFAULTS.interval);
+  const timeout = parseDuration(healthcheck.timeout || HEALTHCHECK_DEFAULTS.timeout);
+  const retries = healthcheck.retries || HEALTHCHECK_DEFAULTS.retries;
+  return parseDuration(healthcheck.startPeriod || HEALTHCHECK_DEFAULTS.startPeriod) + (interval + timeout) * retries + interval;
+}
+
+/**
+ * Convert port mappings into docker run -p arguments
+ */
+export function formatPortArgs(mappings: PortMapping[]): string[] {
+  const args: string[] = [];
+  for (const mapping of mappings) {
+    const address = [mapping.hostIp, mapping.hostPort, mapping.containerPort].filter(Boolean).join(':');
+    args.push('-p', mapping.protocol === 'tcp' ? address : `${address}/${mapping.protocol}`);
+  }
+  return args;
+}
+
+/**
+ * Get the command to run for a profile, falling back to the given default
+ */
+export function getProfileCommand(profile: ProfileConfig, fallback: string[]): string[] {
+  if (!profile.command) {
+    return fallback;
+  }
+
+  if (Array.isArray(profile.command)) {
+    return profile.command.length > 0 ? profile.command : fallback;
+  }
+
+  return ['sh', '-c', profile.command];
+}
+
+/**
+ * Pick the command of a run: arguments given on the command line replace the profile command
+ * They stay an argv list, so quoting and spaces reach the container unchanged.
+ */
+export function resolveRunCommand(args: string[], profile: ProfileConfig, fallback: string[]): string[] {
+  return args.length > 0 ? args : getProfileCommand(profile, fallback);
+}
+
+/**
+ * Apply the profile env on top of the workspace-level config env
+ */
+export function applyProfileToConfig(config: AisanityConfig, profile: ProfileConfig): AisanityConfig {
+  if (!profile.env) {
+    return config;
+  }
+
+  return {
+    ...config,
+    env: { ...(config.env || {}), ...profile.env }
+  };
+}
@@ -0,0 +1,191 @@
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { hashWorkspacePath } from './container-utils';
+
+const STATE_FILE_NAME = 'state.json';
+const LOCK_FILE_NAME = 'state.lock';
+
+// How long to wait for another aisanity command to release the state lock
+const DEFAULT_LOCK_TIMEOUT = 5000;
+const LOCK_POLL_INTERVAL = 50;
+
+export interface StateLockOptions {
+  timeout?: number; // Milliseconds to wait for the lock before giving up
+  env?: Record<string, string | undefined>;
+}
+
+export interface BuildState {
+  hash: string;    // Hash of the Dockerfile and build context the image was built from
+  builtAt: string; // ISO timestamp
+}
+
+export interface WorkspaceState {
+  builds?: Record<string, BuildState>; // Keyed by image tag
+  shownWarnings?: string[];            // Keys of warnings that are only shown once
+}
+
+/**
+ * Get the directory aisanity keeps local state in for a workspace
+ * State lives under $XDG_STATE_HOME (or ~/.local/state), one directory per workspace path.
+ */
+export function getStateDir(workspacePath: string, env: Record<string, string | undefined> = process.env): string {
+  const stateHome = env.XDG_STATE_HOME || path.join(env.HOME || os.homedir(), '.local', 'state');
+  return path.join(stateHome, 'aisanity', `${path.basename(path.resolve(workspacePath))}-${hashWorkspacePath(workspacePath)}`);
+}
+
+/**
+ * Read the workspace state, or an empty state when there is none yet
+ */
+export function readWorkspaceState(workspacePath: string, env: Record<string, string | undefined> = process.env): WorkspaceState {
+  const statePath = path.join(getStateDir(workspacePath, env), STATE_FILE_NAME);
+  if (!fs.existsSync(statePath)) {
+    return {};
+  }
+
+  try {
+    return JSON.parse(fs.readFileSync(statePath, 'utf8'));
+  } catch (error) {
+    // State is a cache; a damaged file only means work is redone
+    return {};
+  }
+}
+
+/**
+ * Replace the workspace state
+ * The file is written next to the old one and renamed over it, so readers never see a partial write.
+ * Use updateWorkspaceState to change state that other commands may be changing too.
+ */
+export function writeWorkspaceState(workspacePath: string, state: WorkspaceState, env: Record<string, string | undefined> = process.env): void {
+  const stateDir = getStateDir(workspacePath, env);
+  fs.mkdirSync(stateDir, { recursive: true });
+  const tempPath = path.join(stateDir, `${STATE_FILE_NAME}.${process.pid}.tmp`);
+  fs.writeFileSync(tempPath, JSON.stringify(state, null, 2) + '\n', 'utf8');
+  fs.renameSync(tempPath, path.join(stateDir, STATE_FILE_NAME));
+}
+
+function isProcessAlive(pid: number): boolean {
+  try {
+    process.kill(pid, 0);
+    return true;
+  } catch (error) {
+    // EPERM means the process exists but belongs to another user
+    return (error as NodeJS.ErrnoException).code === 'EPERM';
+  }
+}
+
+/**
+ * Remove a stale lock file without removing a fresh lock that replaced it in the meantime
+ * The lock is renamed to a name of its own first, so only one command takes it over, and
+ * a lock that turns out not to be the stale one is put back.
+ */
+function removeStaleLock(lockPath: string, stale: fs.Stats): void {
+  const claimedPath = `${lockPath}.${process.pid}.${Math.random().toString(36).slice(2)}.stale`;
+  try {
+    fs.renameSync(lockPath, claimedPath);
+  } catch (error) {
+    // Another command took the lock over first
+    return;
+  }
+
+  const claimed = fs.statSync(claimedPath);
+  if (claimed.ino !== stale.ino || claimed.dev !== stale.dev) {
+    try {
+      fs.linkSync(claimedPath, lockPath);
+    } catch (error) {
+      // Yet another command holds the lock by now
+    }
+  }
+  fs.rmSync(claimedPath, { force: true });
+}
+
+/**
+ * Take the state lock of a workspace: an exclusively created lock file holding the owner's pid
+ * Locks left behind by processes that no longer run are taken over, as are lock files
+ * without a pid that are older than the timeout.
+ * @throws Error when another aisanity command keeps the lock for longer than the timeout
+ */
+async function acquireStateLock(workspacePath: string, options: StateLockOptions): Promise<() => void> {
+  const stateDir = getStateDir(workspacePath, options.env);
+  const lockPath = path.join(stateDir, LOCK_FILE_NAME);
+  const timeout = options.timeout ?? DEFAULT_LOCK_TIMEOUT;
+  const deadline = Date.now() + timeout;
+  fs.mkdirSync(stateDir, { recursive: true });
+
+  for (;;) {
+    try {
+      const fd = fs.openSync(lockPath, 'wx');
+      fs.writeSync(fd, String(process.pid));
+      fs.closeSync(fd);
+      return () => fs.rmSync(lockPath, { force: true });
+    } catch (error) {
+      if ((error as NodeJS.ErrnoException).code !== 'EEXIST') {
+        throw error;
+      }
+    }
+
+    let owner = NaN;
+    let stats: fs.Stats;
+    try {
+      const fd = fs.openSync(lockPath, 'r');
+      try {
+        stats = fs.fstatSync(fd);
+        owner = parseInt(fs.readFileSync(fd, 'utf8'), 10);
+      } finally {
+        fs.closeSync(fd);
+      }
+    } catch (error) {
+      // Released between the open and the read
+      continue;
+    }
+    // A lock file without a pid is still being written by its owner, unless the owner died
+    // before writing it
+    const stale = isNaN(owner)
+      ? Date.now() - stats.mtimeMs > timeout
+      : owner !== process.pid && !isProcessAlive(owner);
+    if (stale) {
+      removeStaleLock(lockPath, stats);
+      continue;
+    }
+
+    if (Date.now() >= deadline) {
+      const ownerInfo = isNaN(owner) ? '' : ` (pid ${owner})`;
+      throw new Error(`Another aisanity command is running for this workspace${ownerInfo}. Try again once it has finished.`);
+    }
+    await new Promise(resolve => setTimeout(resolve, LOCK_POLL_INTERVAL));
+  }
+}
+
+/**
+ * Read, change and write the workspace state while holding the workspace state lock,
+ * so concurrent aisanity commands do not overwrite each other's changes
+ */
+export async function updateWorkspaceState(
+  workspacePath: string,
+  update: (state: WorkspaceState) => WorkspaceState | Promise<WorkspaceState>,
+  options: StateLockOptions = {}
+): Promise<WorkspaceState> {
+  const release = await acquireStateLock(workspacePath, options);
+  try {
+    const env = options.env || process.env;
+    const state = await update(readWorkspaceState(workspacePath, env));
+    writeWorkspaceState(workspacePath, state, env);
+    return state;
+  } finally {
+    release();
+  }
+}
+
+/**
+ * Record that a one-time warning was shown for a workspace
+ * @returns true the first time for a key, false when the warning was shown before
+ */
+export async function markWarningShown(workspacePath: string, key: string, options: StateLockOptions = {}): Promise<boolean> {
+  let first = false;
+  await updateWorkspaceState(workspacePath, state => {
+    const shown = state.shownWarnings || [];
+    first = !shown.includes(key);
+    return first ? { ...state, shownWarnings: [...shown, key] } : state;
+  }, options);
+  return first;
+}
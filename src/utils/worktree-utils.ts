@@ -2,12 +2,12 @@ import * as fs from 'fs';
 import * as path from 'path';
 import { execSync } from 'child_process';
 import { AisanityConfig, loadAisanityConfig, getWorkspaceName, sanitizeBranchName, getCurrentBranch } from './config';
-import { discoverContainers, discoverAllAisanityContainers, DockerContainer, WorktreeValidationResult } from './container-utils';
+import { discoverContainers, discoverAllAisanityContainers, DockerContainer, WorktreeValidationResult, generateContainerName } from './container-utils';
 
 export interface WorktreeInfo {
   path: string;          // Absolute path to worktree directory
   branch: string;        // Associated git branch name
-  containerName: string; // Generated container name (e.g., "aisanity-feature-auth")
+  containerName: string; // Generated container name (e.g., "aisanity-feature-auth-1a2b3c4d")
   isActive: boolean;     // Whether this is the currently active worktree
   configPath: string;    // Path to .aisanity config file
 }
@@ -195,10 +195,12 @@ export function isValidGitWorktree(mainGitDir: string, worktreeName: string): bo
 
 /**
  * Generate container name for worktree
+ * When the worktree path is given, a short path hash is appended to match getContainerName
  */
-export function generateWorktreeContainerName(workspaceName: string, worktreeName: string): string {
+export function generateWorktreeContainerName(workspaceName: string, worktreeName: string, worktreePath?: string): string {
   const sanitizedWorktreeName = sanitizeBranchName(worktreeName);
-  return `${workspaceName}-${sanitizedWorktreeName}`;
+  const prefix = `${workspaceName}-${sanitizedWorktreeName}`;
+  return worktreePath ? generateContainerName(prefix, worktreePath) : prefix;
 }
 
 /**
@@ -252,7 +254,7 @@ let mainGitRepo: string;
   const mainWorktree: WorktreeInfo = {
     path: mainGitRepo,
     branch: mainBranch,
-    containerName: generateWorktreeContainerName(mainWorkspaceName, mainBranch),
+    containerName: generateWorktreeContainerName(mainWorkspaceName, mainBranch, mainGitRepo),
     isActive: isInMainRepo && !isInWorktreeDir,
     configPath: path.join(mainConfigPath, '.aisanity')
   };
@@ -282,7 +284,7 @@ let mainGitRepo: string;
           const worktreeInfo: WorktreeInfo = {
             path: worktreePath,
             branch: worktreeBranch,
-            containerName: generateWorktreeContainerName(mainWorkspaceName, worktreeName),
+            containerName: generateWorktreeContainerName(mainWorkspaceName, worktreeName, worktreePath),
             isActive: cwd === worktreePath || cwd.startsWith(worktreePath + path.sep),
             configPath: path.join(worktreePath, '.aisanity')
           };
@@ -384,7 +386,7 @@ export function getWorktreeByName(worktreeName: string, topLevelPath: string): W
     return {
       path: worktreePath,
       branch: worktreeBranch,
-      containerName: generateWorktreeContainerName(mainWorkspaceName, worktreeName),
+      containerName: generateWorktreeContainerName(mainWorkspaceName, worktreeName, worktreePath),
       isActive: process.cwd() === worktreePath,
       configPath: path.join(worktreePath, '.aisanity')
     };
@@ -0,0 +1,35 @@
+import { describe, it, expect } from 'bun:test';
+import { attachCommand, getAttachIdLabels } from '../src/commands/attach';
+import { runCommand } from '../src/commands/run';
+
+describe('detached run and attach', () => {
+  it('should offer --detach on run without changing -d (debug)', () => {
+    const detach = runCommand.options.find(option => option.long === '--detach');
+    const debug = runCommand.options.find(option => option.long === '--debug');
+    expect(detach).toBeDefined();
+    expect(debug?.short).toBe('-d');
+  });
+
+  it('should register the attach command', () => {
+    expect(attachCommand.name()).toBe('attach');
+    expect(attachCommand.options.map(option => option.long)).toContain('--profile');
+  });
+
+  describe('getAttachIdLabels', () => {
+    it('should identify the container by its aisanity labels only', () => {
+      const labels = {
+        'aisanity.workspace': '/work/app',
+        'aisanity.branch': 'main',
+        'aisanity.container': 'app-main-1a2b3c4d',
+        'aisanity.profile': '',
+        'devcontainer.local_folder': '/work/app'
+      };
+
+      expect(getAttachIdLabels(labels)).toEqual([
+        'aisanity.workspace=/work/app',
+        'aisanity.branch=main',
+        'aisanity.container=app-main-1a2b3c4d'
+      ]);
+    });
+  });
+});
@@ -0,0 +1,60 @@
+import { describe, it, expect } from 'bun:test';
+import { cleanupCommand, cleanCommand, selectCacheVolumesToClean, selectOrphanedCacheVolumes } from '../src/commands/cleanup';
+import { stopCommand } from '../src/commands/stop';
+import { selectLabeledContainers, DockerContainer } from '../src/utils/container-utils';
+
+describe('cleanup command', () => {
+  it('should keep "clean" a separate command, both with a --volumes option', () => {
+    expect(cleanupCommand.aliases()).not.toContain('clean');
+    expect(cleanCommand.name()).toBe('clean');
+    expect(cleanupCommand.options.map(option => option.long)).toContain('--volumes');
+    expect(cleanCommand.options.map(option => option.long)).toContain('--volumes');
+  });
+
+  it('should pair clean with stop --all, both supporting --dry-run', () => {
+    expect(cleanCommand.options.map(option => option.long)).toContain('--dry-run');
+    expect(stopCommand.options.map(option => option.long)).toContain('--all');
+    expect(stopCommand.options.map(option => option.long)).toContain('--dry-run');
+  });
+
+  describe('selectLabeledContainers', () => {
+    const container = (id: string, status: string, labels: Record<string, string>): DockerContainer => ({
+      id, name: id, image: 'node:22', status, ports: '', labels
+    });
+    const containers = [
+      container('running', 'Up 2 hours', { 'aisanity.workspace': '/work/app' }),
+      container('paused', 'Up 5 minutes (Paused)', { 'aisanity.workspace': '/work/app' }),
+      container('exited', 'Exited (0) 3 days ago', { 'aisanity.workspace': '/work/lib' }),
+      container('created', 'Created', { 'aisanity.workspace': '/work/lib' }),
+      container('unrelated', 'Exited (1) 1 hour ago', { 'devcontainer.local_folder': '/work/app' })
+    ];
+
+    it('should only select containers with the aisanity.workspace label', () => {
+      expect(selectLabeledContainers(containers, true).map(c => c.id)).toEqual(['running', 'paused']);
+      expect(selectLabeledContainers(containers, false).map(c => c.id)).toEqual(['exited', 'created']);
+    });
+  });
+
+  describe('cache volume selection', () => {
+    const volumes = [
+      { name: 'aisanity-app-1a2b3c4d-npm', labels: { 'aisanity.workspace': '/work/app', 'aisanity.cache': 'npm' } },
+      { name: 'aisanity-lib-5e6f7a8b-npm', labels: { 'aisanity.workspace': '/work/lib', 'aisanity.cache': 'npm' } },
+      { name: 'aisanity-old-9c0d1e2f-npm', labels: { 'aisanity.workspace': '/work/old', 'aisanity.cache': 'npm' } },
+      { name: 'aisanity-unknown-npm', labels: { 'aisanity.cache': 'npm' } }
+    ];
+
+    it('should only select the cache volumes of the given workspaces', () => {
+      expect(selectCacheVolumesToClean(volumes, ['/work/app']).map(volume => volume.name)).toEqual(['aisanity-app-1a2b3c4d-npm']);
+      expect(selectCacheVolumesToClean(volumes, [])).toEqual([]);
+    });
+
+    it('should select orphaned cache volumes and keep those of existing workspaces', () => {
+      const exists = (p: string) => p !== '/work/old';
+
+      expect(selectOrphanedCacheVolumes(volumes, exists).map(volume => volume.name)).toEqual([
+        'aisanity-old-9c0d1e2f-npm',
+        'aisanity-unknown-npm'
+      ]);
+    });
+  });
+});
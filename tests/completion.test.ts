@@ -0,0 +1,89 @@
+import { describe, it, expect } from 'bun:test';
+import { Command } from 'commander';
+import { collectCompletionCommands, generateCompletionScript, COMPLETE_PROFILES_COMMAND } from '../src/utils/completion';
+
+function buildProgram(): Command {
+  const program = new Command('aisanity');
+  program.addCommand(
+    new Command('run')
+      .description('Run a command in the sandbox')
+      .option('--profile <name>', 'Profile to use')
+      .option('--workspace <path>', 'Workspace root')
+      .option('-v, --verbose', "Show the sandbox's details")
+  );
+  program.addCommand(new Command('cleanup').alias('clean').description('Remove stale containers'));
+
+  const worktree = new Command('worktree').description('Manage worktrees');
+  worktree.addCommand(new Command('create').description('Create a worktree').option('--devcontainer-json <path>', 'Template'));
+  worktree.addCommand(new Command('list').description('List worktrees'));
+  program.addCommand(worktree);
+
+  program.addCommand(new Command(COMPLETE_PROFILES_COMMAND).description('internal'));
+  return program;
+}
+
+describe('Shell completion', () => {
+  describe('collectCompletionCommands', () => {
+    it('should walk nested commands and leave out internal ones', () => {
+      const commands = collectCompletionCommands(buildProgram());
+
+      expect(commands.map(command => command.path.join(' '))).toEqual(['', 'run', 'cleanup', 'worktree', 'worktree create', 'worktree list']);
+      expect(commands[0].subcommands).toEqual(['run', 'cleanup', 'worktree']);
+      expect(commands.find(command => command.path[0] === 'cleanup')?.aliases).toEqual(['clean']);
+    });
+
+    it('should classify option values', () => {
+      const run = collectCompletionCommands(buildProgram()).find(command => command.path.join(' ') === 'run')!;
+
+      expect(run.options).toEqual([
+        { names: ['--profile'], description: 'Profile to use', value: 'profiles' },
+        { names: ['--workspace'], description: 'Workspace root', value: 'directories' },
+        { names: ['-v', '--verbose'], description: "Show the sandbox's details" }
+      ]);
+    });
+
+    it('should complete files for --config and --events-socket', () => {
+      const program = new Command('aisanity');
+      program.addCommand(new Command('run')
+        .option('--config <path>', 'Config file')
+        .option('--events-socket <path>', 'Events socket'));
+      const run = collectCompletionCommands(program).find(command => command.path.join(' ') === 'run')!;
+
+      expect(run.options.map(option => option.value)).toEqual(['files', 'files']);
+    });
+  });
+
+  describe('generateCompletionScript', () => {
+    it('should complete profiles and directories in bash', () => {
+      const script = generateCompletionScript('bash', buildProgram());
+
+      expect(script).toContain('complete -o default -F _aisanity aisanity');
+      expect(script).toContain(`--profile) COMPREPLY=($(compgen -W "$(aisanity ${COMPLETE_PROFILES_COMMAND} 2>/dev/null)" -- "$cur")); return ;;`);
+      expect(script).toContain('--workspace) COMPREPLY=($(compgen -d -- "$cur")); return ;;');
+      expect(script).toContain('"cleanup"|"clean") words="" ;;');
+      expect(script).toContain('"worktree create") words="--devcontainer-json" ;;');
+      expect(script).not.toContain(`words="run cleanup worktree ${COMPLETE_PROFILES_COMMAND}`);
+    });
+
+    it('should describe commands and options in zsh', () => {
+      const script = generateCompletionScript('zsh', buildProgram());
+
+      expect(script.startsWith('#compdef aisanity\n')).toBe(true);
+      expect(script).toContain("'run:Run a command in the sandbox'");
+      expect(script).toContain(`'-v:Show the sandbox'\\''s details'`);
+      expect(script).toContain('--workspace) _directories; return ;;');
+      expect(script).toContain('compdef _aisanity aisanity');
+    });
+
+    it('should register fish completions per command', () => {
+      const script = generateCompletionScript('fish', buildProgram());
+
+      expect(script).toContain("complete -c aisanity -n '__fish_use_subcommand' -a run -d 'Run a command in the sandbox'");
+      expect(script).toContain(`complete -c aisanity -n '__fish_seen_subcommand_from run' -l profile -r -a '(aisanity ${COMPLETE_PROFILES_COMMAND} 2>/dev/null)' -d 'Profile to use'`);
+      expect(script).toContain("complete -c aisanity -n '__fish_seen_subcommand_from run' -l workspace -r -a '(__fish_complete_directories)' -d 'Workspace root'");
+      expect(script).toContain("-s v -l verbose -d 'Show the sandbox\\'s details'");
+      expect(script).toContain("complete -c aisanity -n '__fish_seen_subcommand_from worktree; and not __fish_seen_subcommand_from create list' -a create -d 'Create a worktree'");
+      expect(script).toContain("complete -c aisanity -n '__fish_seen_subcommand_from worktree; and __fish_seen_subcommand_from create' -l devcontainer-json -r -F -d 'Template'");
+    });
+  });
+});
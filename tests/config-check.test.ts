@@ -0,0 +1,84 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { checkConfigFile } from '../src/utils/config-check';
+import { setConfigOverride } from '../src/utils/config';
+
+describe('checkConfigFile', () => {
+  let tempDir: string;
+  const hostEnv = { HOME: '/nonexistent-home' };
+
+  const writeConfig = (content: string) => fs.writeFileSync(path.join(tempDir, '.aisanity'), content);
+  const details = (result: ReturnType<typeof checkConfigFile>) => result.errors.map(error => error.detail);
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-validate-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should accept a valid config', () => {
+    writeConfig('workspace: app\nprofiles:\n  default:\n    ports: ["3000"]\n');
+    const result = checkConfigFile(tempDir, { hostEnv });
+    expect(result.errors).toEqual([]);
+    expect(result.warnings).toEqual([]);
+  });
+
+  it('should check the config given with --config', () => {
+    setConfigOverride({ path: path.join(tempDir, '<stdin>'), content: 'workspace: app\nprofiles:\n  default:\n    restart: sometimes\n' });
+    try {
+      const result = checkConfigFile(tempDir, { hostEnv });
+      expect(result.configPath).toBe(path.join(tempDir, '<stdin>'));
+      expect(details(result)[0]).toContain("Profile 'default': restart must be one of");
+    } finally {
+      setConfigOverride(null);
+    }
+  });
+
+  it('should report a missing config', () => {
+    expect(details(checkConfigFile(tempDir, { hostEnv }))).toEqual(['file does not exist. Run "aisanity init" first']);
+  });
+
+  it('should collect the problems of every profile', () => {
+    writeConfig([
+      'workspace: app',
+      'profiles:',
+      '  default:',
+      '    ports: ["70000"]',
+      '    network: "bad name"',
+      '  test:',
+      '    env:',
+      '      TOKEN: ${MISSING_TOKEN}',
+      '    mounts:',
+      '      - ./data',
+      ''
+    ].join('\n'));
+
+    const errors = details(checkConfigFile(tempDir, { hostEnv }));
+    expect(errors.length).toBe(4);
+    expect(errors[0]).toBe("Profile 'test' env TOKEN: Host variable MISSING_TOKEN is not set (use ${MISSING_TOKEN:-default} to provide a fallback)");
+    expect(errors.some(error => error.startsWith("Profile 'default'") && error.includes('70000'))).toBe(true);
+    expect(errors.some(error => error.startsWith("Profile 'default': network must be"))).toBe(true);
+    expect(errors.some(error => error.startsWith("Profile 'test': Invalid mount \"./data\""))).toBe(true);
+  });
+
+  it('should only check mount sources when asked to', () => {
+    writeConfig('workspace: app\nbase:\n  mounts:\n    - ./missing:/data\n');
+    expect(checkConfigFile(tempDir, { hostEnv }).errors).toEqual([]);
+    expect(details(checkConfigFile(tempDir, { hostEnv, checkMounts: true }))[0]).toContain('mount source does not exist');
+    expect(fs.existsSync(path.join(tempDir, 'missing'))).toBe(false);
+  });
+
+  it('should check the requested profile and warn without a default one', () => {
+    writeConfig('workspace: app\nprofiles:\n  test: {}\n');
+    expect(checkConfigFile(tempDir, { hostEnv }).warnings).toEqual([
+      "No 'default' profile: aisanity run needs --profile (one of test)"
+    ]);
+    expect(details(checkConfigFile(tempDir, { hostEnv, profile: 'prod' }))).toEqual([
+      "Profile 'prod' not found in .aisanity config. Available profiles: test"
+    ]);
+  });
+});
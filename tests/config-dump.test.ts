@@ -0,0 +1,68 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { getEffectiveConfig } from '../src/commands/config-dump';
+import { loadAisanityConfig, readAisanityConfig } from '../src/utils/config';
+
+describe('config dump', () => {
+  let tempDir: string;
+  const savedEnv = { ...process.env };
+
+  const dump = (options = {}) => getEffectiveConfig(loadAisanityConfig(tempDir)!, readAisanityConfig(tempDir)!, tempDir, options);
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-dump-'));
+    process.env.XDG_CONFIG_HOME = path.join(tempDir, 'user-config');
+    process.env.DUMP_TOKEN = 'secret-token';
+    fs.writeFileSync(path.join(tempDir, '.aisanity'), [
+      'workspace: app',
+      'stopTimeout: 30',
+      'base:',
+      '  env:',
+      '    LOG_DIR: ${workspace}/logs/${profile}',
+      'profiles:',
+      '  default:',
+      '    image: node:22',
+      '    mounts:',
+      '      - ./data:/data:ro',
+      '    env:',
+      '      TOKEN: ${DUMP_TOKEN}',
+      '      NODE_ENV: test',
+      'envProfiles:',
+      '  ci:',
+      '    CI: "true"',
+      ''
+    ].join('\n'));
+  });
+
+  afterEach(() => {
+    process.env = { ...savedEnv };
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should merge the selected profile into the workspace settings', () => {
+    const effective = dump();
+    expect(effective.workspace).toBe('app');
+    expect(effective.stopTimeout).toBe(30);
+    expect(effective.profile).toBe('default');
+    expect(effective.image).toBe('node:22');
+    expect(effective.profiles).toBeUndefined();
+    expect(effective.mounts).toEqual([`type=bind,source=${path.join(tempDir, 'data')},target=/data,readonly`]);
+  });
+
+  it('should expand templates and mask host values', () => {
+    expect(dump().env).toEqual({
+      LOG_DIR: `${tempDir}/logs/default`,
+      TOKEN: '***',
+      NODE_ENV: 'test'
+    });
+    expect((dump({ showSecrets: true }).env as Record<string, string>).TOKEN).toBe('secret-token');
+  });
+
+  it('should layer env profiles', () => {
+    const effective = dump({ envProfiles: ['ci'] });
+    expect(effective.envProfiles).toEqual(['ci']);
+    expect((effective.env as Record<string, string>).CI).toBe('true');
+  });
+});
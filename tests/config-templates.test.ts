@@ -0,0 +1,65 @@
+import { describe, it, expect } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { getConfigTemplate, getConfigTemplateNames, createTemplateConfig } from '../src/utils/config-templates';
+import { parseAisanityYaml } from '../src/utils/config-validation';
+import { parseCacheVolumes } from '../src/utils/profile-utils';
+import { getDevContainerTemplate } from '../src/utils/devcontainer-templates';
+import { initCommand } from '../src/commands/init';
+import { configureDisplay, getDisplaySettings } from '../src/utils/display';
+
+describe('Config templates', () => {
+  it('should provide go, node, python and rust templates', () => {
+    expect(getConfigTemplateNames()).toEqual(['go', 'node', 'python', 'rust']);
+  });
+
+  it('should reject unknown templates with the available names', () => {
+    expect(() => getConfigTemplate('cobol')).toThrow("Unknown template 'cobol'. Available templates: go, node, python, rust");
+  });
+
+  for (const name of getConfigTemplateNames()) {
+    it(`should render a valid config for ${name}`, () => {
+      const config = parseAisanityYaml(createTemplateConfig('app', name), '.aisanity') as any;
+      const profile = config.profiles.default;
+
+      expect(config.workspace).toBe('app');
+      expect(profile.image).toBe(getConfigTemplate(name).profile.image);
+      expect(parseCacheVolumes(profile.cacheVolumes, 'default').length).toBeGreaterThan(0);
+
+      // The profile image should agree with the devcontainer written next to it
+      const devcontainer = JSON.parse(getDevContainerTemplate(getConfigTemplate(name).projectType)!.devcontainerJson);
+      expect(devcontainer.image).toBe(profile.image);
+    });
+  }
+
+  it('should expose --template, --list and --force on init', () => {
+    expect(initCommand.options.map(option => option.long)).toEqual(['--template', '--list', '--force']);
+  });
+
+  it('should print nothing from init with --quiet', async () => {
+    const tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-init-quiet-'));
+    const previousCwd = process.cwd();
+    const previousDisplay = getDisplaySettings();
+    const originalLog = console.log;
+    const logOutput: string[] = [];
+    try {
+      process.chdir(tempDir);
+      console.log = (...args: any[]) => {
+        logOutput.push(args.join(' '));
+      };
+      // addDisplayOptions applies --quiet this way before the action runs
+      configureDisplay({ ...previousDisplay, quiet: true });
+
+      await initCommand.parseAsync(['--template', 'node'], { from: 'user' });
+
+      expect(fs.existsSync(path.join(tempDir, '.aisanity'))).toBe(true);
+      expect(logOutput).toEqual([]);
+    } finally {
+      console.log = originalLog;
+      configureDisplay(previousDisplay);
+      process.chdir(previousCwd);
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    }
+  });
+});
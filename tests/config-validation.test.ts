@@ -0,0 +1,143 @@
+import { describe, it, expect, beforeEach, afterEach, spyOn } from 'bun:test';
+import * as fs from 'fs';
+import * as path from 'path';
+import * as os from 'os';
+import { loadAisanityConfig } from '../src/utils/config';
+import { parseAisanityYaml, validateAisanityConfig, collectAisanityYamlErrors, ConfigValidationError } from '../src/utils/config-validation';
+import { execCommand } from '../src/commands/exec';
+
+describe('Config validation', () => {
+  describe('parseAisanityYaml', () => {
+    it('should accept known fields', () => {
+      const config = parseAisanityYaml('workspace: app\nenv:\n  A: "1"\nprofiles:\n  default:\n    mounts:\n      - ./a:/a\n', '.aisanity');
+      expect(config).toEqual({ workspace: 'app', env: { A: '1' }, profiles: { default: { mounts: ['./a:/a'] } } });
+    });
+
+    it('should report unknown top-level fields with their line number', () => {
+      expect(() => parseAisanityYaml('workspace: app\ncontainername: box\n', '/repo/.aisanity')).toThrow(
+        'Invalid .aisanity config /repo/.aisanity:2: unknown field "containername" (did you mean "containerName"?)'
+      );
+    });
+
+    it('should report unknown profile fields with the profile name', () => {
+      const content = 'workspace: app\nprofiles:\n  test:\n    image: node:22\n    mount:\n      - ./a:/a\n';
+      expect(() => parseAisanityYaml(content, '.aisanity')).toThrow(
+        `.aisanity:5: unknown field "mount" in profile 'test' (did you mean "mounts"?)`
+      );
+    });
+
+    it('should report type mismatches', () => {
+      expect(() => parseAisanityYaml('workspace: app\nworktree: maybe\n', '.aisanity')).toThrow(
+        '.aisanity:2: field "worktree" must be true or false'
+      );
+    });
+
+    it('should wrap YAML syntax errors with the config path', () => {
+      expect(() => parseAisanityYaml('workspace: [app\n', '/repo/.aisanity')).toThrow('Invalid .aisanity config /repo/.aisanity');
+    });
+  });
+
+  describe('collectAisanityYamlErrors', () => {
+    it('should report every problem instead of the first one', () => {
+      const content = 'workspace: app\nworktree: maybe\nprofiles:\n  test:\n    mount: []\n    ports: 3000\n';
+      const { errors } = collectAisanityYamlErrors(content, '.aisanity');
+      expect(errors.map(error => [error.line, error.detail])).toEqual([
+        [2, 'field "worktree" must be true or false'],
+        [5, `unknown field "mount" in profile 'test' (did you mean "mounts"?)`],
+        [6, 'field "profiles.test.ports" must be a list']
+      ]);
+    });
+
+    it('should return the parsed value of a valid file', () => {
+      expect(collectAisanityYamlErrors('workspace: app\n', '.aisanity')).toEqual({ value: { workspace: 'app' }, errors: [] });
+    });
+  });
+
+  describe('validateAisanityConfig', () => {
+    it('should reject unknown fields in JSON configs without a line number', () => {
+      expect(() => validateAisanityConfig({ workspace: 'app', mountz: [] }, 'config.json')).toThrow(
+        'Invalid .aisanity config config.json: unknown field "mountz"'
+      );
+    });
+
+    it('should validate mount entries given as maps', () => {
+      const config = { workspace: 'app', base: { mounts: ['./a:/a', { source: './b', target: '/b', readOnly: true }] } };
+      expect(() => validateAisanityConfig(config, 'config.json')).toThrow(
+        'unknown field "readOnly" in "base.mounts.1" (did you mean "readonly"?)'
+      );
+      expect(() => validateAisanityConfig({ workspace: 'app', base: { mounts: [42] } }, 'config.json')).toThrow(
+        'field "base.mounts.0" must be a string or a map'
+      );
+    });
+
+    it('should validate healthcheck blocks', () => {
+      const healthcheck = { command: ['curl', '-f', 'http://localhost:3000'], interval: '5s', retries: 3 };
+      expect(() => validateAisanityConfig({ workspace: 'app', profiles: { web: { healthcheck } } }, 'config.json')).not.toThrow();
+      expect(() => validateAisanityConfig({ workspace: 'app', profiles: { web: { healthcheck: { command: 'true', retry: 3 } } } }, 'config.json')).toThrow(
+        'unknown field "retry" in "profiles.web.healthcheck"'
+      );
+      expect(() => validateAisanityConfig({ workspace: 'app', profiles: { web: { healthcheck: { command: 'true', retries: '3' } } } }, 'config.json')).toThrow(
+        'field "profiles.web.healthcheck.retries" must be a number'
+      );
+    });
+
+    it('should validate env files and secrets', () => {
+      const profile = { envFile: ['.env', '.env.local'], secrets: [{ source: 'secrets/token', target: '/run/secrets/token' }] };
+      expect(() => validateAisanityConfig({ workspace: 'app', profiles: { web: profile } }, 'config.json')).not.toThrow();
+      expect(() => validateAisanityConfig({ workspace: 'app', base: { secrets: ['secrets/token'] } }, 'config.json')).toThrow(
+        'field "base.secrets.0" must be a map with a source'
+      );
+      expect(() => validateAisanityConfig({ workspace: 'app', base: { secrets: [{ source: 'token', targt: '/token' }] } }, 'config.json')).toThrow(
+        'unknown field "targt" in "base.secrets.0" (did you mean "target"?)'
+      );
+    });
+
+    it('should throw ConfigValidationError', () => {
+      expect(() => validateAisanityConfig({ bogus: true }, 'config.json')).toThrow(ConfigValidationError);
+    });
+  });
+
+  describe('commands', () => {
+    let tempDir: string;
+    let originalCwd: string;
+
+    beforeEach(() => {
+      originalCwd = process.cwd();
+      tempDir = path.join(os.tmpdir(), `config-validation-test-${Date.now()}-${Math.random()}`);
+      fs.mkdirSync(tempDir, { recursive: true });
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: app\nmounts:\n  - ./a:/a\n', 'utf8');
+    });
+
+    afterEach(() => {
+      process.chdir(originalCwd);
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    it('should fail loading a config with an unknown top-level key', () => {
+      expect(() => loadAisanityConfig(tempDir)).toThrow('unknown field "mounts"');
+    });
+
+    it('should exit non-zero and name the unknown key', async () => {
+      const mockExit = spyOn(process, 'exit').mockImplementation((code) => {
+        throw new Error(`process.exit called with code ${code}`);
+      });
+      const mockError = spyOn(console, 'error').mockImplementation(() => {});
+
+      process.chdir(tempDir);
+      let exitError: Error | undefined;
+      try {
+        await execCommand.parseAsync(['node', 'exec', 'true']);
+      } catch (error) {
+        exitError = error as Error;
+      }
+
+      const output = mockError.mock.calls.map(call => call.map(String).join(' ')).join('\n');
+      mockExit.mockRestore();
+      mockError.mockRestore();
+
+      expect(exitError?.message).toBe('process.exit called with code 1');
+      expect(output).toContain('unknown field "mounts"');
+      expect(output).toContain(':2:');
+    });
+  });
+});
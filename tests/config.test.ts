@@ -6,7 +6,19 @@ import {
   sanitizeBranchName,
   createAisanityConfig,
   generateExpectedContainerName,
-  detectProjectType
+  detectProjectType,
+  getContainerName,
+  getLegacyContainerName,
+  loadAisanityConfig,
+  findWorkspaceRoot,
+  getWorkspaceRoot,
+  resolveWorkspaceOption,
+  getAisanityConfigPath,
+  getUserConfigPath,
+  mergeUserConfig,
+  resolveConfigExtends,
+  resolveConfigOption,
+  setConfigOverride
 } from '../src/utils/config';
 
 describe('Config Utils', () => {
@@ -35,6 +47,352 @@ describe('Config Utils', () => {
     });
   });
 
+  describe('getContainerName', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = path.join(os.tmpdir(), `config-name-test-${Date.now()}-${Math.random()}`);
+      fs.mkdirSync(tempDir, { recursive: true });
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    const createWorkspace = (parent: string): string => {
+      const workspacePath = path.join(tempDir, parent, 'backend');
+      fs.mkdirSync(workspacePath, { recursive: true });
+      fs.writeFileSync(path.join(workspacePath, '.aisanity'), createAisanityConfig('backend'), 'utf8');
+      return workspacePath;
+    };
+
+    test('gives workspaces with identical basenames distinct names', () => {
+      const first = getContainerName(createWorkspace('repo-a'));
+      const second = getContainerName(createWorkspace('repo-b'));
+
+      expect(first).not.toBe(second);
+      expect(first).toMatch(/^backend-main-[0-9a-f]{8}$/);
+      expect(second).toMatch(/^backend-main-[0-9a-f]{8}$/);
+    });
+
+    test('is stable for the same workspace path', () => {
+      const workspacePath = createWorkspace('repo-a');
+      expect(getContainerName(workspacePath)).toBe(getContainerName(workspacePath));
+    });
+
+    test('keeps the legacy name as the readable prefix', () => {
+      const workspacePath = createWorkspace('repo-a');
+      expect(getLegacyContainerName(workspacePath)).toBe('backend-main');
+      expect(getContainerName(workspacePath).startsWith('backend-main-')).toBe(true);
+    });
+
+    test('uses an explicit containerName unchanged', () => {
+      const workspacePath = createWorkspace('repo-a');
+      fs.writeFileSync(path.join(workspacePath, '.aisanity'), 'workspace: backend\ncontainerName: my-box\n', 'utf8');
+      expect(getContainerName(workspacePath)).toBe('my-box');
+    });
+  });
+
+  describe('loadAisanityConfig', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = path.join(os.tmpdir(), `config-load-test-${Date.now()}-${Math.random()}`);
+      fs.mkdirSync(tempDir, { recursive: true });
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    test('loads profile ports', () => {
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: web\nbase:\n  ports:\n    - "8080:8080"\n    - 3000\n', 'utf8');
+      const config = loadAisanityConfig(tempDir);
+      expect(config?.base?.ports).toEqual(['8080:8080', 3000]);
+    });
+
+    test('interpolates profile env from the host at load time', () => {
+      process.env.AISANITY_TEST_HOST_VAR = 'from-host';
+      try {
+        fs.writeFileSync(
+          path.join(tempDir, '.aisanity'),
+          'workspace: web\nprofiles:\n  default:\n    env:\n      FROM_HOST: ${AISANITY_TEST_HOST_VAR}\n      WITH_DEFAULT: ${AISANITY_TEST_UNSET:-fallback}\n',
+          'utf8'
+        );
+        const config = loadAisanityConfig(tempDir);
+        expect(config?.profiles?.default.env).toEqual({ FROM_HOST: 'from-host', WITH_DEFAULT: 'fallback' });
+      } finally {
+        delete process.env.AISANITY_TEST_HOST_VAR;
+      }
+    });
+
+    test('rejects unset host variables without a default', () => {
+      fs.writeFileSync(
+        path.join(tempDir, '.aisanity'),
+        'workspace: web\nprofiles:\n  default:\n    env:\n      TOKEN: ${AISANITY_TEST_UNSET}\n',
+        'utf8'
+      );
+      expect(() => loadAisanityConfig(tempDir)).toThrow("Profile 'default' env TOKEN: Host variable AISANITY_TEST_UNSET is not set");
+    });
+
+    test('loads env profiles in the same forms as profile env', () => {
+      process.env.AISANITY_TEST_HOST_VAR = 'ci-token';
+      try {
+        fs.writeFileSync(
+          path.join(tempDir, '.aisanity'),
+          'workspace: web\nenvProfiles:\n  ci:\n    CI: "true"\n    TOKEN: ${AISANITY_TEST_HOST_VAR}\n  forward:\n    - AISANITY_TEST_HOST_VAR\n',
+          'utf8'
+        );
+        const config = loadAisanityConfig(tempDir);
+        expect(config?.envProfiles).toEqual({ ci: { CI: 'true', TOKEN: 'ci-token' }, forward: { AISANITY_TEST_HOST_VAR: 'ci-token' } });
+      } finally {
+        delete process.env.AISANITY_TEST_HOST_VAR;
+      }
+    });
+
+    test('rejects invalid resource limits', () => {
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: web\nprofiles:\n  big:\n    resources:\n      memory: 2gigs\n', 'utf8');
+      expect(() => loadAisanityConfig(tempDir)).toThrow(`Invalid .aisanity config ${path.join(tempDir, '.aisanity')}: Profile 'big': resources Invalid memory "2gigs"`);
+    });
+
+    test('rejects duplicate host ports', () => {
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: web\nbase:\n  ports:\n    - "8080:8080"\n    - "8080:80"\n', 'utf8');
+      expect(() => loadAisanityConfig(tempDir)).toThrow('host port 8080/tcp is published twice');
+    });
+  });
+
+  describe('mergeUserConfig', () => {
+    const user = {
+      env: { EDITOR: 'vim', MODE: 'user' },
+      envWhitelist: ['EDITOR'],
+      base: {
+        mounts: ['/home/me/.gitconfig:/home/vscode/.gitconfig:ro'],
+        cacheVolumes: { shared: '/cache' },
+        env: ['SSH_AUTH_SOCK', 'LANG=C.UTF-8'] as any
+      }
+    };
+
+    test('project env overrides user env key by key', () => {
+      const merged = mergeUserConfig(user, { workspace: 'web', env: { MODE: 'project' } });
+      expect(merged.env).toEqual({ EDITOR: 'vim', MODE: 'project' });
+    });
+
+    test('project mounts are appended after user mounts', () => {
+      const merged = mergeUserConfig(user, { workspace: 'web', base: { mounts: ['./fixtures:/fixtures'] } });
+      expect(merged.base?.mounts).toEqual(['/home/me/.gitconfig:/home/vscode/.gitconfig:ro', './fixtures:/fixtures']);
+      expect(merged.base?.cacheVolumes).toEqual({ shared: '/cache' });
+    });
+
+    test('merges list and map env forms', () => {
+      const merged = mergeUserConfig(user, { workspace: 'web', base: { env: { LANG: 'en_US.UTF-8' } } });
+      expect(merged.base?.env).toEqual({ SSH_AUTH_SOCK: null, LANG: 'en_US.UTF-8' });
+    });
+
+    test('appends lists without duplicating entries', () => {
+      const merged = mergeUserConfig(user, { workspace: 'web', envWhitelist: ['EDITOR', 'PAGER'] });
+      expect(merged.envWhitelist).toEqual(['EDITOR', 'PAGER']);
+    });
+
+    test('clear: true keeps the user config out of a block', () => {
+      const merged = mergeUserConfig(user, { workspace: 'web', base: { clear: true, mounts: ['./a:/a'] } });
+      expect(merged.base).toEqual({ clear: true, mounts: ['./a:/a'] });
+      expect(merged.env).toEqual(user.env);
+
+      expect(mergeUserConfig(user, { workspace: 'web', clear: true })).toEqual({ workspace: 'web', clear: true });
+    });
+
+    test('replaces the command instead of appending to it', () => {
+      const merged = mergeUserConfig(
+        { profiles: { default: { command: ['bash'] } } },
+        { workspace: 'web', profiles: { default: { command: ['npm', 'test'] } } }
+      );
+      expect(merged.profiles?.default.command).toEqual(['npm', 'test']);
+    });
+
+    test('appends runArgs without dropping repeated flags', () => {
+      const merged = mergeUserConfig(
+        { base: { runArgs: ['--cap-add', 'SYS_PTRACE'] } },
+        { workspace: 'web', base: { runArgs: ['--cap-add', 'NET_ADMIN'] } }
+      );
+      expect(merged.base?.runArgs).toEqual(['--cap-add', 'SYS_PTRACE', '--cap-add', 'NET_ADMIN']);
+    });
+
+    test('is loaded from XDG_CONFIG_HOME under the project config', () => {
+      const tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'config-user-test-'));
+      const previous = process.env.XDG_CONFIG_HOME;
+      try {
+        process.env.XDG_CONFIG_HOME = path.join(tempDir, 'config');
+        fs.mkdirSync(path.join(tempDir, 'config', 'aisanity'), { recursive: true });
+        fs.writeFileSync(getUserConfigPath(), 'env:\n  EDITOR: vim\n', 'utf8');
+        fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: web\nenv:\n  NODE_ENV: test\n', 'utf8');
+
+        expect(loadAisanityConfig(tempDir)?.env).toEqual({ EDITOR: 'vim', NODE_ENV: 'test' });
+      } finally {
+        if (previous === undefined) {
+          delete process.env.XDG_CONFIG_HOME;
+        } else {
+          process.env.XDG_CONFIG_HOME = previous;
+        }
+        fs.rmSync(tempDir, { recursive: true, force: true });
+      }
+    });
+  });
+
+  describe('resolveConfigExtends', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'config-extends-test-'));
+      fs.mkdirSync(path.join(tempDir, 'services', 'api'), { recursive: true });
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    test('merges a chain of parents under the config, relative to each file', () => {
+      fs.writeFileSync(path.join(tempDir, 'root.aisanity.yaml'), 'env:\n  LEVEL: root\n  ROOT: "1"\nbase:\n  mounts:\n    - ./shared:/shared\n', 'utf8');
+      fs.writeFileSync(path.join(tempDir, 'services', 'common.aisanity.yaml'), 'extends: ../root.aisanity.yaml\nenv:\n  LEVEL: common\nbase:\n  image: node:22\n', 'utf8');
+      fs.writeFileSync(path.join(tempDir, 'services', 'api', '.aisanity'), 'extends: ../common.aisanity.yaml\nworkspace: api\nenv:\n  LEVEL: api\n', 'utf8');
+
+      const config = loadAisanityConfig(path.join(tempDir, 'services', 'api'));
+      expect(config?.workspace).toBe('api');
+      expect(config?.env).toEqual({ LEVEL: 'api', ROOT: '1' });
+      expect(config?.base).toEqual({ mounts: ['./shared:/shared'], image: 'node:22' });
+      expect(config?.extends).toBeUndefined();
+    });
+
+    test('keeps repeated runArgs flags from parents and the user config', () => {
+      const previous = process.env.XDG_CONFIG_HOME;
+      try {
+        process.env.XDG_CONFIG_HOME = path.join(tempDir, 'config');
+        fs.mkdirSync(path.join(tempDir, 'config', 'aisanity'), { recursive: true });
+        fs.writeFileSync(getUserConfigPath(), 'base:\n  runArgs: [--cap-add, SYS_PTRACE]\n', 'utf8');
+        fs.writeFileSync(path.join(tempDir, 'common.yaml'), 'base:\n  runArgs: [--cap-add, NET_ADMIN]\n', 'utf8');
+        fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: web\nextends: common.yaml\nbase:\n  runArgs: [--cap-add, SYS_ADMIN]\n', 'utf8');
+
+        expect(loadAisanityConfig(tempDir)?.base?.runArgs).toEqual([
+          '--cap-add', 'SYS_PTRACE', '--cap-add', 'NET_ADMIN', '--cap-add', 'SYS_ADMIN'
+        ]);
+      } finally {
+        if (previous === undefined) {
+          delete process.env.XDG_CONFIG_HOME;
+        } else {
+          process.env.XDG_CONFIG_HOME = previous;
+        }
+      }
+    });
+
+    test('rejects circular extends', () => {
+      const first = path.join(tempDir, 'a.yaml');
+      const second = path.join(tempDir, 'b.yaml');
+      fs.writeFileSync(first, 'extends: b.yaml\n', 'utf8');
+      fs.writeFileSync(second, 'extends: ./a.yaml\n', 'utf8');
+
+      expect(() => resolveConfigExtends({ workspace: 'web', extends: 'a.yaml' }, path.join(tempDir, '.aisanity')))
+        .toThrow(`circular extends: ${path.join(tempDir, '.aisanity')} -> ${first} -> ${second} -> ${first}`);
+    });
+
+    test('reports a missing parent', () => {
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: web\nextends: missing.yaml\n', 'utf8');
+      expect(() => loadAisanityConfig(tempDir)).toThrow(`extends missing.yaml, but ${path.join(tempDir, 'missing.yaml')} does not exist`);
+    });
+
+    test('validates parents like the config itself', () => {
+      fs.writeFileSync(path.join(tempDir, 'common.yaml'), 'envWhitelist: [A]\nstopTimout: 5\n', 'utf8');
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: web\nextends: common.yaml\n', 'utf8');
+      expect(() => loadAisanityConfig(tempDir)).toThrow(`${path.join(tempDir, 'common.yaml')}:2`);
+    });
+  });
+
+  describe('--config', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'config-override-test-'));
+    });
+
+    afterEach(() => {
+      setConfigOverride(null);
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    test('reads config from stdin only with an explicit workspace', () => {
+      expect(() => resolveConfigOption('-', undefined, () => 'workspace: web\n')).toThrow('must be given with --workspace');
+
+      setConfigOverride(resolveConfigOption('-', tempDir, () => 'workspace: generated\n'));
+      expect(loadAisanityConfig(tempDir)?.workspace).toBe('generated');
+      expect(getAisanityConfigPath(tempDir)).toBeNull();
+    });
+
+    test('uses a config file anywhere instead of searching for .aisanity', () => {
+      const nested = path.join(tempDir, 'a', 'b');
+      fs.mkdirSync(nested, { recursive: true });
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: found\n', 'utf8');
+      const configFile = path.join(tempDir, 'ci.yaml');
+      fs.writeFileSync(configFile, 'workspace: explicit\n', 'utf8');
+
+      setConfigOverride(resolveConfigOption(configFile, undefined));
+      expect(getWorkspaceRoot(nested)).toBe(nested);
+      expect(loadAisanityConfig(nested)?.workspace).toBe('explicit');
+      expect(getAisanityConfigPath(nested)).toBe(configFile);
+    });
+
+    test('rejects a missing config file', () => {
+      expect(() => resolveConfigOption(path.join(tempDir, 'missing.yaml'), undefined)).toThrow('Config file does not exist');
+    });
+  });
+
+  describe('findWorkspaceRoot', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'config-root-test-'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    test('finds the nearest config in a parent directory', () => {
+      const nested = path.join(tempDir, 'project', 'src', 'pkg');
+      fs.mkdirSync(nested, { recursive: true });
+      fs.writeFileSync(path.join(tempDir, 'project', '.aisanity'), 'workspace: web\n', 'utf8');
+
+      expect(findWorkspaceRoot(nested)).toBe(path.join(tempDir, 'project'));
+      expect(getAisanityConfigPath(path.join(tempDir, 'project'))).toBe(path.join(tempDir, 'project', '.aisanity'));
+    });
+
+    test('stops at the git repository root', () => {
+      const repo = path.join(tempDir, 'repo');
+      fs.mkdirSync(path.join(repo, '.git', 'objects'), { recursive: true });
+      fs.mkdirSync(path.join(repo, 'src'), { recursive: true });
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: outer\n', 'utf8');
+
+      expect(findWorkspaceRoot(path.join(repo, 'src'))).toBeNull();
+      expect(getWorkspaceRoot(path.join(repo, 'src'))).toBe(path.join(repo, 'src'));
+    });
+
+    test('takes --workspace as given, relative to the current directory', () => {
+      const previousCwd = process.cwd();
+      try {
+        fs.mkdirSync(path.join(tempDir, 'project'));
+        process.chdir(tempDir);
+        expect(resolveWorkspaceOption({ workspace: 'project' })).toBe(path.join(fs.realpathSync(tempDir), 'project'));
+      } finally {
+        process.chdir(previousCwd);
+      }
+    });
+
+    test('resolves the config.json of the directory format', () => {
+      fs.mkdirSync(path.join(tempDir, '.aisanity'));
+      expect(getAisanityConfigPath(tempDir)).toBeNull();
+
+      fs.writeFileSync(path.join(tempDir, '.aisanity', 'config.json'), '{"workspace":"web"}', 'utf8');
+      expect(getAisanityConfigPath(tempDir)).toBe(path.join(tempDir, '.aisanity', 'config.json'));
+    });
+  });
+
   describe('detectProjectType', () => {
     let tempDir: string;
 
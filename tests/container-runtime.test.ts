@@ -0,0 +1,540 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  ContainerRuntime,
+  selectContainerRuntime,
+  fromApiContainer,
+  formatApiPorts,
+  parsePodmanPsOutput,
+  translatePodmanRunArgs,
+  getDevcontainerRuntimeArgs,
+  DockerCliRuntime,
+  PodmanCliRuntime,
+  getDockerHost,
+  getRuntimeSocketPath,
+  setContainerRuntime,
+  fromApiHealth,
+  fromApiState,
+  ContainerHealth
+} from '../src/utils/container-runtime';
+import {
+  DockerContainer,
+  ContainerAlreadyStoppedError,
+  findRunningContainer,
+  getContainerStatus,
+  stopContainer,
+  ensureCacheVolumes,
+  getCacheVolumeName,
+  pullImage,
+  waitForHealthy,
+  waitForAttachedProcess,
+  removeSandbox,
+  detectContainerDrift,
+  formatConfigHash,
+  diffConfigHash,
+  describeExit,
+  parsePullPolicy,
+  describePullFailure
+} from '../src/utils/container-utils';
+
+describe('Container runtime', () => {
+  describe('selectContainerRuntime', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-runtime-'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    it('should honour AISANITY_RUNTIME', () => {
+      expect(selectContainerRuntime({ AISANITY_RUNTIME: 'sdk', PATH: '' })).toBe('sdk');
+      expect(selectContainerRuntime({ AISANITY_RUNTIME: 'cli', PATH: '' })).toBe('cli');
+      expect(selectContainerRuntime({ AISANITY_RUNTIME: 'podman', PATH: '' })).toBe('podman');
+      expect(() => selectContainerRuntime({ AISANITY_RUNTIME: 'lxc' })).toThrow('Invalid AISANITY_RUNTIME "lxc"');
+    });
+
+    it('should prefer the docker CLI when it is on PATH', () => {
+      fs.writeFileSync(path.join(tempDir, 'docker'), '');
+      expect(selectContainerRuntime({ PATH: tempDir, DOCKER_HOST: 'tcp://localhost:2375' })).toBe('cli');
+    });
+
+    it('should fall back to podman when docker is absent', () => {
+      fs.writeFileSync(path.join(tempDir, 'podman'), '');
+      expect(selectContainerRuntime({ PATH: tempDir, DOCKER_HOST: 'tcp://localhost:2375' })).toBe('podman');
+    });
+
+    it('should use the API when only the daemon socket is available', () => {
+      const socketPath = path.join(tempDir, 'docker.sock');
+      fs.writeFileSync(socketPath, '');
+      expect(selectContainerRuntime({ PATH: '', DOCKER_HOST: `unix://${socketPath}` })).toBe('sdk');
+      expect(selectContainerRuntime({ PATH: '', DOCKER_HOST: `unix://${path.join(tempDir, 'missing.sock')}` })).toBe('cli');
+    });
+
+    it('should default DOCKER_HOST to the local socket', () => {
+      expect(getDockerHost({})).toBe('unix:///var/run/docker.sock');
+    });
+
+    it('should find the daemon socket to share with a sandbox', () => {
+      expect(getRuntimeSocketPath('cli', {})).toBe('/var/run/docker.sock');
+      expect(getRuntimeSocketPath('sdk', { DOCKER_HOST: 'unix:///run/user/1000/docker.sock' })).toBe('/run/user/1000/docker.sock');
+      expect(getRuntimeSocketPath('podman', { XDG_RUNTIME_DIR: '/run/user/1000' }, 1000)).toBe('/run/user/1000/podman/podman.sock');
+      expect(getRuntimeSocketPath('podman', {}, 0)).toBe('/run/podman/podman.sock');
+      expect(() => getRuntimeSocketPath('cli', { DOCKER_HOST: 'tcp://10.0.0.2:2375' })).toThrow('dockerAccess needs a unix socket');
+    });
+  });
+
+  describe('fromApiContainer', () => {
+    it('should match the docker ps representation', () => {
+      const container = fromApiContainer({
+        Id: '0123456789abcdef0123456789abcdef',
+        Names: ['/my-app-main-1a2b3c4d'],
+        Image: 'node:22',
+        Status: 'Up 2 hours',
+        Ports: [
+          { IP: '0.0.0.0', PrivatePort: 8080, PublicPort: 8080, Type: 'tcp' },
+          { PrivatePort: 9000, Type: 'tcp' }
+        ],
+        Labels: { 'aisanity.workspace': '/work/app' }
+      });
+
+      expect(container).toEqual({
+        id: '0123456789ab',
+        name: 'my-app-main-1a2b3c4d',
+        image: 'node:22',
+        status: 'Up 2 hours',
+        labels: { 'aisanity.workspace': '/work/app' },
+        ports: '0.0.0.0:8080->8080/tcp, 9000/tcp'
+      });
+    });
+
+    it('should convert inspect health state', () => {
+      expect(fromApiHealth(null)).toBeNull();
+      expect(fromApiHealth({ Status: 'unhealthy', Log: [{ ExitCode: 1, Output: 'down\n' }] })).toEqual({
+        status: 'unhealthy',
+        log: [{ exitCode: 1, output: 'down\n' }]
+      });
+    });
+
+    it('should convert inspect container state', () => {
+      expect(fromApiState({ Running: false, ExitCode: 137, OOMKilled: true, Error: '' })).toEqual({
+        running: false,
+        exitCode: 137,
+        oomKilled: true,
+        error: ''
+      });
+      expect(fromApiState(undefined)).toEqual({ running: false, exitCode: 0, oomKilled: false, error: '' });
+    });
+
+    it('should bracket IPv6 host addresses', () => {
+      expect(formatApiPorts([{ IP: '::', PrivatePort: 53, PublicPort: 5353, Type: 'udp' }])).toBe('[::]:5353->53/udp');
+    });
+  });
+
+  describe('podman', () => {
+    it('should parse ps output with JSON labels', () => {
+      const output = [
+        'abc123\tapp-main-1a2b3c4d\tdocker.io/library/node:22\tUp 2 hours\t0.0.0.0:8080->8080/tcp\t{"aisanity.workspace":"/work/app","aisanity.branch":"main"}',
+        'def456\tother\tubuntu\tExited (0) 1 hour ago\t\tnull',
+        ''
+      ].join('\n');
+
+      expect(parsePodmanPsOutput(output)).toEqual([
+        {
+          id: 'abc123',
+          name: 'app-main-1a2b3c4d',
+          image: 'docker.io/library/node:22',
+          status: 'Up 2 hours',
+          labels: { 'aisanity.workspace': '/work/app', 'aisanity.branch': 'main' },
+          ports: '0.0.0.0:8080->8080/tcp'
+        },
+        { id: 'def456', name: 'other', image: 'ubuntu', status: 'Exited (0) 1 hour ago', labels: {}, ports: '' }
+      ]);
+    });
+
+    it('should translate docker run flags', () => {
+      expect(translatePodmanRunArgs(['--gpus', 'all', '-p', '8080:8080'])).toEqual([
+        '--device', 'nvidia.com/gpu=all', '-p', '8080:8080', '--userns=keep-id'
+      ]);
+      expect(translatePodmanRunArgs(['--userns=auto'])).toEqual(['--userns=auto']);
+    });
+
+    it('should point the devcontainer CLI at podman', () => {
+      expect(getDevcontainerRuntimeArgs(new PodmanCliRuntime())).toEqual(['--docker-path', 'podman']);
+      expect(getDevcontainerRuntimeArgs(new DockerCliRuntime())).toEqual([]);
+    });
+  });
+
+  describe('container-utils with a runtime', () => {
+    const running: DockerContainer = {
+      id: 'abc123',
+      name: 'app-main-1a2b3c4d',
+      image: 'node:22',
+      status: 'Up 5 minutes',
+      labels: { 'aisanity.workspace': '/work/app', 'aisanity.branch': 'main', 'aisanity.profile': 'test' },
+      ports: '0.0.0.0:3000->3000/tcp'
+    };
+
+    const fakeRuntime = (
+      containers: DockerContainer[],
+      volumes: string[] = [],
+      health: (ContainerHealth | null)[] = []
+    ): ContainerRuntime & {
+      stopped: string[];
+      removed: string[];
+      created: Record<string, Record<string, string>>;
+      restartPolicies: Record<string, string>;
+    } => ({
+      name: 'sdk',
+      command: 'docker',
+      stopped: [],
+      removed: [],
+      created: {},
+      restartPolicies: {},
+      async getServerVersion() {
+        return '27.0.1';
+      },
+      async listContainers(options) {
+        return containers.filter(container => !options.ids || options.ids.includes(container.id));
+      },
+      async isContainerRunning(containerId) {
+        return containers.some(container => container.id === containerId && container.status.startsWith('Up'));
+      },
+      async stopContainer(containerId) {
+        this.stopped.push(containerId);
+      },
+      async setRestartPolicy(containerId, policy) {
+        this.restartPolicies[containerId] = policy;
+      },
+      async removeContainer(containerId) {
+        this.removed.push(containerId);
+      },
+      async getPortBindings() {
+        return [];
+      },
+      async getContainerLabels(containerId) {
+        return containers.find(container => container.id === containerId)?.labels || {};
+      },
+      async getContainerHealth() {
+        return health.length > 1 ? health.shift()! : health[0] ?? null;
+      },
+      async getContainerState() {
+        return { running: true, exitCode: 0, oomKilled: false, error: '' };
+      },
+      async streamLogs() {
+        return 0;
+      },
+      async listVolumes(options) {
+        return volumes
+          .filter(name => !options.names || options.names.includes(name))
+          .map(name => ({ name, labels: {} }));
+      },
+      async createVolume(name, labels) {
+        this.created[name] = labels;
+      },
+      async removeVolume() {},
+      async pullImage() {},
+      async getImageDigests(image) {
+        return image === 'node:22' ? ['node@sha256:' + 'a'.repeat(64)] : [];
+      },
+      async hasImage() {
+        return false;
+      },
+      async getImagePlatform() {
+        return null;
+      },
+      async buildImage() {}
+    });
+
+    afterEach(() => {
+      setContainerRuntime(null);
+    });
+
+    it('should derive container status from the runtime listing', async () => {
+      setContainerRuntime(fakeRuntime([running]));
+      expect(await getContainerStatus('abc123')).toEqual({ status: 'Running', ports: ['0.0.0.0:3000->3000/tcp'] });
+    });
+
+    it('should find running containers by profile', async () => {
+      setContainerRuntime(fakeRuntime([running]));
+      expect(await findRunningContainer('/work/app', 'main', 'test')).toBe('abc123');
+      expect(await findRunningContainer('/work/app', 'main', 'default')).toBeNull();
+    });
+
+    it('should stop running containers and reject stopped ones', async () => {
+      const runtime = fakeRuntime([running, { ...running, id: 'def456', status: 'Exited (0) 1 hour ago' }]);
+      setContainerRuntime(runtime);
+
+      await stopContainer('abc123', 5);
+      expect(runtime.stopped).toEqual(['abc123']);
+      await expect(stopContainer('def456', 5)).rejects.toBeInstanceOf(ContainerAlreadyStoppedError);
+    });
+
+    it('should clear a restart policy that would start the container again', async () => {
+      const runtime = fakeRuntime([
+        { ...running, labels: { ...running.labels, 'aisanity.restart': 'unless-stopped' } },
+        { ...running, id: 'def456', labels: { ...running.labels, 'aisanity.restart': 'on-failure:3' } }
+      ]);
+      setContainerRuntime(runtime);
+
+      await stopContainer('abc123', 5);
+      await stopContainer('def456', 5);
+      expect(runtime.restartPolicies).toEqual({ abc123: 'no' });
+      expect(runtime.stopped).toEqual(['abc123', 'def456']);
+    });
+
+    it('should forward a cancellation to the attached process and stop the container', async () => {
+      const runtime = fakeRuntime([running]);
+      setContainerRuntime(runtime);
+
+      const killed: string[] = [];
+      let exit: (code: number) => void = () => {};
+      const child = {
+        exited: new Promise<number>(resolve => { exit = resolve; }),
+        kill: (signal?: string) => { killed.push(signal || 'SIGTERM'); exit(130); }
+      };
+      const controller = new AbortController();
+
+      const pending = waitForAttachedProcess(child, async () => 'abc123', controller.signal, { stopTimeout: 1 });
+      controller.abort('SIGINT');
+
+      expect(await pending).toBe(130);
+      expect(killed).toEqual(['SIGINT']);
+      expect(runtime.stopped).toEqual(['abc123']);
+    });
+
+    it('should leave the container running when the attached process exits on its own', async () => {
+      const runtime = fakeRuntime([running]);
+      setContainerRuntime(runtime);
+
+      const child = { exited: Promise.resolve(3), kill: () => {} };
+      expect(await waitForAttachedProcess(child, async () => 'abc123', new AbortController().signal)).toBe(3);
+      expect(runtime.stopped).toEqual([]);
+
+      expect(await waitForAttachedProcess(child, async () => 'abc123', new AbortController().signal, { remove: true })).toBe(3);
+      expect(runtime.stopped).toEqual(['abc123']);
+      expect(runtime.removed).toEqual(['abc123']);
+    });
+
+    it('should report the exit before the container is removed', async () => {
+      const runtime = fakeRuntime([running]);
+      setContainerRuntime(runtime);
+
+      const events: string[] = [];
+      const child = { exited: Promise.resolve(137), kill: () => {} };
+      const exitCode = await waitForAttachedProcess(child, async () => 'abc123', new AbortController().signal, {
+        remove: true,
+        onExit: async code => {
+          events.push(`exit ${code}, removed ${runtime.removed.length}`);
+        }
+      });
+
+      expect(exitCode).toBe(137);
+      expect(events).toEqual(['exit 137, removed 0']);
+      expect(runtime.removed).toEqual(['abc123']);
+    });
+
+    describe('describeExit', () => {
+      const state = { running: true, exitCode: 0, oomKilled: false, error: '' };
+
+      it('should report OOM kills with the memory limit', () => {
+        expect(describeExit(137, { ...state, running: false, exitCode: 137, oomKilled: true }, '2g'))
+          .toBe('Container was OOM-killed; consider raising resources.memory (currently 2g)');
+        expect(describeExit(1, { ...state, oomKilled: true })).toBe('Container was OOM-killed; consider setting resources.memory');
+      });
+
+      it('should name the signal of exit codes above 128', () => {
+        expect(describeExit(137, state)).toContain('killed by SIGKILL (exit code 137)');
+        expect(describeExit(143, state)).toBe('Command was terminated by SIGTERM (exit code 143)');
+      });
+
+      it('should report a container that stopped with the command', () => {
+        expect(describeExit(1, { ...state, running: false, exitCode: 2, error: 'mount failed' }))
+          .toBe('Container stopped with exit code 2: mount failed');
+      });
+
+      it('should add nothing to a plain failure', () => {
+        expect(describeExit(1, state)).toBeNull();
+        expect(describeExit(1, null)).toBeNull();
+      });
+    });
+
+    it('should remove containers whether or not they are running', async () => {
+      const runtime = fakeRuntime([running, { ...running, id: 'def456', status: 'Exited (0) 1 hour ago' }]);
+      setContainerRuntime(runtime);
+
+      await removeSandbox('abc123');
+      await removeSandbox('def456');
+      expect(runtime.stopped).toEqual(['abc123']);
+      expect(runtime.removed).toEqual(['abc123', 'def456']);
+    });
+
+    it('should report image and config drift of reused containers', () => {
+      const container = { ...running, labels: { ...running.labels, 'aisanity.created': '2026-01-01T00:00:00.000Z' } };
+      const mtimes: Record<string, number> = { '/work/app/.aisanity': Date.parse('2026-02-01'), '/work/app/.devcontainer/devcontainer.json': Date.parse('2025-12-01') };
+      const mtimeOf = (file: string) => mtimes[file];
+
+      expect(detectContainerDrift(container, { image: 'node:22', configFiles: ['/work/app/.devcontainer/devcontainer.json'] }, mtimeOf)).toEqual([]);
+      expect(detectContainerDrift(container, { image: 'node:24', configFiles: Object.keys(mtimes) }, mtimeOf)).toEqual([
+        'image is node:22, config wants node:24',
+        '.aisanity changed after the container was created'
+      ]);
+    });
+
+    it('should name the config sections that changed since the container was created', () => {
+      const sections = {
+        devcontainer: { image: 'node:22' },
+        env: { NODE_ENV: 'test', CI: '1' },
+        mounts: ['type=bind,source=/work/cache,target=/cache'],
+        ports: ['3000:3000'],
+        resources: undefined,
+        network: [],
+        user: '1000:1000',
+        healthcheck: undefined,
+        runArgs: undefined
+      };
+      const hash = formatConfigHash(sections);
+      const container = { ...running, labels: { ...running.labels, 'aisanity.created': '2026-01-01T00:00:00.000Z', 'aisanity.config': hash } };
+
+      expect(formatConfigHash({ ...sections, env: { CI: '1', NODE_ENV: 'test' } })).toBe(hash);
+      expect(diffConfigHash(hash, formatConfigHash({ ...sections, env: {}, ports: ['3000:3000', '9229:9229'] }))).toEqual(['env', 'ports']);
+
+      // The hash replaces the file modification check
+      expect(detectContainerDrift(container, { configFiles: ['/work/app/.aisanity'], configHash: hash }, () => Date.parse('2026-02-01'))).toEqual([]);
+      expect(detectContainerDrift(container, { configFiles: [], configHash: formatConfigHash({ ...sections, mounts: [] }) })).toEqual([
+        'mounts changed since the container was created'
+      ]);
+    });
+
+    it('should only hash the platform when one is set', () => {
+      const sections = {
+        devcontainer: {}, env: {}, mounts: [], ports: [], resources: undefined, network: [],
+        user: undefined, healthcheck: undefined, runArgs: undefined, platform: undefined
+      };
+      const hash = formatConfigHash(sections);
+
+      // Containers created before the platform section keep their hash
+      expect(hash).not.toContain('platform=');
+      expect(diffConfigHash(hash, formatConfigHash({ ...sections, platform: 'linux/amd64' }))).toEqual(['platform']);
+    });
+
+    it('should resolve the digest of a pulled image', async () => {
+      setContainerRuntime(fakeRuntime([]));
+      expect(await pullImage('node:22')).toBe('sha256:' + 'a'.repeat(64));
+      expect(await pullImage('local-build')).toBeNull();
+    });
+
+    it('should pull the requested platform', async () => {
+      const pulls: (string | undefined)[] = [];
+      setContainerRuntime({
+        ...fakeRuntime([]),
+        async pullImage(_image, _debug, _signal, platform) {
+          pulls.push(platform);
+        }
+      });
+
+      await pullImage('node:22', false, { platform: 'linux/amd64' });
+      await pullImage('node:22');
+      expect(pulls).toEqual(['linux/amd64', undefined]);
+    });
+
+    it('should parse the pull policy', () => {
+      expect(parsePullPolicy(undefined)).toBe('missing');
+      expect(parsePullPolicy('always')).toBe('always');
+      expect(parsePullPolicy('never')).toBe('never');
+      expect(() => parsePullPolicy('sometimes')).toThrow('Invalid --pull value "sometimes". Expected never, missing, always');
+    });
+
+    it('should suggest aisanity pull when the registry cannot be reached', () => {
+      const offline = new Error('Pulling node:22 failed after 3 attempts: dial tcp: lookup registry-1.docker.io: no such host');
+      expect(describePullFailure('node:22', offline)).toContain('Cannot pull node:22: the registry could not be reached. Run "aisanity pull" while online');
+      expect(describePullFailure('node:22', offline)).toContain('no such host');
+      expect(describePullFailure('node:22', new Error('manifest unknown'))).toBe('manifest unknown');
+    });
+
+    it('should retry failed pulls with backoff', async () => {
+      let calls = 0;
+      const retries: string[] = [];
+      setContainerRuntime({
+        ...fakeRuntime([]),
+        async pullImage() {
+          calls++;
+          if (calls < 3) {
+            throw new Error('net/http: TLS handshake timeout');
+          }
+        }
+      });
+
+      expect(await pullImage('node:22', false, { backoff: 1, onRetry: message => retries.push(message) })).toBe('sha256:' + 'a'.repeat(64));
+      expect(calls).toBe(3);
+      expect(retries).toEqual([
+        'Pulling node:22 failed (attempt 1/3), retrying in 0.001s: net/http: TLS handshake timeout',
+        'Pulling node:22 failed (attempt 2/3), retrying in 0.002s: net/http: TLS handshake timeout'
+      ]);
+    });
+
+    it('should surface the last pull error once all attempts failed', async () => {
+      let calls = 0;
+      setContainerRuntime({
+        ...fakeRuntime([]),
+        async pullImage() {
+          calls++;
+          throw new Error(`manifest unknown (${calls})`);
+        }
+      });
+
+      await expect(pullImage('node:22', false, { attempts: 2, backoff: 1 })).rejects.toThrow('Pulling node:22 failed after 2 attempts: manifest unknown (2)');
+      expect(calls).toBe(2);
+    });
+
+    it('should stop retrying when the pull timeout expires', async () => {
+      setContainerRuntime({
+        ...fakeRuntime([]),
+        async pullImage() {
+          throw new Error('connection reset by peer');
+        }
+      });
+
+      await expect(pullImage('node:22', false, { backoff: 1000, timeout: 20 })).rejects.toThrow('Pulling node:22 timed out after 0.02s (last error: connection reset by peer)');
+    });
+
+    it('should poll health until the container is healthy or unhealthy', async () => {
+      const starting = { status: 'starting', log: [] };
+      setContainerRuntime(fakeRuntime([], [], [starting, starting, { status: 'healthy', log: [] }]));
+      expect((await waitForHealthy('abc123', 5000, false, 0))?.status).toBe('healthy');
+
+      const unhealthy = { status: 'unhealthy', log: [{ exitCode: 7, output: 'connection refused' }] };
+      setContainerRuntime(fakeRuntime([], [], [starting, unhealthy]));
+      expect(await waitForHealthy('abc123', 5000, false, 0)).toEqual(unhealthy);
+    });
+
+    it('should stop polling at the deadline and report containers without a healthcheck', async () => {
+      setContainerRuntime(fakeRuntime([], [], [{ status: 'starting', log: [] }]));
+      expect((await waitForHealthy('abc123', 0, false, 0))?.status).toBe('starting');
+
+      setContainerRuntime(fakeRuntime([]));
+      expect(await waitForHealthy('abc123', 5000, false, 0)).toBeNull();
+    });
+
+    it('should create only the missing cache volumes', async () => {
+      const existing = getCacheVolumeName('app', '/work/app', 'npm');
+      const missing = getCacheVolumeName('app', '/work/app', 'cargo');
+      const runtime = fakeRuntime([], [existing]);
+      setContainerRuntime(runtime);
+
+      const created = await ensureCacheVolumes([
+        { name: 'npm', volume: existing },
+        { name: 'cargo', volume: missing }
+      ], '/work/app');
+
+      expect(created).toEqual([missing]);
+      expect(runtime.created).toEqual({
+        [missing]: { 'aisanity.workspace': '/work/app', 'aisanity.cache': 'cargo' }
+      });
+    });
+  });
+});
@@ -2,7 +2,11 @@ import { expect, test, describe } from 'bun:test';
 import {
   validateContainerLabels,
   generateContainerLabels,
-  ContainerLabels
+  matchesProfile,
+  runWithConcurrency,
+  ContainerLabels,
+  LABEL_PROFILE,
+  LABEL_VERSION
 } from '../src/utils/container-utils';
 
 describe('container-utils', () => {
@@ -38,5 +42,61 @@ describe('container-utils', () => {
       expect(labels['aisanity.branch']).toBe('main');
       expect(labels['aisanity.container']).toBe('test-container');
     });
+
+    test('should label the profile and CLI version', async () => {
+      const labels = await generateContainerLabels('test-project', 'main', 'test-container', '/test/workspace', 'review');
+
+      expect(labels[LABEL_PROFILE]).toBe('review');
+      expect(labels[LABEL_VERSION]).toBeDefined();
+    });
+
+    test('should default to the default profile', async () => {
+      const labels = await generateContainerLabels('test-project', 'main', 'test-container', '/test/workspace');
+      expect(labels[LABEL_PROFILE]).toBe('default');
+    });
+  });
+
+  describe('matchesProfile', () => {
+    test('should match the profile label', () => {
+      expect(matchesProfile({ [LABEL_PROFILE]: 'review' }, 'review')).toBe(true);
+      expect(matchesProfile({ [LABEL_PROFILE]: 'review' }, 'default')).toBe(false);
+    });
+
+    test('should treat containers without a profile label as the default profile', () => {
+      expect(matchesProfile({ 'aisanity.workspace': '/test/workspace' }, 'default')).toBe(true);
+      expect(matchesProfile({ 'aisanity.workspace': '/test/workspace' }, 'review')).toBe(false);
+    });
+  });
+
+  describe('runWithConcurrency', () => {
+    test('should run at most limit tasks at a time', async () => {
+      let active = 0;
+      let peak = 0;
+      const results = await runWithConcurrency([1, 2, 3, 4, 5], 2, async (item) => {
+        active++;
+        peak = Math.max(peak, active);
+        await new Promise(resolve => setTimeout(resolve, 5));
+        active--;
+        return item * 10;
+      });
+
+      expect(peak).toBe(2);
+      expect(results.map(result => result?.status === 'fulfilled' ? result.value : null)).toEqual([10, 20, 30, 40, 50]);
+    });
+
+    test('should not start new tasks after a failure', async () => {
+      const started: number[] = [];
+      const results = await runWithConcurrency([1, 2, 3], 1, async (item) => {
+        started.push(item);
+        if (item === 2) {
+          throw new Error('failed');
+        }
+        return item;
+      });
+
+      expect(started).toEqual([1, 2]);
+      expect(results[1]?.status).toBe('rejected');
+      expect(results[2]).toBeUndefined();
+    });
   });
-});
\ No newline at end of file
+});
@@ -8,10 +8,18 @@ import {
   createBranchSpecificDevContainer,
   getBranchSpecificDevContainerPath,
   getBaseDevContainerPath,
+  createProfileDevContainer,
+  getProfileDevContainerPath,
+  hasDevContainerOverrides,
+  applyDevContainerOverrides,
+  getDevContainerRunCommands,
+  getContainerWorkspaceFolder,
+  formatShellCommands,
   FileNotFoundError,
   InvalidJsonError,
 } from "../src/utils/devcontainer-templates";
 import { ProjectType } from "../src/utils/config";
+import { translatePodmanRunArgs } from "../src/utils/container-runtime";
 
 describe("Devcontainer Templates", () => {
   describe("getDevContainerTemplate", () => {
@@ -247,4 +255,133 @@ describe("Devcontainer Templates", () => {
       expect(getBaseDevContainerPath(cwd)).toBe(expected);
     });
   });
+  describe("createProfileDevContainer", () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = path.join(os.tmpdir(), `devcontainer-profile-test-${Date.now()}-${Math.random()}`);
+      fs.mkdirSync(tempDir, { recursive: true });
+    });
+
+    afterEach(() => {
+      if (fs.existsSync(tempDir)) {
+        fs.rmSync(tempDir, { recursive: true, force: true });
+      }
+    });
+
+    it("should override image and drop build configuration", () => {
+      const basePath = path.join(tempDir, "devcontainer.json");
+      const profilePath = getProfileDevContainerPath(basePath, "test");
+      fs.writeFileSync(basePath, JSON.stringify({ name: "Base", build: { dockerfile: "Dockerfile" } }), "utf8");
+
+      createProfileDevContainer(basePath, profilePath, { image: "node:22-slim" });
+
+      const result = JSON.parse(fs.readFileSync(profilePath, "utf8"));
+      expect(result).toEqual({ name: "Base", image: "node:22-slim" });
+    });
+
+    it("should append runArgs to the base runArgs", () => {
+      const basePath = path.join(tempDir, "devcontainer.json");
+      const profilePath = getProfileDevContainerPath(basePath, "web");
+      fs.writeFileSync(basePath, JSON.stringify({ image: "node:22", runArgs: ["--init"] }), "utf8");
+
+      createProfileDevContainer(basePath, profilePath, { runArgs: ["-p", "8080:8080"] });
+
+      const result = JSON.parse(fs.readFileSync(profilePath, "utf8"));
+      expect(result.image).toBe("node:22");
+      expect(result.runArgs).toEqual(["--init", "-p", "8080:8080"]);
+    });
+
+    it("should translate the combined runArgs for the container runtime", () => {
+      const basePath = path.join(tempDir, "devcontainer.json");
+      const profilePath = getProfileDevContainerPath(basePath, "default");
+      fs.writeFileSync(basePath, JSON.stringify({ image: "node:22", runArgs: ["--gpus", "all"] }), "utf8");
+
+      const overrides = { runArgs: ["-p", "8080:8080"], translateRunArgs: translatePodmanRunArgs };
+      expect(hasDevContainerOverrides(overrides)).toBe(true);
+      createProfileDevContainer(basePath, profilePath, overrides);
+
+      const result = JSON.parse(fs.readFileSync(profilePath, "utf8"));
+      expect(result.runArgs).toEqual(["--device", "nvidia.com/gpu=all", "-p", "8080:8080", "--userns=keep-id"]);
+    });
+
+    it("should append profile runArgs last and untranslated", () => {
+      const content = { image: "node:22", runArgs: ["--init"] };
+      const overrides = { runArgs: ["-p", "8080:8080"], translateRunArgs: translatePodmanRunArgs, extraRunArgs: ["--gpus", "all", "--cap-add=SYS_PTRACE"] };
+
+      expect(hasDevContainerOverrides({ extraRunArgs: ["--cap-add=SYS_PTRACE"] })).toBe(true);
+      expect(applyDevContainerOverrides(content, overrides).runArgs).toEqual([
+        "--init", "-p", "8080:8080", "--userns=keep-id", "--gpus", "all", "--cap-add=SYS_PTRACE"
+      ]);
+    });
+  });
+
+  describe("getProfileDevContainerPath", () => {
+    it("should place the profile file next to the base file", () => {
+      const basePath = "/home/user/project/.devcontainer/devcontainer.json";
+      const expected = path.join("/home/user/project/.devcontainer", ".aisanity-profile-ci_fast.json");
+      expect(getProfileDevContainerPath(basePath, "ci-fast")).toBe(expected);
+    });
+  });
+
+  describe("getContainerWorkspaceFolder", () => {
+    it("should default to /workspaces/<folder> and substitute workspace variables", () => {
+      expect(getContainerWorkspaceFolder({}, "/work/app")).toBe("/workspaces/app");
+      expect(getContainerWorkspaceFolder({ workspaceFolder: "/src/${localWorkspaceFolderBasename}" }, "/work/app")).toBe("/src/app");
+    });
+  });
+
+  describe("getDevContainerRunCommands", () => {
+    const options = {
+      workspacePath: "/work/app",
+      labels: ["aisanity.workspace=/work/app", "aisanity.branch=main"],
+      env: { NODE_ENV: "test" },
+      command: ["npm", "test"],
+    };
+
+    it("should expand labels, mounts, env and run flags of an image config", () => {
+      const content = applyDevContainerOverrides(
+        { image: "node:22", runArgs: ["--cap-add=SYS_PTRACE"], containerEnv: { HOME_DIR: "${localWorkspaceFolder}" } },
+        { runArgs: ["-p", "8080:8080", "--mount", "type=volume,source=aisanity-app-npm,target=/root/.npm"] },
+      );
+
+      expect(getDevContainerRunCommands("docker", content, "/work/app/.devcontainer", options)).toEqual([
+        [
+          "docker", "run", "-it",
+          "--label", "aisanity.workspace=/work/app",
+          "--label", "aisanity.branch=main",
+          "--mount", "type=bind,source=/work/app,target=/workspaces/app",
+          "-w", "/workspaces/app",
+          "-e", "HOME_DIR=/work/app",
+          "-e", "NODE_ENV=test",
+          "--cap-add=SYS_PTRACE", "-p", "8080:8080",
+          "--mount", "type=volume,source=aisanity-app-npm,target=/root/.npm",
+          "node:22", "npm", "test",
+        ],
+      ]);
+    });
+
+    it("should run as the profile user in the container and in exec sessions", () => {
+      const content = applyDevContainerOverrides({ image: "node:22", remoteUser: "node" }, { user: "1001:1001" });
+
+      expect(content.containerUser).toBe("1001:1001");
+      expect(content.remoteUser).toBe("1001:1001");
+      expect(getDevContainerRunCommands("docker", content, "/work/app/.devcontainer", options)[0]).toContain("1001:1001");
+    });
+
+    it("should build Dockerfile configs before running them", () => {
+      const commands = getDevContainerRunCommands("podman", { build: { dockerfile: "Dockerfile", context: ".." } }, "/work/app/.devcontainer", options);
+
+      expect(commands[0]).toEqual(["podman", "build", "-t", "aisanity-app", "-f", "/work/app/.devcontainer/Dockerfile", "/work/app"]);
+      expect(commands[1].slice(-3)).toEqual(["aisanity-app", "npm", "test"]);
+    });
+  });
+
+  describe("formatShellCommands", () => {
+    it("should quote arguments for a single shell line", () => {
+      expect(formatShellCommands([["docker", "build", "."], ["docker", "run", "-e", "MSG=it's here", "img", ""]])).toBe(
+        "docker build . && docker run -e 'MSG=it'\\''s here' img ''",
+      );
+    });
+  });
 });
@@ -0,0 +1,94 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import { Command } from 'commander';
+import {
+  addDisplayOptions,
+  colors,
+  configureDisplay,
+  getDisplaySettings,
+  print,
+  resolveDisplaySettings,
+  symbol
+} from '../src/utils/display';
+import { createLoggerFromCommandOptions } from '../src/utils/logger';
+
+describe('display', () => {
+  const initial = getDisplaySettings();
+  let logOutput: string[];
+  let originalLog: typeof console.log;
+
+  beforeEach(() => {
+    logOutput = [];
+    originalLog = console.log;
+    console.log = (...args: any[]) => {
+      logOutput.push(args.join(' '));
+    };
+  });
+
+  afterEach(() => {
+    console.log = originalLog;
+    configureDisplay(initial);
+  });
+
+  describe('resolveDisplaySettings', () => {
+    it('should treat --quiet and --silent alike', () => {
+      expect(resolveDisplaySettings({ quiet: true }, {}).quiet).toBe(true);
+      expect(resolveDisplaySettings({ silent: true }, {}).quiet).toBe(true);
+      expect(resolveDisplaySettings({}, {}).quiet).toBe(false);
+    });
+
+    it('should turn colors off when NO_COLOR is set', () => {
+      expect(resolveDisplaySettings({}, { NO_COLOR: '1' }).color).toBe(false);
+    });
+
+    it('should keep emoji unless --no-emoji is given', () => {
+      expect(resolveDisplaySettings({}, {}).emoji).toBe(true);
+      expect(resolveDisplaySettings({ emoji: false }, {}).emoji).toBe(false);
+    });
+  });
+
+  it('should fall back to ASCII symbols with --no-emoji', () => {
+    configureDisplay({ quiet: false, color: false, emoji: true });
+    expect(symbol('success')).toBe('✅');
+
+    configureDisplay({ quiet: false, color: false, emoji: false });
+    expect(symbol('success')).toBe('[ok]');
+    expect(symbol('failure')).toBe('[x]');
+    expect(symbol('current')).toBe('>');
+  });
+
+  it('should leave text uncolored when colors are off', () => {
+    configureDisplay({ quiet: false, color: false, emoji: true });
+    expect(colors().red('failed')).toBe('failed');
+  });
+
+  it('should suppress informational prints in quiet mode', () => {
+    configureDisplay({ quiet: true, color: false, emoji: true });
+    print('hidden');
+    configureDisplay({ quiet: false, color: false, emoji: true });
+    print('shown');
+
+    expect(logOutput).toEqual(['shown']);
+  });
+
+  it('should make the logger silent for --quiet', () => {
+    const logger = createLoggerFromCommandOptions({ quiet: true });
+    logger.info('hidden');
+
+    expect(logOutput).toEqual([]);
+  });
+
+  it('should add the flags to leaf commands only', () => {
+    const program = new Command('aisanity');
+    const group = new Command('worktree');
+    group.addCommand(new Command('list'));
+    program.addCommand(group);
+    program.addCommand(new Command('run').option('--silent, --quiet', 'Suppress aisanity output'));
+
+    addDisplayOptions(program);
+
+    const flags = (command: Command) => command.options.map(option => option.flags);
+    expect(flags(group)).toEqual([]);
+    expect(flags(group.commands[0])).toEqual(['-q, --quiet', '--no-emoji']);
+    expect(flags(program.commands[1])).toEqual(['--silent, --quiet', '--no-emoji']);
+  });
+});
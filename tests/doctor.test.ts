@@ -0,0 +1,144 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { checkBinaries, checkDaemon, checkDockerGroup, checkConfig, checkImages } from '../src/utils/doctor';
+import { ContainerRuntime } from '../src/utils/container-runtime';
+
+function fakeRuntime(overrides: Partial<ContainerRuntime> = {}): ContainerRuntime {
+  return {
+    name: 'cli',
+    command: 'docker',
+    async getServerVersion() {
+      return '27.0.1';
+    },
+    async hasImage() {
+      return false;
+    },
+    ...overrides
+  } as unknown as ContainerRuntime;
+}
+
+const GROUP_FILE = 'root:x:0:\ndocker:x:998:alice,bob\nusers:x:100:\n';
+
+describe('doctor', () => {
+  let tempDir: string;
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-doctor-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  describe('checkBinaries', () => {
+    it('should fail when the runtime or devcontainer CLI is missing', () => {
+      fs.writeFileSync(path.join(tempDir, 'docker'), '', { mode: 0o755 });
+
+      const checks = checkBinaries(fakeRuntime(), { PATH: tempDir });
+      expect(checks.map(check => check.status)).toEqual(['pass', 'fail']);
+      expect(checks[1].hint).toContain('@devcontainers/cli');
+
+      expect(checkBinaries(fakeRuntime({ command: 'podman', name: 'podman' } as Partial<ContainerRuntime>), { PATH: tempDir })[0].status).toBe('fail');
+    });
+
+    it('should not need a CLI for the API runtime', () => {
+      const [runtimeCheck] = checkBinaries(fakeRuntime({ name: 'sdk' } as Partial<ContainerRuntime>), { PATH: '', DOCKER_HOST: 'tcp://127.0.0.1:2375' });
+      expect(runtimeCheck).toEqual({ name: 'Container runtime', status: 'pass', detail: 'Docker Engine API at tcp://127.0.0.1:2375' });
+    });
+  });
+
+  describe('checkDaemon', () => {
+    it('should report the daemon version', async () => {
+      expect(await checkDaemon(fakeRuntime())).toEqual({ name: 'Daemon', status: 'pass', detail: 'docker 27.0.1' });
+    });
+
+    it('should fail with a platform hint when the daemon is down', async () => {
+      const runtime = fakeRuntime({
+        async getServerVersion() {
+          throw new Error('Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?');
+        }
+      });
+
+      const linux = await checkDaemon(runtime, 'linux');
+      expect(linux.status).toBe('fail');
+      expect(linux.hint).toBe('Start Docker with: sudo systemctl start docker');
+      expect((await checkDaemon(runtime, 'darwin')).hint).toContain('Docker Desktop');
+    });
+
+    it('should point at socket permissions', async () => {
+      const runtime = fakeRuntime({
+        async getServerVersion() {
+          throw new Error('permission denied while trying to connect to the Docker daemon socket');
+        }
+      });
+      expect((await checkDaemon(runtime, 'linux')).hint).toContain('docker group');
+    });
+  });
+
+  describe('checkDockerGroup', () => {
+    const groups = (user: string, gids: number[], uid: number = 1000) => ({ user, uid, gids, groupFile: GROUP_FILE });
+
+    it('should only apply to docker on Linux', () => {
+      expect(checkDockerGroup(fakeRuntime(), 'darwin')).toBeNull();
+      expect(checkDockerGroup(fakeRuntime({ name: 'podman' } as Partial<ContainerRuntime>), 'linux', groups('alice', []))).toBeNull();
+    });
+
+    it('should check group membership of the session', () => {
+      expect(checkDockerGroup(fakeRuntime(), 'linux', groups('alice', [100, 998]))?.status).toBe('pass');
+      expect(checkDockerGroup(fakeRuntime(), 'linux', groups('root', [], 0))?.detail).toBe('running as root');
+
+      const stale = checkDockerGroup(fakeRuntime(), 'linux', groups('bob', [100]));
+      expect(stale?.status).toBe('warn');
+      expect(stale?.hint).toContain('newgrp docker');
+
+      expect(checkDockerGroup(fakeRuntime(), 'linux', groups('carol', [100]))?.hint).toBe(
+        'Add it with: sudo usermod -aG docker carol (then log in again)'
+      );
+      expect(checkDockerGroup(fakeRuntime(), 'linux', { user: 'carol', uid: 1000, gids: [], groupFile: 'users:x:100:\n' })?.detail).toBe(
+        'there is no docker group on this system'
+      );
+    });
+  });
+
+  describe('checkConfig', () => {
+    it('should report missing, valid and invalid configs', () => {
+      expect(checkConfig(tempDir).check.status).toBe('warn');
+
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: app\nprofiles:\n  default: {}\n  test: {}\n');
+      const loaded = checkConfig(tempDir);
+      expect(loaded.check).toEqual({ name: 'Config', status: 'pass', detail: `${path.join(tempDir, '.aisanity')} (profiles: default, test)` });
+      expect(loaded.config?.workspace).toBe('app');
+
+      fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: app\nstopTimout: 5\n');
+      const invalid = checkConfig(tempDir);
+      expect(invalid.check.status).toBe('fail');
+      expect(invalid.config).toBeNull();
+    });
+  });
+
+  describe('checkImages', () => {
+    it('should check the image of every profile', async () => {
+      const runtime = fakeRuntime({
+        async hasImage(image: string) {
+          return image === 'node:22';
+        }
+      });
+      const config = {
+        workspace: 'app',
+        profiles: { default: { image: 'node:22' }, py: { image: 'python:3.12' }, built: { build: { context: '.' } }, bare: {} }
+      };
+
+      const checks = await checkImages(config, tempDir, runtime);
+      expect(checks.map(check => [check.name, check.status])).toEqual([
+        ['Image (bare)', 'warn'],
+        ['Image (built)', 'warn'],
+        ['Image (default)', 'pass'],
+        ['Image (py)', 'warn']
+      ]);
+      expect(checks[0].detail).toBe('no image configured');
+      expect(checks[1].hint).toBe('Build it with: aisanity build');
+    });
+  });
+});
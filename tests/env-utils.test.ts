@@ -7,10 +7,19 @@ import {
   parseCliEnvVars,
   mergeEnvVariables,
   formatRemoteEnvArgs,
+  formatDockerEnvArgs,
+  interpolateEnvValue,
+  resolveDeclaredEnv,
   validateWhitelistPatterns,
   processEnvironmentVariables,
-  generateDevcontainerEnvFlags
+  generateDevcontainerEnvFlags,
+  maskHostEnvValues,
+  parseEnvFile,
+  loadEnvFiles
 } from '../src/utils/env-utils';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
 import { AisanityConfig } from '../src/utils/config';
 
 // Mock process.env for testing
@@ -182,6 +191,149 @@ describe('Environment Variable Utilities', () => {
       const result = mergeEnvVariables({}, {}, {}, []);
       expect(result).toEqual({});
     });
+
+    it('should put env file values under config values and over host values', () => {
+      const result = mergeEnvVariables(
+        {},
+        { TOKEN: 'host', HOST_VAR: 'host' },
+        { MODE: 'config' },
+        ['TOKEN', 'HOST_VAR'],
+        { TOKEN: 'file', MODE: 'file' }
+      );
+
+      expect(result).toEqual({ TOKEN: 'file', MODE: 'config', HOST_VAR: 'host' });
+    });
+  });
+
+  describe('parseEnvFile', () => {
+    it('should parse plain, quoted and exported values', () => {
+      const content = [
+        '# credentials',
+        '',
+        'PLAIN=value # trailing comment',
+        'export EXPORTED=yes',
+        'SINGLE=\'no $expansion \\n here\'',
+        'DOUBLE="line one\\nline two \\"quoted\\" # kept"',
+        'HASH=abc#def',
+        'EMPTY=',
+        'SPACED = padded ',
+        'MULTI="first',
+        'second"'
+      ].join('\n');
+
+      expect(parseEnvFile(content, '.env')).toEqual({
+        PLAIN: 'value',
+        EXPORTED: 'yes',
+        SINGLE: 'no $expansion \\n here',
+        DOUBLE: 'line one\nline two "quoted" # kept',
+        HASH: 'abc#def',
+        EMPTY: '',
+        SPACED: 'padded',
+        MULTI: 'first\nsecond'
+      });
+    });
+
+    it('should report malformed lines with their line number', () => {
+      expect(() => parseEnvFile('A=1\nnot a variable\n', '.env')).toThrow('.env:2: expected KEY=value');
+      expect(() => parseEnvFile('1A=1\n', '.env')).toThrow('.env:1: invalid variable name "1A"');
+      expect(() => parseEnvFile('A="open\n', '.env')).toThrow('.env:1: unterminated double quote');
+      expect(() => parseEnvFile('A="x" y\n', '.env')).toThrow('.env:1: unexpected text after the closing quote');
+    });
+  });
+
+  describe('loadEnvFiles', () => {
+    it('should load files relative to the workspace, later files winning', () => {
+      const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-envfile-'));
+      try {
+        fs.writeFileSync(path.join(workspace, '.env'), 'A=1\nB=1\n');
+        fs.writeFileSync(path.join(workspace, '.env.local'), 'B=2\n');
+
+        expect(loadEnvFiles(['.env', '.env.local'], workspace, 'dev')).toEqual({ A: '1', B: '2' });
+        expect(loadEnvFiles(undefined, workspace, 'dev')).toEqual({});
+        expect(() => loadEnvFiles('missing.env', workspace, 'dev')).toThrow(
+          `Profile 'dev': env file does not exist: ${path.join(workspace, 'missing.env')}`
+        );
+      } finally {
+        fs.rmSync(workspace, { recursive: true, force: true });
+      }
+    });
+  });
+
+  describe('formatDockerEnvArgs', () => {
+    it('should format environment variables as docker -e flags', () => {
+      const result = formatDockerEnvArgs({ NODE_ENV: 'development', EMPTY: '' });
+
+      expect(result).toEqual(['-e', 'NODE_ENV=development', '-e', 'EMPTY=']);
+    });
+  });
+
+  describe('maskHostEnvValues', () => {
+    it('should mask whitelisted and passed through host values only', () => {
+      const result = maskHostEnvValues(
+        { GITHUB_TOKEN: 'ghp_secret', API_KEY: 'from-host', NODE_ENV: 'test' },
+        { GITHUB_TOKEN: 'ghp_secret' },
+        { API_KEY: 'from-host', NODE_ENV: 'development' }
+      );
+
+      expect(result).toEqual({ GITHUB_TOKEN: '***', API_KEY: '***', NODE_ENV: 'test' });
+    });
+  });
+
+  describe('interpolateEnvValue', () => {
+    const hostEnv = { HOST_VAR: 'from-host', EMPTY: '' };
+
+    it('should substitute host variables', () => {
+      expect(interpolateEnvValue('${HOST_VAR}', hostEnv)).toBe('from-host');
+      expect(interpolateEnvValue('prefix-${HOST_VAR}-suffix', hostEnv)).toBe('prefix-from-host-suffix');
+    });
+
+    it('should use the :- default for unset or empty variables', () => {
+      expect(interpolateEnvValue('${MISSING:-fallback}', hostEnv)).toBe('fallback');
+      expect(interpolateEnvValue('${EMPTY:-fallback}', hostEnv)).toBe('fallback');
+      expect(interpolateEnvValue('${MISSING:-}', hostEnv)).toBe('');
+    });
+
+    it('should error when a variable is unset without a default', () => {
+      expect(() => interpolateEnvValue('${MISSING}', hostEnv)).toThrow('Host variable MISSING is not set');
+    });
+
+    it('should pass a set but empty variable through without a default', () => {
+      expect(interpolateEnvValue('${EMPTY}', hostEnv)).toBe('');
+      expect(interpolateEnvValue('a${EMPTY}b', hostEnv)).toBe('ab');
+    });
+
+    it('should leave plain values untouched', () => {
+      expect(interpolateEnvValue('literal $HOST_VAR value', hostEnv)).toBe('literal $HOST_VAR value');
+    });
+
+    it('should take template variables before host variables', () => {
+      expect(interpolateEnvValue('${workspace}/${HOST_VAR}', { ...hostEnv, workspace: 'ignored' }, { workspace: '/work' })).toBe('/work/from-host');
+    });
+  });
+
+  describe('resolveDeclaredEnv', () => {
+    const hostEnv = { HOST_VAR: 'from-host', TOKEN: 'secret' };
+
+    it('should resolve literals and interpolated values from a map', () => {
+      expect(resolveDeclaredEnv({ NODE_ENV: 'test', PORT: 8080, FROM_HOST: '${HOST_VAR}' }, hostEnv)).toEqual({
+        NODE_ENV: 'test',
+        PORT: '8080',
+        FROM_HOST: 'from-host'
+      });
+    });
+
+    it('should forward bare keys from the host', () => {
+      expect(resolveDeclaredEnv(['TOKEN', 'UNSET', 'MODE=ci'], hostEnv)).toEqual({ TOKEN: 'secret', MODE: 'ci' });
+      expect(resolveDeclaredEnv({ TOKEN: null }, hostEnv)).toEqual({ TOKEN: 'secret' });
+    });
+
+    it('should name the variable in interpolation errors', () => {
+      expect(() => resolveDeclaredEnv({ API_URL: '${MISSING_URL}' }, hostEnv)).toThrow('API_URL: Host variable MISSING_URL is not set');
+    });
+
+    it('should reject invalid variable names', () => {
+      expect(() => resolveDeclaredEnv(['1BAD'], hostEnv)).toThrow('Invalid environment variable name');
+    });
   });
 
   describe('formatRemoteEnvArgs', () => {
@@ -0,0 +1,53 @@
+import { describe, it, expect } from 'bun:test';
+import * as fs from 'fs';
+import * as net from 'net';
+import * as os from 'os';
+import * as path from 'path';
+import { createEventSocket } from '../src/utils/events';
+import { toRunEventJson } from '../src/utils/output';
+
+const run = { workspace: '/home/user/app', profile: 'default', container: 'abc123' };
+
+describe('createEventSocket', () => {
+  it('should write events as JSON lines to the socket', async () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-events-'));
+    const socketPath = path.join(dir, 'events.sock');
+    let received = '';
+    const done = new Promise<void>(resolve => {
+      const server = net.createServer(connection => {
+        connection.on('data', chunk => { received += chunk.toString(); });
+        connection.on('end', () => {
+          server.close();
+          resolve();
+        });
+      });
+      server.listen(socketPath);
+    });
+    await new Promise(resolve => setTimeout(resolve, 50));
+
+    const errors: string[] = [];
+    const events = createEventSocket(socketPath, message => errors.push(message));
+    events.emit(toRunEventJson('created', run));
+    events.emit(toRunEventJson('started', run));
+    await events.close();
+    await done;
+
+    const lines = received.trim().split('\n').map(line => JSON.parse(line));
+    expect(lines.map(line => line.event)).toEqual(['created', 'started']);
+    expect(lines[0].container).toBe('abc123');
+    expect(errors).toEqual([]);
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  it('should report a missing socket once and keep going', async () => {
+    const errors: string[] = [];
+    const events = createEventSocket(path.join(os.tmpdir(), 'aisanity-missing.sock'), message => errors.push(message));
+    events.emit(toRunEventJson('started', run));
+    await new Promise(resolve => setTimeout(resolve, 50));
+    events.emit(toRunEventJson('exited', { ...run, exitCode: 0 }));
+    await events.close();
+
+    expect(errors.length).toBe(1);
+    expect(errors[0]).toContain('aisanity-missing.sock');
+  });
+});
@@ -0,0 +1,96 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { resolveBuildPaths, hashBuildContext, buildProfileImage } from '../src/utils/image-build';
+import { readWorkspaceState, getStateDir } from '../src/utils/state';
+import { getBuildImageTag } from '../src/utils/container-utils';
+import { setContainerRuntime, ContainerRuntime, BuildImageOptions } from '../src/utils/container-runtime';
+
+describe('Image build', () => {
+  let tempDir: string;
+  let originalStateHome: string | undefined;
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-build-'));
+    originalStateHome = process.env.XDG_STATE_HOME;
+    process.env.XDG_STATE_HOME = path.join(tempDir, 'state');
+    fs.mkdirSync(path.join(tempDir, 'docker'));
+    fs.writeFileSync(path.join(tempDir, 'docker', 'Dockerfile'), 'FROM node:22\nCOPY . /app\n');
+    fs.writeFileSync(path.join(tempDir, 'docker', 'app.js'), 'console.log(1);\n');
+  });
+
+  afterEach(() => {
+    if (originalStateHome === undefined) {
+      delete process.env.XDG_STATE_HOME;
+    } else {
+      process.env.XDG_STATE_HOME = originalStateHome;
+    }
+    setContainerRuntime(null);
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should resolve the context against the workspace and the Dockerfile against the context', () => {
+    expect(resolveBuildPaths({ context: 'docker' }, tempDir, 'dev')).toEqual({
+      context: path.join(tempDir, 'docker'),
+      dockerfile: path.join(tempDir, 'docker', 'Dockerfile')
+    });
+    expect(() => resolveBuildPaths({ context: 'docker', dockerfile: 'Dockerfile.dev' }, tempDir, 'dev')).toThrow(
+      `Profile 'dev': Dockerfile ${path.join(tempDir, 'docker', 'Dockerfile.dev')} does not exist`
+    );
+    expect(() => resolveBuildPaths({ context: 'missing' }, tempDir, 'dev')).toThrow('is not a directory');
+  });
+
+  it('should change the hash when the context changes, except for ignored files', () => {
+    const paths = resolveBuildPaths({ context: 'docker' }, tempDir, 'dev');
+    const initial = hashBuildContext(paths);
+    expect(hashBuildContext(paths)).toBe(initial);
+
+    fs.writeFileSync(path.join(tempDir, 'docker', '.dockerignore'), 'node_modules\n*.log\n');
+    const ignoring = hashBuildContext(paths);
+    fs.mkdirSync(path.join(tempDir, 'docker', 'node_modules'));
+    fs.writeFileSync(path.join(tempDir, 'docker', 'node_modules', 'dep.js'), '');
+    fs.writeFileSync(path.join(tempDir, 'docker', 'debug.log'), 'noise');
+    expect(hashBuildContext(paths)).toBe(ignoring);
+
+    fs.writeFileSync(path.join(tempDir, 'docker', 'app.js'), 'console.log(2);\n');
+    expect(hashBuildContext(paths)).not.toBe(ignoring);
+  });
+
+  it('should tag builds per workspace and profile', () => {
+    expect(getBuildImageTag('My App', '/work/app', 'dev')).toMatch(/^aisanity-my-app-[0-9a-f]+:dev$/);
+    expect(getBuildImageTag('app', '/work/a', 'dev')).not.toBe(getBuildImageTag('app', '/work/b', 'dev'));
+  });
+
+  it('should build only when the Dockerfile or context changed', async () => {
+    const builds: BuildImageOptions[] = [];
+    const images = new Set<string>();
+    setContainerRuntime({
+      async hasImage(image: string) {
+        return images.has(image);
+      },
+      async buildImage(options: BuildImageOptions) {
+        builds.push(options);
+        images.add(options.tag);
+      }
+    } as unknown as ContainerRuntime);
+
+    const first = await buildProfileImage({ context: 'docker' }, 'dev', 'app', tempDir);
+    expect(first.built).toBe(true);
+    expect(builds[0].labels).toEqual({ 'aisanity.workspace': tempDir, 'aisanity.profile': 'dev' });
+    expect(readWorkspaceState(tempDir).builds?.[first.tag]?.hash).toBeDefined();
+    expect(getStateDir(tempDir).startsWith(path.join(tempDir, 'state', 'aisanity'))).toBe(true);
+
+    expect((await buildProfileImage({ context: 'docker' }, 'dev', 'app', tempDir)).built).toBe(false);
+    expect((await buildProfileImage({ context: 'docker' }, 'dev', 'app', tempDir, { force: true })).built).toBe(true);
+
+    fs.appendFileSync(path.join(tempDir, 'docker', 'Dockerfile'), 'RUN true\n');
+    expect((await buildProfileImage({ context: 'docker' }, 'dev', 'app', tempDir)).built).toBe(true);
+    expect(builds).toHaveLength(3);
+
+    // A different platform is a different image
+    expect((await buildProfileImage({ context: 'docker' }, 'dev', 'app', tempDir, { platform: 'linux/amd64' })).built).toBe(true);
+    expect(builds[3].platform).toBe('linux/amd64');
+    expect((await buildProfileImage({ context: 'docker' }, 'dev', 'app', tempDir, { platform: 'linux/amd64' })).built).toBe(false);
+  });
+});
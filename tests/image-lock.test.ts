@@ -0,0 +1,192 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  parseImageReference,
+  formatPinnedImage,
+  selectRepoDigest,
+  readImageLock,
+  writeImageLock,
+  lockImage,
+  resolveLockedImage,
+  getImageLockPath,
+  hashLockConfig,
+  findLockDrift
+} from '../src/utils/image-lock';
+import { collectProfileImages } from '../src/commands/pull';
+import { collectLockDigests } from '../src/commands/lock';
+import { loadAisanityConfig, getUserConfigPath } from '../src/utils/config';
+
+const DIGEST = 'sha256:' + '0123456789abcdef'.repeat(4);
+
+describe('Image lock', () => {
+  describe('parseImageReference', () => {
+    it('should split name, tag and digest', () => {
+      expect(parseImageReference('node:22')).toEqual({ name: 'node', tag: '22' });
+      expect(parseImageReference(`localhost:5000/org/app:1.2@${DIGEST}`)).toEqual({
+        name: 'localhost:5000/org/app',
+        tag: '1.2',
+        digest: DIGEST
+      });
+      expect(parseImageReference('localhost:5000/app')).toEqual({ name: 'localhost:5000/app' });
+    });
+
+    it('should reject malformed digests', () => {
+      expect(() => parseImageReference('node:22@sha256:abc')).toThrow('Invalid image "node:22@sha256:abc"');
+    });
+  });
+
+  it('should pin images by digest and keep the tag', () => {
+    expect(formatPinnedImage('node:22', DIGEST)).toBe(`node:22@${DIGEST}`);
+  });
+
+  it('should pick the repo digest of the same repository', () => {
+    expect(selectRepoDigest('ghcr.io/org/app:1', [`mirror.local/app@sha256:${'f'.repeat(64)}`, `ghcr.io/org/app@${DIGEST}`])).toBe(DIGEST);
+    expect(selectRepoDigest('app:1', [])).toBeNull();
+  });
+
+  describe('lock file', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-lock-'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    it('should round-trip locked digests', () => {
+      expect(readImageLock(tempDir)).toBeNull();
+
+      const lock = lockImage(null, 'node:22', DIGEST, new Date('2024-01-02T03:04:05Z'));
+      writeImageLock(tempDir, lock);
+
+      expect(readImageLock(tempDir)).toEqual({
+        version: 1,
+        images: { 'node:22': { digest: DIGEST, pulledAt: '2024-01-02T03:04:05.000Z' } }
+      });
+    });
+
+    it('should keep the config and build hashes when a digest is added', () => {
+      const lock = { version: 1, images: {}, config: 'sha256:c', builds: { web: 'sha256:b', api: 'sha256:a' } };
+      writeImageLock(tempDir, lockImage(lock, 'node:22', DIGEST, new Date('2024-01-02T03:04:05Z')));
+
+      const written = readImageLock(tempDir);
+      expect(written?.config).toBe('sha256:c');
+      expect(Object.keys(written?.builds || {})).toEqual(['api', 'web']);
+      expect(written?.images['node:22'].digest).toBe(DIGEST);
+    });
+
+    it('should report invalid lock files with their path', () => {
+      fs.writeFileSync(getImageLockPath(tempDir), '{ not json', 'utf8');
+      expect(() => readImageLock(tempDir)).toThrow(`Invalid lock file ${getImageLockPath(tempDir)}`);
+    });
+  });
+
+  describe('resolveLockedImage', () => {
+    const lock = lockImage(null, 'node:22', DIGEST);
+
+    it('should pin tag-only images found in the lock', () => {
+      expect(resolveLockedImage('node:22', lock)).toEqual({ image: `node:22@${DIGEST}`, digest: DIGEST });
+      expect(resolveLockedImage('python:3.13', lock)).toEqual({ image: 'python:3.13' });
+    });
+
+    it('should use images pinned in the config as written', () => {
+      expect(resolveLockedImage(`node:20@${DIGEST}`, null)).toEqual({ image: `node:20@${DIGEST}`, digest: DIGEST });
+    });
+  });
+
+  describe('config and build digests', () => {
+    it('should hash configs regardless of key order', () => {
+      expect(hashLockConfig({ workspace: 'app', env: { A: '1', B: '2' } })).toBe(hashLockConfig({ env: { B: '2', A: '1' }, workspace: 'app' }));
+      expect(hashLockConfig({ workspace: 'app' })).not.toBe(hashLockConfig({ workspace: 'web' }));
+    });
+
+    it('should hash the build context of each building profile', () => {
+      const tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-lock-build-'));
+      try {
+        fs.writeFileSync(path.join(tempDir, 'Dockerfile'), 'FROM node:22\n');
+        const config = { workspace: 'app', profiles: { default: {}, dev: { build: { context: '.' } } } };
+
+        const digests = collectLockDigests(config, tempDir);
+        expect(Object.keys(digests.builds)).toEqual(['dev']);
+        expect(digests.builds.dev).toMatch(/^sha256:[a-f0-9]{64}$/);
+        expect(collectLockDigests(config, tempDir, 'default').builds).toEqual({});
+      } finally {
+        fs.rmSync(tempDir, { recursive: true, force: true });
+      }
+    });
+
+    it('should hash the project config without the user config', () => {
+      const tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-lock-config-'));
+      const previous = process.env.XDG_CONFIG_HOME;
+      try {
+        process.env.XDG_CONFIG_HOME = path.join(tempDir, 'config');
+        fs.writeFileSync(path.join(tempDir, '.aisanity'), 'workspace: app\nenv:\n  NODE_ENV: test\n');
+        const before = collectLockDigests(loadAisanityConfig(tempDir)!, tempDir).config;
+
+        fs.mkdirSync(path.join(tempDir, 'config', 'aisanity'), { recursive: true });
+        fs.writeFileSync(getUserConfigPath(), 'env:\n  EDITOR: vim\n');
+        const config = loadAisanityConfig(tempDir)!;
+        expect(config.env).toEqual({ EDITOR: 'vim', NODE_ENV: 'test' });
+        expect(collectLockDigests(config, tempDir).config).toBe(before);
+      } finally {
+        if (previous === undefined) {
+          delete process.env.XDG_CONFIG_HOME;
+        } else {
+          process.env.XDG_CONFIG_HOME = previous;
+        }
+        fs.rmSync(tempDir, { recursive: true, force: true });
+      }
+    });
+
+    it('should report what changed since the lock was generated', () => {
+      const lock = { version: 1, images: {}, config: 'sha256:c', builds: { dev: 'sha256:b' } };
+
+      expect(findLockDrift(lock, { config: 'sha256:c', builds: { dev: 'sha256:b' } })).toEqual([]);
+      expect(findLockDrift(lock, { config: 'sha256:x', builds: { dev: 'sha256:y' } })).toEqual([
+        'the config changed since .aisanity.lock was generated',
+        "the build context of profile 'dev' changed since .aisanity.lock was generated"
+      ]);
+    });
+
+    it('should only count what the lock does not cover as drift when frozen', () => {
+      const lock = { version: 1, images: {} };
+
+      expect(findLockDrift(lock, { config: 'sha256:c', builds: { dev: 'sha256:b' } })).toEqual([]);
+      expect(findLockDrift(lock, { config: 'sha256:c', builds: { dev: 'sha256:b' } }, true)).toEqual([
+        '.aisanity.lock does not record the config',
+        "the build context of profile 'dev' is not in .aisanity.lock"
+      ]);
+    });
+  });
+
+  describe('collectProfileImages', () => {
+    const config = {
+      workspace: 'app',
+      profiles: { default: {}, test: { image: 'node:22-slim' }, ci: { image: 'node:22-slim' } }
+    };
+
+    it('should collect unique images with the devcontainer image as fallback', () => {
+      expect(collectProfileImages(config, 'node:22').map(({ image }) => image).sort()).toEqual(['node:22', 'node:22-slim']);
+      expect(collectProfileImages(config, 'node:22', 'test')).toEqual([{ image: 'node:22-slim' }]);
+      expect(collectProfileImages({ workspace: 'app' }, undefined)).toEqual([]);
+    });
+
+    it('should pull each image for the platform of its profile', () => {
+      const platforms = {
+        workspace: 'app',
+        profiles: { default: { image: 'node:22' }, arm: { image: 'node:22', platform: 'linux/arm64' } }
+      };
+      expect(collectProfileImages(platforms, undefined)).toEqual([{ image: 'node:22', platform: 'linux/arm64' }, { image: 'node:22' }]);
+      expect(() => collectProfileImages({ workspace: 'app', profiles: { default: { image: 'node:22', platform: 'arm64' } } }, undefined))
+        .toThrow("Profile 'default'");
+    });
+
+    it('should skip profiles that build their image', () => {
+      expect(collectProfileImages({ workspace: 'app', profiles: { dev: { build: { context: '.' } } } }, 'node:22')).toEqual([]);
+    });
+  });
+});
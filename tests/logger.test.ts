@@ -1,5 +1,5 @@
 import { describe, test, expect, beforeEach, afterEach } from 'bun:test';
-import { Logger } from '../src/utils/logger';
+import { Logger, resolveLogFormat } from '../src/utils/logger';
 
 describe('Logger', () => {
   let originalConsoleLog: any;
@@ -325,4 +325,36 @@ describe('Logger', () => {
       expect(errorOutput).toContain('Warning message');
     });
   });
+
+  describe('JSON log format', () => {
+    test('should emit one timing record per phase with the context fields', async () => {
+      const logger = new Logger(true, false, false, 'json');
+      logger.setFields({ workspace: '/work/app', profile: 'default' });
+      logger.phase('config_load', Date.now() - 5);
+      await expect(logger.time('container_create', async () => {
+        throw new Error('devcontainer up failed with code 1');
+      })).rejects.toThrow('devcontainer up failed');
+
+      expect(logOutput).toHaveLength(0);
+      const records = errorOutput.map(line => JSON.parse(line));
+      expect(records[0]).toMatchObject({ level: 'info', phase: 'config_load', status: 'ok', workspace: '/work/app', profile: 'default' });
+      expect(records[0].durationMs).toBeGreaterThanOrEqual(5);
+      expect(records[1]).toMatchObject({ phase: 'container_create', status: 'error', error: 'devcontainer up failed with code 1' });
+    });
+
+    test('should keep text output and show timing only in debug mode', () => {
+      new Logger().phase('exec', Date.now());
+      expect(logOutput).toHaveLength(0);
+
+      new Logger(false, false, true).phase('exec', Date.now());
+      expect(logOutput[0]).toMatch(/^\[Timing\] exec: \d+ms$/);
+    });
+
+    test('should take the format from the flag, then the environment', () => {
+      expect(resolveLogFormat(undefined, {})).toBe('text');
+      expect(resolveLogFormat(undefined, { AISANITY_LOG_FORMAT: 'json' })).toBe('json');
+      expect(resolveLogFormat('text', { AISANITY_LOG_FORMAT: 'json' })).toBe('text');
+      expect(() => resolveLogFormat('yaml', {})).toThrow('Invalid log format "yaml". Expected one of: text, json');
+    });
+  });
 });
\ No newline at end of file
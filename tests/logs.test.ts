@@ -0,0 +1,95 @@
+import { describe, it, expect } from 'bun:test';
+import { parseLogOptions, parseIdleTimeout, logsCommand } from '../src/commands/logs';
+import { parseLogSince, createLogDemuxer, createIdleSignal } from '../src/utils/container-runtime';
+
+describe('logs command', () => {
+  it('should expose docker logs style options', () => {
+    const flags = logsCommand.options.map(option => option.long);
+    expect(flags).toContain('--follow');
+    expect(flags).toContain('--tail');
+    expect(flags).toContain('--since');
+    expect(flags).toContain('--workspace');
+  });
+
+  describe('parseLogOptions', () => {
+    it('should accept valid values', () => {
+      expect(parseLogOptions({ follow: true, tail: '100', since: '10m' })).toEqual({ follow: true, tail: '100', since: '10m' });
+      expect(parseLogOptions({ tail: 'all' })).toEqual({ follow: false, tail: 'all', since: undefined });
+    });
+
+    it('should follow until the logs go quiet with --until', () => {
+      expect(parseLogOptions({ tail: '50', until: '10s' })).toEqual({ follow: true, tail: '50', since: undefined, idleTimeout: 10000 });
+      expect(parseIdleTimeout('5')).toBe(5000);
+      expect(parseIdleTimeout('1m30s')).toBe(90000);
+    });
+
+    it('should reject invalid values', () => {
+      expect(() => parseLogOptions({ until: 'soon' })).toThrow('Invalid --until value "soon"');
+      expect(() => parseLogOptions({ tail: '-5' })).toThrow('Invalid --tail value "-5"');
+      expect(() => parseLogOptions({ since: 'yesterday' })).toThrow('Invalid --since value "yesterday"');
+    });
+  });
+
+  describe('parseLogSince', () => {
+    const now = Date.parse('2024-01-02T12:00:00Z');
+
+    it('should resolve relative durations', () => {
+      expect(parseLogSince('90s', now)).toBe(now / 1000 - 90);
+      expect(parseLogSince('1h30m', now)).toBe(now / 1000 - 5400);
+    });
+
+    it('should accept timestamps', () => {
+      expect(parseLogSince('1704196800', now)).toBe(1704196800);
+      expect(parseLogSince('2024-01-02T11:00:00Z', now)).toBe(now / 1000 - 3600);
+    });
+  });
+
+  describe('createIdleSignal', () => {
+    const wait = (ms: number) => new Promise(resolve => setTimeout(resolve, ms));
+
+    it('should abort once nothing reset it for the timeout', async () => {
+      const idle = createIdleSignal(30);
+      await wait(20);
+      idle.reset();
+      await wait(20);
+      expect(idle.signal.aborted).toBe(false);
+      await wait(30);
+      expect(idle.signal.aborted).toBe(true);
+      idle.dispose();
+    });
+
+    it('should follow the outer signal without a timeout', async () => {
+      const outer = new AbortController();
+      const idle = createIdleSignal(undefined, outer.signal);
+      await wait(10);
+      expect(idle.signal.aborted).toBe(false);
+      outer.abort();
+      expect(idle.signal.aborted).toBe(true);
+      idle.dispose();
+    });
+  });
+
+  describe('createLogDemuxer', () => {
+    const frame = (stream: number, text: string): Uint8Array => {
+      const payload = new TextEncoder().encode(text);
+      const header = new Uint8Array(8);
+      header[0] = stream;
+      new DataView(header.buffer).setUint32(4, payload.length);
+      const result = new Uint8Array(8 + payload.length);
+      result.set(header);
+      result.set(payload, 8);
+      return result;
+    };
+
+    it('should split frames by stream, including frames across chunks', () => {
+      const received: [number, string][] = [];
+      const write = createLogDemuxer((stream, payload) => received.push([stream, new TextDecoder().decode(payload)]));
+
+      const data = new Uint8Array([...frame(1, 'hello\n'), ...frame(2, 'oops\n')]);
+      write(data.subarray(0, 10));
+      write(data.subarray(10));
+
+      expect(received).toEqual([[1, 'hello\n'], [2, 'oops\n']]);
+    });
+  });
+});
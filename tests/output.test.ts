@@ -0,0 +1,117 @@
+import { describe, it, expect } from 'bun:test';
+import {
+  parseOutputFormat,
+  toContainerJson,
+  toStatusJson,
+  toDryRunJson,
+  toLogsJson,
+  toRunEventJson,
+  formatJson,
+  JSON_SCHEMA_VERSION
+} from '../src/utils/output';
+import { DockerContainer } from '../src/utils/container-utils';
+
+const container: DockerContainer = {
+  id: 'abc123',
+  name: 'app-main',
+  image: 'node:22',
+  status: 'Up 2 hours (healthy)',
+  ports: '0.0.0.0:3000->3000/tcp, :::3000->3000/tcp',
+  labels: {
+    'aisanity.workspace': '/home/user/app',
+    'aisanity.branch': 'main',
+    'aisanity.profile': 'node'
+  }
+};
+
+describe('JSON output', () => {
+  describe('parseOutputFormat', () => {
+    it('should default to text', () => {
+      expect(parseOutputFormat(undefined)).toBe('text');
+      expect(parseOutputFormat('json')).toBe('json');
+    });
+
+    it('should reject unknown formats', () => {
+      expect(() => parseOutputFormat('yaml')).toThrow('Invalid --output value "yaml". Expected one of: text, json');
+    });
+  });
+
+  describe('toContainerJson', () => {
+    it('should expose state, labels and ports', () => {
+      expect(toContainerJson(container)).toEqual({
+        id: 'abc123',
+        name: 'app-main',
+        state: 'running',
+        status: 'Up 2 hours (healthy)',
+        image: 'node:22',
+        workspace: '/home/user/app',
+        branch: 'main',
+        profile: 'node',
+        ports: ['0.0.0.0:3000->3000/tcp', ':::3000->3000/tcp'],
+        labels: container.labels
+      });
+    });
+
+    it('should fill in defaults for unlabeled containers', () => {
+      const json = toContainerJson({ id: 'x', name: 'x', image: 'alpine', status: 'Exited (0) 1 minute ago', ports: '', labels: {} });
+
+      expect(json.state).toBe('exited');
+      expect(json.workspace).toBeNull();
+      expect(json.branch).toBeNull();
+      expect(json.profile).toBe('default');
+      expect(json.ports).toEqual([]);
+    });
+  });
+
+  it('should build the status document', () => {
+    const status = toStatusJson('/home/user/app', [container]);
+
+    expect(status.schemaVersion).toBe(JSON_SCHEMA_VERSION);
+    expect(status.workspace).toBe('/home/user/app');
+    expect(status.containers.map(entry => entry.id)).toEqual(['abc123']);
+    expect(JSON.parse(formatJson(status))).toEqual(status);
+  });
+
+  it('should split dry run labels into a map', () => {
+    const dryRun = toDryRunJson({
+      workspace: '/home/user/app',
+      profile: 'default',
+      image: 'node:22',
+      labels: ['aisanity.workspace=/home/user/app', 'aisanity.container=a=b'],
+      command: ['bash'],
+      runArgs: ['--gpus=all'],
+      commands: [['docker', 'run', '-it', '--gpus=all', 'node:22', 'bash']]
+    });
+
+    expect(dryRun.labels).toEqual({ 'aisanity.workspace': '/home/user/app', 'aisanity.container': 'a=b' });
+    expect(dryRun.schemaVersion).toBe(JSON_SCHEMA_VERSION);
+  });
+
+  it('should describe the logs target', () => {
+    expect(toLogsJson(container, { follow: true, tail: '100' })).toMatchObject({
+      container: { id: 'abc123', state: 'running' },
+      follow: true,
+      tail: '100',
+      since: null
+    });
+  });
+
+  it('should stamp run events with the schema version and time', () => {
+    const event = toRunEventJson('exited', {
+      workspace: '/home/user/app',
+      profile: 'node',
+      container: 'abc123',
+      exitCode: 2
+    }, new Date('2026-01-02T03:04:05Z'));
+
+    expect(event).toEqual({
+      schemaVersion: JSON_SCHEMA_VERSION,
+      event: 'exited',
+      time: '2026-01-02T03:04:05.000Z',
+      workspace: '/home/user/app',
+      profile: 'node',
+      container: 'abc123',
+      exitCode: 2
+    });
+  });
+});
@@ -0,0 +1,717 @@
+import { describe, it, expect } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  DEFAULT_PROFILE,
+  getProfileNames,
+  mergeProfiles,
+  mergeCliMounts,
+  resolveProfile,
+  parseProfileMount,
+  resolveProfileMounts,
+  resolveProfileSecrets,
+  formatExcludeMounts,
+  formatMountArgs,
+  parseCacheVolumes,
+  parseDuration,
+  formatHealthcheckArgs,
+  getHealthcheckDeadline,
+  parseMemorySize,
+  formatResourceArgs,
+  formatResourceSummary,
+  formatNetworkArgs,
+  validateProfileNetworks,
+  formatDockerAccessArgs,
+  validatePlatform,
+  getHostPlatform,
+  isSamePlatform,
+  validateWorkdir,
+  validateRestartPolicy,
+  getMountTargets,
+  isPathOnMount,
+  formatWorkdirCommand,
+  getProfileWarnings,
+  resolveContainerUser,
+  getProfileCommand,
+  resolveRunCommand,
+  applyProfileToConfig,
+  applyEnvProfiles,
+  expandTemplateVariables,
+  resolveProfileTemplates,
+  resolveProfileEnvironments,
+  parsePortMapping,
+  validatePorts,
+  validateProfilePorts,
+  formatPortArgs
+} from '../src/utils/profile-utils';
+import { AisanityConfig } from '../src/utils/config';
+
+describe('Profile Utilities', () => {
+  const config: AisanityConfig = {
+    workspace: 'test-project',
+    base: {
+      mounts: ['./cache:/cache'],
+      env: { SHARED: 'yes', MODE: 'base' }
+    },
+    profiles: {
+      default: { image: 'node:22' },
+      test: {
+        image: 'node:22-slim',
+        mounts: ['./fixtures:/fixtures'],
+        env: { MODE: 'test' },
+        command: ['npm', 'test']
+      },
+      review: { command: 'git diff main' }
+    }
+  };
+
+  describe('getProfileNames', () => {
+    it('should return sorted profile names', () => {
+      expect(getProfileNames(config)).toEqual(['default', 'review', 'test']);
+    });
+
+    it('should return empty list when no profiles are defined', () => {
+      expect(getProfileNames({ workspace: 'x' })).toEqual([]);
+    });
+  });
+
+  describe('mergeProfiles', () => {
+    it('should merge env and concatenate mounts', () => {
+      const merged = mergeProfiles(config.base!, config.profiles!.test);
+
+      expect(merged.image).toBe('node:22-slim');
+      expect(merged.env).toEqual({ SHARED: 'yes', MODE: 'test' });
+      expect(merged.mounts).toEqual(['./cache:/cache', './fixtures:/fixtures']);
+    });
+
+    it('should merge cache volumes by name', () => {
+      const merged = mergeProfiles(
+        { cacheVolumes: { npm: '/root/.npm', cargo: '/root/.cargo' } },
+        { cacheVolumes: { npm: '/home/node/.npm' } }
+      );
+
+      expect(merged.cacheVolumes).toEqual({ npm: '/home/node/.npm', cargo: '/root/.cargo' });
+    });
+
+    it('should let a profile image replace an inherited build and vice versa', () => {
+      expect(mergeProfiles({ build: { context: 'docker' } }, { image: 'node:22' })).toEqual({ image: 'node:22' });
+      expect(mergeProfiles({ image: 'node:22' }, { build: { context: 'docker' } })).toEqual({ build: { context: 'docker' } });
+    });
+
+    it('should merge resource limits by key', () => {
+      const merged = mergeProfiles({ resources: { cpus: 2, memory: '2g' } }, { resources: { memory: '4g' } });
+
+      expect(merged.resources).toEqual({ cpus: 2, memory: '4g' });
+    });
+
+    it('should append env files, secrets and excludes', () => {
+      const merged = mergeProfiles(
+        { envFile: '.env', secrets: [{ source: 'token' }] },
+        { envFile: ['.env.test'], secrets: [{ source: 'key', target: '/keys/key' }] }
+      );
+
+      expect(merged.envFile).toEqual(['.env', '.env.test']);
+      expect(mergeProfiles({ exclude: ['node_modules'] }, { exclude: ['node_modules', 'dist'] }).exclude).toEqual(['node_modules', 'dist']);
+      expect(merged.secrets).toEqual([{ source: 'token' }, { source: 'key', target: '/keys/key' }]);
+    });
+
+    it('should append runArgs after the base ones', () => {
+      expect(mergeProfiles({ runArgs: ['--init'] }, { runArgs: ['--gpus', 'all'] }).runArgs).toEqual(['--init', '--gpus', 'all']);
+    });
+  });
+
+  describe('resolveProfile', () => {
+    it('should fall back to the default profile', () => {
+      const profile = resolveProfile(config);
+
+      expect(profile.name).toBe(DEFAULT_PROFILE);
+      expect(profile.image).toBe('node:22');
+      expect(profile.mounts).toEqual(['./cache:/cache']);
+    });
+
+    it('should resolve a named profile inheriting the base block', () => {
+      const profile = resolveProfile(config, 'test');
+
+      expect(profile.name).toBe('test');
+      expect(profile.env).toEqual({ SHARED: 'yes', MODE: 'test' });
+    });
+
+    it('should list available profiles when the profile does not exist', () => {
+      expect(() => resolveProfile(config, 'missing')).toThrow(
+        "Profile 'missing' not found in .aisanity config. Available profiles: default, review, test"
+      );
+    });
+
+    it('should error when no profile is given and there is no default profile', () => {
+      const noDefault: AisanityConfig = { workspace: 'x', profiles: { dev: {} } };
+      expect(() => resolveProfile(noDefault)).toThrow('Available profiles: dev');
+    });
+
+    it('should resolve legacy configs without profiles to the default profile', () => {
+      const profile = resolveProfile({ workspace: 'x' });
+      expect(profile.name).toBe(DEFAULT_PROFILE);
+    });
+
+    it('should reject named profiles when no profiles are defined', () => {
+      expect(() => resolveProfile({ workspace: 'x' }, 'test')).toThrow("Profile 'test' not found");
+    });
+  });
+
+  describe('parseProfileMount', () => {
+    it('should resolve relative sources against the workspace', () => {
+      expect(parseProfileMount('./cache:/cache', '/home/user/project')).toBe(
+        'type=bind,source=/home/user/project/cache,target=/cache'
+      );
+    });
+
+    it('should keep absolute sources', () => {
+      expect(parseProfileMount('/data:/data', '/home/user/project')).toBe('type=bind,source=/data,target=/data');
+    });
+
+    it('should reject malformed mounts', () => {
+      expect(() => parseProfileMount('/data', '/workspace')).toThrow('Expected source:target');
+      expect(() => parseProfileMount('/data:relative', '/workspace')).toThrow('Target must be an absolute container path');
+      expect(() => parseProfileMount('/data:/data:rx', '/workspace')).toThrow('Expected source:target');
+    });
+
+    it('should render readonly and consistency options', () => {
+      expect(parseProfileMount('./src:/src:ro,cached', '/home/user/project')).toBe(
+        'type=bind,source=/home/user/project/src,target=/src,readonly,consistency=cached'
+      );
+      expect(parseProfileMount({ source: 'data', target: '/data', readonly: true, consistency: 'delegated' }, '/w')).toBe(
+        'type=bind,source=/w/data,target=/data,readonly,consistency=delegated'
+      );
+      expect(parseProfileMount({ source: '/data', target: '/data', readonly: false }, '/w')).toBe(
+        'type=bind,source=/data,target=/data'
+      );
+    });
+
+    it('should reject unknown consistency values', () => {
+      expect(() => parseProfileMount({ source: '/data', target: '/data', consistency: 'fast' as any }, '/w')).toThrow(
+        'consistency must be one of: cached, delegated, consistent'
+      );
+    });
+  });
+
+  describe('resolveProfileMounts', () => {
+    it('should resolve sources against the workspace root and require them to exist', () => {
+      const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-mounts-'));
+      try {
+        fs.mkdirSync(path.join(workspace, 'cache'));
+
+        expect(resolveProfileMounts(['./cache:/cache:ro'], workspace, 'test')).toEqual([
+          `type=bind,source=${path.join(workspace, 'cache')},target=/cache,readonly`
+        ]);
+        expect(() => resolveProfileMounts([{ source: 'missing', target: '/missing' }], workspace, 'test')).toThrow(
+          `Profile 'test': mount source does not exist: ${path.join(workspace, 'missing')}`
+        );
+      } finally {
+        fs.rmSync(workspace, { recursive: true, force: true });
+      }
+    });
+
+    it('should create missing sources with createMissing, as a directory or a file', () => {
+      const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-mounts-'));
+      try {
+        expect(resolveProfileMounts([
+          { source: './data/db', target: '/data', createMissing: true },
+          { source: './config/app.toml', target: '/etc/app.toml', createMissing: 'file', readonly: true }
+        ], workspace, 'test')).toEqual([
+          `type=bind,source=${path.join(workspace, 'data', 'db')},target=/data`,
+          `type=bind,source=${path.join(workspace, 'config', 'app.toml')},target=/etc/app.toml,readonly`
+        ]);
+        expect(fs.statSync(path.join(workspace, 'data', 'db')).isDirectory()).toBe(true);
+        expect(fs.readFileSync(path.join(workspace, 'config', 'app.toml'), 'utf8')).toBe('');
+
+        // Existing sources of the other kind are reported rather than mounted
+        expect(() => resolveProfileMounts([{ source: './data/db', target: '/db', createMissing: 'file' }], workspace, 'test')).toThrow(
+          `Profile 'test': mount source ${path.join(workspace, 'data', 'db')} is not a file, but createMissing is file`
+        );
+      } finally {
+        fs.rmSync(workspace, { recursive: true, force: true });
+      }
+    });
+
+    it('should not create missing sources in a dry run', () => {
+      const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-mounts-'));
+      try {
+        resolveProfileMounts([{ source: './scratch', target: '/scratch', createMissing: 'directory' }], workspace, 'test', true);
+        expect(fs.existsSync(path.join(workspace, 'scratch'))).toBe(false);
+        expect(() => resolveProfileMounts([{ source: './scratch', target: '/scratch' }], workspace, 'test', true)).toThrow(
+          'mount source does not exist'
+        );
+      } finally {
+        fs.rmSync(workspace, { recursive: true, force: true });
+      }
+    });
+  });
+
+  describe('mergeCliMounts', () => {
+    it('should add flag mounts and let them replace profile mounts with the same target', () => {
+      const mounts = ['./cache:/cache', { source: './fixtures', target: '/fixtures', readonly: true }];
+
+      expect(mergeCliMounts(mounts, ['../data:/cache:ro', '/tmp/scratch:/scratch'], '/work/app/src')).toEqual([
+        { source: './fixtures', target: '/fixtures', readonly: true },
+        { source: '/work/app/data', target: '/cache', readonly: true },
+        { source: '/tmp/scratch', target: '/scratch' }
+      ]);
+      expect(mergeCliMounts(mounts, [], '/work/app')).toEqual(mounts);
+      expect(() => mergeCliMounts([], ['/tmp'], '/work/app')).toThrow('Invalid --mount "/tmp". Expected source:target[:ro]');
+    });
+  });
+
+  describe('resolveProfileSecrets', () => {
+    it('should mount secret files read-only, by default under /run/secrets', () => {
+      const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-secrets-'));
+      try {
+        fs.mkdirSync(path.join(workspace, 'secrets'));
+        fs.writeFileSync(path.join(workspace, 'secrets', 'npm-token'), 'secret');
+
+        expect(resolveProfileSecrets([
+          { source: 'secrets/npm-token' },
+          { source: './secrets/npm-token', target: '/home/node/.npmrc-token' }
+        ], workspace, 'dev')).toEqual([
+          `type=bind,source=${path.join(workspace, 'secrets', 'npm-token')},target=/run/secrets/npm-token,readonly`,
+          `type=bind,source=${path.join(workspace, 'secrets', 'npm-token')},target=/home/node/.npmrc-token,readonly`
+        ]);
+        expect(() => resolveProfileSecrets([{ source: 'secrets' }], workspace, 'dev')).toThrow(
+          `Profile 'dev': secret file does not exist: ${path.join(workspace, 'secrets')}`
+        );
+        expect(() => resolveProfileSecrets([{ source: 'secrets/npm-token', target: 'relative' }], workspace, 'dev')).toThrow(
+          'must be an absolute container path'
+        );
+        expect(() => resolveProfileSecrets([{ source: 'secrets/npm-token' }, { source: 'secrets/npm-token' }], workspace, 'dev')).toThrow(
+          "Profile 'dev': two secrets are mounted at /run/secrets/npm-token"
+        );
+      } finally {
+        fs.rmSync(workspace, { recursive: true, force: true });
+      }
+    });
+  });
+
+  describe('formatExcludeMounts', () => {
+    it('should mask workspace subpaths with anonymous volumes', () => {
+      expect(formatExcludeMounts(['node_modules', './packages/web/node_modules/', 'node_modules'], '/workspaces/app', 'dev')).toEqual([
+        'type=volume,target=/workspaces/app/node_modules',
+        'type=volume,target=/workspaces/app/packages/web/node_modules'
+      ]);
+    });
+
+    it('should reject paths outside the workspace', () => {
+      for (const entry of ['/etc', '../other', '.']) {
+        expect(() => formatExcludeMounts([entry], '/workspaces/app', 'dev')).toThrow(
+          `Profile 'dev': exclude entry "${entry}" must be a path inside the workspace`
+        );
+      }
+    });
+  });
+
+  describe('formatMountArgs', () => {
+    it('should emit one --mount flag per mount', () => {
+      expect(formatMountArgs(['type=bind,source=/a,target=/a', 'type=bind,source=/b,target=/b,readonly'])).toEqual([
+        '--mount', 'type=bind,source=/a,target=/a',
+        '--mount', 'type=bind,source=/b,target=/b,readonly'
+      ]);
+    });
+  });
+
+  describe('parseCacheVolumes', () => {
+    it('should return cache names and targets', () => {
+      expect(parseCacheVolumes({ npm: '/root/.npm' }, 'dev')).toEqual([{ name: 'npm', target: '/root/.npm' }]);
+      expect(parseCacheVolumes(undefined, 'dev')).toEqual([]);
+    });
+
+    it('should reject invalid names and relative targets', () => {
+      expect(() => parseCacheVolumes({ 'npm cache': '/root/.npm' }, 'dev')).toThrow("Profile 'dev': invalid cache volume name");
+      expect(() => parseCacheVolumes({ npm: '.npm' }, 'dev')).toThrow('target must be an absolute container path');
+    });
+  });
+
+  describe('resources', () => {
+    it('should parse docker memory sizes', () => {
+      expect(parseMemorySize('512m')).toBe(512 * 1024 ** 2);
+      expect(parseMemorySize('2G')).toBe(2 * 1024 ** 3);
+      expect(parseMemorySize('1.5g')).toBe(1.5 * 1024 ** 3);
+      expect(() => parseMemorySize('2gb')).toThrow('Invalid memory "2gb"');
+    });
+
+    it('should format docker resource flags', () => {
+      expect(formatResourceArgs({ cpus: 1.5, memory: '2g', pidsLimit: 256 }, 'dev')).toEqual([
+        '--cpus', '1.5',
+        '--memory', '2g',
+        '--pids-limit', '256'
+      ]);
+      expect(formatResourceArgs({}, 'dev')).toEqual([]);
+      expect(formatResourceSummary({ cpus: 2, pidsLimit: 100 })).toBe('cpus=2 pidsLimit=100');
+    });
+
+    it('should reject values docker would refuse', () => {
+      expect(() => formatResourceArgs({ cpus: 0 }, 'dev')).toThrow("Profile 'dev': resources cpus must be a number above 0");
+      expect(() => formatResourceArgs({ memory: 'lots' }, 'dev')).toThrow("Profile 'dev': resources Invalid memory");
+      expect(() => formatResourceArgs({ memory: '512' }, 'dev')).toThrow('below the 6m minimum');
+      expect(() => formatResourceArgs({ pidsLimit: 0 }, 'dev')).toThrow('pidsLimit must be a positive whole number');
+      expect(formatResourceArgs({ pidsLimit: -1 }, 'dev')).toEqual(['--pids-limit', '-1']);
+    });
+  });
+
+  describe('platforms', () => {
+    it('should accept os/arch and an optional variant', () => {
+      expect(validatePlatform('linux/amd64', 'default')).toBe('linux/amd64');
+      expect(validatePlatform('Linux/ARM64/v8', 'default')).toBe('linux/arm64/v8');
+    });
+
+    it('should reject other platforms', () => {
+      expect(() => validatePlatform('amd64', 'x86')).toThrow("Profile 'x86': platform must be os/arch[/variant], e.g. linux/amd64 (got \"amd64\")");
+      expect(() => validatePlatform('linux/amd64 --privileged', 'x86')).toThrow('platform must be os/arch');
+    });
+
+    it('should map the host architecture to a linux platform', () => {
+      expect(getHostPlatform('x64')).toBe('linux/amd64');
+      expect(getHostPlatform('arm64')).toBe('linux/arm64');
+    });
+
+    it('should compare variants only when both platforms have one', () => {
+      expect(isSamePlatform('linux/arm64', 'linux/arm64/v8')).toBe(true);
+      expect(isSamePlatform('linux/arm/v6', 'linux/arm/v7')).toBe(false);
+      expect(isSamePlatform('linux/amd64', 'linux/arm64')).toBe(false);
+    });
+  });
+
+  describe('validateRestartPolicy', () => {
+    it('should accept docker restart policies', () => {
+      for (const policy of ['no', 'on-failure', 'on-failure:5', 'unless-stopped', 'always']) {
+        expect(validateRestartPolicy(policy, 'default')).toBe(policy);
+      }
+    });
+
+    it('should reject unknown policies and retry limits on other policies', () => {
+      expect(() => validateRestartPolicy('sometimes', 'bg')).toThrow("Profile 'bg': restart must be one of no, on-failure[:max], unless-stopped, always");
+      expect(() => validateRestartPolicy('always:3', 'bg')).toThrow('got "always:3"');
+      expect(() => validateRestartPolicy('on-failure:x', 'bg')).toThrow('got "on-failure:x"');
+    });
+  });
+
+  describe('workdir', () => {
+    it('should require an absolute container path', () => {
+      expect(validateWorkdir('/workspace/app/', 'default')).toBe('/workspace/app/');
+      expect(validateWorkdir('/workspace//app', 'default')).toBe('/workspace/app');
+      expect(() => validateWorkdir('app', 'default')).toThrow("Profile 'default': workdir must be an absolute path in the container");
+    });
+
+    it('should read the targets of mount specs', () => {
+      expect(getMountTargets([
+        'type=bind,source=/work/data,target=/data,readonly',
+        'type=volume,target=/workspace/node_modules',
+        'type=volume,source=cache,dst=/cache'
+      ])).toEqual(['/data', '/workspace/node_modules', '/cache']);
+    });
+
+    it('should tell whether a directory is on a mount', () => {
+      expect(isPathOnMount('/workspace', ['/workspace'])).toBe(true);
+      expect(isPathOnMount('/workspace/src', ['/data', '/workspace/'])).toBe(true);
+      expect(isPathOnMount('/workspace-old', ['/workspace'])).toBe(false);
+      expect(isPathOnMount('/tmp', ['/workspace'])).toBe(false);
+    });
+
+    it('should start commands in the workdir', () => {
+      expect(formatWorkdirCommand(['npm', 'test'], '/workspace/app')).toEqual(['sh', '-c', 'cd "$0" && exec "$@"', '/workspace/app', 'npm', 'test']);
+    });
+  });
+
+  describe('resolveContainerUser', () => {
+    const ids = { uid: 1001, gid: 1002 };
+
+    it('should map to the host user by default on Linux only', () => {
+      expect(resolveContainerUser(undefined, 'dev', 'cli', 'linux', ids)).toBe('1001:1002');
+      expect(resolveContainerUser(undefined, 'dev', 'cli', 'darwin', ids)).toBeUndefined();
+      expect(resolveContainerUser('auto', 'dev', 'sdk', 'darwin', ids)).toBe('1001:1002');
+    });
+
+    it('should leave auto to keep-id under podman', () => {
+      expect(resolveContainerUser('auto', 'dev', 'podman', 'linux', ids)).toBeUndefined();
+      expect(resolveContainerUser('node', 'dev', 'podman', 'linux', ids)).toBe('node');
+    });
+
+    it('should keep the image user or pass explicit users through', () => {
+      expect(resolveContainerUser('image', 'dev', 'cli', 'linux', ids)).toBeUndefined();
+      expect(resolveContainerUser('1000:1000', 'dev', 'cli', 'linux', ids)).toBe('1000:1000');
+      expect(() => resolveContainerUser('me@host', 'dev', 'cli', 'linux', ids)).toThrow("Profile 'dev': user must be auto, image");
+    });
+  });
+
+  describe('network', () => {
+    it('should format the network flag', () => {
+      expect(formatNetworkArgs({ network: 'none' }, 'offline')).toEqual(['--network', 'none']);
+      expect(formatNetworkArgs({ network: 'my-net.1' }, 'dev')).toEqual(['--network', 'my-net.1']);
+      expect(formatNetworkArgs({}, 'dev')).toEqual([]);
+    });
+
+    it('should reject malformed network names', () => {
+      expect(() => formatNetworkArgs({ network: 'container:abc' }, 'dev')).toThrow("Profile 'dev': network must be none, bridge, host");
+    });
+
+    it('should reject published ports without a network, including inherited ones', () => {
+      const offline: AisanityConfig = { workspace: 'app', base: { ports: ['3000'] }, profiles: { offline: { network: 'none' } } };
+      expect(() => validateProfileNetworks(offline)).toThrow("Profile 'offline': ports cannot be published with network \"none\"");
+      expect(() => validateProfileNetworks({ workspace: 'app', base: { network: 'none' } })).not.toThrow();
+    });
+  });
+
+  describe('dockerAccess', () => {
+    it('should mount the socket and add its group', () => {
+      const tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-docker-access-'));
+      const socketPath = path.join(tempDir, 'docker.sock');
+      fs.writeFileSync(socketPath, '');
+      try {
+        expect(formatDockerAccessArgs(socketPath, 'dind')).toEqual([
+          '--mount', `type=bind,source=${socketPath},target=/var/run/docker.sock`,
+          '--group-add', String(fs.statSync(socketPath).gid)
+        ]);
+      } finally {
+        fs.rmSync(tempDir, { recursive: true, force: true });
+      }
+    });
+
+    it('should require the socket except in dry runs', () => {
+      expect(() => formatDockerAccessArgs('/nonexistent/docker.sock', 'dind')).toThrow(
+        "Profile 'dind': dockerAccess needs the Docker socket, but /nonexistent/docker.sock does not exist"
+      );
+      expect(formatDockerAccessArgs('/nonexistent/docker.sock', 'dind', true)).toEqual([
+        '--mount', 'type=bind,source=/nonexistent/docker.sock,target=/var/run/docker.sock'
+      ]);
+    });
+
+    it('should warn when an offline profile can still reach the host through the socket', () => {
+      const config: AisanityConfig = {
+        workspace: 'app',
+        base: { dockerAccess: true },
+        profiles: { offline: { network: 'none' }, dev: {} }
+      };
+      expect(getProfileWarnings(config)).toEqual([
+        "Profile 'offline': dockerAccess with network \"none\" still gives the sandbox control of the host through the Docker socket"
+      ]);
+      expect(getProfileWarnings({ workspace: 'app', profiles: { offline: { network: 'none' } } })).toEqual([]);
+    });
+  });
+
+  describe('healthcheck', () => {
+    it('should parse docker durations', () => {
+      expect(parseDuration('500ms')).toBe(500);
+      expect(parseDuration('1m30s')).toBe(90000);
+      expect(parseDuration('1.5s')).toBe(1500);
+      expect(() => parseDuration('5')).toThrow('Invalid duration "5"');
+    });
+
+    it('should format docker health flags', () => {
+      expect(formatHealthcheckArgs({ command: ['curl', '-f', 'http://localhost:3000/health'], interval: '2s', retries: 5 }, 'dev')).toEqual([
+        '--health-cmd', 'curl -f http://localhost:3000/health',
+        '--health-interval', '2s',
+        '--health-retries', '5'
+      ]);
+      expect(formatHealthcheckArgs({ command: 'pg_isready || exit 1' }, 'dev')).toEqual(['--health-cmd', 'pg_isready || exit 1']);
+    });
+
+    it('should reject invalid settings with the profile name', () => {
+      expect(() => formatHealthcheckArgs({ command: 'true', timeout: 'soon' }, 'dev')).toThrow("Profile 'dev': healthcheck timeout: Invalid duration");
+      expect(() => formatHealthcheckArgs({ command: 'true', retries: 0 }, 'dev')).toThrow('retries must be a positive whole number');
+    });
+
+    it('should allow every retry to run before giving up', () => {
+      expect(getHealthcheckDeadline({ command: 'true', interval: '2s', timeout: '1s', retries: 3, startPeriod: '10s' })).toBe(21000);
+      expect(getHealthcheckDeadline()).toBe(30000 + 60000 * 3);
+    });
+  });
+
+  describe('getProfileCommand', () => {
+    it('should use the fallback when the profile has no command', () => {
+      expect(getProfileCommand({}, ['bash'])).toEqual(['bash']);
+    });
+
+    it('should use array commands as argv', () => {
+      expect(getProfileCommand({ command: ['npm', 'test'] }, ['bash'])).toEqual(['npm', 'test']);
+    });
+
+    it('should run string commands through the shell', () => {
+      expect(getProfileCommand({ command: 'git diff main' }, ['bash'])).toEqual(['sh', '-c', 'git diff main']);
+    });
+  });
+
+  describe('resolveRunCommand', () => {
+    it('should let command line arguments replace the profile command', () => {
+      const profile = { command: 'npm run dev' };
+
+      expect(resolveRunCommand(['npm', 'test', '--', '--grep', 'two words'], profile, ['bash'])).toEqual(['npm', 'test', '--', '--grep', 'two words']);
+      expect(resolveRunCommand([], profile, ['bash'])).toEqual(['sh', '-c', 'npm run dev']);
+      expect(resolveRunCommand([], {}, ['bash'])).toEqual(['bash']);
+    });
+  });
+
+  describe('applyEnvProfiles', () => {
+    const withBundles: AisanityConfig = {
+      ...config,
+      envProfiles: { ci: { CI: 'true', MODE: 'ci' }, prod: { MODE: 'prod', TOKEN: 'secret' } }
+    };
+
+    it('should layer env profiles over the run profile env in order', () => {
+      const profile = resolveProfile(withBundles, 'test');
+
+      expect(applyEnvProfiles(withBundles, profile, ['ci', 'prod']).env).toEqual({ SHARED: 'yes', MODE: 'prod', CI: 'true', TOKEN: 'secret' });
+      expect(applyEnvProfiles(withBundles, profile, ['prod', 'ci']).env?.MODE).toBe('ci');
+      expect(applyEnvProfiles(withBundles, profile, [])).toBe(profile);
+    });
+
+    it('should reject unknown env profiles', () => {
+      expect(() => applyEnvProfiles(withBundles, resolveProfile(withBundles), ['staging'])).toThrow(
+        "Env profile 'staging' not found in .aisanity config. Available env profiles: ci, prod"
+      );
+      expect(() => applyEnvProfiles(config, resolveProfile(config), ['ci'])).toThrow('no envProfiles are defined');
+    });
+  });
+
+  describe('applyProfileToConfig', () => {
+    it('should layer profile env over config env', () => {
+      const result = applyProfileToConfig({ workspace: 'x', env: { A: '1', B: '1' } }, { env: { B: '2' } });
+      expect(result.env).toEqual({ A: '1', B: '2' });
+    });
+  });
+
+  describe('parsePortMapping', () => {
+    it('should parse host:container and ip:host:container forms', () => {
+      expect(parsePortMapping('8080:80')).toEqual({ hostIp: undefined, hostPort: '8080', containerPort: '80', protocol: 'tcp' });
+      expect(parsePortMapping('127.0.0.1:5432:5432')).toEqual({
+        hostIp: '127.0.0.1',
+        hostPort: '5432',
+        containerPort: '5432',
+        protocol: 'tcp'
+      });
+    });
+
+    it('should treat a single port as a random host port', () => {
+      expect(parsePortMapping('8080').hostPort).toBeUndefined();
+      expect(parsePortMapping('53/udp').protocol).toBe('udp');
+    });
+
+    it('should reject malformed ports', () => {
+      expect(() => parsePortMapping('web:80')).toThrow('Ports must be numbers between 1 and 65535');
+      expect(() => parsePortMapping('70000:80')).toThrow('Ports must be numbers between 1 and 65535');
+      expect(() => parsePortMapping('8080:80/sctp')).toThrow('Protocol must be tcp or udp');
+      expect(() => parsePortMapping('localhost:8080:80')).toThrow('Host IP must be an IPv4 address');
+    });
+  });
+
+  describe('validatePorts', () => {
+    it('should reject duplicate host ports', () => {
+      expect(() => validatePorts(['8080:80', '127.0.0.1:8080:81'], 'web')).toThrow(
+        "Profile 'web': host port 8080/tcp is published twice"
+      );
+    });
+
+    it('should allow the same host port on different host IPs', () => {
+      expect(validatePorts(['127.0.0.1:8080:80', '192.168.1.5:8080:81'], 'web')).toHaveLength(2);
+      expect(() => validatePorts(['127.0.0.1:8080:80', '127.0.0.1:8080:81'], 'web')).toThrow('host port 8080/tcp is published twice');
+      expect(() => validatePorts(['127.0.0.1:8080:80', '0.0.0.0:8080:81'], 'web')).toThrow('host port 8080/tcp is published twice');
+    });
+
+    it('should allow the same port number on different protocols and random host ports', () => {
+      expect(validatePorts(['53:53', '53:53/udp', '8080', '8080'], 'dns')).toHaveLength(4);
+    });
+
+    it('should detect duplicates introduced through the base block', () => {
+      const withBase: AisanityConfig = {
+        workspace: 'x',
+        base: { ports: ['8080:8080'] },
+        profiles: { default: { ports: ['8080:3000'] } }
+      };
+      expect(() => validateProfilePorts(withBase)).toThrow("Profile 'default': host port 8080/tcp is published twice");
+    });
+  });
+
+  describe('expandTemplateVariables', () => {
+    const variables = { workspace: '/work/app', home: '/home/dev', profile: 'ci' };
+
+    it('should expand workspace, home and profile', () => {
+      expect(expandTemplateVariables('${home}/.npmrc:${workspace}/.npmrc', variables)).toBe('/home/dev/.npmrc:/work/app/.npmrc');
+      expect(expandTemplateVariables('cache-${profile}', variables)).toBe('cache-ci');
+      expect(expandTemplateVariables('./data:/data', variables)).toBe('./data:/data');
+    });
+
+    it('should reject unknown variables', () => {
+      expect(() => expandTemplateVariables('${workdir}/data', variables)).toThrow('unknown template variable ${workdir}');
+    });
+  });
+
+  describe('resolveProfileTemplates', () => {
+    const variables = { workspace: '/work/app', home: '/home/dev' };
+
+    it('should expand mount strings and mount sources and targets', () => {
+      const resolved = resolveProfileTemplates({
+        workspace: 'app',
+        profiles: {
+          default: {
+            mounts: ['${home}/.gitconfig:/home/node/.gitconfig:ro', { source: '${workspace}/out', target: '/out/${profile}' }]
+          }
+        }
+      }, variables);
+      expect(resolved.profiles!.default.mounts).toEqual([
+        '/home/dev/.gitconfig:/home/node/.gitconfig:ro',
+        { source: '/work/app/out', target: '/out/default' }
+      ]);
+    });
+
+    it('should fill ${profile} in base mounts with each profile name', () => {
+      const resolved = resolveProfileTemplates({
+        workspace: 'app',
+        base: { mounts: ['${workspace}/logs/${profile}:/logs'] },
+        profiles: { default: {}, ci: {} }
+      }, variables);
+      expect(resolveProfile(resolved, 'ci').mounts).toEqual(['/work/app/logs/ci:/logs']);
+      expect(resolveProfile(resolved, 'default').mounts).toEqual(['/work/app/logs/default:/logs']);
+    });
+
+    it('should name the profile of an unknown variable', () => {
+      expect(() => resolveProfileTemplates({
+        workspace: 'app',
+        profiles: { web: { mounts: ['${HOME}/.ssh:/root/.ssh'] } }
+      }, variables)).toThrow("Profile 'web' mounts: unknown template variable ${HOME}");
+    });
+  });
+
+  describe('resolveProfileEnvironments with template variables', () => {
+    const variables = { workspace: '/work/app', home: '/home/dev' };
+
+    it('should expand template variables alongside host variables', () => {
+      const resolved = resolveProfileEnvironments({
+        workspace: 'app',
+        base: { env: { LOG_DIR: '${workspace}/logs/${profile}' } },
+        profiles: { ci: { env: { CACHE: '${home}/.cache/${profile}', TOKEN: '${CI_TOKEN}' } } }
+      }, { CI_TOKEN: 'secret' }, variables);
+      expect(resolveProfile(resolved, 'ci').env).toEqual({
+        LOG_DIR: '/work/app/logs/ci',
+        CACHE: '/home/dev/.cache/ci',
+        TOKEN: 'secret'
+      });
+    });
+
+    it('should fill ${profile} in env profiles with the selected profile', () => {
+      const resolved = resolveProfileEnvironments({
+        workspace: 'app',
+        profiles: { ci: {} },
+        envProfiles: { tagged: { RUN_TAG: 'run-${profile}' } }
+      }, {}, variables);
+      expect(applyEnvProfiles(resolved, resolveProfile(resolved, 'ci'), ['tagged']).env).toEqual({ RUN_TAG: 'run-ci' });
+    });
+  });
+
+  describe('formatPortArgs', () => {
+    it('should render -p flags', () => {
+      const mappings = validatePorts(['8080:8080', '127.0.0.1:5432:5432', '3000', '53:53/udp'], 'default');
+      expect(formatPortArgs(mappings)).toEqual([
+        '-p', '8080:8080',
+        '-p', '127.0.0.1:5432:5432',
+        '-p', '3000',
+        '-p', '53:53/udp'
+      ]);
+    });
+  });
+});
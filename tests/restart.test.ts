@@ -0,0 +1,50 @@
+import { describe, it, expect } from 'bun:test';
+import { getRestartRunArgs, formatRestartedContainer, restartCommand } from '../src/commands/restart';
+import { runCommand } from '../src/commands/run';
+
+describe('restart', () => {
+  describe('getRestartRunArgs', () => {
+    it('should start the container detached and recreate it on drift', () => {
+      expect(getRestartRunArgs('/work/app', {})).toEqual(['--detach', '--auto-recreate', '--workspace', '/work/app']);
+    });
+
+    it('should pass the profile and output options on', () => {
+      expect(getRestartRunArgs('/work/app', { profile: 'test', verbose: true, quiet: true })).toEqual([
+        '--detach', '--auto-recreate', '--workspace', '/work/app', '--profile', 'test', '--verbose', '--quiet'
+      ]);
+    });
+
+    it('should only use options run accepts', () => {
+      const flags = runCommand.options.flatMap(option => [option.long, option.flags.includes('--quiet') ? '--quiet' : undefined]);
+      for (const arg of getRestartRunArgs('/work/app', { profile: 'test', verbose: true, debug: true, quiet: true })) {
+        if (arg.startsWith('--')) {
+          expect(flags).toContain(arg);
+        }
+      }
+    });
+  });
+
+  describe('formatRestartedContainer', () => {
+    const container = { id: 'abc123', name: 'app-main', image: 'node:22', status: 'Up 2 seconds', labels: {}, ports: '' };
+
+    it('should report a restarted container', () => {
+      expect(formatRestartedContainer(container, 'abc123')).toEqual([
+        'Restarted container: app-main',
+        '  ID: abc123',
+        '  Image: node:22',
+        '  Status: Up 2 seconds'
+      ]);
+    });
+
+    it('should say when the container was recreated', () => {
+      expect(formatRestartedContainer(container, 'old456')[0]).toBe('Recreated container: app-main');
+    });
+  });
+
+  it('should accept --profile, --workspace and --timeout', () => {
+    const flags = restartCommand.options.map(option => option.long);
+    expect(flags).toContain('--profile');
+    expect(flags).toContain('--workspace');
+    expect(flags).toContain('--timeout');
+  });
+});
@@ -0,0 +1,95 @@
+import { describe, it, expect, afterEach } from 'bun:test';
+import { runProfiles, formatProfileRunResults } from '../src/commands/run-profiles';
+import { setContainerRuntime, ContainerRuntime } from '../src/utils/container-runtime';
+import { DockerContainer } from '../src/utils/container-utils';
+import { createLogger } from '../src/utils/logger';
+
+describe('run with several profiles', () => {
+  describe('formatProfileRunResults', () => {
+    const container = { id: 'abc123', name: 'app-main-api', image: 'node:22', status: 'Up 2 seconds', labels: {}, ports: '' };
+
+    it('should report each profile on its own line', () => {
+      expect(formatProfileRunResults([
+        { profile: 'api', container },
+        { profile: 'db', error: 'no such image' },
+        { profile: 'cache', skipped: true }
+      ])).toEqual([
+        'api    app-main-api  abc123  Up 2 seconds',
+        'db     failed',
+        'cache  not started'
+      ]);
+    });
+
+    it('should mark containers that were stopped again', () => {
+      expect(formatProfileRunResults([{ profile: 'api', container }], ['api'])).toEqual([
+        'api  app-main-api  abc123  stopped (rolled back)'
+      ]);
+    });
+  });
+
+  describe('runProfiles', () => {
+    const config = { workspace: 'app', profiles: { api: {}, db: {}, cache: {} } };
+    const logger = createLogger({ silent: true });
+
+    // Only what runProfiles and stopContainers use; started containers show up in the listing
+    const fakeRuntime = (containers: DockerContainer[]) => ({
+      stopped: [] as string[],
+      async listContainers() {
+        return [...containers];
+      },
+      async isContainerRunning() {
+        return true;
+      },
+      async getContainerLabels() {
+        return {};
+      },
+      async stopContainer(containerId: string) {
+        this.stopped.push(containerId);
+      }
+    });
+    const start = (containers: DockerContainer[], failing: string[] = []) => async (profile: string) => {
+      if (failing.includes(profile)) {
+        throw new Error(`no image for ${profile}`);
+      }
+      const id = `${profile}-id`;
+      containers.push({
+        id, name: `app-main-${profile}`, image: 'node:22', status: 'Up 1 second', ports: '',
+        labels: { 'aisanity.workspace': '/work/app', 'aisanity.profile': profile }
+      });
+      return id;
+    };
+
+    afterEach(() => {
+      setContainerRuntime(null);
+    });
+
+    it('should start every profile once, in the order given', async () => {
+      const containers: DockerContainer[] = [];
+      setContainerRuntime(fakeRuntime(containers) as unknown as ContainerRuntime);
+
+      const { results, rolledBack } = await runProfiles(config, ['api', 'db', 'api'], '/work/app', start(containers), {}, logger);
+      expect(results.map(result => [result.profile, result.container?.id])).toEqual([['api', 'api-id'], ['db', 'db-id']]);
+      expect(rolledBack).toEqual([]);
+    });
+
+    it('should stop the containers it started when a profile fails', async () => {
+      const containers: DockerContainer[] = [];
+      const runtime = fakeRuntime(containers);
+      setContainerRuntime(runtime as unknown as ContainerRuntime);
+
+      const { results, rolledBack } = await runProfiles(config, ['api', 'db'], '/work/app', start(containers, ['db']), {}, logger);
+      expect(results[1]).toEqual({ profile: 'db', error: 'no image for db' });
+      expect(rolledBack).toEqual(['api']);
+      expect(runtime.stopped).toEqual(['api-id']);
+    });
+
+    it('should reject unknown profiles before starting any', async () => {
+      const started: string[] = [];
+      await expect(runProfiles(config, ['api', 'web'], '/work/app', async profile => {
+        started.push(profile);
+        return null;
+      }, {}, logger)).rejects.toThrow("'web'");
+      expect(started).toEqual([]);
+    });
+  });
+});
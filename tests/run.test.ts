@@ -0,0 +1,58 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as net from 'net';
+import * as os from 'os';
+import * as path from 'path';
+import { runSandbox, RunError } from '../src/commands/run';
+import { createLogger } from '../src/utils/logger';
+import { readWorkspaceState } from '../src/utils/state';
+
+describe('runSandbox', () => {
+  let tempDir: string;
+  let stateHome: string | undefined;
+  const logger = createLogger({ silent: true });
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-run-'));
+    stateHome = process.env.XDG_STATE_HOME;
+    process.env.XDG_STATE_HOME = path.join(tempDir, 'state');
+  });
+
+  afterEach(() => {
+    if (stateHome === undefined) {
+      delete process.env.XDG_STATE_HOME;
+    } else {
+      process.env.XDG_STATE_HOME = stateHome;
+    }
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should not record the emulation warning when the options are invalid', async () => {
+    const platform = process.arch === 's390x' ? 'linux/ppc64le' : 'linux/s390x';
+    const config = { workspace: 'app', profiles: { default: { platform } } };
+
+    await expect(runSandbox(config, undefined, tempDir, [], { rm: true, detach: true }, logger)).rejects.toThrow(RunError);
+    expect(readWorkspaceState(tempDir).shownWarnings).toBeUndefined();
+  });
+
+  it('should not connect to the events socket when the options are invalid', async () => {
+    const socketPath = path.join(tempDir, 'events.sock');
+    let connections = 0;
+    const server = net.createServer(connection => {
+      connections++;
+      connection.destroy();
+    });
+    await new Promise<void>(resolve => server.listen(socketPath, resolve));
+
+    try {
+      const config = { workspace: 'app' };
+      await expect(runSandbox(config, undefined, tempDir, ['make'], { detach: true, eventsSocket: socketPath }, logger)).rejects.toThrow(
+        '--detach starts the container without running a command.'
+      );
+      await new Promise(resolve => setTimeout(resolve, 50));
+      expect(connections).toBe(0);
+    } finally {
+      server.close();
+    }
+  });
+});
@@ -0,0 +1,85 @@
+import { describe, it, expect } from 'bun:test';
+import { parseContainerState, buildSandboxStatusRows, formatSandboxTable } from '../src/commands/status';
+import { DockerContainer } from '../src/utils/container-utils';
+
+describe('status --all', () => {
+  const container = (id: string, workspace: string, status: string, labels: Record<string, string> = {}): DockerContainer => ({
+    id,
+    name: `container-${id}`,
+    image: 'node:22',
+    status,
+    ports: '',
+    labels: { 'aisanity.workspace': workspace, ...labels }
+  });
+
+  describe('parseContainerState', () => {
+    it('should report running containers with their uptime', () => {
+      expect(parseContainerState('Up 2 hours')).toEqual({ state: 'running', uptime: '2 hours' });
+      expect(parseContainerState('Up 5 minutes (healthy)')).toEqual({ state: 'running', uptime: '5 minutes' });
+    });
+
+    it('should report paused containers', () => {
+      expect(parseContainerState('Up 3 days (Paused)')).toEqual({ state: 'paused', uptime: '3 days' });
+    });
+
+    it('should report stopped containers without uptime', () => {
+      expect(parseContainerState('Exited (0) 3 minutes ago')).toEqual({ state: 'exited', uptime: '-' });
+      expect(parseContainerState('Created')).toEqual({ state: 'created', uptime: '-' });
+    });
+  });
+
+  describe('buildSandboxStatusRows', () => {
+    it('should sort rows by workspace path', () => {
+      const rows = buildSandboxStatusRows([
+        container('1', '/work/zeta', 'Up 1 hour'),
+        container('2', '/work/alpha', 'Exited (0) 1 hour ago', { 'aisanity.profile': 'test' }),
+        container('3', '/work/alpha', 'Up 2 hours')
+      ]);
+
+      expect(rows.map(row => row.workspace)).toEqual(['/work/alpha', '/work/alpha', '/work/zeta']);
+      expect(rows[0].profile).toBe('default');
+      expect(rows[1].profile).toBe('test');
+      expect(rows[1].state).toBe('exited');
+    });
+
+    it('should show the resource limits label', () => {
+      const rows = buildSandboxStatusRows([
+        container('1', '/work/alpha', 'Up 1 hour', { 'aisanity.resources': 'cpus=2 memory=2g' }),
+        container('2', '/work/zeta', 'Up 1 hour')
+      ]);
+
+      expect(rows.map(row => row.resources)).toEqual(['cpus=2 memory=2g', '-']);
+    });
+
+    it('should show the restart policy label', () => {
+      const rows = buildSandboxStatusRows([
+        container('1', '/work/alpha', 'Up 1 hour', { 'aisanity.restart': 'unless-stopped' }),
+        container('2', '/work/zeta', 'Up 1 hour')
+      ]);
+
+      expect(rows.map(row => row.restart)).toEqual(['unless-stopped', '-']);
+    });
+
+    it('should skip containers without a workspace label', () => {
+      const unlabeled = { ...container('1', '', 'Up 1 hour'), labels: {} };
+      expect(buildSandboxStatusRows([unlabeled])).toEqual([]);
+    });
+  });
+
+  describe('formatSandboxTable', () => {
+    it('should keep columns aligned for long workspace paths', () => {
+      const rows = buildSandboxStatusRows([
+        container('1', '/a', 'Up 1 hour'),
+        container('2', '/very/long/path/to/some/deeply/nested/project/workspace', 'Exited (1) 2 days ago')
+      ]);
+
+      const lines = formatSandboxTable(rows).split('\n');
+      const widths = new Set(lines.map(line => line.length));
+
+      expect(widths.size).toBe(1);
+      expect(lines[1]).toContain('Workspace');
+      expect(lines[1]).toContain('Uptime');
+      expect(lines.some(line => line.includes('/very/long/path/to/some/deeply/nested/project/workspace'))).toBe(true);
+    });
+  });
+});
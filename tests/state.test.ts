@@ -0,0 +1,122 @@
+import { describe, it, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { getStateDir, readWorkspaceState, writeWorkspaceState, updateWorkspaceState, markWarningShown, WorkspaceState } from '../src/utils/state';
+
+describe('Workspace state', () => {
+  let tempDir: string;
+  let env: Record<string, string | undefined>;
+  const workspace = '/work/app';
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'aisanity-state-'));
+    env = { XDG_STATE_HOME: tempDir };
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  const lockPath = () => path.join(getStateDir(workspace, env), 'state.lock');
+  const tick = () => new Promise(resolve => setTimeout(resolve, 1));
+
+  it('should keep state per workspace', () => {
+    writeWorkspaceState(workspace, { builds: { 'app:dev': { hash: 'a', builtAt: 'now' } } }, env);
+
+    expect(readWorkspaceState(workspace, env).builds?.['app:dev'].hash).toBe('a');
+    expect(readWorkspaceState('/work/other', env)).toEqual({});
+    expect(fs.readdirSync(getStateDir(workspace, env))).toEqual(['state.json']);
+  });
+
+  it('should serialize concurrent updates', async () => {
+    // Each updater yields between reading and writing, which loses updates without the lock
+    const addBuilds = async (prefix: string) => {
+      for (let i = 0; i < 5; i++) {
+        await updateWorkspaceState(workspace, async (state: WorkspaceState) => {
+          await tick();
+          return { ...state, builds: { ...(state.builds || {}), [`${prefix}-${i}`]: { hash: prefix, builtAt: 'now' } } };
+        }, { env });
+      }
+    };
+
+    await Promise.all([addBuilds('first'), addBuilds('second')]);
+
+    expect(Object.keys(readWorkspaceState(workspace, env).builds || {})).toHaveLength(10);
+    expect(fs.existsSync(lockPath())).toBe(false);
+  });
+
+  it('should give up when another command keeps the lock', async () => {
+    let release: () => void = () => {};
+    const holder = updateWorkspaceState(workspace, state => new Promise<WorkspaceState>(resolve => {
+      release = () => resolve(state);
+    }), { env });
+    await tick();
+
+    await expect(updateWorkspaceState(workspace, state => state, { env, timeout: 100 })).rejects.toThrow(
+      `Another aisanity command is running for this workspace (pid ${process.pid}). Try again once it has finished.`
+    );
+
+    release();
+    await holder;
+  });
+
+  it('should take over locks of processes that are gone', async () => {
+    fs.mkdirSync(getStateDir(workspace, env), { recursive: true });
+    fs.writeFileSync(lockPath(), '999999999');
+
+    await updateWorkspaceState(workspace, () => ({ builds: {} }), { env, timeout: 100 });
+    expect(readWorkspaceState(workspace, env)).toEqual({ builds: {} });
+  });
+
+  it('should take over lock files without a pid once they are older than the timeout', async () => {
+    fs.mkdirSync(getStateDir(workspace, env), { recursive: true });
+    fs.writeFileSync(lockPath(), '');
+    const past = new Date(Date.now() - 60000);
+    fs.utimesSync(lockPath(), past, past);
+
+    await updateWorkspaceState(workspace, () => ({ builds: {} }), { env, timeout: 100 });
+    expect(readWorkspaceState(workspace, env)).toEqual({ builds: {} });
+    expect(fs.readdirSync(getStateDir(workspace, env))).toEqual(['state.json']);
+  });
+
+  it('should wait for the timeout before taking over a fresh lock file without a pid', async () => {
+    fs.mkdirSync(getStateDir(workspace, env), { recursive: true });
+    fs.writeFileSync(lockPath(), '');
+
+    const updating = updateWorkspaceState(workspace, () => ({ builds: {} }), { env, timeout: 200 });
+    await new Promise(resolve => setTimeout(resolve, 100));
+    expect(fs.readFileSync(lockPath(), 'utf8')).toBe('');
+
+    await updating;
+    expect(readWorkspaceState(workspace, env)).toEqual({ builds: {} });
+  });
+
+  it('should let only one command take over a stale lock', async () => {
+    fs.mkdirSync(getStateDir(workspace, env), { recursive: true });
+    fs.writeFileSync(lockPath(), '999999999');
+
+    await Promise.all([1, 2, 3].map(i => updateWorkspaceState(workspace, async (state: WorkspaceState) => {
+      await tick();
+      return { ...state, builds: { ...(state.builds || {}), [`build-${i}`]: { hash: String(i), builtAt: 'now' } } };
+    }, { env, timeout: 1000 })));
+
+    expect(Object.keys(readWorkspaceState(workspace, env).builds || {})).toHaveLength(3);
+    expect(fs.readdirSync(getStateDir(workspace, env))).toEqual(['state.json']);
+  });
+
+  it('should release the lock when the update fails', async () => {
+    await expect(updateWorkspaceState(workspace, () => {
+      throw new Error('boom');
+    }, { env })).rejects.toThrow('boom');
+
+    expect(fs.existsSync(lockPath())).toBe(false);
+  });
+
+  it('should report a one-time warning only the first time', async () => {
+    expect(await markWarningShown(workspace, 'emulation:linux/amd64', { env })).toBe(true);
+    expect(await markWarningShown(workspace, 'emulation:linux/amd64', { env })).toBe(false);
+    expect(await markWarningShown(workspace, 'emulation:linux/arm64', { env })).toBe(true);
+    expect(readWorkspaceState(workspace, env).shownWarnings).toEqual(['emulation:linux/amd64', 'emulation:linux/arm64']);
+  });
+});
@@ -3,6 +3,7 @@ import * as fs from 'fs';
 
 // Import the function to test
 import { generateStats } from '../src/commands/stats';
+import { configureDisplay, getDisplaySettings } from '../src/utils/display';
 
 // Helper function to mock empty directory
 function mockEmptyDirectory() {
@@ -160,5 +161,21 @@ describe('stats command', () => {
         mockProcessExit.mockRestore();
       }
     });
+
+    test('should not print progress messages with --quiet', async () => {
+      const { mockExistsSync, mockReaddirSync } = mockEmptyDirectory();
+      const previousDisplay = getDisplaySettings();
+      // addDisplayOptions applies --quiet this way before the action runs
+      configureDisplay({ ...previousDisplay, quiet: true });
+
+      try {
+        await generateStats({ days: '30' });
+        expect(console.log).not.toHaveBeenCalledWith('No OpenCode message files found');
+      } finally {
+        configureDisplay(previousDisplay);
+        mockExistsSync.mockRestore();
+        mockReaddirSync.mockRestore();
+      }
+    });
   });
-});
\ No newline at end of file
+});
@@ -245,10 +245,10 @@ describe('aisanity status - backward compatibility', () => {
   });
 
   it('should maintain CLI interface compatibility', async () => {
-    // Test that the status command has expected options (updated for debug flag)
+    // Test that the status command has expected options (updated for debug, --all, --workspace and --output flags)
     const { statusCommand } = await import('../src/commands/status');
     
-    expect(statusCommand.options).toHaveLength(3);
+    expect(statusCommand.options).toHaveLength(6);
     
     const worktreeOption = statusCommand.options.find(opt => opt.flags === '--worktree <path>');
     expect(worktreeOption).toBeDefined();
@@ -261,5 +261,14 @@ describe('aisanity status - backward compatibility', () => {
     const debugOption = statusCommand.options.find(opt => opt.flags === '-d, --debug');
     expect(debugOption).toBeDefined();
     expect(debugOption?.description).toBeDefined();
+    
+    const allOption = statusCommand.options.find(opt => opt.flags === '--all');
+    expect(allOption).toBeDefined();
+
+    const outputOption = statusCommand.options.find(opt => opt.flags === '--output <format>');
+    expect(outputOption).toBeDefined();
+
+    const workspaceOption = statusCommand.options.find(opt => opt.flags === '--workspace <path>');
+    expect(workspaceOption).toBeDefined();
   });
 });
\ No newline at end of file
@@ -0,0 +1,49 @@
+import { describe, it, expect } from 'bun:test';
+import { resolveStopTimeout, getContainerStopTimeout } from '../src/commands/stop';
+import { DEFAULT_STOP_TIMEOUT, ContainerAlreadyStoppedError, LABEL_STOP_TIMEOUT } from '../src/utils/container-utils';
+
+describe('stop timeout', () => {
+  describe('resolveStopTimeout', () => {
+    it('should default to 10 seconds', () => {
+      expect(DEFAULT_STOP_TIMEOUT).toBe(10);
+      expect(resolveStopTimeout(undefined, undefined)).toBe(DEFAULT_STOP_TIMEOUT);
+    });
+
+    it('should use stopTimeout from config', () => {
+      expect(resolveStopTimeout(undefined, 30)).toBe(30);
+    });
+
+    it('should let --timeout override the config', () => {
+      expect(resolveStopTimeout('5', 30)).toBe(5);
+      expect(resolveStopTimeout('0', 30)).toBe(0);
+    });
+
+    it('should reject invalid --timeout values', () => {
+      expect(() => resolveStopTimeout('soon', undefined)).toThrow('Invalid --timeout value');
+      expect(() => resolveStopTimeout('-1', undefined)).toThrow('Invalid --timeout value');
+    });
+  });
+
+  describe('getContainerStopTimeout', () => {
+    it('should use the stopTimeout the container was created with', () => {
+      expect(getContainerStopTimeout({ [LABEL_STOP_TIMEOUT]: '30' })).toBe(30);
+      expect(getContainerStopTimeout({ [LABEL_STOP_TIMEOUT]: '0' })).toBe(0);
+    });
+
+    it('should default for containers without a valid label', () => {
+      expect(getContainerStopTimeout({})).toBe(DEFAULT_STOP_TIMEOUT);
+      expect(getContainerStopTimeout({ [LABEL_STOP_TIMEOUT]: 'soon' })).toBe(DEFAULT_STOP_TIMEOUT);
+    });
+  });
+
+  describe('ContainerAlreadyStoppedError', () => {
+    it('should be distinguishable from other errors', () => {
+      const error = new ContainerAlreadyStoppedError('abc123');
+
+      expect(error).toBeInstanceOf(ContainerAlreadyStoppedError);
+      expect(error.name).toBe('ContainerAlreadyStoppedError');
+      expect(error.containerId).toBe('abc123');
+      expect(error.message).toBe('Container abc123 is already stopped');
+    });
+  });
+});